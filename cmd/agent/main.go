@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/Maxim-Ba/metriccollector/internal/agent/client"
 	"github.com/Maxim-Ba/metriccollector/internal/agent/config"
+	"github.com/Maxim-Ba/metriccollector/internal/agent/exporter"
 	metricGenerator "github.com/Maxim-Ba/metriccollector/internal/agent/generator"
 	"github.com/Maxim-Ba/metriccollector/internal/logger"
 	"github.com/Maxim-Ba/metriccollector/internal/signature"
@@ -36,9 +38,24 @@ func main() {
 	logger.SetLogLevel(parameters.LogLevel)
 	signature.New(parameters.Key, parameters.CryptoKeyPath)
 	httpClient := client.NewClient(parameters.Address)
+	httpClient.SetToken(parameters.Token)
 	reportIntervalStart := time.Now()
 
 	var wg sync.WaitGroup
+	var exposed *exporter.Exporter
+	var exposeServer *http.Server
+	if parameters.ExposeAddress != "" {
+		exposed = exporter.New()
+		exposeServer = &http.Server{Addr: parameters.ExposeAddress, Handler: exposed.Handler()}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.LogInfo("Serving metrics on ", parameters.ExposeAddress)
+			if err := exposeServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.LogError("ListenAndServe: ", err)
+			}
+		}()
+	}
 	wg.Add(1)
 
 	go func() {
@@ -54,19 +71,25 @@ func main() {
 					logger.LogError(err)
 					panic("Can not collect metrics")
 				}
+				if exposed != nil {
+					exposed.Set(metrics)
+				}
 				if time.Since(reportIntervalStart) >= time.Duration(parameters.ReportInterval)*time.Second {
 					metricGenerator.Generator.UpdatePollCount()
-					err = utils.RetryWrapper(func() error {
-						return httpClient.SendMetrics(metrics)
-					}, []error{client.ErrServerInternalError, client.ErrRequestTimeout})
-					if err != nil {
-						logger.LogError(err)
-					}
-					err = utils.RetryWrapper(func() error {
-						return httpClient.SendMetricsWithBatch(metrics)
-					}, []error{client.ErrServerInternalError, client.ErrRequestTimeout})
-					if err != nil {
-						logger.LogError(err)
+					// без адреса сервера агент только отдаёт метрики по -expose
+					if parameters.Address != "" {
+						err = utils.RetryWrapper(func() error {
+							return httpClient.SendMetrics(metrics)
+						}, []error{client.ErrServerInternalError, client.ErrRequestTimeout, client.ErrTooManyRequests})
+						if err != nil {
+							logger.LogError(err)
+						}
+						err = utils.RetryWrapper(func() error {
+							return httpClient.SendMetricsWithBatch(metrics)
+						}, []error{client.ErrServerInternalError, client.ErrRequestTimeout, client.ErrTooManyRequests})
+						if err != nil {
+							logger.LogError(err)
+						}
 					}
 
 					reportIntervalStart = time.Now()
@@ -78,6 +101,13 @@ func main() {
 
 	<-exit // Ожидание сигнала завершения
 	cancel()
+	if exposeServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := exposeServer.Shutdown(shutdownCtx); err != nil {
+			logger.LogError("Server Shutdown: ", err)
+		}
+		shutdownCancel()
+	}
 	wg.Wait()
 
 	logger.LogInfo("Shutting down agent...")
@@ -5,15 +5,29 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	_ "net/http/pprof"
 
+	"github.com/Maxim-Ba/metriccollector/internal/agent/client"
 	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/server/auth"
 	"github.com/Maxim-Ba/metriccollector/internal/server/config"
+	"github.com/Maxim-Ba/metriccollector/internal/server/handlers"
+	"github.com/Maxim-Ba/metriccollector/internal/server/handlers/middleware"
 	"github.com/Maxim-Ba/metriccollector/internal/server/router"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/anomaly"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/cluster"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/follower"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/relay"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/rules"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/scraper"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/silence"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/stream"
 	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
 	"github.com/Maxim-Ba/metriccollector/internal/signature"
 	"github.com/Maxim-Ba/metriccollector/pkg/buildinfo"
@@ -42,11 +56,102 @@ func main() {
 	p.Start()
 	signature.New(parameters.Key, parameters.CryptoKeyPath)
 	logger.SetLogLevel(parameters.LogLevel)
+	_, err = middleware.NewTrustedSubnet(parameters.TrustedSubnet, parameters.TrustedSubnetRead, parameters.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	middleware.NewRateLimiter(parameters.RateLimitRead, parameters.RateLimitWrite, parameters.RateLimitBurst, parameters.RateLimitKey)
+	middleware.NewBodyLimit(parameters.MaxBodySize, parameters.MaxDecompressedSize, parameters.MaxRestoreSize)
+	err = handlers.SetBatchOptions(parameters.BatchMode, parameters.BatchChunkSize)
+	if err != nil {
+		panic(err)
+	}
+	handlers.SetRateWindow(parameters.RateWindowSecond)
 
 	_, err = storage.New(parameters)
 	if err != nil {
 		panic(err)
 	}
+	_, err = auth.New(parameters.AuthEnabled, parameters.TokensPath, parameters.AdminToken, storage.DB())
+	if err != nil {
+		panic(err)
+	}
+	_, err = silence.New(parameters.SilencesPath, storage.DB())
+	if err != nil {
+		panic(err)
+	}
+	stream.Instance = stream.New(parameters.StreamBufferSize)
+	anomaly.Instance = anomaly.New(parameters.AnomalyDetection, parameters.AnomalyZScore)
+	anomaly.Instance.OnAnomaly(anomaly.Unsilenced(silence.Instance, anomaly.LogEvent))
+	if parameters.RulesPath != "" {
+		rules.Instance, err = rules.Load(parameters.RulesPath)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if parameters.Leader == "" {
+		replication.Instance = replication.New(parameters.ReplicationLogSize)
+		_, err = middleware.NewLeader(nil, parameters.LeaderWrites)
+		if err != nil {
+			panic(err)
+		}
+		go rules.Instance.Run(ctx, storage.StorageInstance, time.Duration(parameters.RulesIntervalSecond)*time.Second)
+	} else {
+		// фолловер только применяет изменения лидера, включая результаты правил
+		follower.Instance, err = follower.New(parameters.Leader, parameters.LeaderToken, storage.StorageInstance)
+		if err != nil {
+			panic(err)
+		}
+		_, err = middleware.NewLeader(follower.Instance.Leader(), parameters.LeaderWrites)
+		if err != nil {
+			panic(err)
+		}
+		replication.Instance = replication.New(0)
+		go follower.Instance.Run(ctx)
+	}
+	if parameters.ClusterNodes != "" {
+		self := parameters.ClusterSelf
+		if self == "" {
+			self = parameters.Address
+		}
+		cluster.Instance, err = cluster.New(strings.Split(parameters.ClusterNodes, ","), self, parameters.ClusterSecret)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if parameters.Upstream != "" {
+		var queue *relay.Queue
+		queue, err = relay.OpenQueue(parameters.RelayQueuePath, relay.DefaultQueueLimit)
+		if err != nil {
+			panic(err)
+		}
+		// пересылка использует клиент агента: gzip, подпись и шифрование те же
+		upstream := client.NewClient(parameters.Upstream)
+		upstream.SetToken(parameters.UpstreamToken)
+		relay.Instance = relay.New(parameters.Upstream, upstream, queue, time.Duration(parameters.RelayIntervalSecond)*time.Second)
+		go relay.Instance.Run(ctx)
+	}
+	if parameters.FederateTargets != "" || parameters.ScrapeAgents != "" {
+		scraper.Instance = scraper.New()
+		var targets []scraper.Target
+		if parameters.FederateTargets != "" {
+			targets, err = scraper.ParseTargets(parameters.FederateTargets)
+			if err != nil {
+				panic(err)
+			}
+			federation := scraper.NewFederation(parameters.FederateToken, storage.StorageInstance)
+			scraper.Instance.Add(scraper.KindFederate, federation, targets, time.Duration(parameters.FederateIntervalSecond)*time.Second)
+		}
+		if parameters.ScrapeAgents != "" {
+			targets, err = scraper.ParseTargets(parameters.ScrapeAgents)
+			if err != nil {
+				panic(err)
+			}
+			agents := scraper.NewAgents(storage.StorageInstance)
+			scraper.Instance.Add(scraper.KindAgent, agents, targets, time.Duration(parameters.ScrapeIntervalSecond)*time.Second)
+		}
+		go scraper.Instance.Run(ctx)
+	}
 	mux := router.New()
 	server := &http.Server{
 		Addr:    parameters.Address,
@@ -74,6 +179,8 @@ func main() {
 	}
 
 	logger.LogInfo("Shutting down server...")
+	stream.Instance.Close()
+	replication.Instance.Close()
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
@@ -84,6 +191,12 @@ func main() {
 			logger.LogError("Server forced close error: ", err)
 		}
 	}
+	if relay.Instance != nil {
+		// накопленное после последнего интервала уйдёт после перезапуска
+		if err = relay.Instance.Flush(); err != nil {
+			logger.LogError("relay: ", err)
+		}
+	}
 	wg.Wait()
 	err = p.Close()
 	logger.LogError(err)
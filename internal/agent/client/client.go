@@ -6,7 +6,12 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Maxim-Ba/metriccollector/internal/logger"
@@ -20,6 +25,9 @@ type Client interface {
 
 type HTTPClient struct {
 	httpClient *http.Client
+	realIP     string
+	agentID    string
+	token      string
 }
 
 var address string
@@ -33,6 +41,11 @@ func NewClient(initAddress string) *HTTPClient {
 	}
 }
 
+// SetToken sets the API token sent as a bearer token with every request.
+func (c *HTTPClient) SetToken(token string) {
+	c.token = token
+}
+
 func (c *HTTPClient) SendMetrics(metrics []*metrics.Metrics) error {
 	logger.LogInfo("send request")
 
@@ -79,6 +92,9 @@ func (c *HTTPClient) SendMetrics(metrics []*metrics.Metrics) error {
 			logger.LogInfo("encodedHash ", encodedHash)
 			req.Header.Set("HashSHA256", encodedHash)
 		}
+		c.setRealIP(req)
+		c.setAgentID(req)
+		c.setToken(req)
 		req.Header.Set("Accept-Encoding", "gzip")
 		req.Header.Set("Content-Encoding", "gzip")
 		req.Header.Set("Content-Type", "application/json")
@@ -94,6 +110,9 @@ func (c *HTTPClient) SendMetrics(metrics []*metrics.Metrics) error {
 		if resp.StatusCode == http.StatusRequestTimeout {
 			return ErrRequestTimeout
 		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return tooManyRequests(resp)
+		}
 		err = resp.Body.Close()
 		if err != nil {
 			logger.LogError(err)
@@ -148,9 +167,14 @@ func (c *HTTPClient) SendMetricsWithBatch(metrics []*metrics.Metrics) error {
 		encodedHash := base64.StdEncoding.EncodeToString(hash)
 		req.Header.Set("HashSHA256", encodedHash)
 	}
+	c.setRealIP(req)
+	c.setAgentID(req)
+	c.setToken(req)
 	req.Header.Set("Accept-Encoding", "gzip")
 	req.Header.Set("Content-Encoding", "gzip")
 	req.Header.Set("Content-Type", "application/json")
+	// применить валидные метрики и вернуть отчёт по отклонённым
+	req.Header.Set("Prefer", "handling=lenient")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -163,6 +187,21 @@ func (c *HTTPClient) SendMetricsWithBatch(metrics []*metrics.Metrics) error {
 	if resp.StatusCode == http.StatusRequestTimeout {
 		return ErrRequestTimeout
 	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return tooManyRequests(resp)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return unexpectedStatus(resp)
+	}
+	report, err := readBatchReport(resp)
+	if err != nil {
+		logger.LogError(err)
+	}
+	if report != nil {
+		for _, rejected := range report.Rejected {
+			logger.LogError("metric rejected by server", rejected.Index, rejected.ID, rejected.Reason)
+		}
+	}
 	err = resp.Body.Close()
 	if err != nil {
 		logger.LogError(err)
@@ -171,3 +210,121 @@ func (c *HTTPClient) SendMetricsWithBatch(metrics []*metrics.Metrics) error {
 
 	return nil
 }
+
+// readBatchReport parses the report returned for a lenient batch update.
+// Returns nil if the server answered without a report, e.g. an older
+// server that does not support lenient handling.
+func readBatchReport(resp *http.Response) (*metrics.BatchReport, error) {
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return nil, nil
+	}
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err = gz.Close(); err != nil {
+				logger.LogError(err)
+			}
+		}()
+		body = gz
+	}
+	var report metrics.BatchReport
+	if err := json.NewDecoder(body).Decode(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (c *HTTPClient) setToken(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+// setAgentID identifies the agent by host name,
+// the server may use it as the rate limiting key.
+func (c *HTTPClient) setAgentID(req *http.Request) {
+	if c.agentID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			logger.LogError(err)
+			return
+		}
+		c.agentID = hostname
+	}
+	req.Header.Set("X-Agent-ID", c.agentID)
+}
+
+// tooManyRequests builds the error for a rate limited response
+// with the delay requested by the server.
+func tooManyRequests(resp *http.Response) error {
+	if err := resp.Body.Close(); err != nil {
+		logger.LogError(err)
+	}
+	return &TooManyRequestsError{Delay: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+}
+
+// unexpectedStatus builds the error for a batch the server did not accept.
+func unexpectedStatus(resp *http.Response) error {
+	if err := resp.Body.Close(); err != nil {
+		logger.LogError(err)
+	}
+	return fmt.Errorf("%w: %s", ErrUnexpectedStatus, resp.Status)
+}
+
+// parseRetryAfter reads a Retry-After value given either in seconds
+// or as an HTTP date. Returns 0 if the value is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// setRealIP sets the X-Real-IP header to the address of the interface
+// used to reach the server, so the server can check it against trusted subnets.
+func (c *HTTPClient) setRealIP(req *http.Request) {
+	if c.realIP == "" {
+		ip, err := outboundIP(req.URL.Host)
+		if err != nil {
+			logger.LogError(err)
+			return
+		}
+		c.realIP = ip.String()
+	}
+	req.Header.Set("X-Real-IP", c.realIP)
+}
+
+// outboundIP returns the local address the system would use to reach host.
+// UDP "connect" only selects a route, no packets are sent.
+func outboundIP(host string) (net.IP, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "80")
+	}
+	conn, err := net.Dial("udp", host)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err = conn.Close(); err != nil {
+			logger.LogError(err)
+		}
+	}()
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, ErrNoOutboundAddress
+	}
+	return addr.IP, nil
+}
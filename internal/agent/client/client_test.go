@@ -53,6 +53,19 @@ func TestHTTPClient_SendMetrics(t *testing.T) {
 				"Content-Encoding": "gzip",
 			},
 		},
+		{
+			name: "Valid X-Real-IP",
+			client: &HTTPClient{
+				httpClient: &http.Client{},
+			},
+			args: args{
+				metrics: []*metrics.Metrics{{}},
+			},
+			wantErr: false,
+			wantHeaders: map[string]string{
+				"X-Real-IP": "127.0.0.1",
+			},
+		},
 		{
 			name: "Valid Accept-Encoding",
 			client: &HTTPClient{
@@ -213,3 +226,107 @@ func TestSendMetricsWithBatch_ErrorCases(t *testing.T) {
 		})
 	}
 }
+
+func TestSendMetricsWithBatch_TooManyRequests(t *testing.T) {
+	originalInstance := signature.Instance
+	defer func() {
+		signature.Instance = originalInstance
+	}()
+	signature.New("", "")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.Header.Get("X-Agent-ID"))
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL[7:])
+	err := client.SendMetricsWithBatch([]*metrics.Metrics{})
+	assert.ErrorIs(t, err, ErrTooManyRequests)
+
+	var tooMany *TooManyRequestsError
+	if assert.ErrorAs(t, err, &tooMany) {
+		assert.Equal(t, 3*time.Second, tooMany.RetryAfter())
+	}
+}
+
+func TestSendMetricsWithBatch_UnexpectedStatus(t *testing.T) {
+	originalInstance := signature.Instance
+	defer func() {
+		signature.Instance = originalInstance
+	}()
+	signature.New("", "")
+
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusBadGateway} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+			}))
+			defer ts.Close()
+
+			client := NewClient(ts.URL[7:])
+			err := client.SendMetricsWithBatch([]*metrics.Metrics{})
+			assert.ErrorIs(t, err, ErrUnexpectedStatus)
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "Empty", value: "", want: 0},
+		{name: "Seconds", value: "5", want: 5 * time.Second},
+		{name: "Negative seconds", value: "-1", want: 0},
+		{name: "HTTP date", value: now.Add(10 * time.Second).Format(http.TimeFormat), want: 10 * time.Second},
+		{name: "Date in the past", value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{name: "Garbage", value: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseRetryAfter(tt.value, now))
+		})
+	}
+}
+
+func TestSendMetricsWithBatch_Report(t *testing.T) {
+	originalInstance := signature.Instance
+	defer func() {
+		signature.Instance = originalInstance
+	}()
+	signature.New("", "")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			assert.Equal(t, "handling=lenient", r.Header.Get("Prefer"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		err := json.NewEncoder(gz).Encode(metrics.BatchReport{
+			Accepted: 1,
+			Rejected: []metrics.RejectedMetric{{Index: 1, ID: "bad", Reason: "not allowed metric type"}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+	}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	report, err := readBatchReport(resp)
+	require.NoError(t, err)
+	require.NotNil(t, report)
+	assert.Equal(t, 1, report.Accepted)
+	assert.Equal(t, "bad", report.Rejected[0].ID)
+
+	client := NewClient(ts.URL[7:])
+	assert.NoError(t, client.SendMetricsWithBatch([]*metrics.Metrics{}))
+}
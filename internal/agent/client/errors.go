@@ -1,6 +1,31 @@
 package client
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var ErrServerInternalError = errors.New("server internal error")
 var ErrRequestTimeout = errors.New("request timeout")
+var ErrNoOutboundAddress = errors.New("can not resolve outbound address")
+var ErrTooManyRequests = errors.New("too many requests")
+var ErrUnexpectedStatus = errors.New("unexpected response status")
+
+// TooManyRequestsError is returned when the server rate limits the agent.
+// It matches ErrTooManyRequests and carries the delay from the Retry-After header,
+// so utils.RetryWrapper waits as long as the server asked.
+type TooManyRequestsError struct {
+	Delay time.Duration
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return ErrTooManyRequests.Error()
+}
+
+func (e *TooManyRequestsError) Is(target error) bool {
+	return target == ErrTooManyRequests
+}
+
+func (e *TooManyRequestsError) RetryAfter() time.Duration {
+	return e.Delay
+}
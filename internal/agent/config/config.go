@@ -17,6 +17,8 @@ type Parameters struct {
 	Key            string `json:"key"`
 	RateLimit      int    `json:"rate_limit"`
 	CryptoKeyPath  string `json:"crypto_key"`
+	Token          string `json:"token"`
+	ExposeAddress  string `json:"expose_address"`
 }
 
 func New() Parameters {
@@ -36,6 +38,8 @@ func New() Parameters {
 		Key:            utils.ResolveString(envConfig.Key, flags.Key, fileConfig.Key),
 
 		CryptoKeyPath: utils.ResolveString(envConfig.CryptoKeyPath, flags.CryptoKeyPath, fileConfig.CryptoKeyPath),
+		Token:         utils.ResolveString(envConfig.Token, flags.Token, fileConfig.Token),
+		ExposeAddress: utils.ResolveString(envConfig.ExposeAddress, flags.ExposeAddress, fileConfig.ExposeAddress),
 	}
 	return parameters
 }
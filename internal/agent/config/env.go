@@ -15,6 +15,8 @@ type Config struct {
 	RateLimit      int    `env:"RATE_LIMIT"`
 	CryptoKeyPath  string `env:"CRYPTO_KEY"`
 	ConfigPath     string `env:"CONFIG"`
+	Token          string `env:"TOKEN"`
+	ExposeAddress  string `env:"EXPOSE_ADDRESS"`
 }
 
 func ParseEnv() *Config {
@@ -18,6 +18,9 @@ type ParsedFlags struct {
 	RateLimit     utils.FlagValue[int]
 	CryptoKeyPath utils.FlagValue[string]
 	ConfigPath    utils.FlagValue[string]
+	Token         utils.FlagValue[string]
+	// адрес, на котором агент отдаёт метрики серверу, опрашивающему агентов
+	ExposeAddress utils.FlagValue[string]
 }
 
 func ParseFlags() *ParsedFlags {
@@ -31,6 +34,8 @@ func ParseFlags() *ParsedFlags {
 	flag.IntVar(&flags.RateLimit.Value, "l", 10, "simultaneously get metrics")
 	flag.StringVar(&flags.CryptoKeyPath.Value, "crypto-key", "", "path for public key for signature")
 	flag.StringVar(&flags.ConfigPath.Value, "c", "", "path for configuration by json")
+	flag.StringVar(&flags.Token.Value, "token", "", "API token with write scope")
+	flag.StringVar(&flags.ExposeAddress.Value, "expose", "", "address to serve the collected metrics at for a server scraping agents, e.g. :9100; pass -a \"\" to stop pushing")
 
 	flag.Parse()
 
@@ -68,6 +73,10 @@ func ParseFlags() *ParsedFlags {
 			flags.CryptoKeyPath.Passed = true
 		case "c":
 			flags.ConfigPath.Passed = true
+		case "token":
+			flags.Token.Passed = true
+		case "expose":
+			flags.ExposeAddress.Passed = true
 		}
 	})
 	return flags
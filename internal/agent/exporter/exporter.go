@@ -0,0 +1,83 @@
+// Package exporter serves the metrics collected by the agent over HTTP,
+// so a server that the host can not reach pulls them instead of the agent
+// pushing them.
+package exporter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/signature"
+)
+
+// Path is the path the metrics are served at.
+const Path = "/metrics"
+
+// StartedHeader carries the time the exporter was created in RFC 3339,
+// a scraper seeing it change knows the agent restarted and its counter
+// totals started from zero.
+const StartedHeader = "X-Agent-Started"
+
+// Exporter keeps the metrics of the last poll.
+type Exporter struct {
+	mu      sync.RWMutex
+	metrics []*metrics.Metrics
+	started string
+}
+
+// New creates an exporter serving an empty list until the first Set.
+func New() *Exporter {
+	return &Exporter{started: time.Now().UTC().Format(time.RFC3339Nano)}
+}
+
+// Set replaces the served metrics with the result of a poll,
+// see generator.Generate.
+func (e *Exporter) Set(ms []*metrics.Metrics) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.metrics = ms
+}
+
+// Handler returns the handler of GET /metrics. The body is a JSON array
+// of metrics, the same as the body of POST /updates/ sent by the agent;
+// PollCount is the total since the agent started, the start time is sent
+// in the StartedHeader. With a signing key the body is signed in the
+// HashSHA256 header.
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+Path, func(res http.ResponseWriter, req *http.Request) {
+		e.mu.RLock()
+		ms := e.metrics
+		e.mu.RUnlock()
+		if ms == nil {
+			ms = []*metrics.Metrics{}
+		}
+		body, err := json.Marshal(ms)
+		if err != nil {
+			logger.LogError(err)
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if signature.Instance != nil && signature.Instance.GetKey() != "" {
+			hash, err := signature.Instance.Get(body)
+			if err != nil {
+				logger.LogError(err)
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res.Header().Set("HashSHA256", base64.StdEncoding.EncodeToString(hash))
+		}
+		res.Header().Set("Content-Type", "application/json")
+		res.Header().Set(StartedHeader, e.started)
+		res.WriteHeader(http.StatusOK)
+		if _, err = res.Write(body); err != nil {
+			logger.LogError(err)
+		}
+	})
+	return mux
+}
@@ -0,0 +1,55 @@
+package exporter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/signature"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestHandler(t *testing.T) {
+	original := signature.Instance
+	defer func() {
+		signature.Instance = original
+	}()
+	signature.New("", "")
+	e := New()
+	h := e.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("HashSHA256"))
+
+	polled := []*metrics.Metrics{
+		{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)},
+		{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(3)},
+	}
+	e.Set(polled)
+	signature.New("secret", "")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, e.started, rec.Header().Get(StartedHeader))
+	var served []*metrics.Metrics
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &served))
+	assert.Equal(t, polled, served)
+	hash, err := base64.StdEncoding.DecodeString(rec.Header().Get("HashSHA256"))
+	require.NoError(t, err)
+	assert.NoError(t, signature.Instance.Check(hash, rec.Body.Bytes()))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Path, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -16,11 +16,13 @@ type MetricDTO struct {
 //   - MType: Metric type, either "gauge" or "counter"
 //   - Delta: Pointer to integer value for counter metrics (optional)
 //   - Value: Pointer to float value for gauge metrics (optional)
+//   - Labels: Arbitrary key-value pairs describing the metric (optional)
 type Metrics struct {
-	ID    string   `json:"id"`              // имя метрики
-	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+	ID     string            `json:"id"`               // имя метрики
+	MType  string            `json:"type"`             // параметр, принимающий значение gauge или counter
+	Delta  *int64            `json:"delta,omitempty"`  // значение метрики в случае передачи counter
+	Value  *float64          `json:"value,omitempty"`  // значение метрики в случае передачи gauge
+	Labels map[string]string `json:"labels,omitempty"` // метки, заменяют сохранённые если переданы
 }
 
 // MetricDTOParams contains parameters for metric lookup operations.
@@ -30,6 +32,23 @@ type MetricDTOParams struct {
 	MetricType  string
 }
 
+// BatchReport is the response to a batch update applied in lenient mode.
+// Fields:
+//   - Accepted: number of metrics saved
+//   - Rejected: metrics skipped because they were invalid
+type BatchReport struct {
+	Accepted int              `json:"accepted"`
+	Rejected []RejectedMetric `json:"rejected"`
+}
+
+// RejectedMetric describes a metric skipped in a batch update.
+// Index is the position of the metric in the request array.
+type RejectedMetric struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
 // GaugeMetrics contains all supported gauge metric names.
 // These represent runtime metrics that can increase or decrease.
 var GaugeMetrics = []string{
@@ -0,0 +1,57 @@
+package silences
+
+import "time"
+
+// Matcher selects alerts by the metric name or a label.
+// Fields:
+//   - Name: label name, "__name__" for the metric name
+//   - Op: "=", "!=", "=~" or "!~"
+//   - Value: value or regular expression matching the whole label value
+type Matcher struct {
+	Name  string `json:"name"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// Silence suppresses notifications of the alerts matching all of its
+// matchers between StartsAt and EndsAt, e.g. during a deploy.
+// Fields:
+//   - ID: Public identifier used to manage the silence
+//   - Matchers: Matchers that must all match the alert
+//   - StartsAt: Start of the silence
+//   - EndsAt: End of the silence, the silence is inactive from this moment
+//   - CreatedBy: Author of the silence (e.g., "deploy-bot")
+//   - Comment: Reason for the silence
+//   - CreatedAt: Time the silence was created
+type Silence struct {
+	ID        string    `json:"id"`
+	Matchers  []Matcher `json:"matchers"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Active reports whether the silence is in effect at t.
+func (s *Silence) Active(t time.Time) bool {
+	return !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
+
+// Silence states returned by Status.
+const (
+	StatusPending = "pending"
+	StatusActive  = "active"
+	StatusExpired = "expired"
+)
+
+// Status returns the state of the silence at t.
+func (s *Silence) Status(t time.Time) string {
+	switch {
+	case t.Before(s.StartsAt):
+		return StatusPending
+	case t.Before(s.EndsAt):
+		return StatusActive
+	}
+	return StatusExpired
+}
@@ -0,0 +1,20 @@
+package tokens
+
+import "time"
+
+// Token represents an API key used for bearer authentication.
+// Only the SHA-256 hash of the secret is kept, the secret itself
+// is shown once when the token is created.
+// Fields:
+//   - ID: Public identifier used to manage the token
+//   - Name: Human readable description (e.g., "grafana")
+//   - Hash: Hex encoded SHA-256 hash of the secret
+//   - Scopes: Granted scopes: "read", "write" or "admin"
+//   - CreatedAt: Time the token was issued
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash,omitempty"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
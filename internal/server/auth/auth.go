@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/tokens"
+)
+
+// Scopes that can be granted to a token.
+// The admin scope implies every other scope.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var knownScopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// bootstrapID identifies the admin token taken from the configuration.
+const bootstrapID = "bootstrap"
+
+// Authenticator keeps the issued API tokens indexed by secret hash
+// and persists changes to the configured token store.
+type Authenticator struct {
+	mu      sync.RWMutex
+	enabled bool
+	tokens  map[string]*tokens.Token
+	store   tokenStore
+}
+
+// Instance is the global authenticator used by the auth middleware
+// and the token management handlers. It is disabled by default.
+var Instance = &Authenticator{
+	tokens: map[string]*tokens.Token{},
+	store:  fileStore{},
+}
+
+// New initializes the token authentication and installs it as Instance.
+// Tokens are kept in the database when a DSN is configured and db is its
+// connection, otherwise in the JSON file at path.
+// Stored tokens are loaded even when enabled is false: admin routes always
+// require an admin token, see middleware.AuthHandle, and the token
+// management routes rewrite the whole store.
+// Parameters:
+//   - enabled: whether requests must carry a bearer token
+//   - path: tokens file used without database
+//   - adminToken: optional bootstrap secret granted the admin scope, it is never persisted
+//   - db: connection of the configured DSN, see storage.DB, or nil
+//
+// Returns:
+//   - *Authenticator: Initialized authenticator
+//   - error: if stored tokens can not be loaded
+func New(enabled bool, path string, adminToken string, db *sql.DB) (*Authenticator, error) {
+	a := &Authenticator{
+		enabled: enabled,
+		tokens:  map[string]*tokens.Token{},
+	}
+	if db != nil {
+		a.store = dbStore{db: db}
+	} else {
+		a.store = fileStore{path: path}
+	}
+	// токены загружаются и без аутентификации: управление токенами
+	// перезаписывает хранилище целиком
+	stored, err := a.store.Load()
+	if err != nil {
+		logger.LogError(err)
+		return nil, err
+	}
+	for _, t := range stored {
+		a.tokens[t.Hash] = t
+	}
+	if adminToken != "" {
+		t := &tokens.Token{
+			ID:        bootstrapID,
+			Name:      "bootstrap admin token",
+			Hash:      Hash(adminToken),
+			Scopes:    []string{ScopeAdmin},
+			CreatedAt: time.Now(),
+		}
+		a.tokens[t.Hash] = t
+	}
+	Instance = a
+	return a, nil
+}
+
+// Enabled reports whether bearer authentication is required.
+func (a *Authenticator) Enabled() bool {
+	return a.enabled
+}
+
+// Authenticate looks up the token for a secret.
+// Returns ErrInvalidToken when the secret is unknown.
+func (a *Authenticator) Authenticate(secret string) (*tokens.Token, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	t, ok := a.tokens[Hash(secret)]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return t, nil
+}
+
+// Create issues a new token with the given scopes and persists its hash.
+// Returns:
+//   - *tokens.Token: Issued token without the secret
+//   - string: The secret, it can not be recovered later
+//   - error: if a scope is unknown or the token can not be stored
+func (a *Authenticator) Create(name string, scopes []string) (*tokens.Token, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", ErrUnknownScope
+	}
+	for _, s := range scopes {
+		if !slices.Contains(knownScopes, s) {
+			return nil, "", ErrUnknownScope
+		}
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+	t := &tokens.Token{
+		ID:        id,
+		Name:      name,
+		Hash:      Hash(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokens[t.Hash] = t
+	if err = a.store.Add(t, a.persistent()); err != nil {
+		delete(a.tokens, t.Hash)
+		return nil, "", err
+	}
+	return t, secret, nil
+}
+
+// List returns all tokens without their hashes.
+func (a *Authenticator) List() []tokens.Token {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	list := make([]tokens.Token, 0, len(a.tokens))
+	for _, t := range a.tokens {
+		item := *t
+		item.Hash = ""
+		list = append(list, item)
+	}
+	slices.SortFunc(list, func(x, y tokens.Token) int {
+		return x.CreatedAt.Compare(y.CreatedAt)
+	})
+	return list
+}
+
+// Revoke deletes the token with the given identifier.
+// Returns ErrTokenNotFound when there is no such token.
+func (a *Authenticator) Revoke(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for hash, t := range a.tokens {
+		if t.ID != id {
+			continue
+		}
+		if t.ID == bootstrapID {
+			return ErrTokenNotFound
+		}
+		delete(a.tokens, hash)
+		if err := a.store.Delete(id, a.persistent()); err != nil {
+			a.tokens[hash] = t
+			return err
+		}
+		return nil
+	}
+	return ErrTokenNotFound
+}
+
+// persistent returns the tokens that must be written to the store,
+// the bootstrap token lives only in memory.
+func (a *Authenticator) persistent() []*tokens.Token {
+	list := make([]*tokens.Token, 0, len(a.tokens))
+	for _, t := range a.tokens {
+		if t.ID != bootstrapID {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+// HasScope reports whether the token grants the scope.
+func HasScope(t *tokens.Token, scope string) bool {
+	return slices.Contains(t.Scopes, ScopeAdmin) || slices.Contains(t.Scopes, scope)
+}
+
+// Hash returns the hex encoded SHA-256 hash of a token secret.
+// Secrets are random, so a plain hash is enough to keep them safe at rest.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
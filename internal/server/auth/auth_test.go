@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticator_CreateAuthenticateRevoke(t *testing.T) {
+	originalInstance := Instance
+	defer func() {
+		Instance = originalInstance
+	}()
+
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	a, err := New(true, path, "", nil)
+	require.NoError(t, err)
+	assert.True(t, a.Enabled())
+	assert.Same(t, a, Instance)
+
+	token, secret, err := a.Create("grafana", []string{ScopeRead})
+	require.NoError(t, err)
+	assert.NotEmpty(t, secret)
+	assert.Equal(t, Hash(secret), token.Hash)
+
+	got, err := a.Authenticate(secret)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, got.ID)
+
+	_, err = a.Authenticate("unknown")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// токены переживают перезапуск
+	restored, err := New(true, path, "", nil)
+	require.NoError(t, err)
+	_, err = restored.Authenticate(secret)
+	require.NoError(t, err)
+
+	list := restored.List()
+	require.Len(t, list, 1)
+	assert.Empty(t, list[0].Hash)
+
+	require.NoError(t, restored.Revoke(token.ID))
+	_, err = restored.Authenticate(secret)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.ErrorIs(t, restored.Revoke(token.ID), ErrTokenNotFound)
+}
+
+func TestAuthenticator_DisabledKeepsStoredTokens(t *testing.T) {
+	originalInstance := Instance
+	defer func() {
+		Instance = originalInstance
+	}()
+
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	a, err := New(true, path, "", nil)
+	require.NoError(t, err)
+	old, oldSecret, err := a.Create("grafana", []string{ScopeRead})
+	require.NoError(t, err)
+
+	// аутентификация выключена, токенами управляет bootstrap admin
+	disabled, err := New(false, path, "admin-secret", nil)
+	require.NoError(t, err)
+	created, _, err := disabled.Create("agent", []string{ScopeWrite})
+	require.NoError(t, err)
+
+	stored, err := fileStore{path: path}.Load()
+	require.NoError(t, err)
+	ids := []string{}
+	for _, t := range stored {
+		ids = append(ids, t.ID)
+	}
+	assert.ElementsMatch(t, []string{old.ID, created.ID}, ids)
+
+	restored, err := New(true, path, "", nil)
+	require.NoError(t, err)
+	_, err = restored.Authenticate(oldSecret)
+	assert.NoError(t, err)
+}
+
+func TestAuthenticator_CreateUnknownScope(t *testing.T) {
+	originalInstance := Instance
+	defer func() {
+		Instance = originalInstance
+	}()
+
+	a, err := New(true, "", "", nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		scopes []string
+	}{
+		{name: "no scopes", scopes: nil},
+		{name: "unknown scope", scopes: []string{ScopeRead, "root"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := a.Create("test", tt.scopes)
+			assert.ErrorIs(t, err, ErrUnknownScope)
+		})
+	}
+}
+
+func TestAuthenticator_BootstrapToken(t *testing.T) {
+	originalInstance := Instance
+	defer func() {
+		Instance = originalInstance
+	}()
+
+	a, err := New(true, "", "admin-secret", nil)
+	require.NoError(t, err)
+
+	token, err := a.Authenticate("admin-secret")
+	require.NoError(t, err)
+	assert.True(t, HasScope(token, ScopeAdmin))
+	assert.ErrorIs(t, a.Revoke(bootstrapID), ErrTokenNotFound)
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{name: "exact scope", scopes: []string{ScopeRead}, scope: ScopeRead, want: true},
+		{name: "missing scope", scopes: []string{ScopeRead}, scope: ScopeWrite, want: false},
+		{name: "admin implies write", scopes: []string{ScopeAdmin}, scope: ScopeWrite, want: true},
+		{name: "write does not imply admin", scopes: []string{ScopeWrite}, scope: ScopeAdmin, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, HasScope(&tokens.Token{Scopes: tt.scopes}, tt.scope))
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package auth
+
+import "errors"
+
+var ErrInvalidToken = errors.New("invalid token")
+
+var ErrUnknownScope = errors.New("unknown token scope")
+
+var ErrTokenNotFound = errors.New("token not found")
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/tokens"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage/database/postgres"
+)
+
+// tokenStore persists token hashes between server restarts.
+// Add and Delete also receive the full list so file based stores can rewrite it.
+type tokenStore interface {
+	Load() ([]*tokens.Token, error)
+	Add(t *tokens.Token, all []*tokens.Token) error
+	Delete(id string, all []*tokens.Token) error
+}
+
+type fileStore struct {
+	path string
+}
+
+func (s fileStore) Load() ([]*tokens.Token, error) {
+	var tokenList []*tokens.Token
+	if s.path == "" {
+		return tokenList, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return tokenList, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return tokenList, nil
+	}
+	if err = json.Unmarshal(data, &tokenList); err != nil {
+		return nil, err
+	}
+	return tokenList, nil
+}
+
+func (s fileStore) Add(_ *tokens.Token, all []*tokens.Token) error {
+	return s.write(all)
+}
+
+func (s fileStore) Delete(_ string, all []*tokens.Token) error {
+	return s.write(all)
+}
+
+func (s fileStore) write(all []*tokens.Token) error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	// файл содержит хэши токенов, поэтому доступен только владельцу
+	if err = os.WriteFile(s.path, data, 0600); err != nil {
+		logger.LogError(err)
+		return err
+	}
+	return nil
+}
+
+type dbStore struct {
+	db *sql.DB
+}
+
+func (s dbStore) Load() ([]*tokens.Token, error) {
+	return postgres.LoadTokensFromDB(s.db)
+}
+
+func (s dbStore) Add(t *tokens.Token, _ []*tokens.Token) error {
+	return postgres.SaveTokenToDB(t, s.db)
+}
+
+func (s dbStore) Delete(id string, _ []*tokens.Token) error {
+	return postgres.DeleteTokenFromDB(id, s.db)
+}
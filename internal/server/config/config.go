@@ -10,18 +10,56 @@ import (
 )
 
 type Parameters struct {
-	Address             string `json:"address"`
-	StoreIntervalSecond int    `json:"store_interval"`
-	StoragePath         string `json:"store_file"`
-	Restore             bool   `json:"restore"`
-	LogLevel            string `json:"log_level"`
-	DatabaseDSN         string `json:"database_dsn"`
-	MigrationsPath      string `json:"migrations_path"`
-	Key                 string `json:"key"`
-	ProfileFileCPU      string `json:"profile_file_cpu"`
-	ProfileFileMem      string `json:"profile_file_mem"`
-	IsProfileOn         bool   `json:"is_profile_on"`
-	CryptoKeyPath       string `json:"crypto_key"`
+	Address                string  `json:"address"`
+	StoreIntervalSecond    int     `json:"store_interval"`
+	StoragePath            string  `json:"store_file"`
+	Restore                bool    `json:"restore"`
+	LogLevel               string  `json:"log_level"`
+	DatabaseDSN            string  `json:"database_dsn"`
+	MigrationsPath         string  `json:"migrations_path"`
+	Key                    string  `json:"key"`
+	ProfileFileCPU         string  `json:"profile_file_cpu"`
+	ProfileFileMem         string  `json:"profile_file_mem"`
+	IsProfileOn            bool    `json:"is_profile_on"`
+	CryptoKeyPath          string  `json:"crypto_key"`
+	TrustedSubnet          string  `json:"trusted_subnet"`
+	TrustedSubnetRead      string  `json:"trusted_subnet_read"`
+	TrustedProxies         string  `json:"trusted_proxies"`
+	AuthEnabled            bool    `json:"auth_enabled"`
+	TokensPath             string  `json:"tokens_file"`
+	AdminToken             string  `json:"admin_token"`
+	RateLimitRead          int     `json:"rate_limit_read"`
+	RateLimitWrite         int     `json:"rate_limit_write"`
+	RateLimitBurst         int     `json:"rate_limit_burst"`
+	RateLimitKey           string  `json:"rate_limit_key"`
+	MaxBodySize            int     `json:"max_body_size"`
+	MaxDecompressedSize    int     `json:"max_decompressed_size"`
+	MaxRestoreSize         int     `json:"max_restore_size"`
+	BatchMode              string  `json:"batch_mode"`
+	BatchChunkSize         int     `json:"batch_chunk_size"`
+	StreamBufferSize       int     `json:"stream_buffer_size"`
+	RateWindowSecond       int     `json:"rate_window"`
+	RulesPath              string  `json:"rules_path"`
+	RulesIntervalSecond    int     `json:"rules_interval"`
+	SilencesPath           string  `json:"silences_file"`
+	AnomalyDetection       bool    `json:"anomaly_detection"`
+	AnomalyZScore          float64 `json:"anomaly_z_score"`
+	Leader                 string  `json:"leader_address"`
+	LeaderWrites           string  `json:"leader_writes"`
+	LeaderToken            string  `json:"leader_token"`
+	ReplicationLogSize     int     `json:"replication_log_size"`
+	ClusterNodes           string  `json:"cluster_nodes"`
+	ClusterSelf            string  `json:"cluster_self"`
+	ClusterSecret          string  `json:"cluster_secret"`
+	Upstream               string  `json:"upstream_address"`
+	UpstreamToken          string  `json:"upstream_token"`
+	RelayIntervalSecond    int     `json:"relay_interval"`
+	RelayQueuePath         string  `json:"relay_queue"`
+	FederateTargets        string  `json:"federate_targets"`
+	FederateToken          string  `json:"federate_token"`
+	FederateIntervalSecond int     `json:"federate_interval"`
+	ScrapeAgents           string  `json:"scrape_agents"`
+	ScrapeIntervalSecond   int     `json:"scrape_interval"`
 }
 
 func New() Parameters {
@@ -34,23 +72,75 @@ func New() Parameters {
 		return fileConfig
 	}
 	parameters := Parameters{
-		Address:             utils.ResolveString(envConfig.Address, flags.RunAddr, fileConfig.Address),
-		StoreIntervalSecond: utils.ResolveInt(envConfig.StoreIntervalSecond, flags.StoreIntervalSecond, fileConfig.StoreIntervalSecond),
-		StoragePath:         utils.ResolveString(envConfig.StoragePath, flags.StoragePath, fileConfig.StoragePath),
-		Restore:             utils.ResolveBool(isRestoreSet(), envConfig.Restore, flags.Restore, fileConfig.Restore),
-		LogLevel:            utils.ResolveString(envConfig.LogLevel, flags.LogLevel, fileConfig.LogLevel),
-		DatabaseDSN:         utils.ResolveString(envConfig.DatabaseDSN, flags.DatabaseDSN, fileConfig.DatabaseDSN),
-		MigrationsPath:      utils.ResolveString(envConfig.MigrationsPath, flags.MigrationsPath, fileConfig.MigrationsPath),
-		Key:                 utils.ResolveString(envConfig.Key, flags.Key, fileConfig.Key),
-		ProfileFileCPU:      utils.ResolveString(envConfig.ProfileFileCPU, flags.ProfileFileCPU, fileConfig.ProfileFileCPU),
-		ProfileFileMem:      utils.ResolveString(envConfig.ProfileFileMem, flags.ProfileFileMem, fileConfig.ProfileFileMem),
-		IsProfileOn:         utils.ResolveBool(isProfileOnSet(), envConfig.IsProfileOn, flags.IsProfileOn, fileConfig.IsProfileOn),
-		CryptoKeyPath:       utils.ResolveString(envConfig.CryptoKeyPath, flags.CryptoKeyPath, fileConfig.CryptoKeyPath),
+		Address:                utils.ResolveString(envConfig.Address, flags.RunAddr, fileConfig.Address),
+		StoreIntervalSecond:    utils.ResolveInt(envConfig.StoreIntervalSecond, flags.StoreIntervalSecond, fileConfig.StoreIntervalSecond),
+		StoragePath:            utils.ResolveString(envConfig.StoragePath, flags.StoragePath, fileConfig.StoragePath),
+		Restore:                utils.ResolveBool(isRestoreSet(), envConfig.Restore, flags.Restore, fileConfig.Restore),
+		LogLevel:               utils.ResolveString(envConfig.LogLevel, flags.LogLevel, fileConfig.LogLevel),
+		DatabaseDSN:            utils.ResolveString(envConfig.DatabaseDSN, flags.DatabaseDSN, fileConfig.DatabaseDSN),
+		MigrationsPath:         utils.ResolveString(envConfig.MigrationsPath, flags.MigrationsPath, fileConfig.MigrationsPath),
+		Key:                    utils.ResolveString(envConfig.Key, flags.Key, fileConfig.Key),
+		ProfileFileCPU:         utils.ResolveString(envConfig.ProfileFileCPU, flags.ProfileFileCPU, fileConfig.ProfileFileCPU),
+		ProfileFileMem:         utils.ResolveString(envConfig.ProfileFileMem, flags.ProfileFileMem, fileConfig.ProfileFileMem),
+		IsProfileOn:            utils.ResolveBool(isProfileOnSet(), envConfig.IsProfileOn, flags.IsProfileOn, fileConfig.IsProfileOn),
+		CryptoKeyPath:          utils.ResolveString(envConfig.CryptoKeyPath, flags.CryptoKeyPath, fileConfig.CryptoKeyPath),
+		TrustedSubnet:          utils.ResolveString(envConfig.TrustedSubnet, flags.TrustedSubnet, fileConfig.TrustedSubnet),
+		TrustedSubnetRead:      utils.ResolveString(envConfig.TrustedSubnetRead, flags.TrustedSubnetRead, fileConfig.TrustedSubnetRead),
+		TrustedProxies:         utils.ResolveString(envConfig.TrustedProxies, flags.TrustedProxies, fileConfig.TrustedProxies),
+		AuthEnabled:            utils.ResolveBool(isAuthEnabledSet(), envConfig.AuthEnabled, flags.AuthEnabled, fileConfig.AuthEnabled),
+		TokensPath:             utils.ResolveString(envConfig.TokensPath, flags.TokensPath, fileConfig.TokensPath),
+		AdminToken:             utils.ResolveString(envConfig.AdminToken, flags.AdminToken, fileConfig.AdminToken),
+		RateLimitRead:          utils.ResolveInt(envConfig.RateLimitRead, flags.RateLimitRead, fileConfig.RateLimitRead),
+		RateLimitWrite:         utils.ResolveInt(envConfig.RateLimitWrite, flags.RateLimitWrite, fileConfig.RateLimitWrite),
+		RateLimitBurst:         utils.ResolveInt(envConfig.RateLimitBurst, flags.RateLimitBurst, fileConfig.RateLimitBurst),
+		RateLimitKey:           utils.ResolveString(envConfig.RateLimitKey, flags.RateLimitKey, fileConfig.RateLimitKey),
+		MaxBodySize:            utils.ResolveInt(envConfig.MaxBodySize, flags.MaxBodySize, fileConfig.MaxBodySize),
+		MaxDecompressedSize:    utils.ResolveInt(envConfig.MaxDecompressedSize, flags.MaxDecompressedSize, fileConfig.MaxDecompressedSize),
+		MaxRestoreSize:         utils.ResolveInt(envConfig.MaxRestoreSize, flags.MaxRestoreSize, fileConfig.MaxRestoreSize),
+		BatchMode:              utils.ResolveString(envConfig.BatchMode, flags.BatchMode, fileConfig.BatchMode),
+		BatchChunkSize:         utils.ResolveInt(envConfig.BatchChunkSize, flags.BatchChunkSize, fileConfig.BatchChunkSize),
+		StreamBufferSize:       utils.ResolveInt(envConfig.StreamBufferSize, flags.StreamBufferSize, fileConfig.StreamBufferSize),
+		RateWindowSecond:       utils.ResolveInt(envConfig.RateWindowSecond, flags.RateWindowSecond, fileConfig.RateWindowSecond),
+		RulesPath:              utils.ResolveString(envConfig.RulesPath, flags.RulesPath, fileConfig.RulesPath),
+		RulesIntervalSecond:    utils.ResolveInt(envConfig.RulesIntervalSecond, flags.RulesIntervalSecond, fileConfig.RulesIntervalSecond),
+		SilencesPath:           utils.ResolveString(envConfig.SilencesPath, flags.SilencesPath, fileConfig.SilencesPath),
+		AnomalyDetection:       utils.ResolveBool(isAnomalyDetectionSet(), envConfig.AnomalyDetection, flags.AnomalyDetection, fileConfig.AnomalyDetection),
+		AnomalyZScore:          utils.ResolveFloat(envConfig.AnomalyZScore, flags.AnomalyZScore, fileConfig.AnomalyZScore),
+		Leader:                 utils.ResolveString(envConfig.Leader, flags.Leader, fileConfig.Leader),
+		LeaderWrites:           utils.ResolveString(envConfig.LeaderWrites, flags.LeaderWrites, fileConfig.LeaderWrites),
+		LeaderToken:            utils.ResolveString(envConfig.LeaderToken, flags.LeaderToken, fileConfig.LeaderToken),
+		ReplicationLogSize:     utils.ResolveInt(envConfig.ReplicationLogSize, flags.ReplicationLogSize, fileConfig.ReplicationLogSize),
+		ClusterNodes:           utils.ResolveString(envConfig.ClusterNodes, flags.ClusterNodes, fileConfig.ClusterNodes),
+		ClusterSelf:            utils.ResolveString(envConfig.ClusterSelf, flags.ClusterSelf, fileConfig.ClusterSelf),
+		ClusterSecret:          utils.ResolveString(envConfig.ClusterSecret, flags.ClusterSecret, fileConfig.ClusterSecret),
+		Upstream:               utils.ResolveString(envConfig.Upstream, flags.Upstream, fileConfig.Upstream),
+		UpstreamToken:          utils.ResolveString(envConfig.UpstreamToken, flags.UpstreamToken, fileConfig.UpstreamToken),
+		RelayIntervalSecond:    utils.ResolveInt(envConfig.RelayIntervalSecond, flags.RelayIntervalSecond, fileConfig.RelayIntervalSecond),
+		RelayQueuePath:         utils.ResolveString(envConfig.RelayQueuePath, flags.RelayQueuePath, fileConfig.RelayQueuePath),
+		FederateTargets:        utils.ResolveString(envConfig.FederateTargets, flags.FederateTargets, fileConfig.FederateTargets),
+		FederateToken:          utils.ResolveString(envConfig.FederateToken, flags.FederateToken, fileConfig.FederateToken),
+		FederateIntervalSecond: utils.ResolveInt(envConfig.FederateIntervalSecond, flags.FederateIntervalSecond, fileConfig.FederateIntervalSecond),
+		ScrapeAgents:           utils.ResolveString(envConfig.ScrapeAgents, flags.ScrapeAgents, fileConfig.ScrapeAgents),
+		ScrapeIntervalSecond:   utils.ResolveInt(envConfig.ScrapeIntervalSecond, flags.ScrapeIntervalSecond, fileConfig.ScrapeIntervalSecond),
 	}
-	fmt.Printf("%+v\n", parameters)
+	fmt.Printf("%+v\n", parameters.redacted())
 	return parameters
 }
 
+// redactedSecret replaces secrets in printed parameters.
+const redactedSecret = "[redacted]"
+
+// redacted returns a copy of the parameters safe to print: the signing key,
+// the tokens and the cluster secret are replaced when set.
+func (p Parameters) redacted() Parameters {
+	for _, secret := range []*string{&p.Key, &p.AdminToken, &p.LeaderToken, &p.ClusterSecret, &p.UpstreamToken, &p.FederateToken} {
+		if *secret != "" {
+			*secret = redactedSecret
+		}
+	}
+	return p
+}
+
 func getParamsByConfigPath(configPath string) (Parameters, error) {
 	var parameters Parameters
 	if configPath == "" {
@@ -157,6 +157,29 @@ func TestResolveBool(t *testing.T) {
 	}
 }
 
+func TestParameters_Redacted(t *testing.T) {
+	p := Parameters{
+		Address:       "localhost:8080",
+		Key:           "hmac-key",
+		AdminToken:    "admin-secret",
+		LeaderToken:   "leader-secret",
+		ClusterSecret: "cluster-secret",
+		UpstreamToken: "upstream-secret",
+		FederateToken: "",
+	}
+
+	redacted := p.redacted()
+	assert.Equal(t, "localhost:8080", redacted.Address)
+	assert.Equal(t, redactedSecret, redacted.Key)
+	assert.Equal(t, redactedSecret, redacted.AdminToken)
+	assert.Equal(t, redactedSecret, redacted.LeaderToken)
+	assert.Equal(t, redactedSecret, redacted.ClusterSecret)
+	assert.Equal(t, redactedSecret, redacted.UpstreamToken)
+	assert.Empty(t, redacted.FederateToken)
+	// исходные параметры не меняются
+	assert.Equal(t, "admin-secret", p.AdminToken)
+}
+
 func TestGetParamsByConfigPath(t *testing.T) {
 	// Создаем временный файл конфигурации
 	configContent := `{
@@ -8,19 +8,57 @@ import (
 )
 
 type Config struct {
-	Address             string `env:"ADDRESS"`
-	StoreIntervalSecond int    `env:"STORE_INTERVAL"`
-	StoragePath         string `env:"FILE_STORAGE_PATH"`
-	Restore             bool   `env:"RESTORE"`
-	LogLevel            string `env:"LOG_LEVEL"`
-	DatabaseDSN         string `env:"DATABASE_DSN"`
-	MigrationsPath      string `env:"MIGRATIONS_PATH"`
-	Key                 string `env:"KEY"`
-	ProfileFileCPU      string `env:"CPU_FILE"`
-	ProfileFileMem      string `env:"MEM_FILE"`
-	IsProfileOn         bool   `env:"IS_PROFILE_ON"`
-	CryptoKeyPath       string `env:"CRYPTO_KEY"`
-	ConfigPath          string `env:"CONFIG"`
+	Address                string  `env:"ADDRESS"`
+	StoreIntervalSecond    int     `env:"STORE_INTERVAL"`
+	StoragePath            string  `env:"FILE_STORAGE_PATH"`
+	Restore                bool    `env:"RESTORE"`
+	LogLevel               string  `env:"LOG_LEVEL"`
+	DatabaseDSN            string  `env:"DATABASE_DSN"`
+	MigrationsPath         string  `env:"MIGRATIONS_PATH"`
+	Key                    string  `env:"KEY"`
+	ProfileFileCPU         string  `env:"CPU_FILE"`
+	ProfileFileMem         string  `env:"MEM_FILE"`
+	IsProfileOn            bool    `env:"IS_PROFILE_ON"`
+	CryptoKeyPath          string  `env:"CRYPTO_KEY"`
+	ConfigPath             string  `env:"CONFIG"`
+	TrustedSubnet          string  `env:"TRUSTED_SUBNET"`
+	TrustedSubnetRead      string  `env:"TRUSTED_SUBNET_READ"`
+	TrustedProxies         string  `env:"TRUSTED_PROXIES"`
+	AuthEnabled            bool    `env:"AUTH_ENABLED"`
+	TokensPath             string  `env:"TOKENS_FILE"`
+	AdminToken             string  `env:"ADMIN_TOKEN"`
+	RateLimitRead          int     `env:"RATE_LIMIT_READ"`
+	RateLimitWrite         int     `env:"RATE_LIMIT_WRITE"`
+	RateLimitBurst         int     `env:"RATE_LIMIT_BURST"`
+	RateLimitKey           string  `env:"RATE_LIMIT_KEY"`
+	MaxBodySize            int     `env:"MAX_BODY_SIZE"`
+	MaxDecompressedSize    int     `env:"MAX_DECOMPRESSED_SIZE"`
+	MaxRestoreSize         int     `env:"MAX_RESTORE_SIZE"`
+	BatchMode              string  `env:"BATCH_MODE"`
+	BatchChunkSize         int     `env:"BATCH_CHUNK_SIZE"`
+	StreamBufferSize       int     `env:"STREAM_BUFFER_SIZE"`
+	RateWindowSecond       int     `env:"RATE_WINDOW"`
+	RulesPath              string  `env:"RULES_PATH"`
+	RulesIntervalSecond    int     `env:"RULES_INTERVAL"`
+	SilencesPath           string  `env:"SILENCES_FILE"`
+	AnomalyDetection       bool    `env:"ANOMALY_DETECTION"`
+	AnomalyZScore          float64 `env:"ANOMALY_Z_SCORE"`
+	Leader                 string  `env:"LEADER_ADDRESS"`
+	LeaderWrites           string  `env:"LEADER_WRITES"`
+	LeaderToken            string  `env:"LEADER_TOKEN"`
+	ReplicationLogSize     int     `env:"REPLICATION_LOG_SIZE"`
+	ClusterNodes           string  `env:"CLUSTER_NODES"`
+	ClusterSelf            string  `env:"CLUSTER_SELF"`
+	ClusterSecret          string  `env:"CLUSTER_SECRET"`
+	Upstream               string  `env:"UPSTREAM_ADDRESS"`
+	UpstreamToken          string  `env:"UPSTREAM_TOKEN"`
+	RelayIntervalSecond    int     `env:"RELAY_INTERVAL"`
+	RelayQueuePath         string  `env:"RELAY_QUEUE_PATH"`
+	FederateTargets        string  `env:"FEDERATE_TARGETS"`
+	FederateToken          string  `env:"FEDERATE_TOKEN"`
+	FederateIntervalSecond int     `env:"FEDERATE_INTERVAL"`
+	ScrapeAgents           string  `env:"SCRAPE_AGENTS"`
+	ScrapeIntervalSecond   int     `env:"SCRAPE_INTERVAL"`
 }
 
 func ParseEnv() *Config {
@@ -51,3 +89,13 @@ func isProfileOnSet() bool {
 	_, isSet := os.LookupEnv("IS_PROFILE_ON")
 	return isSet
 }
+
+func isAuthEnabledSet() bool {
+	_, isSet := os.LookupEnv("AUTH_ENABLED")
+	return isSet
+}
+
+func isAnomalyDetectionSet() bool {
+	_, isSet := os.LookupEnv("ANOMALY_DETECTION")
+	return isSet
+}
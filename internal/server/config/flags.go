@@ -21,6 +21,69 @@ type ParsedFlags struct {
 	IsProfileOn    utils.FlagValue[bool]
 	CryptoKeyPath  utils.FlagValue[string]
 	ConfigPath     utils.FlagValue[string]
+	// CIDR списки через запятую
+	TrustedSubnet     utils.FlagValue[string]
+	TrustedSubnetRead utils.FlagValue[string]
+	TrustedProxies    utils.FlagValue[string]
+	AuthEnabled       utils.FlagValue[bool]
+	TokensPath        utils.FlagValue[string]
+	AdminToken        utils.FlagValue[string]
+	// запросов в секунду на клиента, 0 - без ограничений
+	RateLimitRead  utils.FlagValue[int]
+	RateLimitWrite utils.FlagValue[int]
+	RateLimitBurst utils.FlagValue[int]
+	// ip agent token
+	RateLimitKey utils.FlagValue[string]
+	// байты, 0 - без ограничений
+	MaxBodySize         utils.FlagValue[int]
+	MaxDecompressedSize utils.FlagValue[int]
+	MaxRestoreSize      utils.FlagValue[int]
+	// all-or-nothing best-effort
+	BatchMode      utils.FlagValue[string]
+	BatchChunkSize utils.FlagValue[int]
+
+	// события на подписчика /api/v1/stream
+	StreamBufferSize utils.FlagValue[int]
+
+	// окно производных _rate и _increase счётчиков
+	RateWindowSecond utils.FlagValue[int]
+
+	// правила записи
+	RulesPath           utils.FlagValue[string]
+	RulesIntervalSecond utils.FlagValue[int]
+
+	// тишины оповещений без базы данных
+	SilencesPath utils.FlagValue[string]
+
+	// обнаружение аномалий gauge
+	AnomalyDetection utils.FlagValue[bool]
+	AnomalyZScore    utils.FlagValue[float64]
+
+	// репликация с лидера
+	Leader             utils.FlagValue[string]
+	LeaderWrites       utils.FlagValue[string]
+	LeaderToken        utils.FlagValue[string]
+	ReplicationLogSize utils.FlagValue[int]
+
+	// шардирование метрик между узлами кластера
+	ClusterNodes  utils.FlagValue[string]
+	ClusterSelf   utils.FlagValue[string]
+	ClusterSecret utils.FlagValue[string]
+
+	// пересылка обновлений на центральный сервер
+	Upstream            utils.FlagValue[string]
+	UpstreamToken       utils.FlagValue[string]
+	RelayIntervalSecond utils.FlagValue[int]
+	RelayQueuePath      utils.FlagValue[string]
+
+	// сбор метрик с других серверов через /federate
+	FederateTargets        utils.FlagValue[string]
+	FederateToken          utils.FlagValue[string]
+	FederateIntervalSecond utils.FlagValue[int]
+
+	// опрос агентов, отдающих метрики по -expose
+	ScrapeAgents         utils.FlagValue[string]
+	ScrapeIntervalSecond utils.FlagValue[int]
 }
 
 // parseFlags обрабатывает аргументы командной строки
@@ -40,6 +103,44 @@ func ParseFlags() *ParsedFlags {
 	flag.StringVar(&flags.Key.Value, "k", "", "private key for signature")
 	flag.StringVar(&flags.CryptoKeyPath.Value, "crypto-key", "", "path for public key")
 	flag.StringVar(&flags.ConfigPath.Value, "c", "", "path for JSON config")
+	flag.StringVar(&flags.TrustedSubnet.Value, "t", "", "comma separated CIDRs allowed to update metrics")
+	flag.StringVar(&flags.TrustedSubnetRead.Value, "tr", "", "comma separated CIDRs allowed to read metrics")
+	flag.StringVar(&flags.TrustedProxies.Value, "tp", "", "comma separated CIDRs of trusted reverse proxies, X-Real-IP and X-Forwarded-For are ignored from other peers")
+	flag.BoolVar(&flags.AuthEnabled.Value, "auth", false, "require bearer tokens with scopes")
+	flag.StringVar(&flags.TokensPath.Value, "tokens", "./tokens.json", "file path for API tokens when database is not used")
+	flag.StringVar(&flags.AdminToken.Value, "admin-token", "", "bootstrap token with admin scope, admin routes are closed without it unless auth is enabled")
+	flag.IntVar(&flags.RateLimitRead.Value, "rl-read", 0, "read requests per second for one client, 0 - unlimited")
+	flag.IntVar(&flags.RateLimitWrite.Value, "rl-write", 0, "write requests per second for one client, 0 - unlimited")
+	flag.IntVar(&flags.RateLimitBurst.Value, "rl-burst", 0, "rate limit bucket size, defaults to the rate")
+	flag.StringVar(&flags.RateLimitKey.Value, "rl-key", "ip", "rate limit client key: ip agent token")
+	flag.IntVar(&flags.MaxBodySize.Value, "max-body", 1<<20, "max request body size in bytes as received, 0 - unlimited")
+	flag.IntVar(&flags.MaxDecompressedSize.Value, "max-decompressed", 10<<20, "max request body size in bytes after gzip decompression, 0 - unlimited")
+	flag.IntVar(&flags.MaxRestoreSize.Value, "max-restore", 256<<20, "max snapshot size in bytes accepted by /admin/restore, before and after decompression, 0 - unlimited")
+	flag.StringVar(&flags.BatchMode.Value, "batch-mode", "all-or-nothing", "batch update mode: all-or-nothing best-effort")
+	flag.IntVar(&flags.BatchChunkSize.Value, "batch-chunk", 1000, "metrics saved to storage at once in best-effort batch updates")
+	flag.IntVar(&flags.StreamBufferSize.Value, "stream-buffer", 256, "events buffered per /api/v1/stream subscriber before they are dropped")
+	flag.IntVar(&flags.RateWindowSecond.Value, "rate-window", 300, "window in seconds for the derived counter _rate and _increase gauges")
+	flag.StringVar(&flags.RulesPath.Value, "rules", "", "path to the JSON file with recording rules, empty - no rules")
+	flag.IntVar(&flags.RulesIntervalSecond.Value, "rules-interval", 10, "recording rules evaluation interval in seconds")
+	flag.StringVar(&flags.SilencesPath.Value, "silences", "./silences.json", "file path for alert silences when database is not used")
+	flag.BoolVar(&flags.AnomalyDetection.Value, "anomaly", false, "flag gauge samples deviating from their moving average")
+	flag.Float64Var(&flags.AnomalyZScore.Value, "anomaly-z", 3, "z-score above which a gauge sample is an anomaly")
+	flag.StringVar(&flags.Leader.Value, "leader", "", "address of the leader to replicate, the server runs as a read-only follower when set")
+	flag.StringVar(&flags.LeaderWrites.Value, "leader-writes", "proxy", "how a follower passes writes to the leader: proxy or redirect")
+	flag.StringVar(&flags.LeaderToken.Value, "leader-token", "", "API token a follower presents to the leader")
+	flag.IntVar(&flags.ReplicationLogSize.Value, "replication-log", 10000, "changes kept for followers catching up")
+	flag.StringVar(&flags.ClusterNodes.Value, "cluster-nodes", "", "comma separated addresses of all cluster nodes, metrics are partitioned between them when set")
+	flag.StringVar(&flags.ClusterSelf.Value, "cluster-self", "", "address of this node in -cluster-nodes, the listen address by default")
+	flag.StringVar(&flags.ClusterSecret.Value, "cluster-secret", "", "secret shared by the cluster nodes, authenticates requests passed between them")
+	flag.StringVar(&flags.Upstream.Value, "upstream", "", "address of the central server, the server runs as a relay forwarding its updates there when set")
+	flag.StringVar(&flags.UpstreamToken.Value, "upstream-token", "", "API token a relay presents to the central server")
+	flag.IntVar(&flags.RelayIntervalSecond.Value, "relay-interval", 10, "interval in seconds between batches a relay sends upstream")
+	flag.StringVar(&flags.RelayQueuePath.Value, "relay-queue", "./relay-queue", "directory a relay keeps the batches not yet sent upstream in")
+	flag.StringVar(&flags.FederateTargets.Value, "federate", "", "comma separated servers to pull metrics from via /federate, as name=host:port or host:port")
+	flag.StringVar(&flags.FederateToken.Value, "federate-token", "", "API token presented to the federation targets")
+	flag.IntVar(&flags.FederateIntervalSecond.Value, "federate-interval", 30, "interval in seconds between scrapes of the federation targets")
+	flag.StringVar(&flags.ScrapeAgents.Value, "scrape-agents", "", "comma separated agents started with -expose to pull metrics from, as name=host:port or host:port")
+	flag.IntVar(&flags.ScrapeIntervalSecond.Value, "scrape-interval", 10, "interval in seconds between scrapes of the agents")
 
 	flag.Parse()
 	flag.Visit(func(f *flag.Flag) {
@@ -70,6 +171,82 @@ func ParseFlags() *ParsedFlags {
 			flags.CryptoKeyPath.Passed = true
 		case "c":
 			flags.ConfigPath.Passed = true
+		case "t":
+			flags.TrustedSubnet.Passed = true
+		case "tr":
+			flags.TrustedSubnetRead.Passed = true
+		case "tp":
+			flags.TrustedProxies.Passed = true
+		case "auth":
+			flags.AuthEnabled.Passed = true
+		case "tokens":
+			flags.TokensPath.Passed = true
+		case "admin-token":
+			flags.AdminToken.Passed = true
+		case "rl-read":
+			flags.RateLimitRead.Passed = true
+		case "rl-write":
+			flags.RateLimitWrite.Passed = true
+		case "rl-burst":
+			flags.RateLimitBurst.Passed = true
+		case "rl-key":
+			flags.RateLimitKey.Passed = true
+		case "max-body":
+			flags.MaxBodySize.Passed = true
+		case "max-decompressed":
+			flags.MaxDecompressedSize.Passed = true
+		case "max-restore":
+			flags.MaxRestoreSize.Passed = true
+		case "batch-mode":
+			flags.BatchMode.Passed = true
+		case "batch-chunk":
+			flags.BatchChunkSize.Passed = true
+		case "stream-buffer":
+			flags.StreamBufferSize.Passed = true
+		case "rate-window":
+			flags.RateWindowSecond.Passed = true
+		case "rules":
+			flags.RulesPath.Passed = true
+		case "rules-interval":
+			flags.RulesIntervalSecond.Passed = true
+		case "silences":
+			flags.SilencesPath.Passed = true
+		case "anomaly":
+			flags.AnomalyDetection.Passed = true
+		case "anomaly-z":
+			flags.AnomalyZScore.Passed = true
+		case "leader":
+			flags.Leader.Passed = true
+		case "leader-writes":
+			flags.LeaderWrites.Passed = true
+		case "leader-token":
+			flags.LeaderToken.Passed = true
+		case "replication-log":
+			flags.ReplicationLogSize.Passed = true
+		case "cluster-nodes":
+			flags.ClusterNodes.Passed = true
+		case "cluster-self":
+			flags.ClusterSelf.Passed = true
+		case "cluster-secret":
+			flags.ClusterSecret.Passed = true
+		case "upstream":
+			flags.Upstream.Passed = true
+		case "upstream-token":
+			flags.UpstreamToken.Passed = true
+		case "relay-interval":
+			flags.RelayIntervalSecond.Passed = true
+		case "relay-queue":
+			flags.RelayQueuePath.Passed = true
+		case "federate":
+			flags.FederateTargets.Passed = true
+		case "federate-token":
+			flags.FederateToken.Passed = true
+		case "federate-interval":
+			flags.FederateIntervalSecond.Passed = true
+		case "scrape-agents":
+			flags.ScrapeAgents.Passed = true
+		case "scrape-interval":
+			flags.ScrapeIntervalSecond.Passed = true
 		}
 	})
 	return flags
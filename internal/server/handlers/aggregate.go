@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// AggregateHandler handles GET /api/v1/aggregate.
+// Query parameters:
+//   - op: sum, min, max, avg, count or topk
+//   - type, prefix, regex, label: metric selection as in ListMetricsHandler
+//   - window: Go duration, e.g. 5m, to aggregate history samples instead of current values
+//   - k: number of metrics returned by topk, 10 by default
+//
+// Returns a metricsService.AggregateResult as JSON.
+func AggregateHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("AggregateHandler")
+	markNodeLocal(res)
+	q, err := parseAggregateQuery(req)
+	if err != nil {
+		writeError(res, invalidQuery(err))
+		return
+	}
+	result, err := metricsService.Aggregate(storage.StorageInstance, q, time.Now())
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, result)
+}
+
+func parseAggregateQuery(req *http.Request) (metricsService.AggregateQuery, error) {
+	values := req.URL.Query()
+	q := metricsService.AggregateQuery{Op: values.Get("op")}
+	if q.Op == "" {
+		return q, fmt.Errorf("%w: op is required", metricsService.ErrUnknownAggregation)
+	}
+	var err error
+	if q.Select, err = parseSelector(req); err != nil {
+		return q, err
+	}
+	if window := values.Get("window"); window != "" {
+		q.Window, err = time.ParseDuration(window)
+		if err != nil || q.Window <= 0 {
+			return q, fmt.Errorf("window %q is not a positive duration", window)
+		}
+	}
+	if k := values.Get("k"); k != "" {
+		q.K, err = strconv.Atoi(k)
+		if err != nil || q.K <= 0 {
+			return q, fmt.Errorf("k %q is not a positive number", k)
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestAggregateHandler(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	for _, m := range []metrics.Metrics{
+		{ID: "HeapAlloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(3), Labels: map[string]string{"host": "a"}},
+		{ID: "HeapSys", MType: constants.Gauge, Value: utils.FloatToPointerFloat(5)},
+		{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(2)},
+	} {
+		require.NoError(t, storage.StorageInstance.SaveMetric(&m))
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		want     string
+	}{
+		{name: "Sum by regex", target: "/api/v1/aggregate?op=sum&regex=^Heap", wantCode: http.StatusOK, want: `{"op":"sum","series":2,"samples":2,"value":8}`},
+		{name: "Max by label", target: "/api/v1/aggregate?op=max&label=host=a", wantCode: http.StatusOK, want: `{"op":"max","series":1,"samples":1,"value":3}`},
+		{name: "Topk", target: "/api/v1/aggregate?op=topk&k=1", wantCode: http.StatusOK, want: `{"op":"topk","series":3,"samples":3,"top":[{"type":"gauge","id":"HeapSys","value":5}]}`},
+		{name: "Window", target: "/api/v1/aggregate?op=count&window=5m&type=counter", wantCode: http.StatusOK, want: `{"op":"count","window":"5m0s","series":1,"samples":0,"value":0}`},
+		{name: "No op", target: "/api/v1/aggregate", wantCode: http.StatusBadRequest},
+		{name: "Unknown op", target: "/api/v1/aggregate?op=median", wantCode: http.StatusBadRequest},
+		{name: "Broken window", target: "/api/v1/aggregate?op=sum&window=-1m", wantCode: http.StatusBadRequest},
+		{name: "Broken k", target: "/api/v1/aggregate?op=topk&k=0", wantCode: http.StatusBadRequest},
+		{name: "Broken regex", target: "/api/v1/aggregate?op=sum&regex=(", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			AggregateHandler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode != http.StatusOK {
+				var body APIError
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, CodeInvalidQuery, body.Code)
+				return
+			}
+			assert.JSONEq(t, tt.want, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/anomaly"
+)
+
+// Number of events returned by AnomaliesHandler by default and at most.
+const (
+	defaultAnomalies = 100
+	maxAnomalies     = 500
+)
+
+// anomaliesResponse is the body of AnomaliesHandler.
+type anomaliesResponse struct {
+	Enabled   bool            `json:"enabled"`
+	Threshold float64         `json:"threshold"`
+	Events    []anomaly.Event `json:"events"`
+}
+
+// AnomaliesHandler handles GET /api/v1/anomalies.
+// Query parameters:
+//   - since: return only events with a greater seq, for polling
+//   - id: return only events of this gauge
+//   - limit: maximum number of events, 100 by default and at most 500
+//
+// Returns the detector settings and the events from the oldest to the newest as JSON.
+func AnomaliesHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("AnomaliesHandler")
+	markNodeLocal(res)
+	q, err := parseAnomalyQuery(req)
+	if err != nil {
+		writeError(res, invalidQuery(err))
+		return
+	}
+	writeJSON(res, http.StatusOK, anomaliesResponse{
+		Enabled:   anomaly.Instance.Enabled(),
+		Threshold: anomaly.Instance.Threshold(),
+		Events:    anomaly.Instance.Events(q),
+	})
+}
+
+func parseAnomalyQuery(req *http.Request) (anomaly.Query, error) {
+	values := req.URL.Query()
+	q := anomaly.Query{ID: values.Get("id"), Limit: defaultAnomalies}
+	var err error
+	if since := values.Get("since"); since != "" {
+		if q.Since, err = strconv.ParseUint(since, 10, 64); err != nil {
+			return q, fmt.Errorf("since %q is not a sequence number", since)
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit <= 0 || q.Limit > maxAnomalies {
+			return q, fmt.Errorf("limit %q is not a number between 1 and %d", limit, maxAnomalies)
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/anomaly"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestAnomaliesHandler(t *testing.T) {
+	originalInstance := anomaly.Instance
+	defer func() {
+		anomaly.Instance = originalInstance
+	}()
+	anomaly.Instance = anomaly.New(true, 3)
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+
+	// обновления попадают в детектор через сервис метрик
+	for i := 0; i < 20; i++ {
+		m := metrics.Metrics{ID: "CPUutilization1", MType: constants.Gauge, Value: utils.FloatToPointerFloat(float64(40 + i%3))}
+		require.NoError(t, metricsService.Update(storage.StorageInstance, &m))
+	}
+	spike := metrics.Metrics{ID: "CPUutilization1", MType: constants.Gauge, Value: utils.FloatToPointerFloat(99)}
+	require.NoError(t, metricsService.Update(storage.StorageInstance, &spike))
+
+	tests := []struct {
+		name       string
+		target     string
+		wantCode   int
+		wantEvents int
+	}{
+		{name: "All", target: "/api/v1/anomalies", wantCode: http.StatusOK, wantEvents: 1},
+		{name: "By gauge", target: "/api/v1/anomalies?id=CPUutilization1&limit=10", wantCode: http.StatusOK, wantEvents: 1},
+		{name: "Other gauge", target: "/api/v1/anomalies?id=Alloc", wantCode: http.StatusOK},
+		{name: "Since last", target: "/api/v1/anomalies?since=1", wantCode: http.StatusOK},
+		{name: "Broken since", target: "/api/v1/anomalies?since=-1", wantCode: http.StatusBadRequest},
+		{name: "Broken limit", target: "/api/v1/anomalies?limit=1000", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			AnomaliesHandler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode != http.StatusOK {
+				var body APIError
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, CodeInvalidQuery, body.Code)
+				return
+			}
+			var body anomaliesResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.True(t, body.Enabled)
+			assert.Equal(t, 3.0, body.Threshold)
+			require.Len(t, body.Events, tt.wantEvents)
+			if tt.wantEvents > 0 {
+				assert.Equal(t, 99.0, body.Events[0].Value)
+			}
+		})
+	}
+}
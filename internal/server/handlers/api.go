@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// ListMetricsHandler handles GET /api/v1/metrics.
+// Query parameters:
+//   - type: gauge or counter
+//   - prefix: metric name prefix
+//   - regex: regular expression the metric name must match
+//   - label: key=value, may be repeated, all labels must match
+//   - sort: name, type or value, "-" prefix for descending order
+//   - limit: page size, 100 by default and at most 1000
+//   - cursor: next_cursor of the previous page
+//
+// Returns a metricsService.Page as JSON.
+// On a cluster node the metrics of all nodes are listed.
+func ListMetricsHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("ListMetricsHandler")
+	q, err := parseListQuery(req)
+	if err != nil {
+		writeError(res, invalidQuery(err))
+		return
+	}
+	var page *metricsService.Page
+	if clustered(req) {
+		page, err = listCluster(req, q)
+	} else {
+		page, err = metricsService.List(storage.StorageInstance, q)
+	}
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, page)
+}
+
+// GetMetricHandler handles GET /api/v1/metrics/{type}/{name}.
+// Returns the metric as JSON or 404 if it does not exist.
+func GetMetricHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("GetMetricHandler")
+	if routeToOwner(res, req, chi.URLParam(req, "name"), nil) {
+		return
+	}
+	metric, err := getMetric(chi.URLParam(req, "type"), chi.URLParam(req, "name"))
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, metric)
+}
+
+// PutMetricHandler handles PUT /api/v1/metrics/{type}/{name}.
+// Accepts {"value": 1.5} for gauges or {"delta": 10} for counters and optional labels.
+// The stored value is replaced, counters are not incremented.
+// Returns the stored metric as JSON.
+func PutMetricHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("PutMetricHandler")
+	if routeToOwner(res, req, chi.URLParam(req, "name"), nil) {
+		return
+	}
+	var metric metrics.Metrics
+	if err := json.NewDecoder(req.Body).Decode(&metric); err != nil {
+		writeError(res, fmt.Errorf("%w: %w", ErrInvalidBody, err))
+		return
+	}
+	metric.MType = chi.URLParam(req, "type")
+	metric.ID = chi.URLParam(req, "name")
+	if err := validateMetric(&metric); err != nil {
+		writeError(res, err)
+		return
+	}
+	if err := metricsService.Set(storage.StorageInstance, &metric); err != nil {
+		writeError(res, err)
+		return
+	}
+	GetMetricHandler(res, req)
+}
+
+// DeleteMetricHandler handles DELETE /api/v1/metrics/{type}/{name}.
+// Responds with HTTP 204 on success or 404 if the metric does not exist.
+func DeleteMetricHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("DeleteMetricHandler")
+	if routeToOwner(res, req, chi.URLParam(req, "name"), nil) {
+		return
+	}
+	err := metricsService.Delete(storage.StorageInstance, chi.URLParam(req, "type"), chi.URLParam(req, "name"))
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// getMetric returns the stored metric, it serves GetMetricHandler
+// and the legacy /value routes.
+func getMetric(mType, name string) (*metrics.Metrics, error) {
+	params := []*metrics.MetricDTOParams{{MetricType: mType, MetricsName: name}}
+	return metricsService.Get(storage.StorageInstance, &params)
+}
+
+// updateMetric validates m and adds it to the stored metric, a counter
+// is incremented. It serves the legacy /update routes.
+func updateMetric(m *metrics.Metrics) error {
+	if err := validateMetric(m); err != nil {
+		return err
+	}
+	return metricsService.Update(storage.StorageInstance, m)
+}
+
+func parseListQuery(req *http.Request) (metricsService.ListQuery, error) {
+	values := req.URL.Query()
+	q, err := parseSelector(req)
+	if err != nil {
+		return q, err
+	}
+	q.Sort = values.Get("sort")
+	q.Cursor = values.Get("cursor")
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("limit %q is not a positive number", limit)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// parseSelector parses the type, prefix, regex and label filters
+// shared by the endpoints that select a set of metrics.
+func parseSelector(req *http.Request) (metricsService.ListQuery, error) {
+	values := req.URL.Query()
+	q := metricsService.ListQuery{
+		Type:   values.Get("type"),
+		Prefix: values.Get("prefix"),
+	}
+	if q.Type != "" && q.Type != constants.Gauge && q.Type != constants.Counter {
+		return q, ErrNoMetricsType
+	}
+	if expr := values.Get("regex"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return q, err
+		}
+		q.Regex = re
+	}
+	for _, label := range values["label"] {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return q, fmt.Errorf("label %q is not key=value", label)
+		}
+		if q.Labels == nil {
+			q.Labels = map[string]string{}
+		}
+		q.Labels[key] = value
+	}
+	return q, nil
+}
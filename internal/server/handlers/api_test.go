@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+func apiRouter() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/api/v1/metrics", ListMetricsHandler)
+	r.Get("/api/v1/metrics/{type}/{name}", GetMetricHandler)
+	r.Put("/api/v1/metrics/{type}/{name}", PutMetricHandler)
+	r.Delete("/api/v1/metrics/{type}/{name}", DeleteMetricHandler)
+	return r
+}
+
+func serveAPI(method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	apiRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestListMetricsHandler(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	for _, body := range []struct{ target, body string }{
+		{"/api/v1/metrics/gauge/HeapAlloc", `{"value":3,"labels":{"host":"a"}}`},
+		{"/api/v1/metrics/gauge/HeapSys", `{"value":1}`},
+		{"/api/v1/metrics/counter/PollCount", `{"delta":2,"labels":{"host":"a"}}`},
+	} {
+		require.Equal(t, http.StatusOK, serveAPI(http.MethodPut, body.target, body.body).Code)
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		want     []string
+	}{
+		{name: "All metrics", target: "/api/v1/metrics", wantCode: http.StatusOK, want: []string{"HeapAlloc", "HeapSys", "PollCount"}},
+		{name: "By type", target: "/api/v1/metrics?type=counter", wantCode: http.StatusOK, want: []string{"PollCount"}},
+		{name: "By prefix and regex", target: "/api/v1/metrics?prefix=Heap&regex=Sys$", wantCode: http.StatusOK, want: []string{"HeapSys"}},
+		{name: "By label", target: "/api/v1/metrics?label=host=a&sort=-value", wantCode: http.StatusOK, want: []string{"HeapAlloc", "PollCount"}},
+		{name: "Unknown type", target: "/api/v1/metrics?type=histogram", wantCode: http.StatusBadRequest},
+		{name: "Broken regex", target: "/api/v1/metrics?regex=(", wantCode: http.StatusBadRequest},
+		{name: "Broken label", target: "/api/v1/metrics?label=host", wantCode: http.StatusBadRequest},
+		{name: "Broken limit", target: "/api/v1/metrics?limit=-1", wantCode: http.StatusBadRequest},
+		{name: "Unknown sort", target: "/api/v1/metrics?sort=size", wantCode: http.StatusBadRequest},
+		{name: "Broken cursor", target: "/api/v1/metrics?cursor=abc", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveAPI(http.MethodGet, tt.target, "")
+			require.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode != http.StatusOK {
+				var apiErr APIError
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+				assert.Equal(t, CodeInvalidQuery, apiErr.Code)
+				return
+			}
+			var page metricsService.Page
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+			got := make([]string, len(page.Metrics))
+			for i, m := range page.Metrics {
+				got[i] = m.ID
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("Pagination", func(t *testing.T) {
+		var first, second metricsService.Page
+		rec := serveAPI(http.MethodGet, "/api/v1/metrics?limit=2", "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &first))
+		require.Len(t, first.Metrics, 2)
+		require.NotEmpty(t, first.NextCursor)
+
+		rec = serveAPI(http.MethodGet, "/api/v1/metrics?limit=2&cursor="+first.NextCursor, "")
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &second))
+		require.Len(t, second.Metrics, 1)
+		assert.Equal(t, "PollCount", second.Metrics[0].ID)
+		assert.Empty(t, second.NextCursor)
+	})
+}
+
+func TestMetricResourceHandlers(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+
+	// PUT заменяет значение счётчика, а не прибавляет к нему
+	for _, delta := range []string{`{"delta":5}`, `{"delta":7}`} {
+		rec := serveAPI(http.MethodPut, "/api/v1/metrics/counter/PollCount", delta)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	rec := serveAPI(http.MethodGet, "/api/v1/metrics/counter/PollCount", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var metric metrics.Metrics
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &metric))
+	require.NotNil(t, metric.Delta)
+	assert.Equal(t, int64(7), *metric.Delta)
+
+	rec = serveAPI(http.MethodPut, "/api/v1/metrics/gauge/Alloc", `{"delta":5}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = serveAPI(http.MethodPut, "/api/v1/metrics/histogram/Alloc", `{"value":5}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = serveAPI(http.MethodPut, "/api/v1/metrics/gauge/Alloc", `{"value":`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serveAPI(http.MethodDelete, "/api/v1/metrics/counter/PollCount", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = serveAPI(http.MethodGet, "/api/v1/metrics/counter/PollCount", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serveAPI(http.MethodDelete, "/api/v1/metrics/counter/PollCount", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serveAPI(http.MethodDelete, "/api/v1/metrics/histogram/PollCount", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/server/auth"
+	"github.com/Maxim-Ba/metriccollector/internal/server/handlers/middleware"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/cluster"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/query"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/relay"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/silence"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// APIError is the body of an error response, see middleware.APIError.
+type APIError = middleware.APIError
+
+// Error codes returned in APIError.Code.
+const (
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeNotAcceptable    = "not_acceptable"
+	CodeInvalidQuery     = "invalid_query"
+	CodeInvalidBody      = middleware.CodeInvalidBody
+	CodeBodyTooLarge     = middleware.CodeBodyTooLarge
+	CodeNoMetricName     = "no_metric_name"
+	CodeInvalidType      = "invalid_metric_type"
+	CodeInvalidValue     = "invalid_value"
+	CodeMetricNotFound   = "metric_not_found"
+	CodeInvalidSnapshot  = "invalid_snapshot"
+	CodeQueryLimit       = "query_limit_exceeded"
+	CodeQueryTimeout     = "query_timeout"
+	CodeQueryFailed      = "query_execution_failed"
+	CodeInvalidSilence   = "invalid_silence"
+	CodeSilenceNotFound  = "silence_not_found"
+	CodeReplicationGap   = "replication_gap"
+	CodeNotLeader        = "not_leader"
+	CodeForwardFailed    = middleware.CodeForwardFailed
+	CodeNotClustered     = "not_clustered"
+	CodeNotRelay         = "not_relay"
+	CodeUnknownScope     = "unknown_scope"
+	CodeTokenNotFound    = "token_not_found"
+	CodeUnavailable      = middleware.CodeUnavailable
+	CodeInternal         = middleware.CodeInternal
+)
+
+// errorMapping binds a typed error to its response.
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings is the single place where handler errors are turned into
+// HTTP statuses. Entries are checked in order with errors.Is, so wrappers
+// such as ErrInvalidQuery must precede the errors they wrap.
+var errorMappings = []errorMapping{
+	{ErrMethodNotAllowed, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	{ErrNotAcceptable, http.StatusNotAcceptable, CodeNotAcceptable},
+	{ErrInvalidQuery, http.StatusBadRequest, CodeInvalidQuery},
+	{middleware.ErrBodyTooLarge, http.StatusRequestEntityTooLarge, CodeBodyTooLarge},
+	{ErrInvalidBody, http.StatusBadRequest, CodeInvalidBody},
+	{ErrNoMetricName, http.StatusNotFound, CodeNoMetricName},
+	{ErrNoMetricsType, http.StatusBadRequest, CodeInvalidType},
+	{ErrWrongValue, http.StatusBadRequest, CodeInvalidValue},
+	{metricsService.ErrNotFound, http.StatusNotFound, CodeMetricNotFound},
+	{metricsService.ErrInvalidCursor, http.StatusBadRequest, CodeInvalidQuery},
+	{metricsService.ErrInvalidSort, http.StatusBadRequest, CodeInvalidQuery},
+	{metricsService.ErrUnknownFormat, http.StatusBadRequest, CodeInvalidQuery},
+	{metricsService.ErrUnknownAggregation, http.StatusBadRequest, CodeInvalidQuery},
+	{metricsService.ErrSnapshotVersion, http.StatusBadRequest, CodeInvalidSnapshot},
+	{metricsService.ErrSnapshotChecksum, http.StatusBadRequest, CodeInvalidSnapshot},
+	{metricsService.ErrInvalidSnapshot, http.StatusBadRequest, CodeInvalidSnapshot},
+	{query.ErrLimitExceeded, http.StatusUnprocessableEntity, CodeQueryLimit},
+	{query.ErrTimeout, http.StatusServiceUnavailable, CodeQueryTimeout},
+	{query.ErrExecution, http.StatusUnprocessableEntity, CodeQueryFailed},
+	{storage.ErrUnknownMetricName, http.StatusNotFound, CodeMetricNotFound},
+	{storage.ErrUnknownMetricType, http.StatusBadRequest, CodeInvalidType},
+	{storage.ErrDatabaseConnection, http.StatusInternalServerError, CodeUnavailable},
+	{silence.ErrInvalidSilence, http.StatusBadRequest, CodeInvalidSilence},
+	{silence.ErrSilenceNotFound, http.StatusNotFound, CodeSilenceNotFound},
+	{replication.ErrGap, http.StatusGone, CodeReplicationGap},
+	{replication.ErrLogDisabled, http.StatusConflict, CodeNotLeader},
+	{cluster.ErrForward, http.StatusBadGateway, CodeForwardFailed},
+	{cluster.ErrNotClustered, http.StatusConflict, CodeNotClustered},
+	{relay.ErrNotRelay, http.StatusConflict, CodeNotRelay},
+	{auth.ErrUnknownScope, http.StatusBadRequest, CodeUnknownScope},
+	{auth.ErrTokenNotFound, http.StatusNotFound, CodeTokenNotFound},
+}
+
+// detailedError attaches details for APIError.Details to an error.
+type detailedError struct {
+	err     error
+	details any
+}
+
+func (e *detailedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *detailedError) Unwrap() error {
+	return e.err
+}
+
+func withDetails(err error, details any) error {
+	return &detailedError{err: err, details: details}
+}
+
+// invalidQuery marks err as a problem with a lookup request,
+// so that e.g. a missing metric name is answered with 400 instead of 404.
+func invalidQuery(err error) error {
+	return fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+}
+
+// errorResponse resolves the status and body for err.
+// Unknown errors are reported as 500 without exposing their text.
+func errorResponse(err error) (int, APIError) {
+	var details any
+	var detailed *detailedError
+	if errors.As(err, &detailed) {
+		details = detailed.details
+	}
+	if middleware.BodyTooLarge(err) {
+		err = middleware.ErrBodyTooLarge
+	}
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return m.status, APIError{Code: m.code, Message: err.Error(), Details: details}
+		}
+	}
+	return http.StatusInternalServerError, APIError{Code: CodeInternal, Message: "internal server error"}
+}
+
+// writeError logs err and answers with the JSON error envelope.
+func writeError(res http.ResponseWriter, err error) {
+	logger.LogError(err)
+	status, body := errorResponse(err)
+	writeJSON(res, status, body)
+}
+
+// writeTextError answers routes that speak plain text with the error message
+// and the same status as writeError. Clients that accept JSON get the envelope.
+func writeTextError(res http.ResponseWriter, req *http.Request, err error) {
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		writeError(res, err)
+		return
+	}
+	logger.LogError(err)
+	status, body := errorResponse(err)
+	http.Error(res, body.Message, status)
+}
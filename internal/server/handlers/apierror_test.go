@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Maxim-Ba/metriccollector/internal/server/auth"
+	"github.com/Maxim-Ba/metriccollector/internal/server/handlers/middleware"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/query"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantMsg    string
+	}{
+		{name: "Method not allowed", err: ErrMethodNotAllowed, wantStatus: http.StatusMethodNotAllowed, wantCode: CodeMethodNotAllowed, wantMsg: "not allowed method"},
+		{name: "No metric name", err: ErrNoMetricName, wantStatus: http.StatusNotFound, wantCode: CodeNoMetricName, wantMsg: "no name metrics"},
+		{name: "Wrong type", err: ErrNoMetricsType, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidType, wantMsg: "not allowed metric type"},
+		{name: "Wrong value", err: ErrWrongValue, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidValue, wantMsg: "wrong value"},
+		{name: "Invalid query wraps metric error", err: invalidQuery(ErrNoMetricName), wantStatus: http.StatusBadRequest, wantCode: CodeInvalidQuery, wantMsg: "invalid query: no name metrics"},
+		{name: "Body too large", err: fmt.Errorf("%w: %w", ErrInvalidBody, &http.MaxBytesError{Limit: 1}), wantStatus: http.StatusRequestEntityTooLarge, wantCode: CodeBodyTooLarge, wantMsg: middleware.ErrBodyTooLarge.Error()},
+		{name: "Metric not found", err: metricsService.ErrNotFound, wantStatus: http.StatusNotFound, wantCode: CodeMetricNotFound, wantMsg: "no metrics found"},
+		{name: "Unknown metric in storage", err: storage.ErrUnknownMetricName, wantStatus: http.StatusNotFound, wantCode: CodeMetricNotFound, wantMsg: "unknown metrics name"},
+		{name: "Token not found", err: auth.ErrTokenNotFound, wantStatus: http.StatusNotFound, wantCode: CodeTokenNotFound, wantMsg: auth.ErrTokenNotFound.Error()},
+		{name: "Not acceptable", err: ErrNotAcceptable, wantStatus: http.StatusNotAcceptable, wantCode: CodeNotAcceptable, wantMsg: ErrNotAcceptable.Error()},
+		{name: "Unknown export format", err: metricsService.ErrUnknownFormat, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidQuery, wantMsg: "unknown export format"},
+		{name: "Snapshot checksum", err: metricsService.ErrSnapshotChecksum, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidSnapshot, wantMsg: "snapshot checksum mismatch"},
+		{name: "Query limit", err: query.ErrTooManySeries, wantStatus: http.StatusUnprocessableEntity, wantCode: CodeQueryLimit, wantMsg: "query limit exceeded: too many series"},
+		{name: "Query timeout", err: query.ErrTimeout, wantStatus: http.StatusServiceUnavailable, wantCode: CodeQueryTimeout, wantMsg: "query timed out"},
+		{name: "Unknown error hides text", err: errors.New("connection refused"), wantStatus: http.StatusInternalServerError, wantCode: CodeInternal, wantMsg: "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := errorResponse(tt.err)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantCode, body.Code)
+			assert.Equal(t, tt.wantMsg, body.Message)
+		})
+	}
+}
+
+func TestWriteTextError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/update/gauge/x/abc", nil)
+	rec := httptest.NewRecorder()
+	writeTextError(rec, req, ErrWrongValue)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "wrong value\n", rec.Body.String())
+
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	writeTextError(rec, req, withDetails(ErrWrongValue, map[string]string{"value": "abc"}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var body APIError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, APIError{Code: CodeInvalidValue, Message: "wrong value", Details: map[string]any{"value": "abc"}}, body)
+}
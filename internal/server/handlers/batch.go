@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+)
+
+// Batch modes for UpdatesHandler.
+const (
+	// BatchAllOrNothing applies a batch only when every metric in it is valid.
+	BatchAllOrNothing = "all-or-nothing"
+	// BatchBestEffort applies valid metrics and skips invalid ones.
+	BatchBestEffort = "best-effort"
+)
+
+const defaultBatchChunkSize = 1000
+
+// preferLenient is the RFC 7240 preference a client sends to get valid
+// metrics applied and a report about the rejected ones.
+const preferLenient = "handling=lenient"
+
+type batchOptions struct {
+	mode      string
+	chunkSize int
+}
+
+var updatesOptions = batchOptions{mode: BatchAllOrNothing, chunkSize: defaultBatchChunkSize}
+
+// SetBatchOptions configures how UpdatesHandler applies batches.
+// Parameters:
+//   - mode: BatchAllOrNothing or BatchBestEffort, empty keeps all-or-nothing
+//   - chunkSize: how many metrics are passed to storage at once in best-effort mode,
+//     defaults to 1000 when not positive; an all-or-nothing batch is saved at once
+//
+// Returns:
+//   - error: ErrUnknownBatchMode for any other mode
+func SetBatchOptions(mode string, chunkSize int) error {
+	if mode == "" {
+		mode = BatchAllOrNothing
+	}
+	if mode != BatchAllOrNothing && mode != BatchBestEffort {
+		return ErrUnknownBatchMode
+	}
+	if chunkSize <= 0 {
+		chunkSize = defaultBatchChunkSize
+	}
+	updatesOptions = batchOptions{mode: mode, chunkSize: chunkSize}
+	return nil
+}
+
+// prefersLenient reports whether the request asks for lenient handling.
+func prefersLenient(req *http.Request) bool {
+	for _, header := range req.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), preferLenient) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// saveFunc saves a chunk of a batch, see saveTo and saveToOwners.
+type saveFunc func(chunk *[]metrics.Metrics) error
+
+// saveTo saves chunks to s.
+func saveTo(s metricsService.Storage) saveFunc {
+	return func(chunk *[]metrics.Metrics) error {
+		return metricsService.UpdateMany(s, chunk)
+	}
+}
+
+// applyBatch decodes a JSON array of metrics from r and saves it with save
+// according to opts.
+// In best-effort mode valid metrics are saved in chunks as they are decoded,
+// invalid ones are skipped and listed in the report. A malformed document
+// stops the batch, but chunks saved before that are kept.
+// In all-or-nothing mode metrics are folded into a staging area (gauges by last
+// value, counters by sum, labels by last), which grows with the number of distinct metrics
+// rather than the size of the batch, and saved in one call of save only
+// after the whole body is decoded and valid. On a cluster node save forwards
+// the metrics of other owners first, see saveToOwners: a failed forward
+// leaves the local metrics unsaved but does not undo earlier forwards.
+func applyBatch(r io.Reader, save saveFunc, opts batchOptions) (metrics.BatchReport, error) {
+	result := metrics.BatchReport{Rejected: []metrics.RejectedMetric{}}
+	if opts.mode == BatchBestEffort {
+		chunk := make([]metrics.Metrics, 0, opts.chunkSize)
+		flush := func() error {
+			if len(chunk) == 0 {
+				return nil
+			}
+			if err := save(&chunk); err != nil {
+				return err
+			}
+			result.Accepted += len(chunk)
+			chunk = chunk[:0]
+			return nil
+		}
+		err := decodeMetrics(r, func(i int, m *metrics.Metrics, err error) error {
+			if err != nil {
+				logger.LogError(err)
+				result.Rejected = append(result.Rejected, metrics.RejectedMetric{Index: i, ID: m.ID, Reason: err.Error()})
+				return nil
+			}
+			chunk = append(chunk, *m)
+			if len(chunk) == opts.chunkSize {
+				return flush()
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+		return result, flush()
+	}
+
+	staged := newStagedBatch()
+	err := decodeMetrics(r, func(i int, m *metrics.Metrics, err error) error {
+		if err != nil {
+			return withDetails(err, metrics.RejectedMetric{Index: i, ID: m.ID, Reason: err.Error()})
+		}
+		staged.add(m)
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if err = staged.commit(save); err != nil {
+		return result, err
+	}
+	result.Accepted = staged.count
+	return result, nil
+}
+
+// decodeMetrics reads a JSON array of metrics one element at a time
+// and passes every element to visit together with its validation error.
+// Decoding stops at the first error returned by visit.
+// Malformed JSON is reported as ErrNoMetricName, errors of the underlying
+// reader (e.g. a body over the size limit) are returned as is.
+func decodeMetrics(r io.Reader, visit func(i int, m *metrics.Metrics, err error) error) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return decodeError(err)
+	}
+	if tok == nil {
+		// null - пустой батч
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return ErrNoMetricName
+	}
+	for i := 0; dec.More(); i++ {
+		var m metrics.Metrics
+		if err = dec.Decode(&m); err != nil {
+			return decodeError(err)
+		}
+		if err = visit(i, &m, validateMetric(&m)); err != nil {
+			return err
+		}
+	}
+	if _, err = dec.Token(); err != nil {
+		return decodeError(err)
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return ErrNoMetricName
+	}
+	return err
+}
+
+func validateMetric(m *metrics.Metrics) error {
+	if m.MType != constants.Gauge && m.MType != constants.Counter {
+		return ErrNoMetricsType
+	}
+	if m.ID == "" {
+		return ErrNoMetricName
+	}
+	if (m.MType == constants.Gauge && m.Value == nil) || (m.MType == constants.Counter && m.Delta == nil) {
+		return ErrWrongValue
+	}
+	return nil
+}
+
+// stagedBatch accumulates an all-or-nothing batch before it is saved.
+// Metrics are kept by type and ID: a gauge keeps its last value, a counter
+// the sum of its deltas, and both the last labels passed.
+type stagedBatch struct {
+	gauges   map[string]metrics.Metrics
+	counters map[string]metrics.Metrics
+	count    int
+}
+
+func newStagedBatch() *stagedBatch {
+	return &stagedBatch{gauges: map[string]metrics.Metrics{}, counters: map[string]metrics.Metrics{}}
+}
+
+func (b *stagedBatch) add(m *metrics.Metrics) {
+	b.count++
+	staged := b.gauges
+	if m.MType == constants.Counter {
+		staged = b.counters
+	}
+	prev, ok := staged[m.ID]
+	next := metrics.Metrics{ID: m.ID, MType: m.MType, Labels: m.Labels}
+	if ok && next.Labels == nil {
+		// метки без новых значений сохраняются, как при последовательных обновлениях
+		next.Labels = prev.Labels
+	}
+	if m.MType == constants.Gauge {
+		value := *m.Value
+		next.Value = &value
+	} else {
+		delta := *m.Delta
+		if ok {
+			delta += *prev.Delta
+		}
+		next.Delta = &delta
+	}
+	staged[m.ID] = next
+}
+
+// commit saves the staged metrics in one call of save, so storage applies
+// them together or not at all.
+func (b *stagedBatch) commit(save saveFunc) error {
+	if b.count == 0 {
+		return nil
+	}
+	all := make([]metrics.Metrics, 0, len(b.gauges)+len(b.counters))
+	for _, m := range b.gauges {
+		all = append(all, m)
+	}
+	for _, m := range b.counters {
+		all = append(all, m)
+	}
+	return save(&all)
+}
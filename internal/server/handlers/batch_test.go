@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyBatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		input        string
+		wantErr      error
+		wantResult   metrics.BatchReport
+		wantGauges   map[string]float64
+		wantCounters map[string]int64
+	}{
+		{
+			name:         "All-or-nothing valid batch",
+			mode:         BatchAllOrNothing,
+			input:        `[{"id":"g","type":"gauge","value":1},{"id":"c","type":"counter","delta":2},{"id":"g","type":"gauge","value":3},{"id":"c","type":"counter","delta":5}]`,
+			wantResult:   metrics.BatchReport{Accepted: 4, Rejected: []metrics.RejectedMetric{}},
+			wantGauges:   map[string]float64{"g": 3},
+			wantCounters: map[string]int64{"c": 7},
+		},
+		{
+			name:    "All-or-nothing rejects batch with invalid metric",
+			mode:    BatchAllOrNothing,
+			input:   `[{"id":"g","type":"gauge","value":1},{"id":"x","type":"unknown","value":1}]`,
+			wantErr: ErrNoMetricsType,
+		},
+		{
+			name:    "All-or-nothing rejects truncated body",
+			mode:    BatchAllOrNothing,
+			input:   `[{"id":"g","type":"gauge","value":1},{"id":"c","type":"cou`,
+			wantErr: ErrNoMetricName,
+		},
+		{
+			name:  "Best-effort skips invalid metrics",
+			mode:  BatchBestEffort,
+			input: `[{"id":"g","type":"gauge","value":1},{"id":"","type":"gauge","value":1},{"id":"c","type":"counter"},{"id":"c","type":"counter","delta":2}]`,
+			wantResult: metrics.BatchReport{Accepted: 2, Rejected: []metrics.RejectedMetric{
+				{Index: 1, ID: "", Reason: ErrNoMetricName.Error()},
+				{Index: 2, ID: "c", Reason: ErrWrongValue.Error()},
+			}},
+			wantGauges:   map[string]float64{"g": 1},
+			wantCounters: map[string]int64{"c": 2},
+		},
+		{
+			name:         "Best-effort keeps chunks saved before malformed JSON",
+			mode:         BatchBestEffort,
+			input:        `[{"id":"g","type":"gauge","value":1},{"id":"h","type":"gauge","value":2},{"id":"c",`,
+			wantErr:      ErrNoMetricName,
+			wantResult:   metrics.BatchReport{Accepted: 2, Rejected: []metrics.RejectedMetric{}},
+			wantGauges:   map[string]float64{"g": 1, "h": 2},
+			wantCounters: map[string]int64{},
+		},
+		{
+			name:         "Null body",
+			mode:         BatchAllOrNothing,
+			input:        `null`,
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage.StorageInstance.ClearAll()
+
+			result, err := applyBatch(strings.NewReader(tt.input), saveTo(storage.StorageInstance), batchOptions{mode: tt.mode, chunkSize: 2})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			if tt.wantResult.Rejected != nil {
+				assert.Equal(t, tt.wantResult, result)
+			}
+
+			empty := []*metrics.MetricDTOParams{}
+			stored, err := storage.StorageInstance.GetMetrics(&empty)
+			require.NoError(t, err)
+			gauges := map[string]float64{}
+			counters := map[string]int64{}
+			for _, m := range *stored {
+				if m.MType == constants.Gauge {
+					gauges[m.ID] = *m.Value
+				} else {
+					counters[m.ID] = *m.Delta
+				}
+			}
+			if tt.wantGauges != nil {
+				assert.Equal(t, tt.wantGauges, gauges)
+				assert.Equal(t, tt.wantCounters, counters)
+			}
+			if tt.wantErr != nil && tt.mode == BatchAllOrNothing {
+				assert.Empty(t, *stored)
+			}
+		})
+	}
+}
+
+func TestApplyBatch_Labels(t *testing.T) {
+	input := `[
+		{"id":"g","type":"gauge","value":1,"labels":{"host":"a"}},
+		{"id":"g","type":"gauge","value":2},
+		{"id":"c","type":"counter","delta":2,"labels":{"host":"a"}},
+		{"id":"c","type":"counter","delta":3,"labels":{"host":"b"}}
+	]`
+	for _, mode := range []string{BatchAllOrNothing, BatchBestEffort} {
+		t.Run(mode, func(t *testing.T) {
+			storage.StorageInstance.ClearAll()
+
+			_, err := applyBatch(strings.NewReader(input), saveTo(storage.StorageInstance), batchOptions{mode: mode, chunkSize: 2})
+			require.NoError(t, err)
+
+			params := []*metrics.MetricDTOParams{
+				{MetricType: constants.Gauge, MetricsName: "g"},
+				{MetricType: constants.Counter, MetricsName: "c"},
+			}
+			stored, err := storage.StorageInstance.GetMetrics(&params)
+			require.NoError(t, err)
+			require.Len(t, *stored, 2)
+			assert.Equal(t, 2.0, *(*stored)[0].Value)
+			assert.Equal(t, map[string]string{"host": "a"}, (*stored)[0].Labels)
+			assert.Equal(t, int64(5), *(*stored)[1].Delta)
+			assert.Equal(t, map[string]string{"host": "b"}, (*stored)[1].Labels)
+		})
+	}
+}
+
+func TestApplyBatch_AllOrNothingSavesOnce(t *testing.T) {
+	var saved [][]metrics.Metrics
+	save := func(chunk *[]metrics.Metrics) error {
+		saved = append(saved, append([]metrics.Metrics(nil), *chunk...))
+		return errors.New("storage unavailable")
+	}
+
+	_, err := applyBatch(bytes.NewReader(largeBatch(10)), save, batchOptions{mode: BatchAllOrNothing, chunkSize: 2})
+	assert.Error(t, err)
+	require.Len(t, saved, 1)
+	assert.Len(t, saved[0], 10)
+}
+
+func TestSetBatchOptions(t *testing.T) {
+	original := updatesOptions
+	defer func() {
+		updatesOptions = original
+	}()
+
+	require.NoError(t, SetBatchOptions("", 0))
+	assert.Equal(t, batchOptions{mode: BatchAllOrNothing, chunkSize: defaultBatchChunkSize}, updatesOptions)
+
+	require.NoError(t, SetBatchOptions(BatchBestEffort, 10))
+	assert.Equal(t, batchOptions{mode: BatchBestEffort, chunkSize: 10}, updatesOptions)
+
+	assert.ErrorIs(t, SetBatchOptions("sometimes", 10), ErrUnknownBatchMode)
+}
+
+// largeBatch builds a JSON batch of n metrics over 1000 distinct names.
+func largeBatch(n int) []byte {
+	batch := make([]metrics.Metrics, n)
+	for i := range batch {
+		if i%2 == 0 {
+			batch[i] = metrics.Metrics{ID: fmt.Sprintf("gauge%d", i%1000), MType: constants.Gauge, Value: utils.FloatToPointerFloat(float64(i))}
+		} else {
+			batch[i] = metrics.Metrics{ID: fmt.Sprintf("counter%d", i%1000), MType: constants.Counter, Delta: utils.FloatToPointerInt(int64(i))}
+		}
+	}
+	body, _ := json.Marshal(batch)
+	return body
+}
+
+// bufferedBatch is the previous implementation of UpdatesHandler:
+// the whole body is read and unmarshalled before it is validated and saved.
+func bufferedBatch(r io.Reader) error {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return err
+	}
+	var metricsSlice []metrics.Metrics
+	if err := json.Unmarshal(buf.Bytes(), &metricsSlice); err != nil {
+		return err
+	}
+	for i := range metricsSlice {
+		if err := validateMetric(&metricsSlice[i]); err != nil {
+			return err
+		}
+	}
+	return metricsService.UpdateMany(storage.StorageInstance, &metricsSlice)
+}
+
+// Сравнение потребления памяти:
+//
+//	go test -run=^$ -bench=Batch -benchmem ./internal/server/handlers/
+func BenchmarkBatch(b *testing.B) {
+	body := largeBatch(100_000)
+
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := bufferedBatch(bytes.NewReader(body)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	for _, mode := range []string{BatchAllOrNothing, BatchBestEffort} {
+		b.Run("streaming "+mode, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := applyBatch(bytes.NewReader(body), saveTo(storage.StorageInstance), batchOptions{mode: mode, chunkSize: defaultBatchChunkSize})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdatesHandler_Lenient(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+
+	body := `[{"id":"g","type":"gauge","value":1},{"id":"bad","type":"histogram","value":1},{"id":"c","type":"counter","delta":3}]`
+	req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	req.Header.Set("Prefer", "respond-async, handling=lenient")
+	rec := httptest.NewRecorder()
+
+	UpdatesHandler(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, preferLenient, rec.Header().Get("Preference-Applied"))
+	var report metrics.BatchReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, metrics.BatchReport{
+		Accepted: 2,
+		Rejected: []metrics.RejectedMetric{{Index: 1, ID: "bad", Reason: ErrNoMetricsType.Error()}},
+	}, report)
+
+	// без Prefer батч с ошибкой отклоняется целиком с указанием метрики
+	storage.StorageInstance.ClearAll()
+	req = httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+
+	UpdatesHandler(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"code":"invalid_metric_type","message":"not allowed metric type","details":{"index":1,"id":"bad","reason":"not allowed metric type"}}`, rec.Body.String())
+}
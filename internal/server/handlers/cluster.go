@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/cluster"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// selectorParams are the query parameters of ListMetricsHandler passed
+// to the peers, paging and sorting are applied to the gathered metrics.
+var selectorParams = []string{"type", "prefix", "regex", "label"}
+
+// clustered reports whether req has to be routed within the cluster:
+// the server is a cluster node and the request came from a client.
+func clustered(req *http.Request) bool {
+	return cluster.Instance != nil && !cluster.Instance.IsForwarded(req)
+}
+
+// markNodeLocal marks the response of a read served from the metrics of
+// this node alone. Single metrics, ListMetricsHandler and batches are routed
+// within the cluster, the other reads depend on the history, anomalies and
+// updates kept by the owner of a metric and answer for the local shard.
+func markNodeLocal(res http.ResponseWriter) {
+	if cluster.Instance != nil {
+		res.Header().Set(cluster.ShardHeader, cluster.Instance.Self())
+	}
+}
+
+// routeToOwner passes the request to the node owning the metric
+// and reports whether it did, the caller serves it otherwise.
+// Parameters:
+//   - id: metric ID
+//   - body: request body already read by the handler, nil if it was not read
+func routeToOwner(res http.ResponseWriter, req *http.Request, id string, body []byte) bool {
+	if !clustered(req) {
+		return false
+	}
+	owner := cluster.Instance.Owner(id)
+	if owner == cluster.Instance.Self() {
+		return false
+	}
+	cluster.Instance.Proxy(res, req, owner, body)
+	return true
+}
+
+// saveToOwners saves the metrics of a chunk owned by this node to s and
+// forwards the others to their owners. A batch is atomic on every node,
+// but a failed forward does not undo what other nodes saved.
+func saveToOwners(req *http.Request, s metricsService.Storage) saveFunc {
+	return func(chunk *[]metrics.Metrics) error {
+		local, remote := cluster.Instance.Split(*chunk)
+		for node, ms := range remote {
+			if err := cluster.Instance.Forward(req.Context(), node, ms, req.Header.Get("Authorization")); err != nil {
+				return err
+			}
+		}
+		if len(local) == 0 {
+			return nil
+		}
+		return metricsService.UpdateMany(s, &local)
+	}
+}
+
+// listCluster lists the metrics of the whole cluster.
+func listCluster(req *http.Request, q metricsService.ListQuery) (*metricsService.Page, error) {
+	filter := url.Values{}
+	values := req.URL.Query()
+	for _, key := range selectorParams {
+		if v, ok := values[key]; ok {
+			filter[key] = v
+		}
+	}
+	gathered, err := cluster.Instance.Gather(req.Context(), storage.StorageInstance, filter, req.Header.Get("Authorization"))
+	if err != nil {
+		return nil, err
+	}
+	return metricsService.List(gathered, q)
+}
+
+// ClusterRebalanceHandler handles POST /cluster/rebalance.
+// Hands the metrics of the node owned by other nodes to their owners,
+// run it on every node after the membership changed.
+// Query parameters:
+//   - dry_run: true to only count the metrics to move
+//
+// Returns a cluster.RebalanceResult as JSON.
+// A standalone server answers 409 with the code not_clustered.
+func ClusterRebalanceHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("ClusterRebalanceHandler")
+	if cluster.Instance == nil {
+		writeError(res, cluster.ErrNotClustered)
+		return
+	}
+	dryRun := false
+	if d := req.URL.Query().Get("dry_run"); d != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(d); err != nil {
+			writeError(res, invalidQuery(fmt.Errorf("dry_run %q is not a boolean", d)))
+			return
+		}
+	}
+	result, err := cluster.Instance.Rebalance(req.Context(), storage.StorageInstance, dryRun, req.Header.Get("Authorization"))
+	if err != nil {
+		writeError(res, withDetails(err, result))
+		return
+	}
+	writeJSON(res, http.StatusOK, result)
+}
+
+// ClusterHandoffHandler handles POST /cluster/handoff.
+// Accepts a JSON array of metrics handed off by their previous owner,
+// identified by the X-Cluster-Handoff header, see Cluster.Accept.
+// Returns a cluster.HandoffResult as JSON.
+// A standalone server answers 409 with the code not_clustered.
+func ClusterHandoffHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("ClusterHandoffHandler")
+	if cluster.Instance == nil {
+		writeError(res, cluster.ErrNotClustered)
+		return
+	}
+	var ms []metrics.Metrics
+	if err := json.NewDecoder(req.Body).Decode(&ms); err != nil {
+		writeError(res, fmt.Errorf("%w: %w", ErrInvalidBody, err))
+		return
+	}
+	for i := range ms {
+		if err := validateMetric(&ms[i]); err != nil {
+			writeError(res, withDetails(err, metrics.RejectedMetric{Index: i, ID: ms[i].ID, Reason: err.Error()}))
+			return
+		}
+	}
+	result, err := cluster.Instance.Accept(storage.StorageInstance, req.Header.Get(cluster.HandoffHeader), ms)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, result)
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/cluster"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+// clusterSelf is the name of the node under test, nothing listens on it.
+const clusterSelf = "http://127.0.0.1:1"
+
+// clusterSecret is the secret shared by the test cluster.
+const clusterSecret = "cluster-secret"
+
+// clusterPeer records the requests forwarded to the other node.
+type clusterPeer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	paths  []string
+	bodies []string
+}
+
+func newClusterPeer(t *testing.T) *clusterPeer {
+	p := &clusterPeer{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		p.mu.Lock()
+		p.paths = append(p.paths, r.Method+" "+r.URL.Path)
+		p.bodies = append(p.bodies, string(body))
+		p.mu.Unlock()
+		if r.URL.Path == "/api/v1/metrics" {
+			writeJSON(res, http.StatusOK, metricsService.Page{Metrics: []metrics.Metrics{{ID: "Remote", MType: constants.Gauge, Value: utils.FloatToPointerFloat(7)}}})
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// useCluster makes the server a node of a two node cluster with p.
+func useCluster(t *testing.T, p *clusterPeer) {
+	c, err := cluster.New([]string{clusterSelf, p.URL}, clusterSelf, clusterSecret)
+	require.NoError(t, err)
+	original := cluster.Instance
+	cluster.Instance = c
+	t.Cleanup(func() {
+		cluster.Instance = original
+	})
+}
+
+// ownedBy returns a metric ID owned by node.
+func ownedBy(node string) string {
+	for i := 0; ; i++ {
+		id := "metric-" + strconv.Itoa(i)
+		if cluster.Instance.Owner(id) == node {
+			return id
+		}
+	}
+}
+
+func TestUpdatesHandler_Cluster(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	p := newClusterPeer(t)
+	useCluster(t, p)
+	local, remote := ownedBy(clusterSelf), ownedBy(p.URL)
+	body := `[{"id":"` + local + `","type":"gauge","value":1},{"id":"` + remote + `","type":"counter","delta":2}]`
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer agent")
+	rec := httptest.NewRecorder()
+	UpdatesHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	all, err := storage.StorageInstance.GetMetrics(&[]*metrics.MetricDTOParams{})
+	require.NoError(t, err)
+	require.Len(t, *all, 1)
+	assert.Equal(t, local, (*all)[0].ID)
+	require.Equal(t, []string{"POST /updates/"}, p.paths)
+	var forwarded []metrics.Metrics
+	require.NoError(t, json.Unmarshal([]byte(p.bodies[0]), &forwarded))
+	require.Len(t, forwarded, 1)
+	assert.Equal(t, remote, forwarded[0].ID)
+
+	// заголовок пересылки без секрета кластера игнорируется
+	req = httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	req.Header.Set(cluster.ForwardedHeader, p.URL)
+	rec = httptest.NewRecorder()
+	UpdatesHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	all, err = storage.StorageInstance.GetMetrics(&[]*metrics.MetricDTOParams{})
+	require.NoError(t, err)
+	assert.Len(t, *all, 1)
+	assert.Len(t, p.paths, 2)
+
+	// пересланный батч сохраняется целиком, даже если узел им не владеет
+	req = httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	req.Header.Set(cluster.ForwardedHeader, p.URL)
+	req.Header.Set(cluster.SecretHeader, clusterSecret)
+	rec = httptest.NewRecorder()
+	UpdatesHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	all, err = storage.StorageInstance.GetMetrics(&[]*metrics.MetricDTOParams{})
+	require.NoError(t, err)
+	assert.Len(t, *all, 2)
+	assert.Len(t, p.paths, 2)
+
+	p.Close()
+	rec = httptest.NewRecorder()
+	UpdatesHandler(rec, httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Contains(t, rec.Body.String(), CodeForwardFailed)
+}
+
+func TestMetricHandlers_Cluster(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	p := newClusterPeer(t)
+	useCluster(t, p)
+	local, remote := ownedBy(clusterSelf), ownedBy(p.URL)
+	require.NoError(t, storage.StorageInstance.SaveMetric(&metrics.Metrics{ID: local, MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)}))
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/metrics", ListMetricsHandler)
+	r.Get("/api/v1/metrics/{type}/{name}", GetMetricHandler)
+	r.Put("/api/v1/metrics/{type}/{name}", PutMetricHandler)
+	r.Post("/update/", UpdateHandler)
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		wantCode int
+		wantPath string
+	}{
+		{name: "Local read", method: http.MethodGet, target: "/api/v1/metrics/gauge/" + local, wantCode: http.StatusOK},
+		{name: "Remote read", method: http.MethodGet, target: "/api/v1/metrics/gauge/" + remote, wantCode: http.StatusOK, wantPath: "GET /api/v1/metrics/gauge/" + remote},
+		{name: "Remote put", method: http.MethodPut, target: "/api/v1/metrics/gauge/" + remote, body: `{"value":2}`, wantCode: http.StatusOK, wantPath: "PUT /api/v1/metrics/gauge/" + remote},
+		{name: "Remote JSON update", method: http.MethodPost, target: "/update/", body: `{"id":"` + remote + `","type":"gauge","value":3}`, wantCode: http.StatusOK, wantPath: "POST /update/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.mu.Lock()
+			p.paths, p.bodies = nil, nil
+			p.mu.Unlock()
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantPath == "" {
+				assert.Empty(t, p.paths)
+				return
+			}
+			require.Equal(t, []string{tt.wantPath}, p.paths)
+			assert.Equal(t, tt.body, p.bodies[0])
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metrics?sort=name", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page metricsService.Page
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	ids := []string{}
+	for _, m := range page.Metrics {
+		ids = append(ids, m.ID)
+	}
+	assert.ElementsMatch(t, []string{local, "Remote"}, ids)
+}
+
+func TestNodeLocalReads_Cluster(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+
+	reads := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+	}{
+		{name: "Aggregate", handler: AggregateHandler, target: "/api/v1/aggregate?op=count"},
+		{name: "Derived", handler: DerivedHandler, target: "/api/v1/derived"},
+		{name: "Prometheus", handler: PrometheusHandler, target: "/metrics"},
+	}
+	for _, tt := range reads {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Empty(t, rec.Header().Get(cluster.ShardHeader))
+
+			p := newClusterPeer(t)
+			useCluster(t, p)
+			rec = httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, clusterSelf, rec.Header().Get(cluster.ShardHeader))
+			assert.Empty(t, p.paths)
+		})
+	}
+}
+
+func TestClusterHandlers_NotClustered(t *testing.T) {
+	original := cluster.Instance
+	defer func() {
+		cluster.Instance = original
+	}()
+	cluster.Instance = nil
+
+	for _, handler := range []http.HandlerFunc{ClusterRebalanceHandler, ClusterHandoffHandler} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/cluster/rebalance", strings.NewReader("[]")))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), CodeNotClustered)
+	}
+}
+
+func TestClusterHandoffHandler(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	useCluster(t, newClusterPeer(t))
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "Accepted", body: `[{"id":"Alloc","type":"gauge","value":1},{"id":"PollCount","type":"counter","delta":2}]`, wantCode: http.StatusOK, wantBody: `{"accepted":2,"skipped":0}`},
+		{name: "Gauge kept", body: `[{"id":"Alloc","type":"gauge","value":5}]`, wantCode: http.StatusOK, wantBody: `{"accepted":0,"skipped":1}`},
+		{name: "Broken JSON", body: `[{`, wantCode: http.StatusBadRequest, wantBody: CodeInvalidBody},
+		{name: "Invalid metric", body: `[{"id":"Alloc","type":"gauge"}]`, wantCode: http.StatusBadRequest, wantBody: CodeInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ClusterHandoffHandler(rec, httptest.NewRequest(http.MethodPost, "/cluster/handoff", strings.NewReader(tt.body)))
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestClusterRebalanceHandler(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	p := newClusterPeer(t)
+	useCluster(t, p)
+	require.NoError(t, storage.StorageInstance.SaveMetric(&metrics.Metrics{ID: ownedBy(p.URL), MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)}))
+
+	rec := httptest.NewRecorder()
+	ClusterRebalanceHandler(rec, httptest.NewRequest(http.MethodPost, "/cluster/rebalance?dry_run=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	ClusterRebalanceHandler(rec, httptest.NewRequest(http.MethodPost, "/cluster/rebalance?dry_run=true", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"dry_run":true,"moved":{"`+p.URL+`":1},"kept":0}`, rec.Body.String())
+	assert.Empty(t, p.paths)
+}
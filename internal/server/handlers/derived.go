@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+var rateWindow = metricsService.DefaultRateWindow
+
+// SetRateWindow configures the default window of the derived counter gauges.
+// Parameters:
+//   - seconds: window length, metricsService.DefaultRateWindow when not positive
+func SetRateWindow(seconds int) {
+	rateWindow = metricsService.DefaultRateWindow
+	if seconds > 0 {
+		rateWindow = time.Duration(seconds) * time.Second
+	}
+}
+
+// DerivedHandler handles GET /api/v1/derived.
+// Returns the read-only <counter>_rate and <counter>_increase gauges
+// computed from the counter history as a JSON array.
+// Query parameters:
+//   - prefix, regex, label: counter selection as in ListMetricsHandler
+//   - window: Go duration, the configured rate window by default
+func DerivedHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("DerivedHandler")
+	markNodeLocal(res)
+	sel, err := parseSelector(req)
+	if err != nil {
+		writeError(res, invalidQuery(err))
+		return
+	}
+	window := rateWindow
+	if w := req.URL.Query().Get("window"); w != "" {
+		window, err = time.ParseDuration(w)
+		if err != nil || window <= 0 {
+			writeError(res, invalidQuery(fmt.Errorf("window %q is not a positive duration", w)))
+			return
+		}
+	}
+	derived, err := metricsService.Derived(storage.StorageInstance, sel, window, time.Now())
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, derived)
+}
+
+// PrometheusHandler handles GET /metrics.
+// Exposes all metrics and the derived counter gauges in the Prometheus
+// text format, so the server can be scraped directly.
+func PrometheusHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("PrometheusHandler")
+	markNodeLocal(res)
+	res.Header().Set("Content-Type", metricsService.PrometheusContentType)
+	res.WriteHeader(http.StatusOK)
+	err := metricsService.WritePrometheus(res, storage.StorageInstance, rateWindow, time.Now())
+	if err != nil {
+		// статус уже отправлен
+		logger.LogError(err)
+	}
+}
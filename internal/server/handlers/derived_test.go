@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestDerivedHandler(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	history.Instance.Reset()
+	defer storage.StorageInstance.ClearAll()
+	defer history.Instance.Reset()
+	for _, delta := range []int64{1, 3} {
+		require.NoError(t, metricsService.Update(storage.StorageInstance, &metrics.Metrics{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(delta)}))
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		want     []string
+	}{
+		{name: "Default window", target: "/api/v1/derived", wantCode: http.StatusOK, want: []string{"PollCount_increase", "PollCount_rate"}},
+		{name: "Filtered out", target: "/api/v1/derived?prefix=Heap", wantCode: http.StatusOK, want: []string{}},
+		{name: "Broken window", target: "/api/v1/derived?window=soon", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			DerivedHandler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var derived []metrics.Metrics
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &derived))
+			ids := []string{}
+			for _, m := range derived {
+				ids = append(ids, m.ID)
+				assert.Equal(t, constants.Gauge, m.MType)
+			}
+			assert.Equal(t, tt.want, ids)
+			if len(derived) > 0 {
+				assert.Equal(t, 3.0, *derived[0].Value)
+			}
+		})
+	}
+}
+
+func TestSetRateWindow(t *testing.T) {
+	defer SetRateWindow(0)
+	SetRateWindow(60)
+	assert.Equal(t, time.Minute, rateWindow)
+	SetRateWindow(-1)
+	assert.Equal(t, metricsService.DefaultRateWindow, rateWindow)
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	require.NoError(t, storage.StorageInstance.SaveMetric(&metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(2.5)}))
+
+	rec := httptest.NewRecorder()
+	PrometheusHandler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, metricsService.PrometheusContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "# TYPE Alloc gauge\nAlloc 2.5\n", rec.Body.String())
+}
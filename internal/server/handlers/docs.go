@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/server/openapi"
+)
+
+// OpenAPIHandler handles GET /openapi.json.
+// Returns the OpenAPI 3 document describing the server routes.
+func OpenAPIHandler(res http.ResponseWriter, req *http.Request) {
+	writeStatic(res, "application/json", openapi.Spec)
+}
+
+// DocsHandler handles GET /docs.
+// Returns an HTML page rendering the OpenAPI document.
+func DocsHandler(res http.ResponseWriter, req *http.Request) {
+	writeStatic(res, "text/html; charset=utf-8", openapi.Viewer)
+}
+
+func writeStatic(res http.ResponseWriter, contentType string, body []byte) {
+	res.Header().Set("Content-Type", contentType)
+	res.WriteHeader(http.StatusOK)
+	if _, err := res.Write(body); err != nil {
+		logger.LogError(err)
+	}
+}
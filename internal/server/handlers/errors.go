@@ -9,4 +9,9 @@ import (
 var ErrNoMetricName = errors.New("no name metrics")
 var ErrNoMetricsType = errors.New("not allowed metric type")
 var ErrWrongValue = errors.New("wrong value")
+var ErrUnknownBatchMode = errors.New("unknown batch mode")
+var ErrMethodNotAllowed = errors.New("not allowed method")
+var ErrInvalidQuery = errors.New("invalid query")
+var ErrInvalidBody = errors.New("invalid request body")
 var ErrWrongBodyEncoding = middleware.ErrWrongBodyEncoding
+var ErrNotAcceptable = errors.New("none of the accepted media types can be produced")
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// exportMediaTypes maps media types of the Accept header to export formats.
+var exportMediaTypes = map[string]string{
+	"text/csv":             metricsService.FormatCSV,
+	"application/x-ndjson": metricsService.FormatNDJSON,
+	"application/ndjson":   metricsService.FormatNDJSON,
+	"application/json":     metricsService.FormatNDJSON,
+	"*/*":                  metricsService.FormatNDJSON,
+}
+
+var exportContentTypes = map[string]string{
+	metricsService.FormatCSV:    "text/csv; charset=utf-8",
+	metricsService.FormatNDJSON: "application/x-ndjson",
+}
+
+// ExportHandler handles GET /api/v1/export.
+// Streams the current metrics, or history samples when a time range is given,
+// as CSV or newline-delimited JSON.
+// Query parameters:
+//   - format: csv or ndjson, takes precedence over the Accept header
+//   - from, to: RFC 3339 bounds of the exported history samples
+//   - history: true to export all history samples
+//
+// Without format and Accept the export is NDJSON.
+// Responds with 406 when no accepted media type can be produced.
+func ExportHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("ExportHandler")
+	markNodeLocal(res)
+	format, err := exportFormat(req)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	samples, r, err := parseTimeRange(req)
+	if err != nil {
+		writeError(res, invalidQuery(err))
+		return
+	}
+
+	name := "metrics"
+	if samples {
+		name = "history"
+	}
+	res.Header().Set("Content-Type", exportContentTypes[format])
+	res.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	res.WriteHeader(http.StatusOK)
+	if samples {
+		err = metricsService.ExportHistory(res, storage.StorageInstance, format, r)
+	} else {
+		err = metricsService.Export(res, storage.StorageInstance, format)
+	}
+	if err != nil {
+		// статус уже отправлен, обрываем выгрузку
+		logger.LogError(err)
+	}
+}
+
+// exportFormat picks the format from the format parameter or the first
+// supported media type of the Accept header.
+func exportFormat(req *http.Request) (string, error) {
+	if format := req.URL.Query().Get("format"); format != "" {
+		if _, ok := exportContentTypes[format]; !ok {
+			return "", invalidQuery(metricsService.ErrUnknownFormat)
+		}
+		return format, nil
+	}
+	accept := req.Header.Get("Accept")
+	if accept == "" {
+		return metricsService.FormatNDJSON, nil
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if format, ok := exportMediaTypes[mediaType]; ok {
+			return format, nil
+		}
+	}
+	return "", ErrNotAcceptable
+}
+
+func parseTimeRange(req *http.Request) (bool, metricsService.TimeRange, error) {
+	values := req.URL.Query()
+	var r metricsService.TimeRange
+	samples := false
+	if h := values.Get("history"); h != "" {
+		var err error
+		if samples, err = strconv.ParseBool(h); err != nil {
+			return false, r, fmt.Errorf("history %q is not a boolean", h)
+		}
+	}
+	for _, bound := range []struct {
+		name string
+		to   *time.Time
+	}{{"from", &r.From}, {"to", &r.To}} {
+		value := values.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return false, r, fmt.Errorf("%s %q is not an RFC 3339 time", bound.name, value)
+		}
+		*bound.to = t
+		samples = true
+	}
+	return samples, r, nil
+}
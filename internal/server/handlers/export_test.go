@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/handlers/middleware"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestExportHandler(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	require.NoError(t, metricsService.Update(storage.StorageInstance, &metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1.5)}))
+
+	tests := []struct {
+		name        string
+		target      string
+		accept      string
+		wantCode    int
+		contentType string
+		body        string
+	}{
+		{name: "Default NDJSON", target: "/api/v1/export", wantCode: http.StatusOK, contentType: "application/x-ndjson", body: `{"id":"Alloc","type":"gauge","value":1.5}` + "\n"},
+		{name: "CSV by Accept", target: "/api/v1/export", accept: "text/html;q=0.9, text/csv", wantCode: http.StatusOK, contentType: "text/csv; charset=utf-8", body: "type,id,value,labels\ngauge,Alloc,1.5,\n"},
+		{name: "Format overrides Accept", target: "/api/v1/export?format=csv", accept: "application/x-ndjson", wantCode: http.StatusOK, contentType: "text/csv; charset=utf-8", body: "type,id,value,labels\ngauge,Alloc,1.5,\n"},
+		{name: "History range", target: "/api/v1/export?format=csv&to=2000-01-01T00:00:00Z", wantCode: http.StatusOK, contentType: "text/csv; charset=utf-8", body: "time,type,id,value\n"},
+		{name: "Unknown format", target: "/api/v1/export?format=xml", wantCode: http.StatusBadRequest},
+		{name: "Broken range", target: "/api/v1/export?from=yesterday", wantCode: http.StatusBadRequest},
+		{name: "Not acceptable", target: "/api/v1/export", accept: "image/png", wantCode: http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			ExportHandler(rec, req)
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+			assert.Equal(t, tt.body, rec.Body.String())
+		})
+	}
+}
+
+func TestExportHandler_Gzip(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	require.NoError(t, metricsService.Update(storage.StorageInstance, &metrics.Metrics{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(2)}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/export?format=csv", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	middleware.GzipHandle(ExportHandler)(rec, req)
+
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "type,id,value,labels\ncounter,PollCount,2,\n", string(body))
+}
+
+func TestExportHandler_GzipNDJSON(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	require.NoError(t, metricsService.Update(storage.StorageInstance, &metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1.5)}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/export", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	middleware.GzipHandle(ExportHandler)(rec, req)
+
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"Alloc","type":"gauge","value":1.5}`+"\n", string(body))
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/scraper"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// FederateHandler handles GET /federate.
+// Returns the selected metrics with the time of their last update for
+// a server pulling from this one, see scraper.Federation.
+// Query parameters:
+//   - type, prefix, regex, label: selection as in ListMetricsHandler
+//   - since: RFC 3339 time, only metrics updated at or after it are returned
+func FederateHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("FederateHandler")
+	markNodeLocal(res)
+	sel, err := parseSelector(req)
+	if err != nil {
+		writeError(res, invalidQuery(err))
+		return
+	}
+	var since time.Time
+	if s := req.URL.Query().Get("since"); s != "" {
+		since, err = time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			writeError(res, invalidQuery(fmt.Errorf("since %q is not an RFC 3339 time", s)))
+			return
+		}
+	}
+	federation, err := metricsService.Federate(storage.StorageInstance, sel, since, time.Now())
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, federation)
+}
+
+// TargetsHandler handles GET /api/v1/targets.
+// Returns the scraper.Health of every scrape target as a JSON array,
+// empty when the server scrapes nothing.
+func TargetsHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("TargetsHandler")
+	writeJSON(res, http.StatusOK, scraper.Instance.Health())
+}
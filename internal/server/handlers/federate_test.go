@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/scraper"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestFederateHandler(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	history.Instance.Reset()
+	defer storage.StorageInstance.ClearAll()
+	defer history.Instance.Reset()
+	before := time.Now().Add(-time.Minute)
+	require.NoError(t, metricsService.UpdateMany(storage.StorageInstance, &[]metrics.Metrics{
+		{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)},
+		{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(2)},
+	}))
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantIDs  []string
+	}{
+		{name: "All", wantCode: http.StatusOK, wantIDs: []string{"PollCount", "Alloc"}},
+		{name: "Type", query: "type=gauge", wantCode: http.StatusOK, wantIDs: []string{"Alloc"}},
+		{name: "Since", query: "since=" + url.QueryEscape(before.Format(time.RFC3339)), wantCode: http.StatusOK, wantIDs: []string{"PollCount", "Alloc"}},
+		{name: "Nothing new", query: "since=" + url.QueryEscape(time.Now().Add(time.Minute).Format(time.RFC3339Nano)), wantCode: http.StatusOK, wantIDs: []string{}},
+		{name: "Broken since", query: "since=yesterday", wantCode: http.StatusBadRequest},
+		{name: "Broken regex", query: "regex=(", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			FederateHandler(rec, httptest.NewRequest(http.MethodGet, "/federate?"+tt.query, nil))
+			require.Equal(t, tt.wantCode, rec.Code, rec.Body.String())
+			if tt.wantCode != http.StatusOK {
+				assert.Contains(t, rec.Body.String(), CodeInvalidQuery)
+				return
+			}
+			var federation metricsService.Federation
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &federation))
+			assert.False(t, federation.Time.IsZero())
+			ids := []string{}
+			for _, m := range federation.Metrics {
+				ids = append(ids, m.ID)
+				assert.NotNil(t, m.Updated)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestTargetsHandler(t *testing.T) {
+	original := scraper.Instance
+	defer func() {
+		scraper.Instance = original
+	}()
+
+	scraper.Instance = nil
+	rec := httptest.NewRecorder()
+	TargetsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/targets", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	scraper.Instance = scraper.New()
+	scraper.Instance.Add(scraper.KindFederate, nil, []scraper.Target{{Name: "eu", URL: "http://10.0.0.1:8080"}}, time.Hour)
+	rec = httptest.NewRecorder()
+	TargetsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/targets", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"target":"eu","url":"http://10.0.0.1:8080","kind":"federate","up":false,"samples":0,"failures":0}]`, rec.Body.String())
+}
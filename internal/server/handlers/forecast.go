@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// ForecastHandler handles GET /api/v1/forecast.
+// Query parameters:
+//   - prefix, regex, label: gauge selection as in ListMetricsHandler
+//   - threshold: value the gauges are expected to reach, 0 by default
+//   - direction: down (default) when the gauges fall to the threshold, up when they rise to it
+//   - window: Go duration of history used for the trend, all kept samples by default
+//   - horizon: Go duration, e.g. 6h, marks the forecasts due within it
+//   - stale: Go duration without samples after which no forecast is made, 5m by default
+//
+// Returns a metricsService.ForecastResult as JSON.
+func ForecastHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("ForecastHandler")
+	markNodeLocal(res)
+	q, err := parseForecastQuery(req)
+	if err != nil {
+		writeError(res, invalidQuery(err))
+		return
+	}
+	result, err := metricsService.Forecasts(storage.StorageInstance, q, time.Now())
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, result)
+}
+
+func parseForecastQuery(req *http.Request) (metricsService.ForecastQuery, error) {
+	values := req.URL.Query()
+	var q metricsService.ForecastQuery
+	var err error
+	if q.Select, err = parseSelector(req); err != nil {
+		return q, err
+	}
+	if threshold := values.Get("threshold"); threshold != "" {
+		q.Threshold, err = strconv.ParseFloat(threshold, 64)
+		if err != nil || math.IsNaN(q.Threshold) || math.IsInf(q.Threshold, 0) {
+			return q, fmt.Errorf("threshold %q is not a number", threshold)
+		}
+	}
+	switch direction := values.Get("direction"); direction {
+	case "", "down":
+	case "up":
+		q.Rising = true
+	default:
+		return q, fmt.Errorf("direction %q is not up or down", direction)
+	}
+	for name, target := range map[string]*time.Duration{"window": &q.Window, "horizon": &q.Horizon, "stale": &q.StaleAfter} {
+		value := values.Get(name)
+		if value == "" {
+			continue
+		}
+		*target, err = time.ParseDuration(value)
+		if err != nil || *target <= 0 {
+			return q, fmt.Errorf("%s %q is not a positive duration", name, value)
+		}
+	}
+	return q, nil
+}
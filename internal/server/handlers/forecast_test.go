@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestForecastHandler(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	history.Instance.Reset()
+	defer storage.StorageInstance.ClearAll()
+	defer history.Instance.Reset()
+	for _, m := range []metrics.Metrics{
+		{ID: "FreeMemory", MType: constants.Gauge, Value: utils.FloatToPointerFloat(512)},
+		{ID: "HeapAlloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(64)},
+		{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(1)},
+	} {
+		require.NoError(t, metricsService.Update(storage.StorageInstance, &m))
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		wantCode   int
+		wantIDs    []string
+		wantStatus string
+	}{
+		{name: "All gauges", target: "/api/v1/forecast?threshold=100", wantCode: http.StatusOK, wantIDs: []string{"FreeMemory", "HeapAlloc"}},
+		{name: "Reached", target: "/api/v1/forecast?prefix=Heap&threshold=100", wantCode: http.StatusOK, wantIDs: []string{"HeapAlloc"}, wantStatus: metricsService.ForecastReached},
+		{name: "Sparse", target: "/api/v1/forecast?prefix=Free&threshold=100&window=1h&horizon=6h", wantCode: http.StatusOK, wantIDs: []string{"FreeMemory"}, wantStatus: metricsService.ForecastInsufficientData},
+		{name: "Rising", target: "/api/v1/forecast?prefix=Heap&threshold=100&direction=up", wantCode: http.StatusOK, wantIDs: []string{"HeapAlloc"}, wantStatus: metricsService.ForecastInsufficientData},
+		{name: "Broken threshold", target: "/api/v1/forecast?threshold=full", wantCode: http.StatusBadRequest},
+		{name: "Broken direction", target: "/api/v1/forecast?direction=sideways", wantCode: http.StatusBadRequest},
+		{name: "Broken window", target: "/api/v1/forecast?window=-1m", wantCode: http.StatusBadRequest},
+		{name: "Broken stale", target: "/api/v1/forecast?stale=later", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ForecastHandler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode != http.StatusOK {
+				var body APIError
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, CodeInvalidQuery, body.Code)
+				return
+			}
+			var body metricsService.ForecastResult
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, 100.0, body.Threshold)
+			ids := []string{}
+			for _, f := range body.Forecasts {
+				ids = append(ids, f.ID)
+				assert.Equal(t, 1, f.Samples)
+				if tt.wantStatus != "" {
+					assert.Equal(t, tt.wantStatus, f.Status)
+				}
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
@@ -1,10 +1,10 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"slices"
 	"strconv"
@@ -20,27 +20,39 @@ import (
 	"github.com/Maxim-Ba/metriccollector/pkg/utils"
 )
 
+// defaultDashboardRefresh is the reload interval of the dashboard in seconds.
+const defaultDashboardRefresh = 10
+
 // GetAllHandler handles HTTP GET requests to retrieve all metrics.
-// Returns an HTML page listing all metrics in storage.
-// Responds with appropriate HTTP status codes for errors.
+// Returns an HTML dashboard listing all metrics in storage with their
+// recent values and last update times.
+// Query parameters:
+//   - sort: name, type, value or updated, "-" prefix for descending order
+//   - type: gauge or counter
+//   - name: substring of the metric name
+//   - refresh: reload interval in seconds, 10 by default, 0 disables it
+//
+// Errors are answered in plain text, see writeTextError.
 func GetAllHandler(res http.ResponseWriter, req *http.Request) {
 	logger.LogInfo("getAllHandler \n")
+	markNodeLocal(res)
 	err := checkForAllowedMethod(req, []string{http.MethodGet})
 	if err != nil {
-		res.WriteHeader(http.StatusMethodNotAllowed)
-
-		utils.WrireZeroBytes(res)
+		writeTextError(res, req, err)
 		return
 	}
-
-	html, err := metricsService.GetAll(storage.StorageInstance)
+	opts, err := parseDashboardOptions(req)
 	if err != nil {
-		res.WriteHeader(http.StatusNotFound)
+		writeTextError(res, req, invalidQuery(err))
+		return
+	}
 
-		utils.WrireZeroBytes(res)
+	html, err := metricsService.GetAll(storage.StorageInstance, opts)
+	if err != nil {
+		writeTextError(res, req, err)
 		return
 	}
-	res.Header().Set("Content-Type", "text/html")
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
 	_, err = res.Write([]byte(html))
 
 	if err != nil {
@@ -49,161 +61,120 @@ func GetAllHandler(res http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func parseDashboardOptions(req *http.Request) (metricsService.DashboardOptions, error) {
+	values := req.URL.Query()
+	opts := metricsService.DashboardOptions{
+		Sort:    values.Get("sort"),
+		Type:    values.Get("type"),
+		Name:    values.Get("name"),
+		Refresh: defaultDashboardRefresh,
+	}
+	if opts.Type != "" && opts.Type != constants.Gauge && opts.Type != constants.Counter {
+		return opts, ErrNoMetricsType
+	}
+	if refresh := values.Get("refresh"); refresh != "" {
+		n, err := strconv.Atoi(refresh)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("refresh %q is not a number of seconds", refresh)
+		}
+		opts.Refresh = n
+	}
+	return opts, nil
+}
+
 // GetOneHandlerByParams handles HTTP GET requests to retrieve a single metric via URL parameters.
 // Expected URL format: /value/<type>/<name>.
 // Returns the metric value as plain text.
 // Responds with HTTP 404 if metric is not found, or 400 for bad requests.
+// It adapts the legacy route to getMetric, see GetMetricHandler.
 func GetOneHandlerByParams(res http.ResponseWriter, req *http.Request) {
 	logger.LogInfo("GetOneHandlerByParams")
 	err := checkForAllowedMethod(req, []string{http.MethodGet})
 	if err != nil {
-		res.WriteHeader(http.StatusMethodNotAllowed)
-
-		utils.WrireZeroBytes(res)
+		writeTextError(res, req, err)
 		return
 	}
-	urlString := req.URL.Path //  /value/asdasd/asdasd/sdfsdfsdf/234
-	params := strings.TrimPrefix(urlString, "/value/")
-	parameters := strings.Split(params, "/")
-	name := parameters[1]
-	metricParams := metrics.MetricDTOParams{MetricsName: name, MetricType: parameters[0]}
-	p := []*metrics.MetricDTOParams{&metricParams}
-	metric, err := metricsService.Get(storage.StorageInstance, &p)
-
-	if err != nil {
-		res.WriteHeader(http.StatusNotFound)
-
-		utils.WrireZeroBytes(res)
+	parameters := strings.Split(strings.TrimPrefix(req.URL.Path, "/value/"), "/")
+	if len(parameters) != 2 {
+		writeTextError(res, req, ErrNoMetricName)
 		return
 	}
-	res.Header().Set("Content-Type", " text/plain")
-	if parameters[0] == constants.Gauge {
-		_, err = res.Write([]byte(strconv.FormatFloat(*metric.Value, 'f', -1, 64)))
-		if err != nil {
-			logger.LogError(err)
-			return
-		}
+	if routeToOwner(res, req, parameters[1], nil) {
 		return
 	}
-	_, err = res.Write([]byte(strconv.FormatInt(int64(*metric.Delta), 10)))
+	metric, err := getMetric(parameters[0], parameters[1])
 	if err != nil {
-		logger.LogError(err)
+		writeTextError(res, req, err)
 		return
 	}
-	res.WriteHeader(http.StatusOK)
+	res.Header().Set("Content-Type", "text/plain")
+	if _, err = res.Write([]byte(formatValue(metric))); err != nil {
+		logger.LogError(err)
+	}
 }
 
 // GetOneHandler handles HTTP POST requests to retrieve a single metric in JSON format.
 // Accepts a metric object in the request body.
 // Returns the current metric value as JSON.
-// Responds with appropriate HTTP status codes for errors.
+// Errors are answered with the APIError envelope.
+// It adapts the legacy route to getMetric, see GetMetricHandler.
 func GetOneHandler(res http.ResponseWriter, req *http.Request) {
 	logger.LogInfo("GetOneHandler \n")
 	err := checkForAllowedMethod(req, []string{http.MethodPost})
 	if err != nil {
-		logger.LogError(err)
-		res.WriteHeader(http.StatusMethodNotAllowed)
-
-		utils.WrireZeroBytes(res)
+		writeError(res, err)
 		return
 	}
-
-	var buf bytes.Buffer
-	_, err = buf.ReadFrom(req.Body)
+	body, err := io.ReadAll(req.Body)
 	if err != nil {
-		logger.LogError(err)
-		res.WriteHeader(http.StatusBadRequest)
-
-		utils.WrireZeroBytes(res)
+		writeError(res, fmt.Errorf("%w: %w", ErrInvalidBody, err))
 		return
 	}
-	requestMetric, err := parseMetric(&buf)
-	logger.LogMetric(requestMetric)
-
+	requestMetric, err := parseMetric(body)
 	if err != nil {
-
-		logger.LogError(err)
-		res.WriteHeader(http.StatusBadRequest)
-
-		utils.WrireZeroBytes(res)
+		writeError(res, invalidQuery(err))
 		return
 	}
-
-	metricParams := metrics.MetricDTOParams{MetricsName: requestMetric.ID, MetricType: requestMetric.MType}
-	p := []*metrics.MetricDTOParams{&metricParams}
-
-	responseMetrics, err := metricsService.Get(storage.StorageInstance, &p)
-
-	if err != nil {
-		res.WriteHeader(http.StatusNotFound)
-		utils.WrireZeroBytes(res)
+	if routeToOwner(res, req, requestMetric.ID, body) {
 		return
 	}
-
-	body, err := json.Marshal(responseMetrics)
-
+	metric, err := getMetric(requestMetric.MType, requestMetric.ID)
 	if err != nil {
-		logger.LogError(err)
-		res.WriteHeader(http.StatusInternalServerError)
-		utils.WrireZeroBytes(res)
+		writeError(res, err)
 		return
 	}
-	logger.LogMetric(*responseMetrics)
-
-	res.Header().Set("Content-Type", " application/json")
-	res.WriteHeader(http.StatusOK)
-	if _, err := res.Write(body); err != nil {
-		logger.LogError(err)
-	}
-
+	writeJSON(res, http.StatusOK, metric)
 }
 
 // UpdateHandler handles HTTP POST requests to update a metric.
 // Accepts a metric object in JSON format in the request body.
 // Returns HTTP 200 on success.
-// Responds with appropriate HTTP status codes for errors.
+// Errors are answered with the APIError envelope.
+// It adapts the legacy route to updateMetric.
 func UpdateHandler(res http.ResponseWriter, req *http.Request) {
 	logger.LogInfo("updateHandler")
 	err := checkForAllowedMethod(req, []string{http.MethodPost})
 	if err != nil {
-		logger.LogError(err)
-		res.WriteHeader(http.StatusMethodNotAllowed)
-		utils.WrireZeroBytes(res)
+		writeError(res, err)
 		return
 	}
-
-	var buf bytes.Buffer
-	_, err = buf.ReadFrom(req.Body)
+	body, err := io.ReadAll(req.Body)
 	if err != nil {
-		logger.LogError(err)
-
-		res.WriteHeader(http.StatusBadRequest)
-		utils.WrireZeroBytes(res)
+		writeError(res, fmt.Errorf("%w: %w", ErrInvalidBody, err))
 		return
 	}
-
-	metric, err := parseMetric(&buf)
-
+	metric, err := parseMetric(body)
 	if err != nil {
-		logger.LogError(err)
-		if err == ErrNoMetricName {
-			res.WriteHeader(http.StatusNotFound)
-		}
-		if err == ErrNoMetricsType || err == ErrWrongValue {
-			res.WriteHeader(http.StatusBadRequest)
-		}
-		utils.WrireZeroBytes(res)
+		writeError(res, err)
 		return
 	}
-
-	err = metricsService.Update(storage.StorageInstance, &metric)
-	if err != nil {
-		logger.LogError(err)
-		res.WriteHeader(http.StatusMethodNotAllowed)
-		utils.WrireZeroBytes(res)
+	if routeToOwner(res, req, metric.ID, body) {
+		return
+	}
+	if err = updateMetric(&metric); err != nil {
+		writeError(res, err)
 		return
 	}
-
 	res.WriteHeader(http.StatusOK)
 	utils.WrireZeroBytes(res)
 }
@@ -211,90 +182,76 @@ func UpdateHandler(res http.ResponseWriter, req *http.Request) {
 // UpdateHandlerByURLParams handles HTTP requests to update a metric via URL parameters.
 // Expected URL format: /update/<type>/<name>/<value>.
 // Returns HTTP 200 on success.
-// Responds with appropriate HTTP status codes for errors.
+// Errors are answered in plain text, see writeTextError.
+// It adapts the legacy route to updateMetric.
 func UpdateHandlerByURLParams(res http.ResponseWriter, req *http.Request) {
 	logger.LogInfo("UpdateHandlerByURLParams \n")
 	err := checkForAllowedMethod(req, []string{http.MethodPost, http.MethodGet})
 	if err != nil {
-		res.WriteHeader(http.StatusMethodNotAllowed)
-		utils.WrireZeroBytes(res)
+		writeTextError(res, req, err)
 		return
 	}
-	urlString := req.URL.Path //  /update/asdasd/asdasd/sdfsdfsdf/234
-	params := strings.TrimPrefix(urlString, "/update/")
-	parameters := strings.Split(params, "/")
-	metric, err := metricRecord(parameters)
-
+	params := strings.TrimPrefix(req.URL.Path, "/update/")
+	metric, err := metricRecord(strings.Split(params, "/"))
 	if err != nil {
-		if err == ErrNoMetricName {
-			res.WriteHeader(http.StatusNotFound)
-		}
-		if err == ErrNoMetricsType || err == ErrWrongValue {
-			res.WriteHeader(http.StatusBadRequest)
-		}
-		utils.WrireZeroBytes(res)
+		writeTextError(res, req, err)
 		return
 	}
-	err = metricsService.Update(storage.StorageInstance, &metric)
-	if err != nil {
-		res.WriteHeader(http.StatusMethodNotAllowed)
-		utils.WrireZeroBytes(res)
+	if routeToOwner(res, req, metric.ID, nil) {
+		return
+	}
+	if err = updateMetric(&metric); err != nil {
+		writeTextError(res, req, err)
 		return
 	}
 	res.WriteHeader(http.StatusOK)
 	if _, err := res.Write([]byte(params)); err != nil {
-		return
+		logger.LogError(err)
 	}
-	utils.WrireZeroBytes(res)
 }
 
 // UpdatesHandler handles HTTP POST requests for batch metric updates.
-// Accepts an array of metric objects in JSON format.
+// Accepts an array of metric objects in JSON format. The body is decoded
+// as a stream; a best-effort batch is applied in chunks, an all-or-nothing one
+// at once, see SetBatchOptions for the batch modes.
+// A request with the "Prefer: handling=lenient" header is applied in best-effort
+// mode and answered with a metrics.BatchReport listing the rejected metrics.
 // Returns HTTP 200 on success.
-// Responds with appropriate HTTP status codes for errors.
+// Errors are answered with the APIError envelope, a rejected all-or-nothing
+// batch names the offending metric in the details.
+// On a cluster node the metrics owned by other nodes are forwarded to them.
 func UpdatesHandler(res http.ResponseWriter, req *http.Request) {
 	logger.LogInfo("UpdatesHandler")
 
 	err := checkForAllowedMethod(req, []string{http.MethodPost})
 	if err != nil {
-		logger.LogError(err)
-		res.WriteHeader(http.StatusMethodNotAllowed)
-		utils.WrireZeroBytes(res)
+		writeError(res, err)
 		return
 	}
-	var buf bytes.Buffer
-	_, err = buf.ReadFrom(req.Body)
-	if err != nil {
-		logger.LogError(err)
-
-		res.WriteHeader(http.StatusBadRequest)
-		utils.WrireZeroBytes(res)
-		return
+	opts := updatesOptions
+	lenient := prefersLenient(req)
+	if lenient {
+		opts.mode = BatchBestEffort
 	}
-	metricsSlice, err := parseMetrics(&buf)
-
+	save := saveTo(storage.StorageInstance)
+	if clustered(req) {
+		save = saveToOwners(req, storage.StorageInstance)
+	}
+	report, err := applyBatch(req.Body, save, opts)
 	if err != nil {
-		logger.LogError(err)
-		if err == ErrNoMetricName {
-			res.WriteHeader(http.StatusNotFound)
-		}
-		if err == ErrNoMetricsType || err == ErrWrongValue {
-			res.WriteHeader(http.StatusBadRequest)
-		}
-		utils.WrireZeroBytes(res)
+		writeError(res, err)
 		return
 	}
-	err = metricsService.UpdateMany(storage.StorageInstance, metricsSlice)
-	if err != nil {
-		logger.LogError(err)
-		res.WriteHeader(http.StatusMethodNotAllowed)
-		utils.WrireZeroBytes(res)
+	if lenient {
+		res.Header().Set("Preference-Applied", preferLenient)
+		writeJSON(res, http.StatusOK, report)
 		return
 	}
 
 	res.WriteHeader(http.StatusOK)
 	utils.WrireZeroBytes(res)
 }
+
 func metricRecord(parameters []string) (metrics.Metrics, error) {
 	if len(parameters) != 3 {
 		return metrics.Metrics{}, ErrNoMetricName
@@ -329,15 +286,16 @@ func metricRecord(parameters []string) (metrics.Metrics, error) {
 	}, nil
 
 }
+
 func checkForAllowedMethod(req *http.Request, allowedMethod []string) error {
 	if !(slices.Contains(allowedMethod, req.Method)) {
-		return fmt.Errorf("not allowed method")
+		return ErrMethodNotAllowed
 	}
 	return nil
 }
-func parseMetric(buf *bytes.Buffer) (metrics.Metrics, error) {
+func parseMetric(body []byte) (metrics.Metrics, error) {
 	var metric metrics.Metrics
-	if err := json.Unmarshal(buf.Bytes(), &metric); err != nil {
+	if err := json.Unmarshal(body, &metric); err != nil {
 		return metrics.Metrics{}, ErrNoMetricName
 	}
 
@@ -349,26 +307,18 @@ func parseMetric(buf *bytes.Buffer) (metrics.Metrics, error) {
 	}
 	return metric, nil
 }
-func parseMetrics(buf *bytes.Buffer) (*[]metrics.Metrics, error) {
-	var metricsSlice []metrics.Metrics
-	if err := json.Unmarshal(buf.Bytes(), &metricsSlice); err != nil {
-		return &[]metrics.Metrics{}, ErrNoMetricName
-	}
-	for _, m := range metricsSlice {
-		if m.MType != constants.Gauge && m.MType != constants.Counter {
-			return &[]metrics.Metrics{}, ErrNoMetricsType
-		}
-		if m.ID == "" {
-			return &[]metrics.Metrics{}, ErrNoMetricName
-		}
-	}
 
-	return &metricsSlice, nil
+// formatValue formats the value of a metric as the legacy plain text routes answer it.
+func formatValue(m *metrics.Metrics) string {
+	if m.MType == constants.Counter {
+		return strconv.FormatInt(*m.Delta, 10)
+	}
+	return strconv.FormatFloat(*m.Value, 'f', -1, 64)
 }
 
 // PingDB checks the database connection.
 // Returns HTTP 200 if connection is successful,
-// or HTTP 500 if there's a connection error.
+// or HTTP 500 with the APIError envelope if there's a connection error.
 func PingDB(res http.ResponseWriter, req *http.Request) {
 	logger.LogInfo("PingDB")
 
@@ -376,9 +326,7 @@ func PingDB(res http.ResponseWriter, req *http.Request) {
 	defer cancel()
 	err := storageService.Ping(ctx, storage.StorageInstance)
 	if err != nil {
-		logger.LogError(err)
-		res.WriteHeader(http.StatusInternalServerError)
-		utils.WrireZeroBytes(res)
+		writeError(res, err)
 		return
 	}
 
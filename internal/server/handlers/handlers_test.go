@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/Maxim-Ba/metriccollector/internal/constants"
@@ -946,8 +947,7 @@ func Test_parseMetric(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			buf := bytes.NewBufferString(tt.input)
-			got, err := parseMetric(buf)
+			got, err := parseMetric([]byte(tt.input))
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -969,7 +969,7 @@ func Test_parseMetric(t *testing.T) {
 	}
 }
 
-func Test_parseMetrics(t *testing.T) {
+func Test_decodeMetrics(t *testing.T) {
 	tests := []struct {
 		name    string
 		input   string
@@ -1025,8 +1025,14 @@ func Test_parseMetrics(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			buf := bytes.NewBufferString(tt.input)
-			got, err := parseMetrics(buf)
+			got := &[]metrics.Metrics{}
+			err := decodeMetrics(strings.NewReader(tt.input), func(_ int, m *metrics.Metrics, err error) error {
+				if err != nil {
+					return err
+				}
+				*got = append(*got, *m)
+				return nil
+			})
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -1179,3 +1185,34 @@ func BenchmarkPingDB(b *testing.B) {
 		handler.ServeHTTP(rec, req)
 	}
 }
+
+func TestGetAllHandler_Dashboard(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	// имя метрики из URL не должно попасть в страницу как разметка
+	rec := httptest.NewRecorder()
+	UpdateHandlerByURLParams(rec, httptest.NewRequest(http.MethodPost, "/update/gauge/<script>alert(1)</1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, metricsService.Update(storage.StorageInstance, &metrics.Metrics{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(3)}))
+
+	rec = httptest.NewRecorder()
+	GetAllHandler(rec, httptest.NewRequest(http.MethodGet, "/?sort=-updated&refresh=0", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	page := rec.Body.String()
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.NotContains(t, page, "<script>")
+	assert.Contains(t, page, "&lt;script&gt;alert(1)&lt;")
+	assert.Contains(t, page, "PollCount")
+	assert.Contains(t, page, "<polyline")
+	assert.NotContains(t, page, `http-equiv="refresh"`)
+
+	rec = httptest.NewRecorder()
+	GetAllHandler(rec, httptest.NewRequest(http.MethodGet, "/?type=counter", nil))
+	assert.NotContains(t, rec.Body.String(), "alert")
+	assert.Contains(t, rec.Body.String(), `content="10"`)
+
+	for _, query := range []string{"?sort=size", "?type=histogram", "?refresh=soon"} {
+		rec = httptest.NewRecorder()
+		GetAllHandler(rec, httptest.NewRequest(http.MethodGet, "/"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
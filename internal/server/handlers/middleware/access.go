@@ -0,0 +1,14 @@
+package middleware
+
+// Access describes which kind of operation a route performs.
+// Middlewares use it to pick the policy that applies to the request.
+type Access int
+
+const (
+	// ReadAccess marks routes that only return stored metrics.
+	ReadAccess Access = iota
+	// WriteAccess marks routes that change stored metrics.
+	WriteAccess
+	// AdminAccess marks routes that manage the server itself.
+	AdminAccess
+)
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+)
+
+// APIError is the body of an error response, shared by the handlers
+// and the middlewares rejecting a request before it reaches them.
+// Fields:
+//   - Code: stable machine readable error code, e.g. "invalid_metric_type"
+//   - Message: human readable description
+//   - Details: optional context, e.g. the rejected metric of a batch
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// Error codes returned by the middlewares in APIError.Code.
+const (
+	CodeInvalidBody     = "invalid_body"
+	CodeBodyTooLarge    = "body_too_large"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeTooManyRequests = "too_many_requests"
+	CodeForwardFailed   = "forward_failed"
+	CodeUnavailable     = "unavailable"
+	CodeInternal        = "internal_error"
+)
+
+// WriteError answers with the JSON error envelope.
+// Parameters:
+//   - res: response writer
+//   - status: HTTP status
+//   - code: one of the Code constants
+//   - message: human readable description
+func WriteError(res http.ResponseWriter, status int, code string, message string) {
+	body, err := json.Marshal(APIError{Code: code, Message: message})
+	if err != nil {
+		logger.LogError(err)
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	if _, err = res.Write(body); err != nil {
+		logger.LogError(err)
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/server/auth"
+)
+
+var accessScopes = map[Access]string{
+	ReadAccess:  auth.ScopeRead,
+	WriteAccess: auth.ScopeWrite,
+	AdminAccess: auth.ScopeAdmin,
+}
+
+// AuthHandle returns a middleware that requires a bearer token with the scope
+// matching the access kind of the route when token authentication is enabled.
+// Admin routes require a token with the admin scope even when authentication
+// is disabled, so they are closed unless an admin token is configured.
+// Responds with 401 Unauthorized for missing or unknown tokens
+// and 403 Forbidden when the token lacks the required scope.
+func AuthHandle(access Access) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+			if !auth.Instance.Enabled() && access != AdminAccess {
+				next.ServeHTTP(res, r)
+				return
+			}
+			secret, ok := bearerToken(r)
+			if !ok {
+				res.Header().Set("WWW-Authenticate", `Bearer realm="metriccollector"`)
+				WriteError(res, http.StatusUnauthorized, CodeUnauthorized, auth.ErrInvalidToken.Error())
+				return
+			}
+			token, err := auth.Instance.Authenticate(secret)
+			if err != nil {
+				logger.LogError(err)
+				res.Header().Set("WWW-Authenticate", `Bearer realm="metriccollector", error="invalid_token"`)
+				WriteError(res, http.StatusUnauthorized, CodeUnauthorized, err.Error())
+				return
+			}
+			if !auth.HasScope(token, accessScopes[access]) {
+				res.Header().Set("WWW-Authenticate", `Bearer realm="metriccollector", error="insufficient_scope"`)
+				WriteError(res, http.StatusForbidden, CodeForbidden, ErrInsufficientScope.Error())
+				return
+			}
+			next.ServeHTTP(res, r)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, secret, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	secret = strings.TrimSpace(secret)
+	return secret, secret != ""
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Maxim-Ba/metriccollector/internal/server/auth"
+	"github.com/Maxim-Ba/metriccollector/internal/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthHandle(t *testing.T) {
+	originalAuth := auth.Instance
+	originalSignature := signature.Instance
+	defer func() {
+		auth.Instance = originalAuth
+		signature.Instance = originalSignature
+	}()
+
+	signature.New("test-key", "")
+	validHash, err := signature.Instance.Get([]byte("test data"))
+	require.NoError(t, err)
+
+	a, err := auth.New(true, "", "", nil)
+	require.NoError(t, err)
+	_, readSecret, err := a.Create("reader", []string{auth.ScopeRead})
+	require.NoError(t, err)
+	_, writeSecret, err := a.Create("writer", []string{auth.ScopeWrite})
+	require.NoError(t, err)
+
+	disabled, err := auth.New(false, "", "admin-secret", nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		enabled      bool
+		access       Access
+		headers      map[string]string
+		expectStatus int
+	}{
+		{
+			name:         "Auth disabled - pass through",
+			enabled:      false,
+			access:       WriteAccess,
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Auth disabled - admin route without token",
+			enabled:      false,
+			access:       AdminAccess,
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Auth disabled - admin route with admin token",
+			enabled:      false,
+			access:       AdminAccess,
+			headers:      map[string]string{"Authorization": "Bearer admin-secret"},
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Auth disabled - admin route with unknown token",
+			enabled:      false,
+			access:       AdminAccess,
+			headers:      map[string]string{"Authorization": "Bearer unknown"},
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Missing token",
+			enabled:      true,
+			access:       ReadAccess,
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Unknown token",
+			enabled:      true,
+			access:       ReadAccess,
+			headers:      map[string]string{"Authorization": "Bearer unknown"},
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Read token on read route",
+			enabled:      true,
+			access:       ReadAccess,
+			headers:      map[string]string{"Authorization": "Bearer " + readSecret},
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Read token on write route",
+			enabled:      true,
+			access:       WriteAccess,
+			headers:      map[string]string{"Authorization": "Bearer " + readSecret},
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "Write token on admin route",
+			enabled:      true,
+			access:       AdminAccess,
+			headers:      map[string]string{"Authorization": "Bearer " + writeSecret},
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "Signed write request without token",
+			enabled:      true,
+			access:       WriteAccess,
+			headers:      map[string]string{"HashSHA256": base64.StdEncoding.EncodeToString(validHash)},
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			name:    "Signed write request with write token",
+			enabled: true,
+			access:  WriteAccess,
+			headers: map[string]string{
+				"HashSHA256":    base64.StdEncoding.EncodeToString(validHash),
+				"Authorization": "Bearer " + writeSecret,
+			},
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Signed read request without token",
+			enabled:      true,
+			access:       ReadAccess,
+			headers:      map[string]string{"HashSHA256": base64.StdEncoding.EncodeToString(validHash)},
+			expectStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth.Instance = a
+			if !tt.enabled {
+				auth.Instance = disabled
+			}
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			wrappedHandler := SignatureHandle(AuthHandle(tt.access)(handler))
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", bytes.NewBufferString("test data"))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+
+			wrappedHandler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectStatus, rr.Code)
+			if tt.expectStatus != http.StatusOK {
+				var body APIError
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				wantCode := map[int]string{http.StatusUnauthorized: CodeUnauthorized, http.StatusForbidden: CodeForbidden}
+				assert.Equal(t, wantCode[tt.expectStatus], body.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+)
+
+// BodyLimit holds the request body size limits in bytes.
+// A zero limit disables the corresponding check.
+type BodyLimit struct {
+	maxBody         int64
+	maxDecompressed int64
+	maxRestore      int64
+}
+
+// BodyLimitInstance is the limit used by BodyLimitHandle and GzipHandle.
+// By default bodies are not limited.
+var BodyLimitInstance = &BodyLimit{}
+
+// NewBodyLimit creates body limits and installs them as BodyLimitInstance.
+// Parameters:
+//   - maxBody: max body size as received, i.e. before decompression
+//   - maxDecompressed: max body size after gzip decompression
+//   - maxRestore: max body size of the routes wrapped with RestoreLimitHandle,
+//     before and after decompression, since a snapshot outgrows maxBody
+func NewBodyLimit(maxBody, maxDecompressed, maxRestore int) *BodyLimit {
+	l := &BodyLimit{maxBody: int64(maxBody), maxDecompressed: int64(maxDecompressed), maxRestore: int64(maxRestore)}
+	BodyLimitInstance = l
+	return l
+}
+
+type bodyLimitKey struct{}
+
+// RestoreLimitHandle is a middleware that applies the restore limit of
+// BodyLimitInstance instead of the other limits to the request, see
+// NewBodyLimit. It has to run before BodyLimitHandle and GzipHandle.
+func RestoreLimitHandle(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		limit := BodyLimitInstance.maxRestore
+		restore := &BodyLimit{maxBody: limit, maxDecompressed: limit}
+		next.ServeHTTP(res, r.WithContext(context.WithValue(r.Context(), bodyLimitKey{}, restore)))
+	})
+}
+
+// limitsOf returns the body limits of the request.
+func limitsOf(r *http.Request) *BodyLimit {
+	if l, ok := r.Context().Value(bodyLimitKey{}).(*BodyLimit); ok {
+		return l
+	}
+	return BodyLimitInstance
+}
+
+// BodyLimitHandle is a middleware that rejects requests with a declared
+// Content-Length above the limit and caps the body for everything else,
+// so readers down the chain get an error instead of unbounded data.
+func BodyLimitHandle(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		limit := limitsOf(r).maxBody
+		if limit <= 0 {
+			next.ServeHTTP(res, r)
+			return
+		}
+		if r.ContentLength > limit {
+			logger.LogError(ErrBodyTooLarge, r.ContentLength)
+			WriteError(res, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, ErrBodyTooLarge.Error())
+			return
+		}
+		r.Body = http.MaxBytesReader(res, r.Body, limit)
+		next.ServeHTTP(res, r)
+	})
+}
+
+// BodyTooLarge reports whether err was caused by a body exceeding
+// one of the configured limits.
+func BodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.Is(err, ErrBodyTooLarge) || errors.As(err, &maxBytesErr)
+}
+
+// cappedReader returns ErrBodyTooLarge once more than remaining bytes are read.
+// Unlike io.LimitReader it does not silently truncate the body.
+type cappedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > c.remaining+1 {
+		p = p[:c.remaining+1]
+	}
+	n, err := c.r.Read(p)
+	if int64(n) > c.remaining {
+		n = int(c.remaining)
+		c.remaining = 0
+		return n, ErrBodyTooLarge
+	}
+	c.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Maxim-Ba/metriccollector/internal/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestBodyLimits(t *testing.T) {
+	originalLimit := BodyLimitInstance
+	originalSignature := signature.Instance
+	defer func() {
+		BodyLimitInstance = originalLimit
+		signature.Instance = originalSignature
+	}()
+
+	// 1 МБ нулей сжимается в ~1 КБ
+	bomb := gzipBytes(t, make([]byte, 1<<20))
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if BodyTooLarge(err) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		require.NoError(t, err)
+		_, err = w.Write(body)
+		require.NoError(t, err)
+	})
+
+	tests := []struct {
+		name            string
+		maxBody         int
+		maxDecompressed int
+		body            []byte
+		gzipped         bool
+		signed          bool
+		restore         bool
+		expectStatus    int
+	}{
+		{
+			name:         "Unlimited",
+			body:         bomb,
+			gzipped:      true,
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Plain body within limit",
+			maxBody:      16,
+			body:         []byte("small"),
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Plain body over limit",
+			maxBody:      16,
+			body:         []byte(strings.Repeat("a", 17)),
+			expectStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:            "Gzip within limits",
+			maxBody:         1 << 16,
+			maxDecompressed: 1 << 20,
+			body:            bomb,
+			gzipped:         true,
+			expectStatus:    http.StatusOK,
+		},
+		{
+			name:            "Gzip bomb",
+			maxBody:         1 << 16,
+			maxDecompressed: 1 << 16,
+			body:            bomb,
+			gzipped:         true,
+			expectStatus:    http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:         "Compressed body over limit",
+			maxBody:      64,
+			body:         bomb,
+			gzipped:      true,
+			expectStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:         "Restore over body limit",
+			maxBody:      64,
+			body:         []byte(strings.Repeat("a", 1<<17)),
+			restore:      true,
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Restore gzip bomb",
+			body:         bomb,
+			gzipped:      true,
+			restore:      true,
+			expectStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:            "Gzip bomb read by SignatureHandle",
+			maxDecompressed: 1 << 16,
+			body:            bomb,
+			gzipped:         true,
+			signed:          true,
+			expectStatus:    http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			NewBodyLimit(tt.maxBody, tt.maxDecompressed, 1<<18)
+			signature.New("", "")
+			if tt.signed {
+				signature.New("test-key", "")
+			}
+
+			handler := BodyLimitHandle(GzipHandle(SignatureHandle(echo)))
+			if tt.restore {
+				handler = RestoreLimitHandle(handler)
+			}
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", bytes.NewReader(tt.body))
+			if tt.gzipped {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			if tt.signed {
+				req.Header.Set("HashSHA256", "c2lnbg==")
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectStatus, rr.Code)
+		})
+	}
+}
+
+func TestCappedReader(t *testing.T) {
+	r := &cappedReader{r: strings.NewReader("abcdef"), remaining: 6}
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef", string(data))
+
+	r = &cappedReader{r: strings.NewReader("abcdefg"), remaining: 6}
+	data, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+	assert.Equal(t, "abcdef", string(data))
+}
@@ -1,46 +1,64 @@
 package middleware
 
 import (
-	"bytes"
 	"compress/gzip"
+	"errors"
 	"io"
 	"net/http"
 	"slices"
+	"strings"
 
 	"github.com/Maxim-Ba/metriccollector/internal/logger"
 )
 
 type gzipWriter struct {
 	http.ResponseWriter
-	Writer io.Writer
+	Writer *gzip.Writer
 }
 
-func (w gzipWriter) Write(b []byte) (int, error) {
+func (w *gzipWriter) Write(b []byte) (int, error) {
 	// w.Writer будет отвечать за gzip-сжатие, поэтому пишем в него
 	return w.Writer.Write(b)
 }
 
+// FlushError writes the data compressed so far and flushes the original
+// ResponseWriter, so http.ResponseController can flush streamed responses.
+func (w *gzipWriter) FlushError() error {
+	if err := w.Writer.Flush(); err != nil {
+		return err
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the original ResponseWriter for http.ResponseController.
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // GzipHandle is a middleware that handles gzip compression for HTTP responses
 // and decompression for requests. It checks the Accept-Encoding and Content-Encoding
 // headers to determine if gzip should be used. Responses are compressed if the client
 // supports gzip, and requests with gzip content are automatically decompressed.
+// Event streams requested with Accept are not compressed, see IsStreaming;
+// a compressed response is still flushed by http.ResponseController.
 func GzipHandle(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
 
 		r, err := decodeGzip(r)
+		if errors.Is(err, ErrBodyTooLarge) {
+			logger.LogError(err)
+			WriteError(res, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, err.Error())
+			return
+		}
 		if err != nil {
 			logger.LogError(err)
-			res.WriteHeader(http.StatusMethodNotAllowed)
-			_, err = res.Write([]byte(""))
-			if err != nil {
-				return
-			}
+			WriteError(res, http.StatusBadRequest, CodeInvalidBody, err.Error())
 			return
 		}
 		// проверяем, что клиент поддерживает gzip-сжатие
 		headerValues := r.Header.Values("Accept-Encoding")
 
-		if !slices.Contains(headerValues, "gzip") {
+		if !slices.Contains(headerValues, "gzip") || IsStreaming(r) {
 			next.ServeHTTP(res, r)
 			return
 		}
@@ -60,10 +78,33 @@ func GzipHandle(next http.HandlerFunc) http.HandlerFunc {
 		}()
 
 		// передаём обработчику страницы переменную типа gzipWriter для вывода данных
-		next.ServeHTTP(gzipWriter{ResponseWriter: res, Writer: gz}, r)
+		next.ServeHTTP(&gzipWriter{ResponseWriter: res, Writer: gz}, r)
 	})
 }
 
+// IsStreaming reports whether the client asks for Server-Sent Events.
+// Compressing small events flushed one by one saves nothing, other
+// streamed responses such as the replication stream are compressed and
+// flushed through gzipWriter.
+func IsStreaming(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// gzipBody decompresses the request body while it is read.
+type gzipBody struct {
+	io.Reader
+	gz   *gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	return errors.Join(b.gz.Close(), b.body.Close())
+}
+
+// decodeGzip replaces a gzip encoded body with a reader that decompresses it
+// on the fly. The decompressed size is capped by the limits of the request,
+// see BodyLimitInstance and RestoreLimitHandle, so reading
+// a decompression bomb fails with ErrBodyTooLarge instead of exhausting memory.
 func decodeGzip(r *http.Request) (*http.Request, error) {
 	headerValues := r.Header.Values("Content-Encoding")
 
@@ -72,19 +113,17 @@ func decodeGzip(r *http.Request) (*http.Request, error) {
 	}
 	gz, err := gzip.NewReader(r.Body)
 	if err != nil {
-		return r, ErrWrongBodyEncoding
-	}
-	defer func() {
-		if err = gz.Close(); err != nil {
-			logger.LogError(err)
+		if BodyTooLarge(err) {
+			return r, ErrBodyTooLarge
 		}
-	}()
-
-	body, err := io.ReadAll(gz)
-	if err != nil {
 		return r, ErrWrongBodyEncoding
-
 	}
-	r.Body = io.NopCloser(bytes.NewReader(body))
+	var body io.Reader = gz
+	if limit := limitsOf(r).maxDecompressed; limit > 0 {
+		body = &cappedReader{r: gz, remaining: limit}
+	}
+	r.Body = &gzipBody{Reader: body, gz: gz, body: r.Body}
+	r.Header.Del("Content-Encoding")
+	r.ContentLength = -1
 	return r, nil
 }
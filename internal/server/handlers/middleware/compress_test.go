@@ -3,6 +3,7 @@ package middleware
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -122,7 +123,11 @@ func TestGzipHandle_InvalidGzipRequest(t *testing.T) {
 	gzipHandler.ServeHTTP(w, req)
 
 	resp := w.Result()
-	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var body APIError
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, APIError{Code: CodeInvalidBody, Message: ErrWrongBodyEncoding.Error()}, body)
 	if err := resp.Body.Close(); err != nil {
 		require.NoError(t, err)
 	}
@@ -158,3 +163,55 @@ func TestGzipWriter_Write(t *testing.T) {
 	assert.Equal(t, "test data", string(body))
 
 }
+
+func TestGzipHandle_Streaming(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte("data: test\n\n"))
+		require.NoError(t, err)
+		require.NoError(t, http.NewResponseController(w).Flush())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+
+	WithLogging(GzipHandle(handler)).ServeHTTP(w, req)
+
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "data: test\n\n", w.Body.String())
+	assert.True(t, w.Flushed)
+
+	// NDJSON, например экспорт или поток репликации, сжимается
+	req.Header.Set("Accept", "application/x-ndjson, application/json")
+	w = httptest.NewRecorder()
+
+	WithLogging(GzipHandle(handler)).ServeHTTP(w, req)
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.True(t, w.Flushed)
+}
+
+func TestGzipHandle_FlushCompressed(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler := http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		_, err := res.Write([]byte("data: test\n\n"))
+		require.NoError(t, err)
+		require.NoError(t, http.NewResponseController(res).Flush())
+		// событие доступно клиенту до завершения ответа
+		gz, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+		require.NoError(t, err)
+		event := make([]byte, len("data: test\n\n"))
+		_, err = io.ReadFull(gz, event)
+		require.NoError(t, err)
+		assert.Equal(t, "data: test\n\n", string(event))
+	})
+	// клиент без Accept: text/event-stream, например curl
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	WithLogging(GzipHandle(handler)).ServeHTTP(w, req)
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.True(t, w.Flushed)
+}
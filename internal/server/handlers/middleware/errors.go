@@ -3,3 +3,15 @@ package middleware
 import "errors"
 
 var ErrWrongBodyEncoding = errors.New("wrong body encoding")
+
+var ErrInvalidSubnet = errors.New("invalid trusted subnet")
+
+var ErrForbiddenAddress = errors.New("address is not in trusted subnet")
+
+var ErrInsufficientScope = errors.New("token scope is insufficient")
+
+var ErrTooManyRequests = errors.New("too many requests")
+
+var ErrBodyTooLarge = errors.New("request body too large")
+
+var ErrUnknownLeaderMode = errors.New("unknown leader write mode")
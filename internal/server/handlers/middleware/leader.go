@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+)
+
+// Ways a follower passes writes to its leader.
+const (
+	LeaderProxy    = "proxy"
+	LeaderRedirect = "redirect"
+)
+
+// Leader forwards the write and admin requests of a follower to its leader.
+// A nil leader URL means the server is the leader and serves them itself.
+type Leader struct {
+	url      *url.URL
+	redirect bool
+	proxy    *httputil.ReverseProxy
+}
+
+// LeaderInstance is the policy used by LeaderHandle.
+// By default the server is a leader.
+var LeaderInstance = &Leader{}
+
+// NewLeader creates the forwarding policy and installs it as LeaderInstance.
+// Parameters:
+//   - leader: URL of the leader, nil on a leader
+//   - mode: LeaderProxy to send requests to the leader and return its
+//     response, LeaderRedirect to answer 307 Temporary Redirect
+//
+// Returns:
+//   - *Leader: the installed policy
+//   - error: ErrUnknownLeaderMode
+func NewLeader(leader *url.URL, mode string) (*Leader, error) {
+	if mode != LeaderProxy && mode != LeaderRedirect {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownLeaderMode, mode)
+	}
+	l := &Leader{url: leader, redirect: mode == LeaderRedirect}
+	if leader != nil {
+		l.proxy = httputil.NewSingleHostReverseProxy(leader)
+		l.proxy.ErrorHandler = func(res http.ResponseWriter, r *http.Request, err error) {
+			logger.LogError("leader proxy: ", err)
+			WriteError(res, http.StatusBadGateway, CodeForwardFailed, "leader is unavailable")
+		}
+	}
+	LeaderInstance = l
+	return l, nil
+}
+
+// LeaderHandle returns a middleware that passes requests of the given
+// access kind to the leader when the server is a follower. Reads are
+// always served locally. The body is forwarded as received, so the
+// leader verifies its signature and decompresses it.
+func LeaderHandle(access Access) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+			l := LeaderInstance
+			if l.url == nil || access == ReadAccess {
+				next.ServeHTTP(res, r)
+				return
+			}
+			if l.redirect {
+				target := *l.url
+				target.Path = l.url.Path + r.URL.Path
+				target.RawQuery = r.URL.RawQuery
+				http.Redirect(res, r, target.String(), http.StatusTemporaryRedirect)
+				return
+			}
+			l.proxy.ServeHTTP(res, r)
+		})
+	}
+}
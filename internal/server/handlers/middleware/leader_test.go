@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLeader(t *testing.T) {
+	originalInstance := LeaderInstance
+	defer func() {
+		LeaderInstance = originalInstance
+	}()
+
+	_, err := NewLeader(nil, LeaderProxy)
+	assert.NoError(t, err)
+	_, err = NewLeader(&url.URL{Scheme: "http", Host: "leader:8080"}, LeaderRedirect)
+	assert.NoError(t, err)
+	_, err = NewLeader(nil, "mirror")
+	assert.ErrorIs(t, err, ErrUnknownLeaderMode)
+}
+
+func TestLeaderHandle(t *testing.T) {
+	originalInstance := LeaderInstance
+	defer func() {
+		LeaderInstance = originalInstance
+	}()
+	var leaderBody string
+	leaderServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		leaderBody = r.Method + " " + r.URL.RequestURI() + " " + string(body) + " " + r.Header.Get("HashSHA256")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer leaderServer.Close()
+	leaderURL, err := url.Parse(leaderServer.URL)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		leader       *url.URL
+		mode         string
+		access       Access
+		expectStatus int
+		expectLeader string
+		expectTarget string
+	}{
+		{name: "leader serves writes", mode: LeaderProxy, access: WriteAccess, expectStatus: http.StatusOK},
+		{name: "follower serves reads", leader: leaderURL, mode: LeaderProxy, access: ReadAccess, expectStatus: http.StatusOK},
+		{name: "follower proxies writes", leader: leaderURL, mode: LeaderProxy, access: WriteAccess, expectStatus: http.StatusAccepted, expectLeader: `POST /updates/?dry_run=1 [{"id":"Alloc"}] c2lnbmVk`},
+		{name: "follower proxies admin requests", leader: leaderURL, mode: LeaderProxy, access: AdminAccess, expectStatus: http.StatusAccepted, expectLeader: `POST /updates/?dry_run=1 [{"id":"Alloc"}] c2lnbmVk`},
+		{name: "follower redirects writes", leader: leaderURL, mode: LeaderRedirect, access: WriteAccess, expectStatus: http.StatusTemporaryRedirect, expectTarget: leaderServer.URL + "/updates/?dry_run=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaderBody = ""
+			_, err := NewLeader(tt.leader, tt.mode)
+			require.NoError(t, err)
+			handler := LeaderHandle(tt.access)(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/?dry_run=1", strings.NewReader(`[{"id":"Alloc"}]`))
+			req.Header.Set("HashSHA256", "c2lnbmVk")
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			assert.Equal(t, tt.expectStatus, rec.Code)
+			assert.Equal(t, tt.expectLeader, leaderBody)
+			assert.Equal(t, tt.expectTarget, rec.Header().Get("Location"))
+		})
+	}
+}
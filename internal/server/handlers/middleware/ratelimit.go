@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/server/auth"
+)
+
+// Keys used to tell clients apart in RateLimiter.
+const (
+	RateLimitByIP    = "ip"
+	RateLimitByAgent = "agent"
+	RateLimitByToken = "token"
+)
+
+// bucketIdleTTL is how long an unused bucket is kept before it is dropped.
+const bucketIdleTTL = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimit struct {
+	rate  float64 // токенов в секунду
+	burst float64 // ёмкость корзины
+}
+
+// RateLimiter is a token bucket limiter with a bucket per client
+// and access kind. A zero rate disables limiting for the access kind.
+type RateLimiter struct {
+	mu        sync.Mutex
+	limits    map[Access]rateLimit
+	keyBy     string
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// RateLimiterInstance is the limiter used by RateLimitHandle.
+// By default requests are not limited.
+var RateLimiterInstance = &RateLimiter{}
+
+// NewRateLimiter creates a limiter and installs it as RateLimiterInstance.
+// Parameters:
+//   - readRate: allowed read requests per second for one client, 0 disables the limit
+//   - writeRate: allowed write and admin requests per second for one client, 0 disables the limit
+//   - burst: bucket size, defaults to the rate when not positive
+//   - keyBy: how clients are identified: "ip", "agent" (X-Agent-ID header) or "token"
+//
+// Clients fall back to their IP address when they send no token or a token
+// that is not issued, and when they send no agent identity or send it from
+// outside of the trusted write networks, so made up identities do not get
+// buckets of their own.
+func NewRateLimiter(readRate, writeRate, burst int, keyBy string) *RateLimiter {
+	l := &RateLimiter{
+		limits: map[Access]rateLimit{
+			ReadAccess:  newRateLimit(readRate, burst),
+			WriteAccess: newRateLimit(writeRate, burst),
+			AdminAccess: newRateLimit(writeRate, burst),
+		},
+		keyBy:   keyBy,
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+	RateLimiterInstance = l
+	return l
+}
+
+func newRateLimit(rate, burst int) rateLimit {
+	if burst <= 0 {
+		burst = rate
+	}
+	return rateLimit{rate: float64(rate), burst: float64(burst)}
+}
+
+// RateLimitHandle returns a middleware that answers 429 Too Many Requests
+// with a Retry-After header once a client exhausts its bucket for the access kind.
+func RateLimitHandle(access Access) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+			wait, ok := RateLimiterInstance.Allow(access, r)
+			if !ok {
+				seconds := int(math.Ceil(wait.Seconds()))
+				logger.LogError(ErrTooManyRequests, RateLimiterInstance.clientKey(r))
+				res.Header().Set("Retry-After", strconv.Itoa(seconds))
+				WriteError(res, http.StatusTooManyRequests, CodeTooManyRequests, ErrTooManyRequests.Error())
+				return
+			}
+			next.ServeHTTP(res, r)
+		})
+	}
+}
+
+// Allow takes a token from the client's bucket.
+// When the bucket is empty it returns false and the time until the next token.
+func (l *RateLimiter) Allow(access Access, r *http.Request) (time.Duration, bool) {
+	limit, ok := l.limits[access]
+	if !ok || limit.rate <= 0 {
+		return 0, true
+	}
+	key := strconv.Itoa(int(access)) + "|" + l.clientKey(r)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(limit.burst, b.tokens+now.Sub(b.last).Seconds()*limit.rate)
+	b.last = now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / limit.rate * float64(time.Second))
+		return wait, false
+	}
+	b.tokens--
+	return 0, true
+}
+
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	ip := SubnetInstance.ClientIP(r)
+	switch l.keyBy {
+	case RateLimitByToken:
+		if secret, ok := bearerToken(r); ok {
+			if t, err := auth.Instance.Authenticate(secret); err == nil {
+				return "token:" + t.ID
+			}
+		}
+	case RateLimitByAgent:
+		// идентификатор агента выбирает сам клиент, верим ему только из доверенной сети
+		if agent := r.Header.Get("X-Agent-ID"); agent != "" && SubnetInstance.trustedWriter(ip) {
+			return "agent:" + agent
+		}
+	}
+	if ip != nil {
+		return "ip:" + ip.String()
+	}
+	return "ip:" + r.RemoteAddr
+}
+
+// sweep drops buckets that were not used for bucketIdleTTL,
+// it runs at most once a minute.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > bucketIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/server/auth"
+)
+
+func TestRateLimitHandle(t *testing.T) {
+	originalLimiter := RateLimiterInstance
+	originalSubnet := SubnetInstance
+	originalAuth := auth.Instance
+	defer func() {
+		RateLimiterInstance = originalLimiter
+		SubnetInstance = originalSubnet
+		auth.Instance = originalAuth
+	}()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	send := func(access Access, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+		req.RemoteAddr = remoteAddr
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		RateLimitHandle(access)(handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		RateLimiterInstance = &RateLimiter{}
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, http.StatusOK, send(WriteAccess, "10.0.0.1:1234", nil).Code)
+		}
+	})
+
+	t.Run("Burst then 429 with Retry-After", func(t *testing.T) {
+		l := NewRateLimiter(0, 1, 2, RateLimitByIP)
+		l.now = clock
+
+		assert.Equal(t, http.StatusOK, send(WriteAccess, "10.0.0.1:1234", nil).Code)
+		assert.Equal(t, http.StatusOK, send(WriteAccess, "10.0.0.1:1234", nil).Code)
+		rr := send(WriteAccess, "10.0.0.1:1234", nil)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"code":"too_many_requests","message":"too many requests"}`, rr.Body.String())
+
+		// другой клиент и чтение не ограничены
+		assert.Equal(t, http.StatusOK, send(WriteAccess, "10.0.0.2:1234", nil).Code)
+		assert.Equal(t, http.StatusOK, send(ReadAccess, "10.0.0.1:1234", nil).Code)
+
+		now = now.Add(time.Second)
+		assert.Equal(t, http.StatusOK, send(WriteAccess, "10.0.0.1:1234", nil).Code)
+	})
+
+	t.Run("Keyed by agent", func(t *testing.T) {
+		_, err := NewTrustedSubnet("10.0.0.0/24", "", "")
+		require.NoError(t, err)
+		l := NewRateLimiter(1, 1, 1, RateLimitByAgent)
+		l.now = clock
+
+		agentA := map[string]string{"X-Agent-ID": "a"}
+		agentB := map[string]string{"X-Agent-ID": "b"}
+		assert.Equal(t, http.StatusOK, send(ReadAccess, "10.0.0.1:1234", agentA).Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(ReadAccess, "10.0.0.1:1234", agentA).Code)
+		assert.Equal(t, http.StatusOK, send(ReadAccess, "10.0.0.1:1234", agentB).Code)
+
+		// вне доверенной сети идентификатор агента не даёт своей корзины
+		assert.Equal(t, http.StatusOK, send(ReadAccess, "192.168.0.1:1234", agentA).Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(ReadAccess, "192.168.0.1:1234", agentB).Code)
+	})
+
+	t.Run("Agent without trusted networks", func(t *testing.T) {
+		SubnetInstance = &TrustedSubnet{}
+		l := NewRateLimiter(1, 1, 1, RateLimitByAgent)
+		l.now = clock
+
+		assert.Equal(t, http.StatusOK, send(ReadAccess, "10.0.0.1:1234", map[string]string{"X-Agent-ID": "a"}).Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(ReadAccess, "10.0.0.1:1234", map[string]string{"X-Agent-ID": "b"}).Code)
+	})
+
+	t.Run("Keyed by token", func(t *testing.T) {
+		a, err := auth.New(true, "", "", nil)
+		require.NoError(t, err)
+		_, secretA, err := a.Create("a", []string{auth.ScopeRead})
+		require.NoError(t, err)
+		_, secretB, err := a.Create("b", []string{auth.ScopeRead})
+		require.NoError(t, err)
+		l := NewRateLimiter(1, 1, 1, RateLimitByToken)
+		l.now = clock
+
+		tokenA := map[string]string{"Authorization": "Bearer " + secretA}
+		tokenB := map[string]string{"Authorization": "Bearer " + secretB}
+		assert.Equal(t, http.StatusOK, send(ReadAccess, "10.0.0.1:1234", tokenA).Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(ReadAccess, "10.0.0.1:1234", tokenA).Code)
+		assert.Equal(t, http.StatusOK, send(ReadAccess, "10.0.0.1:1234", tokenB).Code)
+
+		// неизвестные токены делят корзину адреса
+		assert.Equal(t, http.StatusOK, send(ReadAccess, "10.0.0.2:1234", map[string]string{"Authorization": "Bearer forged-1"}).Code)
+		assert.Equal(t, http.StatusTooManyRequests, send(ReadAccess, "10.0.0.2:1234", map[string]string{"Authorization": "Bearer forged-2"}).Code)
+	})
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	originalLimiter := RateLimiterInstance
+	defer func() {
+		RateLimiterInstance = originalLimiter
+	}()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(1, 1, 1, RateLimitByIP)
+	l.now = func() time.Time { return now }
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	_, ok := l.Allow(ReadAccess, req)
+	assert.True(t, ok)
+	assert.Len(t, l.buckets, 1)
+
+	now = now.Add(bucketIdleTTL + time.Minute)
+	req.RemoteAddr = "10.0.0.2:1234"
+	_, ok = l.Allow(ReadAccess, req)
+	assert.True(t, ok)
+	assert.Len(t, l.buckets, 1)
+}
@@ -26,6 +26,12 @@ func (r *hashResponseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Unwrap returns the original ResponseWriter,
+// so that http.ResponseController can flush streamed responses.
+func (r *hashResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // SignatureHandle is a middleware that handles request/response signing.
 // For incoming requests, it verifies the HashSHA256 header if present.
 // For responses, it calculates and sets the HashSHA256 header when
@@ -44,15 +50,19 @@ func SignatureHandle(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 		bodyBytes, err := io.ReadAll(r.Body)
+		if BodyTooLarge(err) {
+			WriteError(res, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, ErrBodyTooLarge.Error())
+			return
+		}
 		if err != nil {
-			http.Error(res, "failed to read request body", http.StatusBadRequest)
+			WriteError(res, http.StatusBadRequest, CodeInvalidBody, "failed to read request body")
 			return
 		}
 
 		if signature.Instance.GetPrivKey() != nil {
 			bodyBytes, err = signature.Instance.Decrypt(bodyBytes)
 			if err != nil {
-				http.Error(res, "failed to decrypt body", http.StatusBadRequest)
+				WriteError(res, http.StatusBadRequest, CodeInvalidBody, "failed to decrypt body")
 				return
 			}
 		}
@@ -61,11 +71,11 @@ func SignatureHandle(next http.HandlerFunc) http.HandlerFunc {
 		if signature.Instance.GetKey() != "" && headerValues != "" {
 			decodedHeader, err := base64.StdEncoding.DecodeString(headerValues)
 			if err != nil {
-				http.Error(res, "invalid base64 encoding", http.StatusBadRequest)
+				WriteError(res, http.StatusBadRequest, CodeInvalidBody, "invalid base64 encoding")
 				return
 			}
 			if err := signature.Instance.Check(decodedHeader, bodyBytes); err != nil {
-				http.Error(res, "", http.StatusBadRequest)
+				WriteError(res, http.StatusBadRequest, CodeInvalidBody, "invalid body signature")
 				return
 			}
 		}
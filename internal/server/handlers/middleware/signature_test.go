@@ -62,7 +62,7 @@ func TestSignatureHandle(t *testing.T) {
 			},
 			requestBody:    "test data",
 			expectStatus:   http.StatusBadRequest,
-			expectResponse: "invalid base64 encoding\n",
+			expectResponse: `{"code":"invalid_body","message":"invalid base64 encoding"}`,
 		},
 		{
 			name: "Invalid signature",
@@ -71,7 +71,7 @@ func TestSignatureHandle(t *testing.T) {
 			},
 			requestBody:    "test data",
 			expectStatus:   http.StatusBadRequest,
-			expectResponse: "\n",
+			expectResponse: `{"code":"invalid_body","message":"invalid body signature"}`,
 		},
 		{
 			name: "No key configured - pass through",
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+)
+
+// TrustedSubnet holds the networks allowed to read and write metrics
+// and the proxies whose forwarding headers can be trusted.
+// An empty network list means the corresponding requests are not restricted.
+type TrustedSubnet struct {
+	write   []*net.IPNet
+	read    []*net.IPNet
+	proxies []*net.IPNet
+}
+
+// SubnetInstance is the policy used by TrustedSubnetHandle.
+// By default it allows requests from any address.
+var SubnetInstance = &TrustedSubnet{}
+
+// NewTrustedSubnet parses comma separated CIDR lists and installs
+// the result as SubnetInstance.
+// Parameters:
+//   - write: networks allowed to update metrics
+//   - read: networks allowed to read metrics
+//   - proxies: reverse proxies allowed to set X-Real-IP and X-Forwarded-For
+//
+// Returns:
+//   - *TrustedSubnet: the installed policy
+//   - error: if any of the lists contains an invalid network
+func NewTrustedSubnet(write, read, proxies string) (*TrustedSubnet, error) {
+	s := &TrustedSubnet{}
+	var err error
+	if s.write, err = parseNetworks(write); err != nil {
+		return nil, err
+	}
+	if s.read, err = parseNetworks(read); err != nil {
+		return nil, err
+	}
+	if s.proxies, err = parseNetworks(proxies); err != nil {
+		return nil, err
+	}
+	SubnetInstance = s
+	return s, nil
+}
+
+// TrustedSubnetHandle returns a middleware that rejects requests from clients
+// outside of the networks configured for the given access kind with 403 Forbidden.
+// Admin routes share the write policy.
+func TrustedSubnetHandle(access Access) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+			networks := SubnetInstance.read
+			if access != ReadAccess {
+				networks = SubnetInstance.write
+			}
+			if len(networks) == 0 {
+				next.ServeHTTP(res, r)
+				return
+			}
+			ip := SubnetInstance.ClientIP(r)
+			if ip == nil || !contains(networks, ip) {
+				logger.LogError(ErrForbiddenAddress, ip)
+				WriteError(res, http.StatusForbidden, CodeForbidden, ErrForbiddenAddress.Error())
+				return
+			}
+			next.ServeHTTP(res, r)
+		})
+	}
+}
+
+// ClientIP resolves the address of the client that made the request.
+// The address of the connection is used unless it belongs to a trusted
+// proxy: only then X-Real-IP or, without it, X-Forwarded-For is consulted,
+// so a client can not choose its address by sending the headers itself.
+func (s *TrustedSubnet) ClientIP(r *http.Request) net.IP {
+	remote := remoteIP(r.RemoteAddr)
+	if remote == nil || !contains(s.proxies, remote) {
+		return remote
+	}
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		return net.ParseIP(strings.TrimSpace(realIP))
+	}
+	// идём справа налево и берём первый адрес, который не принадлежит прокси
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+		if !contains(s.proxies, ip) {
+			return ip
+		}
+	}
+	return remote
+}
+
+// trustedWriter reports whether ip belongs to the configured write
+// networks, false when none are configured.
+func (s *TrustedSubnet) trustedWriter(ip net.IP) bool {
+	return ip != nil && contains(s.write, ip)
+}
+
+func remoteIP(remoteAddr string) net.IP {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseNetworks(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		// одиночный адрес без маски считаем сетью из одного хоста
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, ErrInvalidSubnet
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, ErrInvalidSubnet
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
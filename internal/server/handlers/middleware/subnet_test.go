@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTrustedSubnet(t *testing.T) {
+	originalInstance := SubnetInstance
+	defer func() {
+		SubnetInstance = originalInstance
+	}()
+
+	tests := []struct {
+		name    string
+		write   string
+		read    string
+		proxies string
+		wantErr bool
+	}{
+		{name: "empty lists", wantErr: false},
+		{name: "several networks", write: "10.0.0.0/8, 192.168.1.0/24", read: "0.0.0.0/0", wantErr: false},
+		{name: "single address", write: "127.0.0.1", proxies: "::1", wantErr: false},
+		{name: "invalid network", write: "10.0.0.0/33", wantErr: true},
+		{name: "invalid address", read: "not-an-ip", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTrustedSubnet(tt.write, tt.read, tt.proxies)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSubnet)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestTrustedSubnetHandle(t *testing.T) {
+	originalInstance := SubnetInstance
+	defer func() {
+		SubnetInstance = originalInstance
+	}()
+
+	tests := []struct {
+		name         string
+		write        string
+		read         string
+		proxies      string
+		access       Access
+		remoteAddr   string
+		headers      map[string]string
+		expectStatus int
+	}{
+		{
+			name:         "No subnet configured - pass through",
+			access:       WriteAccess,
+			remoteAddr:   "8.8.8.8:1234",
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "X-Real-IP ignored without trusted proxies",
+			write:        "192.168.1.0/24",
+			access:       WriteAccess,
+			remoteAddr:   "8.8.8.8:1234",
+			headers:      map[string]string{"X-Real-IP": "192.168.1.10"},
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "X-Real-IP from trusted proxy",
+			write:        "192.168.1.0/24",
+			proxies:      "10.0.0.1",
+			access:       WriteAccess,
+			remoteAddr:   "10.0.0.1:1234",
+			headers:      map[string]string{"X-Real-IP": "192.168.1.10"},
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Write from untrusted X-Real-IP",
+			write:        "192.168.1.0/24",
+			proxies:      "192.168.1.10",
+			access:       WriteAccess,
+			remoteAddr:   "192.168.1.10:1234",
+			headers:      map[string]string{"X-Real-IP": "10.0.0.1"},
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "Write without header uses RemoteAddr",
+			write:        "192.168.1.0/24",
+			access:       WriteAccess,
+			remoteAddr:   "192.168.1.10:1234",
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Read is not restricted by write policy",
+			write:        "192.168.1.0/24",
+			access:       ReadAccess,
+			remoteAddr:   "8.8.8.8:1234",
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "Read policy rejects outsider",
+			read:         "10.0.0.0/8",
+			access:       ReadAccess,
+			remoteAddr:   "8.8.8.8:1234",
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "X-Real-IP ignored when not sent by trusted proxy",
+			write:        "192.168.1.0/24",
+			proxies:      "10.0.0.1",
+			access:       WriteAccess,
+			remoteAddr:   "8.8.8.8:1234",
+			headers:      map[string]string{"X-Real-IP": "192.168.1.10"},
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "X-Forwarded-For from trusted proxy",
+			write:        "192.168.1.0/24",
+			proxies:      "10.0.0.0/24",
+			access:       WriteAccess,
+			remoteAddr:   "10.0.0.1:1234",
+			headers:      map[string]string{"X-Forwarded-For": "8.8.8.8, 192.168.1.10, 10.0.0.2"},
+			expectStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTrustedSubnet(tt.write, tt.read, tt.proxies)
+			require.NoError(t, err)
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			wrappedHandler := TrustedSubnetHandle(tt.access)(handler)
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/update/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+
+			wrappedHandler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectStatus, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/query"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// QueryHandler handles GET /api/v1/query.
+// Query parameters:
+//   - q: the query, e.g. sum by (host) (rate(PollCount[5m]))
+//
+// Returns a query.Result as JSON. Syntax errors are answered with 400 and
+// the position of the error in details, queries exceeding query.DefaultLimits
+// with 422 or 503 on timeout.
+func QueryHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("QueryHandler")
+	markNodeLocal(res)
+	q := req.URL.Query().Get("q")
+	if q == "" {
+		writeError(res, invalidQuery(errors.New("q is required")))
+		return
+	}
+	expr, err := query.Parse(q)
+	if err != nil {
+		var parseErr *query.ParseError
+		if errors.As(err, &parseErr) {
+			err = withDetails(err, map[string]int{"position": parseErr.Pos + 1})
+		}
+		writeError(res, invalidQuery(err))
+		return
+	}
+	result, err := query.Eval(req.Context(), storage.StorageInstance, expr, time.Now(), query.DefaultLimits)
+	if err != nil {
+		writeError(res, fmt.Errorf("%s: %w", q, err))
+		return
+	}
+	writeJSON(res, http.StatusOK, result)
+}
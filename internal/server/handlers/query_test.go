@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestQueryHandler(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	for _, m := range []metrics.Metrics{
+		{ID: "HeapInuse", MType: constants.Gauge, Value: utils.FloatToPointerFloat(50), Labels: map[string]string{"host": "a"}},
+		{ID: "HeapSys", MType: constants.Gauge, Value: utils.FloatToPointerFloat(200), Labels: map[string]string{"host": "a"}},
+		{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(2)},
+	} {
+		require.NoError(t, storage.StorageInstance.SaveMetric(&m))
+	}
+
+	type want struct {
+		code    int
+		body    string
+		errCode string
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  want
+	}{
+		{name: "Scalar", query: "2 * 21", want: want{code: http.StatusOK, body: `{"result_type":"scalar","result":42}`}},
+		{name: "Ratio", query: "HeapInuse / HeapSys * 100", want: want{code: http.StatusOK, body: `{"result_type":"vector","result":[{"labels":{"host":"a"},"value":25}]}`}},
+		{name: "Aggregation", query: `sum({__name__=~"Heap.*"})`, want: want{code: http.StatusOK, body: `{"result_type":"vector","result":[{"value":250}]}`}},
+		{name: "No query", want: want{code: http.StatusBadRequest, errCode: CodeInvalidQuery}},
+		{name: "Syntax error", query: "sum(", want: want{code: http.StatusBadRequest, errCode: CodeInvalidQuery}},
+		{name: "Type error", query: "rate(PollCount)", want: want{code: http.StatusBadRequest, errCode: CodeInvalidQuery}},
+		{name: "Execution error", query: "1 / 0", want: want{code: http.StatusUnprocessableEntity, errCode: CodeQueryFailed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			target := "/api/v1/query"
+			if tt.query != "" {
+				target += "?q=" + url.QueryEscape(tt.query)
+			}
+			QueryHandler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+			require.Equal(t, tt.want.code, rec.Code)
+			if tt.want.errCode != "" {
+				var body APIError
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, tt.want.errCode, body.Code)
+				return
+			}
+			assert.JSONEq(t, tt.want.body, rec.Body.String())
+		})
+	}
+}
+
+func TestQueryHandler_ParseErrorPosition(t *testing.T) {
+	rec := httptest.NewRecorder()
+	QueryHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/query?q="+url.QueryEscape("HeapInuse +"), nil))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"position":12}`, string(mustField(t, rec.Body.Bytes(), "details")))
+}
+
+func mustField(t *testing.T, body []byte, field string) json.RawMessage {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &fields))
+	return fields[field]
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/relay"
+)
+
+// RelayStatusHandler handles GET /relay/status.
+// Returns the relay.Status of a relay: pending and queued updates and
+// the result of the last delivery to the central server.
+// A server that is not a relay answers 409 with the code not_relay.
+func RelayStatusHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("RelayStatusHandler")
+	if relay.Instance == nil {
+		writeError(res, relay.ErrNotRelay)
+		return
+	}
+	writeJSON(res, http.StatusOK, relay.Instance.Status())
+}
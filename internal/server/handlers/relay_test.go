@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/relay"
+)
+
+func TestRelayStatusHandler(t *testing.T) {
+	original := relay.Instance
+	defer func() {
+		relay.Instance = original
+	}()
+
+	relay.Instance = nil
+	rec := httptest.NewRecorder()
+	RelayStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/relay/status", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), CodeNotRelay)
+
+	q, err := relay.OpenQueue(t.TempDir(), 0)
+	require.NoError(t, err)
+	relay.Instance = relay.New("central:8080", nil, q, time.Hour)
+	rec = httptest.NewRecorder()
+	RelayStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/relay/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status relay.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, relay.Status{Upstream: "central:8080"}, status)
+}
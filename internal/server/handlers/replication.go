@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/follower"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// replicationBatchSize is the number of log entries written between flushes.
+const replicationBatchSize = 1000
+
+// replicationSnapshot is the body of GET /replication/snapshot.
+type replicationSnapshot struct {
+	Epoch    string                   `json:"epoch"`
+	Seq      uint64                   `json:"seq"`
+	Snapshot *metricsService.Snapshot `json:"snapshot"`
+}
+
+// ReplicationSnapshotHandler handles GET /replication/snapshot.
+// Returns a snapshot of all metrics with the epoch and the sequence number
+// of the last change it contains, a follower streams the changes after it.
+// A follower answers 409 with the code not_leader.
+func ReplicationSnapshotHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("ReplicationSnapshotHandler")
+	var snap *metricsService.Snapshot
+	epoch, seq, err := replication.Instance.Consistent(func() error {
+		var err error
+		snap, err = metricsService.TakeSnapshot(storage.StorageInstance, time.Now())
+		return err
+	})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, replicationSnapshot{Epoch: epoch, Seq: seq, Snapshot: snap})
+}
+
+// ReplicationStreamHandler handles GET /replication/stream.
+// Query parameters:
+//   - epoch: epoch of the snapshot the follower restored
+//   - since: sequence number of the last change the follower applied
+//
+// Streams the log entries after since as newline delimited JSON and then
+// every new entry as it is recorded. Idle streams get an empty line every
+// keepAliveInterval. Answers 410 with the code replication_gap when the
+// entries are no longer kept or the epoch differs, the follower has to
+// restore a snapshot then. The stream ends when the follower falls that far behind.
+func ReplicationStreamHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("ReplicationStreamHandler")
+	values := req.URL.Query()
+	epoch := values.Get("epoch")
+	if epoch == "" {
+		writeError(res, invalidQuery(errors.New("epoch is required")))
+		return
+	}
+	since, err := strconv.ParseUint(values.Get("since"), 10, 64)
+	if err != nil {
+		writeError(res, invalidQuery(errors.New("since must be a sequence number")))
+		return
+	}
+	entries, changed, err := replication.Instance.Since(epoch, since, replicationBatchSize)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	rc := http.NewResponseController(res)
+	res.Header().Set("Content-Type", "application/x-ndjson")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	enc := json.NewEncoder(res)
+	for {
+		for i := range entries {
+			if err = enc.Encode(&entries[i]); err != nil {
+				logger.LogError(err)
+				return
+			}
+			since = entries[i].Seq
+		}
+		if err = rc.Flush(); err != nil {
+			logger.LogError(err)
+			return
+		}
+		if len(entries) < replicationBatchSize {
+			select {
+			case <-req.Context().Done():
+				return
+			case <-keepAlive.C:
+				if _, err = res.Write([]byte("\n")); err != nil {
+					logger.LogError(err)
+					return
+				}
+			case <-changed:
+			}
+		}
+		entries, changed, err = replication.Instance.Since(epoch, since, replicationBatchSize)
+		if err != nil {
+			logger.LogInfo("replication stream ends: ", err)
+			return
+		}
+	}
+}
+
+// ReplicationStatusHandler handles GET /replication/status.
+// Returns the replication.Status of the server as a leader or a follower.
+func ReplicationStatusHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("ReplicationStatusHandler")
+	if follower.Instance != nil {
+		writeJSON(res, http.StatusOK, follower.Instance.Status())
+		return
+	}
+	writeJSON(res, http.StatusOK, replication.Instance.Status())
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/handlers/middleware"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestReplicationSnapshotHandler(t *testing.T) {
+	original := replication.Instance
+	defer func() {
+		replication.Instance = original
+	}()
+	replication.Instance = replication.New(10)
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	require.NoError(t, metricsService.Update(storage.StorageInstance, &metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)}))
+
+	rec := httptest.NewRecorder()
+	ReplicationSnapshotHandler(rec, httptest.NewRequest(http.MethodGet, "/replication/snapshot", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body replicationSnapshot
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, replication.Instance.Status().Epoch, body.Epoch)
+	assert.Equal(t, uint64(1), body.Seq)
+	require.NoError(t, body.Snapshot.Verify())
+	require.Len(t, body.Snapshot.Metrics, 1)
+
+	replication.Instance = replication.New(0)
+	rec = httptest.NewRecorder()
+	ReplicationSnapshotHandler(rec, httptest.NewRequest(http.MethodGet, "/replication/snapshot", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), CodeNotLeader)
+}
+
+func TestReplicationStreamHandler_Errors(t *testing.T) {
+	original := replication.Instance
+	defer func() {
+		replication.Instance = original
+	}()
+	replication.Instance = replication.New(10)
+	epoch := replication.Instance.Status().Epoch
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		wantBody string
+	}{
+		{name: "No epoch", target: "/replication/stream?since=0", wantCode: http.StatusBadRequest, wantBody: CodeInvalidQuery},
+		{name: "Broken since", target: "/replication/stream?since=-1&epoch=" + epoch, wantCode: http.StatusBadRequest, wantBody: CodeInvalidQuery},
+		{name: "Other epoch", target: "/replication/stream?since=0&epoch=old", wantCode: http.StatusGone, wantBody: CodeReplicationGap},
+		{name: "Ahead", target: "/replication/stream?since=5&epoch=" + epoch, wantCode: http.StatusGone, wantBody: CodeReplicationGap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ReplicationStreamHandler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestReplicationStreamHandler(t *testing.T) {
+	original := replication.Instance
+	defer func() {
+		replication.Instance = original
+	}()
+	replication.Instance = replication.New(10)
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	require.NoError(t, metricsService.Update(storage.StorageInstance, &metrics.Metrics{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(2)}))
+
+	server := httptest.NewServer(middleware.WithLogging(middleware.GzipHandle(ReplicationStreamHandler)))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?since=0&epoch="+replication.Instance.Status().Epoch, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	// поток сжимается и сбрасывается клиенту запись за записью
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	gz, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	body := bufio.NewReader(gz)
+	line, err := body.ReadString('\n')
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"seq":1,"op":"update","metrics":[{"id":"PollCount","type":"counter","delta":2}]}`, line)
+
+	// новые изменения приходят в уже открытый поток
+	require.NoError(t, metricsService.Delete(storage.StorageInstance, constants.Counter, "PollCount"))
+	line, err = body.ReadString('\n')
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"seq":2,"op":"delete","metrics":[{"id":"PollCount","type":"counter"}]}`, line)
+
+	// закрытие журнала завершает поток
+	replication.Instance.Close()
+	_, err = body.ReadString('\n')
+	assert.Error(t, err)
+}
+
+func TestReplicationStatusHandler(t *testing.T) {
+	original := replication.Instance
+	defer func() {
+		replication.Instance = original
+	}()
+	replication.Instance = replication.New(10)
+
+	rec := httptest.NewRecorder()
+	ReplicationStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/replication/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status replication.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, replication.RoleLeader, status.Role)
+	assert.Equal(t, replication.Instance.Status().Epoch, status.Epoch)
+}
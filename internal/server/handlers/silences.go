@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/silences"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/silence"
+)
+
+// createSilenceRequest is the body accepted by CreateSilenceHandler.
+// Duration is an alternative to ends_at, e.g. "30m" for a deploy.
+type createSilenceRequest struct {
+	silences.Silence
+	Duration string `json:"duration,omitempty"`
+}
+
+// silenceResponse is a silence with its state at the time of the request.
+type silenceResponse struct {
+	silences.Silence
+	Status string `json:"status"`
+}
+
+// GetSilencesHandler handles HTTP GET requests to list the silences
+// with their status: pending, active or expired.
+func GetSilencesHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("GetSilencesHandler")
+	err := checkForAllowedMethod(req, []string{http.MethodGet})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	now := time.Now()
+	list := silence.Instance.List()
+	body := make([]silenceResponse, len(list))
+	for i, s := range list {
+		body[i] = silenceResponse{Silence: s, Status: s.Status(now)}
+	}
+	writeJSON(res, http.StatusOK, body)
+}
+
+// CreateSilenceHandler handles HTTP POST requests to create a silence.
+// Accepts {"matchers": [{"name": "host", "op": "=", "value": "a"}], "duration": "30m"}
+// or explicit starts_at and ends_at. The id and created_at are assigned by the server.
+// Responds with HTTP 201 on success or 400 for broken matchers or times.
+func CreateSilenceHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("CreateSilenceHandler")
+	err := checkForAllowedMethod(req, []string{http.MethodPost})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	var body createSilenceRequest
+	if err = json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(res, fmt.Errorf("%w: %w", ErrInvalidBody, err))
+		return
+	}
+	if body.Duration != "" {
+		if !body.EndsAt.IsZero() {
+			writeError(res, fmt.Errorf("%w: duration and ends_at are mutually exclusive", silence.ErrInvalidSilence))
+			return
+		}
+		d, err := time.ParseDuration(body.Duration)
+		if err != nil || d <= 0 {
+			writeError(res, fmt.Errorf("%w: duration %q is not a positive duration", silence.ErrInvalidSilence, body.Duration))
+			return
+		}
+		start := body.StartsAt
+		if start.IsZero() {
+			start = time.Now().UTC()
+			body.StartsAt = start
+		}
+		body.EndsAt = start.Add(d)
+	}
+	created, err := silence.Instance.Create(body.Silence)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusCreated, silenceResponse{Silence: *created, Status: created.Status(time.Now())})
+}
+
+// DeleteSilenceHandler handles HTTP DELETE requests to remove a silence,
+// its alerts are notified again from then on.
+// Expected URL format: /api/silences/<id>.
+// Responds with HTTP 204 on success or 404 if the silence does not exist.
+func DeleteSilenceHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("DeleteSilenceHandler")
+	err := checkForAllowedMethod(req, []string{http.MethodDelete})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(req.URL.Path, "/api/silences/"), "/")
+	if err = silence.Instance.Delete(id); err != nil {
+		writeError(res, err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
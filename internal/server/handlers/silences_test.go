@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/silence"
+)
+
+func TestSilenceHandlers(t *testing.T) {
+	originalInstance := silence.Instance
+	defer func() {
+		silence.Instance = originalInstance
+	}()
+	_, err := silence.New(filepath.Join(t.TempDir(), "silences.json"), nil)
+	require.NoError(t, err)
+
+	endsAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{name: "Duration", body: `{"matchers":[{"name":"host","value":"a"}],"duration":"30m","comment":"deploy"}`, wantCode: http.StatusCreated},
+		{name: "Ends at", body: `{"matchers":[{"name":"__name__","op":"=~","value":"Heap.*"}],"ends_at":"` + endsAt + `"}`, wantCode: http.StatusCreated},
+		{name: "Broken body", body: `{`, wantCode: http.StatusBadRequest, wantErr: CodeInvalidBody},
+		{name: "No end", body: `{"matchers":[{"name":"host","value":"a"}]}`, wantCode: http.StatusBadRequest, wantErr: CodeInvalidSilence},
+		{name: "Duration and end", body: `{"matchers":[{"name":"host","value":"a"}],"duration":"30m","ends_at":"` + endsAt + `"}`, wantCode: http.StatusBadRequest, wantErr: CodeInvalidSilence},
+		{name: "Broken duration", body: `{"matchers":[{"name":"host","value":"a"}],"duration":"soon"}`, wantCode: http.StatusBadRequest, wantErr: CodeInvalidSilence},
+		{name: "Silences everything", body: `{"matchers":[{"name":"host","op":"!=","value":"a"}],"duration":"1h"}`, wantCode: http.StatusBadRequest, wantErr: CodeInvalidSilence},
+	}
+	var created []silenceResponse
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			CreateSilenceHandler(rec, httptest.NewRequest(http.MethodPost, "/api/silences/", strings.NewReader(tt.body)))
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantErr != "" {
+				var body APIError
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, tt.wantErr, body.Code)
+				return
+			}
+			var body silenceResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.NotEmpty(t, body.ID)
+			assert.Equal(t, "active", body.Status)
+			created = append(created, body)
+		})
+	}
+	require.Len(t, created, 2)
+	assert.Equal(t, 30*time.Minute, created[0].EndsAt.Sub(created[0].StartsAt))
+
+	rec := httptest.NewRecorder()
+	GetSilencesHandler(rec, httptest.NewRequest(http.MethodGet, "/api/silences/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list []silenceResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Len(t, list, 2)
+
+	rec = httptest.NewRecorder()
+	DeleteSilenceHandler(rec, httptest.NewRequest(http.MethodDelete, "/api/silences/"+created[0].ID, nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = httptest.NewRecorder()
+	DeleteSilenceHandler(rec, httptest.NewRequest(http.MethodDelete, "/api/silences/"+created[0].ID, nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Len(t, silence.Instance.List(), 1)
+}
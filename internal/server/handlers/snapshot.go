@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// SnapshotHandler handles GET /admin/snapshot.
+// Returns a versioned, checksummed metricsService.Snapshot of all metrics
+// as a JSON attachment that RestoreHandler accepts.
+func SnapshotHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("SnapshotHandler")
+	snap, err := metricsService.TakeSnapshot(storage.StorageInstance, time.Now())
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	name := "snapshot-" + snap.CreatedAt.Format("20060102T150405Z") + ".json"
+	res.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	writeJSON(res, http.StatusOK, snap)
+}
+
+// RestoreHandler handles POST /admin/restore.
+// Accepts a snapshot produced by SnapshotHandler and replaces or merges
+// the current metrics with it, then persists them to the file or database.
+// Query parameters:
+//   - mode: replace (default) deletes metrics missing from the snapshot, merge keeps them
+//   - dry_run: true to only report the difference
+//
+// Returns a metricsService.RestoreResult as JSON.
+func RestoreHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("RestoreHandler")
+	mode, dryRun, err := parseRestoreQuery(req)
+	if err != nil {
+		writeError(res, invalidQuery(err))
+		return
+	}
+	var snap metricsService.Snapshot
+	if err = json.NewDecoder(req.Body).Decode(&snap); err != nil {
+		writeError(res, fmt.Errorf("%w: %w", ErrInvalidBody, err))
+		return
+	}
+	result, err := metricsService.Restore(storage.StorageInstance, &snap, mode, dryRun)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	if !dryRun {
+		if err = storage.Persist(); err != nil {
+			writeError(res, err)
+			return
+		}
+	}
+	writeJSON(res, http.StatusOK, result)
+}
+
+func parseRestoreQuery(req *http.Request) (string, bool, error) {
+	values := req.URL.Query()
+	mode := metricsService.RestoreReplace
+	if m := values.Get("mode"); m != "" {
+		mode = m
+	}
+	if mode != metricsService.RestoreReplace && mode != metricsService.RestoreMerge {
+		return "", false, fmt.Errorf("%w: %q", metricsService.ErrUnknownRestoreMode, mode)
+	}
+	dryRun := false
+	if d := values.Get("dry_run"); d != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(d); err != nil {
+			return "", false, fmt.Errorf("dry_run %q is not a boolean", d)
+		}
+	}
+	return mode, dryRun, nil
+}
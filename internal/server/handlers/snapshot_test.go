@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestSnapshotAndRestoreHandlers(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	require.NoError(t, storage.StorageInstance.SaveMetric(&metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1.5)}))
+	require.NoError(t, storage.StorageInstance.SaveMetric(&metrics.Metrics{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(7)}))
+
+	rec := httptest.NewRecorder()
+	SnapshotHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/snapshot", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Regexp(t, `^attachment; filename="snapshot-\d{8}T\d{6}Z\.json"$`, rec.Header().Get("Content-Disposition"))
+	snapshot := rec.Body.String()
+	var snap metricsService.Snapshot
+	require.NoError(t, json.Unmarshal([]byte(snapshot), &snap))
+	assert.Len(t, snap.Metrics, 2)
+
+	// состояние расходится со снимком
+	require.NoError(t, storage.StorageInstance.SaveMetric(&metrics.Metrics{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(3)}))
+	require.NoError(t, storage.StorageInstance.SaveMetric(&metrics.Metrics{ID: "Extra", MType: constants.Gauge, Value: utils.FloatToPointerFloat(9)}))
+
+	restore := func(target, body string) (*httptest.ResponseRecorder, metricsService.RestoreResult) {
+		rec := httptest.NewRecorder()
+		RestoreHandler(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		var result metricsService.RestoreResult
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		}
+		return rec, result
+	}
+
+	rec, result := restore("/admin/restore?dry_run=true", snapshot)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, metricsService.RestoreReplace, result.Mode)
+	require.Len(t, result.Changed, 1)
+	assert.Equal(t, int64(10), *result.Changed[0].Old.Delta)
+	require.Len(t, result.Removed, 1)
+	assert.Equal(t, "Extra", result.Removed[0].ID)
+	assert.Equal(t, 1, result.Unchanged)
+
+	rec, result = restore("/admin/restore?mode=merge", snapshot)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, result.Removed)
+	params := []*metrics.MetricDTOParams{}
+	all, err := storage.StorageInstance.GetMetrics(&params)
+	require.NoError(t, err)
+	assert.Len(t, *all, 3)
+
+	rec, _ = restore("/admin/restore", snapshot)
+	require.Equal(t, http.StatusOK, rec.Code)
+	all, err = storage.StorageInstance.GetMetrics(&params)
+	require.NoError(t, err)
+	assert.Len(t, *all, 2)
+	rec = httptest.NewRecorder()
+	SnapshotHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/snapshot", nil))
+	var again metricsService.Snapshot
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &again))
+	assert.Equal(t, snap.Checksum, again.Checksum)
+
+	tampered := strings.Replace(snapshot, `"value":1.5`, `"value":2.5`, 1)
+	for _, tt := range []struct {
+		name     string
+		target   string
+		body     string
+		wantCode string
+	}{
+		{name: "Tampered", target: "/admin/restore", body: tampered, wantCode: CodeInvalidSnapshot},
+		{name: "Unknown mode", target: "/admin/restore?mode=append", body: snapshot, wantCode: CodeInvalidQuery},
+		{name: "Broken dry_run", target: "/admin/restore?dry_run=maybe", body: snapshot, wantCode: CodeInvalidQuery},
+		{name: "Broken body", target: "/admin/restore", body: "{", wantCode: CodeInvalidBody},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := restore(tt.target, tt.body)
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			var body APIError
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantCode, body.Code)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/stream"
+)
+
+// keepAliveInterval is how often an idle stream sends a comment,
+// so that proxies do not close the connection.
+var keepAliveInterval = 15 * time.Second
+
+// droppedEvent is the data of the "dropped" event.
+type droppedEvent struct {
+	Dropped uint64 `json:"dropped"`
+}
+
+// StreamHandler handles GET /api/v1/stream.
+// Every accepted metric update is sent as a Server-Sent Event "update"
+// with the metric as JSON data.
+// Query parameters:
+//   - type: gauge or counter
+//   - prefix: metric name prefix
+//
+// When the subscriber buffer overflows, updates are dropped and the total
+// number of dropped updates is sent as a "dropped" event before the next update.
+func StreamHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("StreamHandler")
+	markNodeLocal(res)
+	filter := stream.Filter{Type: req.URL.Query().Get("type"), Prefix: req.URL.Query().Get("prefix")}
+	if filter.Type != "" && filter.Type != constants.Gauge && filter.Type != constants.Counter {
+		writeError(res, invalidQuery(ErrNoMetricsType))
+		return
+	}
+
+	sub := stream.Instance.Subscribe(filter)
+	defer stream.Instance.Unsubscribe(sub)
+
+	rc := http.NewResponseController(res)
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		logger.LogError(err)
+		return
+	}
+
+	serveEvents(req.Context(), res, sub)
+}
+
+// serveEvents writes updates of sub to res until the context is done
+// or the subscription is closed.
+func serveEvents(ctx context.Context, res http.ResponseWriter, sub *stream.Subscription) {
+	rc := http.NewResponseController(res)
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	var reported uint64
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(res, ": keep-alive\n\n")
+		case m, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if dropped := sub.Dropped(); dropped != reported {
+				reported = dropped
+				err = writeEvent(res, "dropped", droppedEvent{Dropped: dropped})
+			}
+			if err == nil {
+				err = writeEvent(res, "update", m)
+			}
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			logger.LogError(err)
+			return
+		}
+	}
+}
+
+func writeEvent(res http.ResponseWriter, event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, body)
+	return err
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/handlers/middleware"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/stream"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+// readEvent reads one event skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	original := stream.Instance
+	stream.Instance = stream.New(1)
+	defer func() {
+		stream.Instance = original
+	}()
+	storage.StorageInstance.ClearAll()
+
+	server := httptest.NewServer(middleware.WithLogging(middleware.GzipHandle(StreamHandler)))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?type=gauge&prefix=Heap", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	require.Equal(t, 1, stream.Instance.Subscribers())
+
+	// подписка уже зарегистрирована, отфильтрованные обновления до неё не доходят
+	require.NoError(t, metricsService.Update(storage.StorageInstance, &metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)}))
+	require.NoError(t, metricsService.UpdateMany(storage.StorageInstance, &[]metrics.Metrics{
+		{ID: "HeapCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(1)},
+		{ID: "HeapAlloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(2)},
+	}))
+
+	body := bufio.NewReader(resp.Body)
+	event, data := readEvent(t, body)
+	assert.Equal(t, "update", event)
+	assert.JSONEq(t, `{"id":"HeapAlloc","type":"gauge","value":2}`, data)
+
+	require.NoError(t, metricsService.Set(storage.StorageInstance, &metrics.Metrics{ID: "HeapIdle", MType: constants.Gauge, Value: utils.FloatToPointerFloat(4)}))
+	event, data = readEvent(t, body)
+	assert.Equal(t, "update", event)
+	assert.JSONEq(t, `{"id":"HeapIdle","type":"gauge","value":4}`, data)
+
+	// закрытие брокера завершает поток
+	stream.Instance.Close()
+	_, err = body.ReadString('\n')
+	assert.Error(t, err)
+}
+
+func TestServeEvents_Dropped(t *testing.T) {
+	b := stream.New(1)
+	sub := b.Subscribe(stream.Filter{})
+	b.Publish(
+		metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)},
+		metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(2)},
+	)
+	// буферизованное событие остаётся в канале после отписки
+	b.Unsubscribe(sub)
+	rec := httptest.NewRecorder()
+
+	serveEvents(context.Background(), rec, sub)
+
+	assert.Equal(t, "event: dropped\ndata: {\"dropped\":1}\n\n"+
+		"event: update\ndata: {\"id\":\"Alloc\",\"type\":\"gauge\",\"value\":1}\n\n", rec.Body.String())
+}
+
+func TestStreamHandler_InvalidType(t *testing.T) {
+	rec := httptest.NewRecorder()
+	StreamHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stream?type=histogram", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), CodeInvalidQuery)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/tokens"
+	"github.com/Maxim-Ba/metriccollector/internal/server/auth"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+// createTokenRequest is the body accepted by CreateTokenHandler.
+type createTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// createTokenResponse returns the issued token together with its secret.
+type createTokenResponse struct {
+	tokens.Token
+	Secret string `json:"token"`
+}
+
+// GetTokensHandler handles HTTP GET requests to list issued API tokens.
+// Token hashes are never returned.
+func GetTokensHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("GetTokensHandler")
+	err := checkForAllowedMethod(req, []string{http.MethodGet})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, auth.Instance.List())
+}
+
+// CreateTokenHandler handles HTTP POST requests to issue a new API token.
+// Accepts {"name": "...", "scopes": ["read"]} and returns the token with its secret.
+// Responds with HTTP 201 on success or 400 for unknown scopes.
+func CreateTokenHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("CreateTokenHandler")
+	err := checkForAllowedMethod(req, []string{http.MethodPost})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	var body createTokenRequest
+	if err = json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(res, fmt.Errorf("%w: %w", ErrInvalidBody, err))
+		return
+	}
+	if body.Name == "" {
+		writeError(res, fmt.Errorf("%w: name is required", ErrInvalidBody))
+		return
+	}
+	token, secret, err := auth.Instance.Create(body.Name, body.Scopes)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	created := createTokenResponse{Token: *token, Secret: secret}
+	created.Hash = ""
+	writeJSON(res, http.StatusCreated, created)
+}
+
+// DeleteTokenHandler handles HTTP DELETE requests to revoke an API token.
+// Expected URL format: /api/tokens/<id>.
+// Responds with HTTP 204 on success or 404 if the token does not exist.
+func DeleteTokenHandler(res http.ResponseWriter, req *http.Request) {
+	logger.LogInfo("DeleteTokenHandler")
+	err := checkForAllowedMethod(req, []string{http.MethodDelete})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(req.URL.Path, "/api/tokens/"), "/")
+	err = auth.Instance.Revoke(id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(res http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		logger.LogError(err)
+		res.WriteHeader(http.StatusInternalServerError)
+		utils.WrireZeroBytes(res)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	if _, err = res.Write(body); err != nil {
+		logger.LogError(err)
+	}
+}
@@ -0,0 +1,16 @@
+// Package openapi embeds the OpenAPI 3 document of the server
+// and a lightweight HTML viewer for it.
+package openapi
+
+import _ "embed"
+
+// Spec is the OpenAPI 3 document describing every route of router.New.
+// It is maintained by hand, router tests fail when a route is missing from it.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Viewer is an HTML page that renders Spec fetched from /openapi.json.
+//
+//go:embed index.html
+var Viewer []byte
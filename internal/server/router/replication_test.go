@@ -0,0 +1,166 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/follower"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/internal/signature"
+)
+
+// replica is the storage of the follower. Storage of the server is
+// global, so the follower keeps its metrics apart from the leader.
+type replica struct {
+	mu      sync.Mutex
+	metrics map[string]metrics.Metrics
+}
+
+func (r *replica) SaveMetric(m *metrics.Metrics) error {
+	list := []metrics.Metrics{*m}
+	return r.SaveMetrics(&list)
+}
+
+func (r *replica) SaveMetrics(list *[]metrics.Metrics) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range *list {
+		if old, ok := r.metrics[m.MType+"/"+m.ID]; ok && m.MType == constants.Counter {
+			delta := *old.Delta + *m.Delta
+			m.Delta = &delta
+		}
+		r.metrics[m.MType+"/"+m.ID] = m
+	}
+	return nil
+}
+
+func (r *replica) GetMetrics(*[]*metrics.MetricDTOParams) (*[]metrics.Metrics, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := []metrics.Metrics{}
+	for _, m := range r.metrics {
+		list = append(list, m)
+	}
+	return &list, nil
+}
+
+func (r *replica) SetMetric(m *metrics.Metrics) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[m.MType+"/"+m.ID] = *m
+	return nil
+}
+
+func (r *replica) DeleteMetric(mType, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.metrics, mType+"/"+name)
+	return nil
+}
+
+func (r *replica) SwapMetrics(stage func([]metrics.Metrics) ([]metrics.Metrics, []metrics.Metrics, error)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := make([]metrics.Metrics, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		all = append(all, m)
+	}
+	set, removed, err := stage(all)
+	if err != nil {
+		return err
+	}
+	for _, m := range removed {
+		delete(r.metrics, m.MType+"/"+m.ID)
+	}
+	for _, m := range set {
+		r.metrics[m.MType+"/"+m.ID] = m
+	}
+	return nil
+}
+
+// values returns the metrics of s by name.
+func values(t *testing.T, s metricsService.Storage) map[string]float64 {
+	all, err := s.GetMetrics(&[]*metrics.MetricDTOParams{})
+	require.NoError(t, err)
+	got := map[string]float64{}
+	for _, m := range *all {
+		got[m.ID] = metricsService.NumericValue(&m)
+	}
+	return got
+}
+
+func TestReplication(t *testing.T) {
+	original := replication.Instance
+	originalSignature := signature.Instance
+	defer func() {
+		replication.Instance = original
+		signature.Instance = originalSignature
+	}()
+	signature.New("", "")
+	replication.Instance = replication.New(100)
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+
+	leader := httptest.NewServer(New())
+	defer leader.Close()
+	send := func(method, path, body string) {
+		t.Helper()
+		req, err := http.NewRequest(method, leader.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := leader.Client().Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Less(t, resp.StatusCode, 300, method+" "+path)
+	}
+	send(http.MethodPost, "/update/counter/PollCount/3", "")
+	send(http.MethodPost, "/update/gauge/Alloc/1.5", "")
+
+	r := &replica{metrics: map[string]metrics.Metrics{}}
+	f, err := follower.New(leader.URL, "", r)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go f.Run(ctx)
+
+	// догоняет по снимку
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(map[string]float64{"PollCount": 3, "Alloc": 1.5}, values(t, r)) && f.Status().Connected
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, f.Status().Snapshots)
+
+	// затем применяет поток изменений
+	send(http.MethodPost, "/updates/", `[{"id":"PollCount","type":"counter","delta":2},{"id":"HeapAlloc","type":"gauge","value":7}]`)
+	send(http.MethodPut, "/api/v1/metrics/gauge/HeapAlloc", `{"value":8}`)
+	send(http.MethodDelete, "/api/v1/metrics/gauge/Alloc", "")
+	require.Eventually(t, func() bool {
+		return f.Status().Seq == replication.Instance.Status().Seq
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]float64{"PollCount": 5, "HeapAlloc": 8}, values(t, r))
+	assert.Equal(t, values(t, storage.StorageInstance), values(t, r))
+	assert.Equal(t, replication.Instance.Status().Epoch, f.Status().Epoch)
+
+	// перезапуск лидера начинает новую эпоху, фолловер снова берёт снимок
+	stopped := replication.Instance
+	replication.Instance = replication.New(100)
+	stopped.Close()
+	send(http.MethodPost, "/update/counter/PollCount/10", "")
+	require.Eventually(t, func() bool {
+		status := f.Status()
+		return status.Snapshots == 2 && status.Epoch == replication.Instance.Status().Epoch && status.Connected
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]float64{"PollCount": 15, "HeapAlloc": 8}, values(t, r))
+	assert.Empty(t, f.Status().LastError)
+}
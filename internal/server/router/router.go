@@ -19,39 +19,117 @@ type Middleware func(http.HandlerFunc) http.HandlerFunc
 // and middleware. The router includes:
 // - Debug profiling endpoints under /debug
 // - Metric retrieval and update endpoints
+// - Versioned REST API under /api/v1: metrics, stream, export, aggregates, rates, queries, anomalies, forecasts, scrape targets
+// - Prometheus exposition at /metrics
 // - Database health check endpoint
-// Middlewares are applied in the order: signature verification, storage sync,
-// gzip compression, and request logging.
+// - Alert silences under /api/silences
+// - Leader snapshot, change stream and status under /replication
+// - Rebalance and handoff of metrics between cluster nodes under /cluster
+// - Delivery status of a relay at /relay/status
+// - Metrics with their update times for other servers at /federate
+// - Snapshot backup and restore under /admin, restore with its own body limit
+// - OpenAPI document at /openapi.json and its viewer at /docs
+// Middlewares are applied in the order: token scope check, signature verification,
+// storage sync, gzip compression, body size limit, forwarding to the leader,
+// trusted subnet check, rate limiting and request logging.
+// Routes that change metrics are registered with writeMiddlewares, token
+// management and /admin routes with adminMiddlewares, so the subnet, scope
+// and rate limit checks apply the matching policy to them; admin routes
+// require an admin token even when authentication is disabled. On a follower
+// both are passed to the leader. The documentation routes
+// are public and only logged and compressed.
+// Every route must be described in openapi.Spec, see TestOpenAPICoversRoutes.
 func New() *chi.Mux {
 	r := chi.NewRouter()
 	r.Mount("/debug", m.Profiler())
 
 	r.Get("/", middlewares(handlers.GetAllHandler))
-
 	r.Route("/value", func(r chi.Router) {
 		r.Post("/", middlewares(handlers.GetOneHandler))
 		r.Get("/{metricType}/{metricName}", middlewares(handlers.GetOneHandlerByParams))
 	})
 	r.Route("/update", func(r chi.Router) {
-		r.Post("/", middlewares(handlers.UpdateHandler))
-		r.Post("/{metricType}/{metricName}/{value}", middlewares(handlers.UpdateHandlerByURLParams))
-		r.Get("/{metricType}/{metricName}/{value}", middlewares(handlers.UpdateHandlerByURLParams))
+		r.Post("/", writeMiddlewares(handlers.UpdateHandler))
+		r.Post("/{metricType}/{metricName}/{value}", writeMiddlewares(handlers.UpdateHandlerByURLParams))
+		r.Get("/{metricType}/{metricName}/{value}", writeMiddlewares(handlers.UpdateHandlerByURLParams))
 	})
 	r.Route("/updates", func(r chi.Router) {
-		r.Post("/", middlewares(handlers.UpdatesHandler))
+		r.Post("/", writeMiddlewares(handlers.UpdatesHandler))
 	})
-
 	r.Route("/ping", func(r chi.Router) {
 		r.Get("/", middlewares(handlers.PingDB))
 	})
+	r.Route("/api/v1/metrics", func(r chi.Router) {
+		r.Get("/", middlewares(handlers.ListMetricsHandler))
+		r.Get("/{type}/{name}", middlewares(handlers.GetMetricHandler))
+		r.Put("/{type}/{name}", writeMiddlewares(handlers.PutMetricHandler))
+		r.Delete("/{type}/{name}", writeMiddlewares(handlers.DeleteMetricHandler))
+	})
+	r.Get("/api/v1/stream", middlewares(handlers.StreamHandler))
+	r.Get("/api/v1/export", middlewares(handlers.ExportHandler))
+	r.Get("/api/v1/aggregate", middlewares(handlers.AggregateHandler))
+	r.Get("/api/v1/derived", middlewares(handlers.DerivedHandler))
+	r.Get("/api/v1/query", middlewares(handlers.QueryHandler))
+	r.Get("/api/v1/anomalies", middlewares(handlers.AnomaliesHandler))
+	r.Get("/api/v1/forecast", middlewares(handlers.ForecastHandler))
+	r.Get("/api/v1/targets", middlewares(handlers.TargetsHandler))
+	r.Get("/metrics", middlewares(handlers.PrometheusHandler))
+	r.Route("/api/tokens", func(r chi.Router) {
+		r.Get("/", adminMiddlewares(handlers.GetTokensHandler))
+		r.Post("/", adminMiddlewares(handlers.CreateTokenHandler))
+		r.Delete("/{id}", adminMiddlewares(handlers.DeleteTokenHandler))
+	})
+	r.Route("/api/silences", func(r chi.Router) {
+		r.Get("/", middlewares(handlers.GetSilencesHandler))
+		r.Post("/", writeMiddlewares(handlers.CreateSilenceHandler))
+		r.Delete("/{id}", writeMiddlewares(handlers.DeleteSilenceHandler))
+	})
+	r.Route("/replication", func(r chi.Router) {
+		r.Get("/snapshot", middlewares(handlers.ReplicationSnapshotHandler))
+		r.Get("/stream", middlewares(handlers.ReplicationStreamHandler))
+		r.Get("/status", middlewares(handlers.ReplicationStatusHandler))
+	})
+	r.Route("/cluster", func(r chi.Router) {
+		r.Post("/rebalance", adminMiddlewares(handlers.ClusterRebalanceHandler))
+		r.Post("/handoff", adminMiddlewares(handlers.ClusterHandoffHandler))
+	})
+	r.Get("/relay/status", middlewares(handlers.RelayStatusHandler))
+	r.Get("/federate", middlewares(handlers.FederateHandler))
+	r.Route("/admin", func(r chi.Router) {
+		r.Get("/snapshot", adminMiddlewares(handlers.SnapshotHandler))
+		r.Post("/restore", middleware.RestoreLimitHandle(adminMiddlewares(handlers.RestoreHandler)))
+	})
+	r.Get("/openapi.json", docsMiddlewares(handlers.OpenAPIHandler))
+	r.Get("/docs", docsMiddlewares(handlers.DocsHandler))
 	return r
 }
 
 func middlewares(next http.HandlerFunc) http.HandlerFunc {
+	return applyMiddlewares(next, middleware.ReadAccess)
+}
+
+func writeMiddlewares(next http.HandlerFunc) http.HandlerFunc {
+	return applyMiddlewares(next, middleware.WriteAccess)
+}
+
+func adminMiddlewares(next http.HandlerFunc) http.HandlerFunc {
+	return applyMiddlewares(next, middleware.AdminAccess)
+}
+
+func docsMiddlewares(next http.HandlerFunc) http.HandlerFunc {
+	return middleware.WithLogging(middleware.GzipHandle(next))
+}
+
+func applyMiddlewares(next http.HandlerFunc, access middleware.Access) http.HandlerFunc {
 	mids := []Middleware{
+		middleware.AuthHandle(access),
 		middleware.SignatureHandle,
 		storage.WithSyncLocalStorage,
 		middleware.GzipHandle,
+		middleware.BodyLimitHandle,
+		middleware.LeaderHandle(access),
+		middleware.TrustedSubnetHandle(access),
+		middleware.RateLimitHandle(access),
 		middleware.WithLogging,
 	}
 	for _, mid := range mids {
@@ -1,10 +1,14 @@
 package router
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/Maxim-Ba/metriccollector/internal/server/openapi"
 	"github.com/Maxim-Ba/metriccollector/internal/signature"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
@@ -69,3 +73,91 @@ func TestRoutePatterns(t *testing.T) {
 		})
 	}
 }
+
+// profilerMethods are the methods checked for routes of the chi profiler,
+// which registers its handlers for every method.
+var profilerMethods = []string{http.MethodGet}
+
+// specPaths maps chi patterns that cannot be written in OpenAPI
+// to the path documenting them.
+var specPaths = map[string]string{
+	"/debug/pprof/*": "/debug/pprof/{profile}",
+}
+
+func loadSpec(t *testing.T) map[string]any {
+	t.Helper()
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(openapi.Spec, &spec))
+	return spec
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	spec := loadSpec(t)
+	paths := spec["paths"].(map[string]any)
+
+	routes := map[string]bool{}
+	err := chi.Walk(New(), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/debug/") && !slices.Contains(profilerMethods, method) {
+			return nil
+		}
+		if alias, ok := specPaths[route]; ok {
+			route = alias
+		}
+		key := strings.ToLower(method) + " " + route
+		routes[key] = true
+
+		item, ok := paths[route].(map[string]any)
+		if assert.True(t, ok, "route %s is missing from openapi.json", route) {
+			assert.Contains(t, item, strings.ToLower(method), "operation %s is missing from openapi.json", key)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// и наоборот: в документе нет операций, которых нет в роутере
+	for path, item := range paths {
+		for method := range item.(map[string]any) {
+			if method == "parameters" {
+				continue
+			}
+			assert.True(t, routes[method+" "+path], "openapi.json describes %s %s, which is not routed", method, path)
+		}
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	spec := loadSpec(t)
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch v := node.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				var target any = spec
+				for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					obj, _ := target.(map[string]any)
+					target = obj[key]
+				}
+				assert.NotNil(t, target, "unresolved $ref %s", ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+}
+
+func TestDocsRoutes(t *testing.T) {
+	r := New()
+	for path, contentType := range map[string]string{"/openapi.json": "application/json", "/docs": "text/html"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Contains(t, rec.Header().Get("Content-Type"), contentType, path)
+	}
+}
@@ -0,0 +1,220 @@
+// Package anomaly flags gauge samples that deviate from the recent
+// behaviour of the gauge. Every gauge keeps an exponentially weighted
+// moving average and variance, a sample whose z-score against them
+// exceeds the threshold is recorded as an Event.
+package anomaly
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+)
+
+// Detector defaults.
+const (
+	// DefaultThreshold is the z-score from which a sample is an anomaly.
+	DefaultThreshold = 3.0
+	// DefaultAlpha is the weight of the newest sample in the moving average,
+	// older samples fade out after roughly 2/DefaultAlpha updates.
+	DefaultAlpha = 0.1
+	// DefaultWarmup is the number of samples a gauge needs before it can be flagged.
+	DefaultWarmup = 10
+	// DefaultEventsSize is the number of events kept.
+	DefaultEventsSize = 1000
+)
+
+// Event is a gauge sample flagged as an anomaly.
+// Fields:
+//   - Seq: increasing number of the event, used to poll for new events
+//   - Time: time the sample was received
+//   - ID: gauge name
+//   - Labels: gauge labels
+//   - Value: the sample
+//   - Mean, StdDev: moving average and standard deviation before the sample
+//   - ZScore: (Value - Mean) / StdDev, negative for drops
+type Event struct {
+	Seq    uint64            `json:"seq"`
+	Time   time.Time         `json:"time"`
+	ID     string            `json:"id"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+	Mean   float64           `json:"mean"`
+	StdDev float64           `json:"stddev"`
+	ZScore float64           `json:"z_score"`
+}
+
+// ewma is the moving state of one gauge.
+type ewma struct {
+	mean     float64
+	variance float64
+	n        int
+}
+
+// Detector keeps the moving state of every gauge and the latest events.
+type Detector struct {
+	mu        sync.Mutex
+	enabled   bool
+	threshold float64
+	alpha     float64
+	warmup    int
+	gauges    map[string]*ewma
+	events    []Event
+	next      int
+	full      bool
+	seq       uint64
+	handlers  []func(Event)
+	now       func() time.Time
+}
+
+// Instance is the global detector fed by the metric service.
+// It is disabled by default.
+var Instance = New(false, DefaultThreshold)
+
+// New creates a detector.
+// Parameters:
+//   - enabled: whether Observe looks at samples at all
+//   - threshold: absolute z-score from which a sample is an anomaly, DefaultThreshold when not positive
+//
+// Returns:
+//   - *Detector: detector without state
+func New(enabled bool, threshold float64) *Detector {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	return &Detector{
+		enabled:   enabled,
+		threshold: threshold,
+		alpha:     DefaultAlpha,
+		warmup:    DefaultWarmup,
+		gauges:    map[string]*ewma{},
+		events:    make([]Event, DefaultEventsSize),
+		now:       time.Now,
+	}
+}
+
+// Enabled reports whether the detector looks at samples.
+func (d *Detector) Enabled() bool {
+	return d.enabled
+}
+
+// Threshold returns the z-score from which a sample is an anomaly.
+func (d *Detector) Threshold() float64 {
+	return d.threshold
+}
+
+// OnAnomaly registers fn to be called with every new event, the server
+// logs the events that are not silenced, see Unsilenced and LogEvent.
+// fn is called synchronously and must not block.
+func (d *Detector) OnAnomaly(fn func(Event)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers = append(d.handlers, fn)
+}
+
+// Observe feeds accepted updates to the detector. Counters and
+// values that are not finite are ignored. A sample is compared with the
+// state before it and then included in the state, so a lasting shift
+// stops being flagged once the average has caught up.
+func (d *Detector) Observe(updates ...metrics.Metrics) {
+	if !d.enabled {
+		return
+	}
+	var found []Event
+	d.mu.Lock()
+	now := d.now()
+	for _, m := range updates {
+		if m.MType != constants.Gauge || m.Value == nil {
+			continue
+		}
+		x := *m.Value
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			continue
+		}
+		st, ok := d.gauges[m.ID]
+		if !ok {
+			st = &ewma{}
+			d.gauges[m.ID] = st
+		}
+		// дисперсия постоянного ряда нулевая, z-оценка для него не определена
+		if std := math.Sqrt(st.variance); st.n >= d.warmup && std > 0 {
+			if z := (x - st.mean) / std; math.Abs(z) >= d.threshold {
+				d.seq++
+				e := Event{Seq: d.seq, Time: now, ID: m.ID, Labels: m.Labels, Value: x, Mean: st.mean, StdDev: std, ZScore: z}
+				d.record(e)
+				found = append(found, e)
+			}
+		}
+		st.add(x, d.alpha)
+	}
+	handlers := d.handlers
+	d.mu.Unlock()
+
+	for _, e := range found {
+		for _, fn := range handlers {
+			fn(e)
+		}
+	}
+}
+
+func (s *ewma) add(x, alpha float64) {
+	s.n++
+	if s.n == 1 {
+		s.mean = x
+		return
+	}
+	diff := x - s.mean
+	incr := alpha * diff
+	s.mean += incr
+	s.variance = (1 - alpha) * (s.variance + diff*incr)
+}
+
+func (d *Detector) record(e Event) {
+	d.events[d.next] = e
+	d.next = (d.next + 1) % len(d.events)
+	if d.next == 0 {
+		d.full = true
+	}
+}
+
+// Forget drops the state of a gauge, e.g. when it is deleted,
+// so a new gauge with the same name starts a new warmup.
+func (d *Detector) Forget(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.gauges, id)
+}
+
+// Query selects events.
+// Fields:
+//   - Since: only events with a greater Seq
+//   - ID: only events of this gauge, all when empty
+//   - Limit: maximum number of events, the oldest matching events are returned first
+type Query struct {
+	Since uint64
+	ID    string
+	Limit int
+}
+
+// Events returns the kept events matching q from the oldest to the newest.
+func (d *Detector) Events(q Query) []Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ordered := d.events[:d.next]
+	if d.full {
+		ordered = append(append([]Event(nil), d.events[d.next:]...), d.events[:d.next]...)
+	}
+	list := []Event{}
+	for _, e := range ordered {
+		if e.Seq <= q.Since || q.ID != "" && e.ID != q.ID {
+			continue
+		}
+		list = append(list, e)
+		if q.Limit > 0 && len(list) == q.Limit {
+			break
+		}
+	}
+	return list
+}
@@ -0,0 +1,147 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+)
+
+func gauge(id string, v float64) metrics.Metrics {
+	return metrics.Metrics{ID: id, MType: "gauge", Value: &v}
+}
+
+// feed sends the values of one gauge to d.
+func feed(d *Detector, id string, values ...float64) {
+	for _, v := range values {
+		d.Observe(gauge(id, v))
+	}
+}
+
+// noisy returns n values alternating around 50.
+func noisy(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = 50 + float64(i%3-1)
+	}
+	return values
+}
+
+func TestDetector_Observe(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		threshold  float64
+		values     []float64
+		wantEvents int
+		wantZ      float64
+	}{
+		{name: "Disabled", values: append(noisy(20), 500)},
+		{name: "Stable", enabled: true, values: noisy(50)},
+		{name: "Spike", enabled: true, values: append(noisy(20), 500), wantEvents: 1, wantZ: 1},
+		{name: "Drop", enabled: true, values: append(noisy(20), -500), wantEvents: 1, wantZ: -1},
+		{name: "Spike during warmup", enabled: true, values: append(noisy(5), 500)},
+		{name: "Constant gauge", enabled: true, values: []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 7}},
+		{name: "High threshold", enabled: true, threshold: 1000, values: append(noisy(20), 500)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New(tt.enabled, tt.threshold)
+			feed(d, "CPUutilization1", tt.values...)
+			events := d.Events(Query{})
+			require.Len(t, events, tt.wantEvents)
+			if tt.wantEvents > 0 {
+				e := events[0]
+				assert.Equal(t, "CPUutilization1", e.ID)
+				assert.Equal(t, tt.values[len(tt.values)-1], e.Value)
+				assert.InDelta(t, 50, e.Mean, 1)
+				assert.Greater(t, e.ZScore*tt.wantZ, DefaultThreshold)
+			}
+		})
+	}
+}
+
+func TestDetector_ShiftIsLearned(t *testing.T) {
+	d := New(true, 0)
+	feed(d, "Load", noisy(20)...)
+	// уровень сменился: первые значения аномальны, затем среднее догоняет
+	for i := 0; i < 100; i++ {
+		feed(d, "Load", 100+float64(i%3-1))
+	}
+	events := d.Events(Query{})
+	require.NotEmpty(t, events)
+	assert.Less(t, len(events), 20)
+	feed(d, "Load", 100)
+	assert.Len(t, d.Events(Query{}), len(events))
+}
+
+func TestDetector_IgnoresCountersAndNonFinite(t *testing.T) {
+	d := New(true, 0)
+	feed(d, "Alloc", noisy(20)...)
+	delta := int64(1000)
+	d.Observe(metrics.Metrics{ID: "Alloc", MType: "counter", Delta: &delta})
+	feed(d, "Alloc", 50)
+	assert.Empty(t, d.Events(Query{}))
+}
+
+func TestDetector_Events(t *testing.T) {
+	d := New(true, 0)
+	d.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+	var notified []Event
+	d.OnAnomaly(func(e Event) {
+		notified = append(notified, e)
+	})
+	feed(d, "A", noisy(20)...)
+	feed(d, "B", noisy(20)...)
+	feed(d, "A", 500)
+	feed(d, "B", 500)
+	feed(d, "A", -500)
+
+	tests := []struct {
+		name    string
+		q       Query
+		wantSeq []uint64
+	}{
+		{name: "All", q: Query{}, wantSeq: []uint64{1, 2, 3}},
+		{name: "Since", q: Query{Since: 1}, wantSeq: []uint64{2, 3}},
+		{name: "By gauge", q: Query{ID: "A"}, wantSeq: []uint64{1, 3}},
+		{name: "Limit keeps the oldest", q: Query{Limit: 2}, wantSeq: []uint64{1, 2}},
+		{name: "Nothing new", q: Query{Since: 3}, wantSeq: []uint64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := []uint64{}
+			for _, e := range d.Events(tt.q) {
+				seq = append(seq, e.Seq)
+			}
+			assert.Equal(t, tt.wantSeq, seq)
+		})
+	}
+	assert.Len(t, notified, 3)
+}
+
+func TestDetector_EventsRing(t *testing.T) {
+	d := New(true, 0)
+	d.events = make([]Event, 2)
+	feed(d, "A", noisy(20)...)
+	for i := 0; i < 3; i++ {
+		feed(d, "A", 1e6*float64(i+1))
+		feed(d, "A", noisy(200)...)
+	}
+	events := d.Events(Query{})
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(2), events[0].Seq)
+	assert.Equal(t, uint64(3), events[1].Seq)
+}
+
+func TestDetector_Forget(t *testing.T) {
+	d := New(true, 0)
+	feed(d, "A", noisy(20)...)
+	d.Forget("A")
+	// после удаления датчик снова проходит прогрев
+	feed(d, "A", 500)
+	assert.Empty(t, d.Events(Query{}))
+}
@@ -0,0 +1,38 @@
+package anomaly
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/silences"
+)
+
+// Silences reports whether notifications about a metric are suppressed,
+// it is implemented by silence.Silencer.
+type Silences interface {
+	Silenced(name string, labels map[string]string, t time.Time) (*silences.Silence, bool)
+}
+
+// Unsilenced wraps an OnAnomaly handler so that it is not called for
+// events matching an active silence of s at the time of the event.
+// Silenced events are still recorded and returned by Events.
+// Parameters:
+//   - s: silences to consult, usually silence.Instance
+//   - notify: handler called with the events that are not silenced
+//
+// Returns:
+//   - func(Event): handler for OnAnomaly
+func Unsilenced(s Silences, notify func(Event)) func(Event) {
+	return func(e Event) {
+		if _, ok := s.Silenced(e.ID, e.Labels, e.Time); ok {
+			return
+		}
+		notify(e)
+	}
+}
+
+// LogEvent writes the event to the server log.
+func LogEvent(e Event) {
+	logger.LogInfo(fmt.Sprintf("anomaly: %s=%g, mean %g, z-score %.2f", e.ID, e.Value, e.Mean, e.ZScore))
+}
@@ -0,0 +1,52 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/silences"
+)
+
+// hostSilence silences one gauge on one host until ends.
+type hostSilence struct {
+	id, host string
+	ends     time.Time
+}
+
+func (s hostSilence) Silenced(name string, labels map[string]string, t time.Time) (*silences.Silence, bool) {
+	if name != s.id || labels["host"] != s.host || !t.Before(s.ends) {
+		return nil, false
+	}
+	return &silences.Silence{ID: "deploy"}, true
+}
+
+func TestUnsilenced(t *testing.T) {
+	now := time.Now()
+	s := hostSilence{id: "CPUutilization1", host: "a", ends: now.Add(time.Hour)}
+
+	tests := []struct {
+		name         string
+		event        Event
+		wantNotified bool
+	}{
+		{name: "Silenced", event: Event{ID: "CPUutilization1", Labels: map[string]string{"host": "a"}, Time: now}},
+		{name: "Other host", event: Event{ID: "CPUutilization1", Labels: map[string]string{"host": "b"}, Time: now}, wantNotified: true},
+		{name: "Other gauge", event: Event{ID: "Alloc", Labels: map[string]string{"host": "a"}, Time: now}, wantNotified: true},
+		{name: "After the silence", event: Event{ID: "CPUutilization1", Labels: map[string]string{"host": "a"}, Time: now.Add(2 * time.Hour)}, wantNotified: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var notified []Event
+			Unsilenced(s, func(e Event) {
+				notified = append(notified, e)
+			})(tt.event)
+			if !tt.wantNotified {
+				assert.Empty(t, notified)
+				return
+			}
+			assert.Equal(t, []Event{tt.event}, notified)
+		})
+	}
+}
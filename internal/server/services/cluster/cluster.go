@@ -0,0 +1,414 @@
+// Package cluster partitions metrics between the servers of a cluster.
+// Every metric ID is owned by one node chosen on a consistent-hash Ring
+// built from the static list of nodes. Any node accepts requests and
+// passes the metrics it does not own to their owners.
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/handlers/middleware"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/signature"
+)
+
+// ForwardedHeader marks a request passed by another node. Such a request is
+// served by the receiving node alone and is never forwarded again.
+// Its value is the name of the sending node. The header is trusted only
+// together with SecretHeader, see Cluster.IsForwarded.
+const ForwardedHeader = "X-Cluster-Forwarded"
+
+// ShardHeader is set on the responses of reads a cluster node answers from
+// the metrics it owns alone, its value is the name of the node.
+const ShardHeader = "X-Cluster-Shard"
+
+// SecretHeader carries the secret shared by the nodes on requests passed
+// between them.
+const SecretHeader = "X-Cluster-Secret"
+
+// DefaultTimeout limits a forwarded request.
+const DefaultTimeout = 10 * time.Second
+
+// gatherPageSize is the page size requested from peers, the maximum of List.
+const gatherPageSize = metricsService.MaxPageSize
+
+// ErrInvalidMembership is returned by New for an empty or malformed node
+// list or when self is not one of the nodes.
+var ErrInvalidMembership = errors.New("invalid cluster membership")
+
+// ErrForward is returned when a peer does not accept forwarded metrics.
+var ErrForward = errors.New("forwarding to the owner node failed")
+
+// ErrNotClustered is returned by the cluster endpoints of a standalone server.
+var ErrNotClustered = errors.New("server is not a cluster node")
+
+// Cluster routes metrics to the nodes owning them.
+type Cluster struct {
+	self    string
+	secret  string
+	ring    *Ring
+	peers   map[string]*url.URL
+	proxies map[string]*httputil.ReverseProxy
+	client  *http.Client
+
+	handoffMu sync.Mutex
+	handoffs  map[string]time.Time
+}
+
+// Instance is the cluster of the server, nil when the server runs standalone.
+var Instance *Cluster
+
+// New creates the cluster membership.
+// Parameters:
+//   - nodes: addresses of all nodes as host:port or http(s) URLs,
+//     in the same form on every node, since they name the ring points
+//   - self: address of this node, one of nodes
+//   - secret: secret shared by all nodes, authenticates forwarded requests
+//
+// Returns:
+//   - *Cluster: the cluster
+//   - error: ErrInvalidMembership, also when secret is empty
+func New(nodes []string, self string, secret string) (*Cluster, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("%w: no cluster secret", ErrInvalidMembership)
+	}
+	c := &Cluster{
+		secret:   secret,
+		peers:    map[string]*url.URL{},
+		proxies:  map[string]*httputil.ReverseProxy{},
+		client:   &http.Client{Timeout: DefaultTimeout},
+		handoffs: map[string]time.Time{},
+	}
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		u, err := parseNode(node)
+		if err != nil {
+			return nil, err
+		}
+		name := u.String()
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("%w: %q is listed twice", ErrInvalidMembership, node)
+		}
+		names = append(names, name)
+		c.peers[name] = u
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: no nodes", ErrInvalidMembership)
+	}
+	u, err := parseNode(self)
+	if err != nil {
+		return nil, err
+	}
+	c.self = u.String()
+	if _, ok := c.peers[c.self]; !ok {
+		return nil, fmt.Errorf("%w: %q is not one of the nodes", ErrInvalidMembership, self)
+	}
+	delete(c.peers, c.self)
+	for name, peer := range c.peers {
+		c.proxies[name] = newProxy(peer)
+	}
+	c.ring = NewRing(names, DefaultVirtualNodes)
+	return c, nil
+}
+
+func parseNode(node string) (*url.URL, error) {
+	node = strings.TrimSpace(node)
+	if !strings.Contains(node, "://") {
+		node = "http://" + node
+	}
+	u, err := url.Parse(node)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q is not a node address", ErrInvalidMembership, node)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u, nil
+}
+
+func newProxy(peer *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(peer)
+	proxy.ErrorHandler = func(res http.ResponseWriter, r *http.Request, err error) {
+		logger.LogError("cluster proxy: ", err)
+		middleware.WriteError(res, http.StatusBadGateway, middleware.CodeForwardFailed, "owner node is unavailable")
+	}
+	return proxy
+}
+
+// Self returns the name of this node.
+func (c *Cluster) Self() string {
+	return c.self
+}
+
+// Nodes returns the names of all nodes.
+func (c *Cluster) Nodes() []string {
+	return c.ring.Nodes()
+}
+
+// Owner returns the name of the node owning the metric ID.
+func (c *Cluster) Owner(id string) string {
+	return c.ring.Owner(id)
+}
+
+// IsForwarded reports whether the request was passed by another node:
+// it carries ForwardedHeader and the cluster secret in SecretHeader.
+// A ForwardedHeader sent by a client without the secret is ignored,
+// the request is routed like any other.
+func (c *Cluster) IsForwarded(r *http.Request) bool {
+	if r.Header.Get(ForwardedHeader) == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(SecretHeader)), []byte(c.secret)) == 1
+}
+
+// Split separates the metrics owned by this node from the others.
+// Returns:
+//   - local: metrics owned by this node
+//   - remote: metrics of the other nodes by node name
+func (c *Cluster) Split(ms []metrics.Metrics) (local []metrics.Metrics, remote map[string][]metrics.Metrics) {
+	remote = map[string][]metrics.Metrics{}
+	for _, m := range ms {
+		owner := c.Owner(m.ID)
+		if owner == c.self {
+			local = append(local, m)
+			continue
+		}
+		remote[owner] = append(remote[owner], m)
+	}
+	return local, remote
+}
+
+// Forward sends a batch update to the node owning the metrics.
+// Parameters:
+//   - ctx: request context
+//   - node: name of the owner
+//   - ms: metrics of the node
+//   - auth: Authorization header of the original request, passed as is,
+//     so every node checks the same token
+//
+// Returns:
+//   - error: ErrForward wrapping the cause
+func (c *Cluster) Forward(ctx context.Context, node string, ms []metrics.Metrics, auth string) error {
+	res, err := c.post(ctx, node, "/updates/", ms, auth)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return responseError(node, res)
+	}
+	return nil
+}
+
+// Proxy passes a single metric request to the owner and writes its response.
+// Parameters:
+//   - res, req: the request being served
+//   - node: name of the owner
+//   - body: request body already read by the handler, nil to read req.Body
+func (c *Cluster) Proxy(res http.ResponseWriter, req *http.Request, node string, body []byte) {
+	proxy, ok := c.proxies[node]
+	if !ok {
+		middleware.WriteError(res, http.StatusBadGateway, middleware.CodeForwardFailed, "unknown cluster node")
+		return
+	}
+	var err error
+	if body == nil && req.Body != nil {
+		if body, err = io.ReadAll(req.Body); err != nil {
+			middleware.WriteError(res, http.StatusBadRequest, middleware.CodeInvalidBody, "failed to read request body")
+			return
+		}
+	}
+	out := req.Clone(req.Context())
+	if err = c.prepare(out, body); err != nil {
+		logger.LogError("cluster proxy: ", err)
+		middleware.WriteError(res, http.StatusInternalServerError, middleware.CodeInternal, "failed to forward request")
+		return
+	}
+	proxy.ServeHTTP(res, out)
+}
+
+// Gather returns the metrics of the whole cluster for List: the metrics
+// of s and the metrics of every peer matching the filter.
+// Parameters:
+//   - ctx: request context
+//   - s: local storage
+//   - filter: type, prefix, regex and label query parameters passed to peers,
+//     List applies them again, so peers may ignore them
+//   - auth: Authorization header of the original request
+//
+// Returns:
+//   - Gathered: read-only storage of the metrics
+//   - error: the error of s or ErrForward when a peer is unavailable
+func (c *Cluster) Gather(ctx context.Context, s metricsService.Storage, filter url.Values, auth string) (Gathered, error) {
+	all := []*metrics.MetricDTOParams{}
+	local, err := s.GetMetrics(&all)
+	if err != nil {
+		return nil, err
+	}
+	gathered := Gathered(slices.Clone(*local))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for node := range c.peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ms, err := c.list(ctx, node, filter, auth)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			gathered = append(gathered, ms...)
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return gathered, nil
+}
+
+// list pages through GET /api/v1/metrics of a peer.
+func (c *Cluster) list(ctx context.Context, node string, filter url.Values, auth string) ([]metrics.Metrics, error) {
+	var ms []metrics.Metrics
+	query := url.Values{}
+	for key, values := range filter {
+		query[key] = values
+	}
+	query.Set("limit", strconv.Itoa(gatherPageSize))
+	for {
+		u := *c.peers[node]
+		u.Path += "/api/v1/metrics"
+		u.RawQuery = query.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if err = c.prepare(req, nil); err != nil {
+			return nil, err
+		}
+		setAuth(req, auth)
+		res, err := c.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrForward, node, err)
+		}
+		if res.StatusCode != http.StatusOK {
+			err = responseError(node, res)
+			res.Body.Close()
+			return nil, err
+		}
+		var page metricsService.Page
+		err = json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrForward, node, err)
+		}
+		ms = append(ms, page.Metrics...)
+		if page.NextCursor == "" {
+			return ms, nil
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+}
+
+// post sends a JSON body to a peer as a forwarded request with the
+// optional extra headers.
+func (c *Cluster) post(ctx context.Context, node, path string, body any, auth string, headers ...http.Header) (*http.Response, error) {
+	peer, ok := c.peers[node]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown node %q", ErrForward, node)
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	u := *peer
+	u.Path += path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, h := range headers {
+		for key, values := range h {
+			req.Header[key] = values
+		}
+	}
+	if err = c.prepare(req, b); err != nil {
+		return nil, err
+	}
+	setAuth(req, auth)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrForward, node, err)
+	}
+	return res, nil
+}
+
+// prepare marks req as forwarded with the cluster secret and sets its body
+// the way the agent does: signed with the HMAC key and encrypted with the
+// public half of the server key, so the peer checks it like any other
+// request. Nodes therefore have to share the key and the key pair.
+func (c *Cluster) prepare(req *http.Request, body []byte) error {
+	req.Header.Set(ForwardedHeader, c.self)
+	req.Header.Set(SecretHeader, c.secret)
+	req.Header.Del("HashSHA256")
+	req.Header.Del("Content-Encoding")
+	// ответ пира сжимает локальный GzipHandle, если клиент его принимает
+	req.Header.Del("Accept-Encoding")
+	if len(body) == 0 {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+		return nil
+	}
+	sig := signature.Instance
+	if sig != nil && sig.GetKey() != "" {
+		hash, err := sig.Get(body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("HashSHA256", base64.StdEncoding.EncodeToString(hash))
+	}
+	if sig != nil && sig.GetPrivKey() != nil {
+		var err error
+		if body, err = sig.Encrypt(body); err != nil {
+			return err
+		}
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return nil
+}
+
+func setAuth(req *http.Request, auth string) {
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+}
+
+// responseError describes an unexpected peer response.
+func responseError(node string, res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("%w: %s responded %s: %s", ErrForward, node, res.Status, strings.TrimSpace(string(body)))
+}
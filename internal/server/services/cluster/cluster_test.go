@@ -0,0 +1,343 @@
+package cluster
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/internal/signature"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+// self is the name of the node under test, nothing listens on it.
+const self = "http://127.0.0.1:1"
+
+// peer is a node answering forwarded requests.
+type peer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+	pages    []metricsService.Page
+}
+
+func newPeer(t *testing.T) *peer {
+	p := &peer{status: http.StatusOK}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.requests = append(p.requests, r)
+		p.bodies = append(p.bodies, body)
+		if p.status != http.StatusOK {
+			http.Error(res, "broken", p.status)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/metrics":
+			page := 0
+			if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+				page, _ = strconv.Atoi(cursor)
+			}
+			_ = json.NewEncoder(res).Encode(p.pages[page])
+		case "/cluster/handoff":
+			_ = json.NewEncoder(res).Encode(HandoffResult{})
+		}
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// ownedBy returns n metric IDs owned by node.
+func ownedBy(c *Cluster, node string, n int) []string {
+	var ids []string
+	for i := 0; len(ids) < n; i++ {
+		id := "metric-" + strconv.Itoa(i)
+		if c.Owner(id) == node {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodes     []string
+		self      string
+		secret    string
+		wantSelf  string
+		wantNodes []string
+		wantErr   error
+	}{
+		{name: "Host and port", nodes: []string{"node-1:8080", " node-2:8080"}, self: "node-2:8080", wantSelf: "http://node-2:8080", wantNodes: []string{"http://node-1:8080", "http://node-2:8080"}},
+		{name: "URLs", nodes: []string{"https://node-1/", "https://node-2"}, self: "https://node-1", wantSelf: "https://node-1", wantNodes: []string{"https://node-1", "https://node-2"}},
+		{name: "Single node", nodes: []string{"node-1:8080"}, self: "http://node-1:8080/", wantSelf: "http://node-1:8080", wantNodes: []string{"http://node-1:8080"}},
+		{name: "No nodes", nodes: nil, self: "node-1:8080", wantErr: ErrInvalidMembership},
+		{name: "Self not listed", nodes: []string{"node-1:8080"}, self: "node-2:8080", wantErr: ErrInvalidMembership},
+		{name: "Duplicate", nodes: []string{"node-1:8080", "http://node-1:8080"}, self: "node-1:8080", wantErr: ErrInvalidMembership},
+		{name: "Bad scheme", nodes: []string{"ftp://node-1"}, self: "ftp://node-1", wantErr: ErrInvalidMembership},
+		{name: "No secret", nodes: []string{"node-1:8080"}, self: "node-1:8080", secret: "-", wantErr: ErrInvalidMembership},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := "cluster-secret"
+			if tt.secret == "-" {
+				secret = ""
+			}
+			c, err := New(tt.nodes, tt.self, secret)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSelf, c.Self())
+			assert.Equal(t, tt.wantNodes, c.Nodes())
+		})
+	}
+}
+
+func TestIsForwarded(t *testing.T) {
+	c, err := New([]string{self}, self, "cluster-secret")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{name: "Client request", want: false},
+		{name: "Forwarded without secret", headers: map[string]string{ForwardedHeader: "http://node-2"}, want: false},
+		{name: "Forwarded with wrong secret", headers: map[string]string{ForwardedHeader: "http://node-2", SecretHeader: "guess"}, want: false},
+		{name: "Secret without forwarded", headers: map[string]string{SecretHeader: "cluster-secret"}, want: false},
+		{name: "Forwarded by a node", headers: map[string]string{ForwardedHeader: "http://node-2", SecretHeader: "cluster-secret"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			assert.Equal(t, tt.want, c.IsForwarded(r))
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	c, err := New([]string{self, "http://127.0.0.1:2"}, self, "cluster-secret")
+	require.NoError(t, err)
+	local := ownedBy(c, self, 2)
+	remote := ownedBy(c, "http://127.0.0.1:2", 1)
+
+	ms := []metrics.Metrics{{ID: local[0], MType: constants.Gauge}, {ID: remote[0], MType: constants.Counter}, {ID: local[1], MType: constants.Counter}}
+	gotLocal, gotRemote := c.Split(ms)
+	assert.Equal(t, []metrics.Metrics{ms[0], ms[2]}, gotLocal)
+	assert.Equal(t, map[string][]metrics.Metrics{"http://127.0.0.1:2": {ms[1]}}, gotRemote)
+}
+
+func TestForward(t *testing.T) {
+	original := signature.Instance
+	defer func() {
+		signature.Instance = original
+	}()
+	signature.New("secret", "")
+	p := newPeer(t)
+	c, err := New([]string{self, p.URL}, self, "cluster-secret")
+	require.NoError(t, err)
+	ms := []metrics.Metrics{{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)}}
+
+	require.NoError(t, c.Forward(context.Background(), p.URL, ms, "Bearer token"))
+	require.Len(t, p.requests, 1)
+	r := p.requests[0]
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Equal(t, "/updates/", r.URL.Path)
+	assert.Equal(t, self, r.Header.Get(ForwardedHeader))
+	assert.Equal(t, "cluster-secret", r.Header.Get(SecretHeader))
+	assert.True(t, c.IsForwarded(r))
+	assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+	var got []metrics.Metrics
+	require.NoError(t, json.Unmarshal(p.bodies[0], &got))
+	assert.Equal(t, ms, got)
+	hash, err := base64.StdEncoding.DecodeString(r.Header.Get("HashSHA256"))
+	require.NoError(t, err)
+	assert.NoError(t, signature.Instance.Check(hash, p.bodies[0]))
+
+	p.status = http.StatusInternalServerError
+	assert.ErrorIs(t, c.Forward(context.Background(), p.URL, ms, ""), ErrForward)
+	p.Close()
+	assert.ErrorIs(t, c.Forward(context.Background(), p.URL, ms, ""), ErrForward)
+}
+
+func TestGather(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	require.NoError(t, storage.StorageInstance.SaveMetric(&metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)}))
+	p := newPeer(t)
+	p.pages = []metricsService.Page{
+		{Metrics: []metrics.Metrics{{ID: "Heap", MType: constants.Gauge, Value: utils.FloatToPointerFloat(2)}}, NextCursor: "1"},
+		{Metrics: []metrics.Metrics{{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(3)}}},
+	}
+	c, err := New([]string{self, p.URL}, self, "cluster-secret")
+	require.NoError(t, err)
+
+	gathered, err := c.Gather(context.Background(), storage.StorageInstance, map[string][]string{"prefix": {"A"}}, "Bearer token")
+	require.NoError(t, err)
+	ids := []string{}
+	for _, m := range gathered {
+		ids = append(ids, m.ID)
+	}
+	assert.ElementsMatch(t, []string{"Alloc", "Heap", "PollCount"}, ids)
+	require.Len(t, p.requests, 2)
+	assert.Equal(t, "A", p.requests[0].URL.Query().Get("prefix"))
+	assert.Equal(t, "1000", p.requests[0].URL.Query().Get("limit"))
+	assert.Equal(t, "1", p.requests[1].URL.Query().Get("cursor"))
+	assert.Equal(t, self, p.requests[1].Header.Get(ForwardedHeader))
+
+	page, err := metricsService.List(gathered, metricsService.ListQuery{Sort: "name", Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, page.Metrics, 2)
+	assert.NotEmpty(t, page.NextCursor)
+
+	p.status = http.StatusServiceUnavailable
+	_, err = c.Gather(context.Background(), storage.StorageInstance, nil, "")
+	assert.ErrorIs(t, err, ErrForward)
+}
+
+func TestRebalance(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	p := newPeer(t)
+	c, err := New([]string{self, p.URL}, self, "cluster-secret")
+	require.NoError(t, err)
+	kept := ownedBy(c, self, 1)[0]
+	moved := ownedBy(c, p.URL, 2)
+	require.NoError(t, storage.StorageInstance.SaveMetrics(&[]metrics.Metrics{
+		{ID: kept, MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)},
+		{ID: moved[0], MType: constants.Gauge, Value: utils.FloatToPointerFloat(2)},
+		{ID: moved[1], MType: constants.Counter, Delta: utils.FloatToPointerInt(3)},
+	}))
+
+	result, err := c.Rebalance(context.Background(), storage.StorageInstance, true, "")
+	require.NoError(t, err)
+	assert.Equal(t, &RebalanceResult{DryRun: true, Moved: map[string]int{p.URL: 2}, Kept: 1}, result)
+	assert.Empty(t, p.requests)
+
+	result, err = c.Rebalance(context.Background(), storage.StorageInstance, false, "Bearer admin")
+	require.NoError(t, err)
+	assert.Equal(t, &RebalanceResult{Moved: map[string]int{p.URL: 2}, Kept: 1}, result)
+	require.Len(t, p.requests, 1)
+	assert.Equal(t, "/cluster/handoff", p.requests[0].URL.Path)
+	assert.Equal(t, "Bearer admin", p.requests[0].Header.Get("Authorization"))
+	assert.NotEmpty(t, p.requests[0].Header.Get(HandoffHeader))
+	var handed []metrics.Metrics
+	require.NoError(t, json.Unmarshal(p.bodies[0], &handed))
+	assert.Len(t, handed, 2)
+	all, err := storage.StorageInstance.GetMetrics(&[]*metrics.MetricDTOParams{})
+	require.NoError(t, err)
+	require.Len(t, *all, 1)
+	assert.Equal(t, kept, (*all)[0].ID)
+
+	// неудачная передача ничего не удаляет
+	require.NoError(t, storage.StorageInstance.SaveMetric(&metrics.Metrics{ID: moved[0], MType: constants.Gauge, Value: utils.FloatToPointerFloat(2)}))
+	p.status = http.StatusInternalServerError
+	_, err = c.Rebalance(context.Background(), storage.StorageInstance, false, "")
+	assert.ErrorIs(t, err, ErrForward)
+	all, err = storage.StorageInstance.GetMetrics(&[]*metrics.MetricDTOParams{})
+	require.NoError(t, err)
+	assert.Len(t, *all, 2)
+}
+
+func TestAccept(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	require.NoError(t, storage.StorageInstance.SaveMetrics(&[]metrics.Metrics{
+		{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)},
+		{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(2)},
+	}))
+
+	c, err := New([]string{self}, self, "cluster-secret")
+	require.NoError(t, err)
+	handed := []metrics.Metrics{
+		{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(10)},
+		{ID: "Heap", MType: constants.Gauge, Value: utils.FloatToPointerFloat(20)},
+		{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(3)},
+	}
+
+	result, err := c.Accept(storage.StorageInstance, "handoff-1", handed)
+	require.NoError(t, err)
+	assert.Equal(t, &HandoffResult{Accepted: 2, Skipped: 1}, result)
+	// повтор той же передачи не добавляет счётчики второй раз
+	result, err = c.Accept(storage.StorageInstance, "handoff-1", handed)
+	require.NoError(t, err)
+	assert.Equal(t, &HandoffResult{Duplicate: true}, result)
+
+	all, err := storage.StorageInstance.GetMetrics(&[]*metrics.MetricDTOParams{})
+	require.NoError(t, err)
+	got := map[string]float64{}
+	for _, m := range *all {
+		got[m.ID] = metricsService.NumericValue(&m)
+	}
+	assert.Equal(t, map[string]float64{"Alloc": 1, "Heap": 20, "PollCount": 5}, got)
+}
+
+func TestRebalance_Batches(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	p := newPeer(t)
+	c, err := New([]string{self, p.URL}, self, "cluster-secret")
+	require.NoError(t, err)
+	var ms []metrics.Metrics
+	for _, id := range ownedBy(c, p.URL, HandoffBatchSize+1) {
+		ms = append(ms, metrics.Metrics{ID: id, MType: constants.Counter, Delta: utils.FloatToPointerInt(1)})
+	}
+	require.NoError(t, storage.StorageInstance.SaveMetrics(&ms))
+
+	result, err := c.Rebalance(context.Background(), storage.StorageInstance, false, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{p.URL: HandoffBatchSize + 1}, result.Moved)
+	require.Len(t, p.requests, 2)
+	sizes := []int{}
+	for i, body := range p.bodies {
+		var handed []metrics.Metrics
+		require.NoError(t, json.Unmarshal(body, &handed))
+		sizes = append(sizes, len(handed))
+		assert.NotEmpty(t, p.requests[i].Header.Get(HandoffHeader))
+	}
+	assert.Equal(t, []int{HandoffBatchSize, 1}, sizes)
+	assert.NotEqual(t, p.requests[0].Header.Get(HandoffHeader), p.requests[1].Header.Get(HandoffHeader))
+}
+
+func TestHandoffID(t *testing.T) {
+	c, err := New([]string{self, "http://127.0.0.1:2"}, self, "cluster-secret")
+	require.NoError(t, err)
+	other, err := New([]string{self, "http://127.0.0.1:2"}, "http://127.0.0.1:2", "cluster-secret")
+	require.NoError(t, err)
+	ms := []metrics.Metrics{{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(3)}}
+	grown := []metrics.Metrics{{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(4)}}
+
+	id, err := c.handoffID(ms)
+	require.NoError(t, err)
+	again, err := c.handoffID(ms)
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+	changed, err := c.handoffID(grown)
+	require.NoError(t, err)
+	assert.NotEqual(t, id, changed)
+	fromOther, err := other.handoffID(ms)
+	require.NoError(t, err)
+	assert.NotEqual(t, id, fromOther)
+}
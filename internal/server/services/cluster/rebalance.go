@@ -0,0 +1,235 @@
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+)
+
+// HandoffHeader carries the identifier of a handoff, the receiving node
+// applies a handoff with the same identifier only once.
+const HandoffHeader = "X-Cluster-Handoff"
+
+// HandoffBatchSize is the largest number of metrics handed off in one
+// request, so a request stays within the body limit of the owner.
+const HandoffBatchSize = 1000
+
+// HandoffTTL is how long a node remembers the handoffs it applied.
+const HandoffTTL = time.Hour
+
+// ErrReadOnly is returned when saving to Gathered.
+var ErrReadOnly = errors.New("gathered metrics are read-only")
+
+// Gathered is a read-only storage of the metrics gathered from the cluster.
+type Gathered []metrics.Metrics
+
+// SaveMetric implements metricsService.Storage, always returns ErrReadOnly.
+func (g Gathered) SaveMetric(m *metrics.Metrics) error {
+	return ErrReadOnly
+}
+
+// SaveMetrics implements metricsService.Storage, always returns ErrReadOnly.
+func (g Gathered) SaveMetrics(m *[]metrics.Metrics) error {
+	return ErrReadOnly
+}
+
+// GetMetrics returns all metrics for empty params, otherwise the chosen ones.
+func (g Gathered) GetMetrics(params *[]*metrics.MetricDTOParams) (*[]metrics.Metrics, error) {
+	if len(*params) == 0 {
+		all := []metrics.Metrics(g)
+		return &all, nil
+	}
+	var chosen []metrics.Metrics
+	for _, p := range *params {
+		for _, m := range g {
+			if m.ID == p.MetricsName && m.MType == p.MetricType {
+				chosen = append(chosen, m)
+			}
+		}
+	}
+	return &chosen, nil
+}
+
+// RebalanceResult describes the metrics a rebalance handed off or,
+// in dry-run mode, would hand off.
+// Fields:
+//   - DryRun: nothing was changed
+//   - Moved: number of metrics by the node they were handed to
+//   - Kept: number of metrics this node owns
+type RebalanceResult struct {
+	DryRun bool           `json:"dry_run"`
+	Moved  map[string]int `json:"moved"`
+	Kept   int            `json:"kept"`
+}
+
+// HandoffResult describes the metrics accepted by Accept.
+// Fields:
+//   - Accepted: counters added and gauges stored
+//   - Skipped: gauges already stored by the owner, their values are newer
+//   - Duplicate: the handoff was applied before and is ignored
+type HandoffResult struct {
+	Accepted  int  `json:"accepted"`
+	Skipped   int  `json:"skipped"`
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+// Rebalance hands the local metrics owned by other nodes to their owners,
+// e.g. after a node joined the cluster. Every node has to run it once the
+// new membership is deployed everywhere. Metrics are handed off in batches
+// of HandoffBatchSize identified by their content, and a batch is deleted
+// locally only after its owner accepted it, so a failed rebalance can be
+// run again: a batch the owner applied but this node did not delete is
+// sent with the same identifier and ignored by the owner.
+// Parameters:
+//   - ctx: request context
+//   - s: local storage
+//   - dryRun: only count the metrics to move
+//   - auth: Authorization header sent to the owners
+//
+// Returns:
+//   - *RebalanceResult: metrics moved and kept
+//   - error: the error of s or ErrForward, metrics moved before it stay moved
+func (c *Cluster) Rebalance(ctx context.Context, s metricsService.Restorer, dryRun bool, auth string) (*RebalanceResult, error) {
+	all := []*metrics.MetricDTOParams{}
+	stored, err := s.GetMetrics(&all)
+	if err != nil {
+		return nil, err
+	}
+	local, remote := c.Split(*stored)
+	result := &RebalanceResult{DryRun: dryRun, Moved: map[string]int{}, Kept: len(local)}
+	for node, ms := range remote {
+		if dryRun {
+			result.Moved[node] = len(ms)
+			continue
+		}
+		// одинаковый порядок даёт одинаковые пачки при повторном запуске
+		slices.SortFunc(ms, func(a, b metrics.Metrics) int {
+			return strings.Compare(a.MType+"/"+a.ID, b.MType+"/"+b.ID)
+		})
+		for chunk := range slices.Chunk(ms, HandoffBatchSize) {
+			if err = c.handoff(ctx, node, chunk, auth); err != nil {
+				return result, err
+			}
+			for _, m := range chunk {
+				if err = metricsService.Delete(s, m.MType, m.ID); err != nil && !notFound(err) {
+					return result, err
+				}
+			}
+			result.Moved[node] += len(chunk)
+		}
+	}
+	return result, nil
+}
+
+func (c *Cluster) handoff(ctx context.Context, node string, ms []metrics.Metrics, auth string) error {
+	id, err := c.handoffID(ms)
+	if err != nil {
+		return err
+	}
+	res, err := c.post(ctx, node, "/cluster/handoff", ms, auth, http.Header{HandoffHeader: {id}})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return responseError(node, res)
+	}
+	var result HandoffResult
+	return json.NewDecoder(res.Body).Decode(&result)
+}
+
+// handoffID identifies a batch by the sending node and its content.
+func (c *Cluster) handoffID(ms []metrics.Metrics) (string, error) {
+	body, err := json.Marshal(ms)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(c.self+"\n"), body...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Accept stores the metrics handed off by a previous owner. Counters are
+// added to the stored ones, since both nodes may have counted increments
+// while the membership changed. Gauges are stored only when absent,
+// a stored gauge was set after the new membership took effect.
+// A handoff applied within HandoffTTL is ignored when it is sent again,
+// so a retried handoff does not add the counters twice.
+// Parameters:
+//   - s: local storage
+//   - id: identifier of the handoff from HandoffHeader, empty to apply it anyway
+//   - ms: handed off metrics
+//
+// Returns:
+//   - *HandoffResult: metrics accepted and skipped
+//   - error: the error of s, the handoff is not remembered then
+func (c *Cluster) Accept(s metricsService.Restorer, id string, ms []metrics.Metrics) (*HandoffResult, error) {
+	c.handoffMu.Lock()
+	defer c.handoffMu.Unlock()
+	now := time.Now()
+	for key, applied := range c.handoffs {
+		if now.Sub(applied) > HandoffTTL {
+			delete(c.handoffs, key)
+		}
+	}
+	if _, ok := c.handoffs[id]; ok && id != "" {
+		return &HandoffResult{Duplicate: true}, nil
+	}
+	result, err := accept(s, ms)
+	if err != nil {
+		return result, err
+	}
+	if id != "" {
+		c.handoffs[id] = now
+	}
+	return result, nil
+}
+
+// accept stores the gauges and then adds all counters in one batch,
+// so a failed handoff has not added a part of its counters.
+func accept(s metricsService.Restorer, ms []metrics.Metrics) (*HandoffResult, error) {
+	result := &HandoffResult{}
+	var counters []metrics.Metrics
+	for i := range ms {
+		m := &ms[i]
+		if m.MType == constants.Counter {
+			counters = append(counters, *m)
+			continue
+		}
+		params := []*metrics.MetricDTOParams{{MetricType: m.MType, MetricsName: m.ID}}
+		_, err := metricsService.Get(s, &params)
+		if err == nil {
+			result.Skipped++
+			continue
+		}
+		if !notFound(err) {
+			return result, err
+		}
+		if err = metricsService.Set(s, m); err != nil {
+			return result, err
+		}
+		result.Accepted++
+	}
+	if len(counters) > 0 {
+		if err := metricsService.UpdateMany(s, &counters); err != nil {
+			return result, err
+		}
+		result.Accepted += len(counters)
+	}
+	return result, nil
+}
+
+// notFound reports whether err means the metric is not stored.
+func notFound(err error) bool {
+	return errors.Is(err, storage.ErrUnknownMetricName) || errors.Is(err, metricsService.ErrNotFound)
+}
@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"hash/fnv"
+	"slices"
+	"strconv"
+)
+
+// DefaultVirtualNodes is the number of ring points per node.
+// More points spread metric IDs more evenly between nodes.
+const DefaultVirtualNodes = 128
+
+// Ring assigns metric IDs to nodes by consistent hashing: every node owns
+// the arcs of the ring ending at its points, so adding a node moves only
+// the IDs falling on the arcs it takes over.
+type Ring struct {
+	nodes  []string
+	points []point
+}
+
+type point struct {
+	hash uint64
+	node string
+}
+
+// NewRing builds a ring of the given nodes.
+// Parameters:
+//   - nodes: node names, every node of the cluster must use the same list
+//   - vnodes: points per node, DefaultVirtualNodes when not positive
+//
+// Returns:
+//   - *Ring: the ring, it owns nothing when nodes is empty
+func NewRing(nodes []string, vnodes int) *Ring {
+	if vnodes <= 0 {
+		vnodes = DefaultVirtualNodes
+	}
+	r := &Ring{nodes: slices.Clone(nodes)}
+	for _, node := range nodes {
+		for i := 0; i < vnodes; i++ {
+			r.points = append(r.points, point{hash: hash(node + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	slices.SortFunc(r.points, func(a, b point) int {
+		if a.hash != b.hash {
+			if a.hash < b.hash {
+				return -1
+			}
+			return 1
+		}
+		// совпадение хешей разрешается одинаково на всех узлах
+		if a.node < b.node {
+			return -1
+		}
+		if a.node > b.node {
+			return 1
+		}
+		return 0
+	})
+	return r
+}
+
+// Nodes returns the nodes of the ring in configuration order.
+func (r *Ring) Nodes() []string {
+	return slices.Clone(r.nodes)
+}
+
+// Owner returns the node owning the metric ID, empty for an empty ring.
+func (r *Ring) Owner(id string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash(id)
+	i, _ := slices.BinarySearchFunc(r.points, h, func(p point, h uint64) int {
+		if p.hash < h {
+			return -1
+		}
+		if p.hash > h {
+			return 1
+		}
+		return 0
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// hash is 64-bit FNV-1a with a final mix, FNV alone places
+// keys differing in the last characters close to each other.
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
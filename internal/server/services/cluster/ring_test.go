@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingOwner(t *testing.T) {
+	assert.Empty(t, NewRing(nil, 0).Owner("Alloc"))
+
+	nodes := []string{"http://node-1:8080", "http://node-2:8080", "http://node-3:8080"}
+	r := NewRing(nodes, 0)
+	other := NewRing([]string{"http://node-3:8080", "http://node-1:8080", "http://node-2:8080"}, 0)
+	for i := 0; i < 1000; i++ {
+		id := "metric-" + strconv.Itoa(i)
+		owner := r.Owner(id)
+		assert.Contains(t, nodes, owner)
+		assert.Equal(t, owner, r.Owner(id))
+		// порядок узлов в конфигурации не влияет на владельца
+		assert.Equal(t, owner, other.Owner(id))
+	}
+	assert.Equal(t, nodes, r.Nodes())
+}
+
+func TestRingBalance(t *testing.T) {
+	nodes := []string{"http://node-1:8080", "http://node-2:8080", "http://node-3:8080"}
+	r := NewRing(nodes, DefaultVirtualNodes)
+	const ids = 30000
+	owned := map[string]int{}
+	for i := 0; i < ids; i++ {
+		owned[r.Owner("metric-"+strconv.Itoa(i))]++
+	}
+	for _, node := range nodes {
+		assert.InDelta(t, 1.0/3, float64(owned[node])/ids, 0.08, node)
+	}
+}
+
+func TestRingAddNode(t *testing.T) {
+	before := NewRing([]string{"http://node-1:8080", "http://node-2:8080", "http://node-3:8080"}, 0)
+	after := NewRing([]string{"http://node-1:8080", "http://node-2:8080", "http://node-3:8080", "http://node-4:8080"}, 0)
+	const ids = 20000
+	moved := 0
+	for i := 0; i < ids; i++ {
+		id := "metric-" + strconv.Itoa(i)
+		if before.Owner(id) == after.Owner(id) {
+			continue
+		}
+		moved++
+		assert.Equal(t, "http://node-4:8080", after.Owner(id), id)
+	}
+	assert.InDelta(t, 0.25, float64(moved)/ids, 0.08)
+}
@@ -0,0 +1,238 @@
+// Package follower keeps the storage of a follower server in sync with
+// its leader: it restores a snapshot of the leader and then applies the
+// changes streamed from the leader replication log.
+package follower
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+)
+
+// DefaultRetryInterval is the pause before reconnecting to the leader.
+const DefaultRetryInterval = time.Second
+
+// ErrInvalidLeader is returned by New for an address that is not a host:port or an http(s) URL.
+var ErrInvalidLeader = errors.New("invalid leader address")
+
+// ErrStreamEnded is returned when the leader closes the stream, e.g. on shutdown.
+var ErrStreamEnded = errors.New("leader closed the replication stream")
+
+// snapshotResponse is the body of GET /replication/snapshot.
+type snapshotResponse struct {
+	Epoch    string                   `json:"epoch"`
+	Seq      uint64                   `json:"seq"`
+	Snapshot *metricsService.Snapshot `json:"snapshot"`
+}
+
+// Follower replicates the leader into a storage.
+type Follower struct {
+	leader  *url.URL
+	token   string
+	storage metricsService.Restorer
+	client  *http.Client
+	retry   time.Duration
+
+	mu     sync.Mutex
+	status replication.Status
+}
+
+// Instance is the follower of the server, nil when the server is a leader.
+var Instance *Follower
+
+// New creates a follower. It does not connect until Run is called.
+// Parameters:
+//   - leader: leader address as host:port or an http(s) URL
+//   - token: API token sent to the leader, empty when the leader does not require one
+//   - s: storage kept in sync with the leader
+//
+// Returns:
+//   - *Follower: the follower
+//   - error: ErrInvalidLeader
+func New(leader, token string, s metricsService.Restorer) (*Follower, error) {
+	if !strings.Contains(leader, "://") {
+		leader = "http://" + leader
+	}
+	u, err := url.Parse(leader)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidLeader, leader)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &Follower{
+		leader:  u,
+		token:   token,
+		storage: s,
+		// у потока нет общего таймаута, он открыт, пока жив лидер
+		client: &http.Client{},
+		retry:  DefaultRetryInterval,
+		status: replication.Status{Role: replication.RoleFollower, Leader: u.String()},
+	}, nil
+}
+
+// Leader returns the URL of the leader.
+func (f *Follower) Leader() *url.URL {
+	return f.leader
+}
+
+// Status returns the replication state of the follower.
+func (f *Follower) Status() replication.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+// Run replicates the leader until ctx is done. The first sync and every
+// sync after a gap in the stream restore a snapshot, then the follower
+// streams changes from the sequence number of the snapshot. Connection
+// errors are retried after DefaultRetryInterval.
+func (f *Follower) Run(ctx context.Context) {
+	for {
+		err := f.sync(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.LogError("replication: ", err)
+		f.update(func(s *replication.Status) {
+			s.Connected = false
+			s.LastError = err.Error()
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(f.retry):
+		}
+	}
+}
+
+// sync restores a snapshot if needed and applies the stream until it fails.
+func (f *Follower) sync(ctx context.Context) error {
+	for {
+		if f.Status().Epoch == "" {
+			if err := f.catchUp(ctx); err != nil {
+				return err
+			}
+		}
+		err := f.stream(ctx)
+		if !errors.Is(err, replication.ErrGap) {
+			return err
+		}
+		logger.LogInfo("replication: gap in the leader log, restoring a snapshot")
+		f.update(func(s *replication.Status) { s.Epoch = "" })
+	}
+}
+
+// catchUp restores the snapshot of the leader.
+func (f *Follower) catchUp(ctx context.Context) error {
+	res, err := f.get(ctx, "/replication/snapshot", nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return responseError(res)
+	}
+	var body snapshotResponse
+	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return err
+	}
+	if body.Snapshot == nil || body.Epoch == "" {
+		return fmt.Errorf("%w: empty snapshot response", metricsService.ErrInvalidSnapshot)
+	}
+	if _, err = metricsService.ApplySnapshot(f.storage, body.Snapshot); err != nil {
+		return err
+	}
+	now := time.Now()
+	f.update(func(s *replication.Status) {
+		s.Epoch, s.Seq = body.Epoch, body.Seq
+		s.Snapshots++
+		s.LastApplied = &now
+	})
+	return nil
+}
+
+// stream applies the changes following the current sequence number.
+func (f *Follower) stream(ctx context.Context) error {
+	status := f.Status()
+	res, err := f.get(ctx, "/replication/stream", url.Values{
+		"epoch": {status.Epoch},
+		"since": {strconv.FormatUint(status.Seq, 10)},
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusGone {
+		return replication.ErrGap
+	}
+	if res.StatusCode != http.StatusOK {
+		return responseError(res)
+	}
+	f.update(func(s *replication.Status) {
+		s.Connected = true
+		s.LastError = ""
+	})
+
+	dec := json.NewDecoder(res.Body)
+	seq := status.Seq
+	for {
+		var e replication.Entry
+		if err = dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return ErrStreamEnded
+			}
+			return err
+		}
+		// пропуск записи означает, что состояние уже не совпадает с лидером
+		if e.Seq != seq+1 {
+			return replication.ErrGap
+		}
+		if err = metricsService.ApplyEntry(f.storage, &e); err != nil {
+			return err
+		}
+		seq = e.Seq
+		now := time.Now()
+		f.update(func(s *replication.Status) {
+			s.Seq = seq
+			s.LastApplied = &now
+		})
+	}
+}
+
+func (f *Follower) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := *f.leader
+	u.Path += path
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/x-ndjson, application/json")
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	return f.client.Do(req)
+}
+
+func (f *Follower) update(change func(s *replication.Status)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	change(&f.status)
+}
+
+// responseError describes an unexpected leader response.
+func responseError(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("leader responded %s: %s", res.Status, strings.TrimSpace(string(body)))
+}
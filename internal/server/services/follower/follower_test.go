@@ -0,0 +1,36 @@
+package follower
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name       string
+		leader     string
+		wantLeader string
+		wantErr    bool
+	}{
+		{name: "Host and port", leader: "localhost:8080", wantLeader: "http://localhost:8080"},
+		{name: "URL", leader: "https://leader.example.com/metrics/", wantLeader: "https://leader.example.com/metrics"},
+		{name: "Empty", leader: "", wantErr: true},
+		{name: "Other scheme", leader: "ftp://leader:21", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.leader, "", nil)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLeader)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLeader, f.Leader().String())
+			assert.Equal(t, replication.Status{Role: replication.RoleFollower, Leader: tt.wantLeader}, f.Status())
+		})
+	}
+}
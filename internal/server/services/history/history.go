@@ -0,0 +1,186 @@
+// Package history keeps the recent values of every metric in memory
+// for the dashboard sparklines and "last updated" times.
+package history
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+)
+
+// DefaultSize is the number of samples kept per metric.
+const DefaultSize = 60
+
+// Sample is the value of a metric at the time it was updated.
+// Fields:
+//   - Time: time of the update
+//   - Value: value after the update, the running total for a counter
+//   - Reset: the counter value was replaced, e.g. by Set, rather than
+//     incremented, a value below the previous one is a counter reset
+type Sample struct {
+	Time  time.Time
+	Value float64
+	Reset bool
+}
+
+// series is a fixed size ring of samples.
+type series struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+func (s *series) add(sample Sample) {
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+func (s *series) last() (Sample, bool) {
+	if !s.full && s.next == 0 {
+		return Sample{}, false
+	}
+	return s.samples[(s.next-1+len(s.samples))%len(s.samples)], true
+}
+
+func (s *series) ordered() []Sample {
+	if !s.full {
+		return append([]Sample(nil), s.samples[:s.next]...)
+	}
+	return append(append([]Sample(nil), s.samples[s.next:]...), s.samples[:s.next]...)
+}
+
+// Recorder keeps the last samples of every metric.
+// Counter samples are running totals of the recorded deltas, so they start
+// from zero for counters restored from a file or database; see Series.
+type Recorder struct {
+	mu     sync.RWMutex
+	size   int
+	series map[string]*series
+	now    func() time.Time
+}
+
+// Instance is the global recorder fed by the metric service.
+var Instance = New(DefaultSize)
+
+// New creates a recorder.
+// Parameters:
+//   - size: samples kept per metric, DefaultSize when not positive
+//
+// Returns:
+//   - *Recorder: empty recorder
+func New(size int) *Recorder {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Recorder{size: size, series: map[string]*series{}, now: time.Now}
+}
+
+func key(mType, id string) string {
+	return mType + "/" + id
+}
+
+// Add records updates with the semantics of storage.SaveMetric:
+// a gauge value replaces the previous one, a counter delta is added to it.
+func (r *Recorder) Add(updates ...metrics.Metrics) {
+	r.record(updates, false)
+}
+
+// Set records updates with the semantics of storage.SetMetric,
+// a counter value replaces the previous one as well.
+func (r *Recorder) Set(updates ...metrics.Metrics) {
+	r.record(updates, true)
+}
+
+func (r *Recorder) record(updates []metrics.Metrics, replace bool) {
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range updates {
+		var value float64
+		switch {
+		case m.MType == constants.Gauge && m.Value != nil:
+			value = *m.Value
+		case m.MType == constants.Counter && m.Delta != nil:
+			value = float64(*m.Delta)
+		default:
+			continue
+		}
+		k := key(m.MType, m.ID)
+		s, ok := r.series[k]
+		if !ok {
+			s = &series{samples: make([]Sample, r.size)}
+			r.series[k] = s
+		}
+		if m.MType == constants.Counter && !replace {
+			if last, ok := s.last(); ok {
+				value += last.Value
+			}
+		}
+		s.add(Sample{Time: now, Value: value, Reset: replace && m.MType == constants.Counter})
+	}
+}
+
+// Series returns the samples of a metric from the oldest to the newest.
+func (r *Recorder) Series(mType, id string) []Sample {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.series[key(mType, id)]
+	if !ok {
+		return nil
+	}
+	return s.ordered()
+}
+
+// LastUpdated returns the time of the latest sample of a metric.
+func (r *Recorder) LastUpdated(mType, id string) (time.Time, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.series[key(mType, id)]
+	if !ok {
+		return time.Time{}, false
+	}
+	last, ok := s.last()
+	return last.Time, ok
+}
+
+// Each calls visit with a copy of the samples of every metric,
+// stopping at the first error returned by visit. Series are visited in key
+// order and the lock is not held while visit runs.
+func (r *Recorder) Each(visit func(mType, id string, samples []Sample) error) error {
+	r.mu.RLock()
+	keys := slices.Sorted(maps.Keys(r.series))
+	r.mu.RUnlock()
+	for _, k := range keys {
+		mType, id, _ := strings.Cut(k, "/")
+		samples := r.Series(mType, id)
+		if samples == nil {
+			continue
+		}
+		if err := visit(mType, id, samples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete forgets the samples of a metric.
+func (r *Recorder) Delete(mType, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.series, key(mType, id))
+}
+
+// Reset forgets every sample.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series = map[string]*series{}
+}
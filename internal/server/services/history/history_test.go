@@ -0,0 +1,82 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func TestRecorder(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	r := New(3)
+	r.now = func() time.Time { return now }
+
+	gauge := func(v float64) metrics.Metrics {
+		return metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(v)}
+	}
+	counter := func(d int64) metrics.Metrics {
+		return metrics.Metrics{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(d)}
+	}
+
+	_, ok := r.LastUpdated(constants.Gauge, "Alloc")
+	assert.False(t, ok)
+	assert.Nil(t, r.Series(constants.Gauge, "Alloc"))
+
+	for i := 1; i <= 4; i++ {
+		now = start.Add(time.Duration(i) * time.Second)
+		r.Add(gauge(float64(i)), counter(2))
+	}
+	// в кольце на три значения остаются последние
+	assert.Equal(t, []Sample{
+		{Time: start.Add(2 * time.Second), Value: 2},
+		{Time: start.Add(3 * time.Second), Value: 3},
+		{Time: start.Add(4 * time.Second), Value: 4},
+	}, r.Series(constants.Gauge, "Alloc"))
+	assert.Equal(t, []Sample{
+		{Time: start.Add(2 * time.Second), Value: 4},
+		{Time: start.Add(3 * time.Second), Value: 6},
+		{Time: start.Add(4 * time.Second), Value: 8},
+	}, r.Series(constants.Counter, "PollCount"))
+
+	now = start.Add(time.Minute)
+	r.Set(counter(1))
+	series := r.Series(constants.Counter, "PollCount")
+	assert.Equal(t, Sample{Time: now, Value: 1, Reset: true}, series[len(series)-1])
+	updated, ok := r.LastUpdated(constants.Counter, "PollCount")
+	assert.True(t, ok)
+	assert.Equal(t, now, updated)
+
+	r.Add(metrics.Metrics{ID: "broken", MType: constants.Gauge})
+	assert.Nil(t, r.Series(constants.Gauge, "broken"))
+
+	r.Delete(constants.Gauge, "Alloc")
+	assert.Nil(t, r.Series(constants.Gauge, "Alloc"))
+	r.Reset()
+	assert.Nil(t, r.Series(constants.Counter, "PollCount"))
+}
+
+func TestRecorder_Each(t *testing.T) {
+	r := New(3)
+	r.Set(metrics.Metrics{ID: "b", MType: constants.Gauge, Value: utils.FloatToPointerFloat(2)})
+	r.Set(metrics.Metrics{ID: "a", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1)})
+	r.Add(metrics.Metrics{ID: "c", MType: constants.Counter, Delta: utils.FloatToPointerInt(3)})
+
+	var visited []string
+	err := r.Each(func(mType, id string, samples []Sample) error {
+		visited = append(visited, mType+"/"+id)
+		assert.Len(t, samples, 1)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"counter/c", "gauge/a", "gauge/b"}, visited)
+
+	stop := assert.AnError
+	err = r.Each(func(string, string, []Sample) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
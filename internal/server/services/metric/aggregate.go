@@ -0,0 +1,165 @@
+package metric
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+// Aggregation operators.
+const (
+	AggSum   = "sum"
+	AggMin   = "min"
+	AggMax   = "max"
+	AggAvg   = "avg"
+	AggCount = "count"
+	AggTopK  = "topk"
+)
+
+// DefaultTopK is the number of series returned by topk when K is not set.
+const DefaultTopK = 10
+
+var ErrUnknownAggregation = errors.New("unknown aggregation")
+
+// AggregateQuery describes an aggregation over the metrics matching Select.
+// Only the filters of Select are used, its sort and paging are ignored.
+// With a zero Window the current values are aggregated, otherwise every
+// history sample recorded within Window before now.
+type AggregateQuery struct {
+	Op     string
+	Select ListQuery
+	Window time.Duration
+	K      int
+}
+
+// SeriesValue is the value of one metric in a topk result.
+type SeriesValue struct {
+	Type  string  `json:"type"`
+	ID    string  `json:"id"`
+	Value float64 `json:"value"`
+}
+
+// AggregateResult is the result of Aggregate.
+// Fields:
+//   - Op: the aggregation operator
+//   - Window: the time window, empty for current values
+//   - Series: number of metrics matched by the query
+//   - Samples: number of values aggregated
+//   - Value: the aggregate, absent for topk and for min, max and avg of no values
+//   - Top: metrics with the largest values for topk
+type AggregateResult struct {
+	Op      string        `json:"op"`
+	Window  string        `json:"window,omitempty"`
+	Series  int           `json:"series"`
+	Samples int           `json:"samples"`
+	Value   *float64      `json:"value,omitempty"`
+	Top     []SeriesValue `json:"top,omitempty"`
+}
+
+// Aggregate applies q.Op to the metrics selected by q.
+// Counter history is shifted to the stored totals as on the dashboard.
+// For topk with a window each metric is ranked by its largest sample.
+// Parameters:
+//   - s: storage to read metrics from
+//   - q: selection, operator and window
+//   - now: end of the window
+//
+// Returns:
+//   - *AggregateResult: the aggregate
+//   - error: ErrUnknownAggregation or a storage error
+func Aggregate(s Storage, q AggregateQuery, now time.Time) (*AggregateResult, error) {
+	if !slices.Contains([]string{AggSum, AggMin, AggMax, AggAvg, AggCount, AggTopK}, q.Op) {
+		return nil, ErrUnknownAggregation
+	}
+	all := []*metrics.MetricDTOParams{}
+	stored, err := s.GetMetrics(&all)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AggregateResult{Op: q.Op}
+	if q.Window > 0 {
+		result.Window = q.Window.String()
+	}
+	var values []float64
+	var top []SeriesValue
+	for _, m := range *stored {
+		if !q.Select.matches(&m) {
+			continue
+		}
+		result.Series++
+		series := []float64{NumericValue(&m)}
+		if q.Window > 0 {
+			series = WindowValues(&m, now.Add(-q.Window), now)
+		}
+		values = append(values, series...)
+		if len(series) > 0 {
+			top = append(top, SeriesValue{Type: m.MType, ID: m.ID, Value: slices.Max(series)})
+		}
+	}
+	result.Samples = len(values)
+
+	switch q.Op {
+	case AggTopK:
+		k := q.K
+		if k <= 0 {
+			k = DefaultTopK
+		}
+		slices.SortFunc(top, func(a, b SeriesValue) int {
+			return cmp.Or(cmp.Compare(b.Value, a.Value), cmp.Compare(a.Type, b.Type), cmp.Compare(a.ID, b.ID))
+		})
+		result.Top = top[:min(k, len(top))]
+		if result.Top == nil {
+			result.Top = []SeriesValue{}
+		}
+	case AggCount:
+		count := float64(len(values))
+		result.Value = &count
+	case AggSum:
+		sum := sumOf(values)
+		result.Value = &sum
+	case AggAvg:
+		if len(values) > 0 {
+			avg := sumOf(values) / float64(len(values))
+			result.Value = &avg
+		}
+	case AggMin:
+		if len(values) > 0 {
+			value := slices.Min(values)
+			result.Value = &value
+		}
+	case AggMax:
+		if len(values) > 0 {
+			value := slices.Max(values)
+			result.Value = &value
+		}
+	}
+	return result, nil
+}
+
+// WindowValues returns the history values of m recorded between from and to,
+// counter samples shifted to the stored total.
+func WindowValues(m *metrics.Metrics, from, to time.Time) []float64 {
+	samples := history.Instance.Series(m.MType, m.ID)
+	values := sampleValues(m, samples)
+	var window []float64
+	for i, sample := range samples {
+		if sample.Time.Before(from) || sample.Time.After(to) {
+			continue
+		}
+		window = append(window, values[i])
+	}
+	return window
+}
+
+func sumOf(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
@@ -0,0 +1,95 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+func TestAggregate(t *testing.T) {
+	s := &MockStorage{}
+	s.On("GetMetrics", mock.Anything).Return(&[]metrics.Metrics{
+		{ID: "HeapAlloc", MType: "gauge", Value: float64Ptr(4), Labels: map[string]string{"host": "a"}},
+		{ID: "HeapAlloc2", MType: "gauge", Value: float64Ptr(1)},
+		{ID: "HeapSys", MType: "gauge", Value: float64Ptr(10)},
+		{ID: "PollCount", MType: "counter", Delta: int64Ptr(7)},
+	}, nil)
+	heap := ListQuery{Prefix: "Heap"}
+
+	tests := []struct {
+		name    string
+		q       AggregateQuery
+		want    *float64
+		series  int
+		top     []string
+		wantErr error
+	}{
+		{name: "Sum", q: AggregateQuery{Op: AggSum, Select: heap}, want: float64Ptr(15), series: 3},
+		{name: "Min", q: AggregateQuery{Op: AggMin, Select: heap}, want: float64Ptr(1), series: 3},
+		{name: "Max by label", q: AggregateQuery{Op: AggMax, Select: ListQuery{Labels: map[string]string{"host": "a"}}}, want: float64Ptr(4), series: 1},
+		{name: "Avg", q: AggregateQuery{Op: AggAvg, Select: heap}, want: float64Ptr(5), series: 3},
+		{name: "Count", q: AggregateQuery{Op: AggCount, Select: ListQuery{Type: "counter"}}, want: float64Ptr(1), series: 1},
+		{name: "Avg of nothing", q: AggregateQuery{Op: AggAvg, Select: ListQuery{Prefix: "Missing"}}},
+		{name: "Topk", q: AggregateQuery{Op: AggTopK, K: 2}, series: 4, top: []string{"HeapSys", "PollCount"}},
+		{name: "Unknown", q: AggregateQuery{Op: "median"}, wantErr: ErrUnknownAggregation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Aggregate(s, tt.q, time.Now())
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result.Value)
+			assert.Equal(t, tt.series, result.Series)
+			var top []string
+			for _, v := range result.Top {
+				top = append(top, v.ID)
+			}
+			assert.Equal(t, tt.top, top)
+		})
+	}
+}
+
+func TestAggregate_Window(t *testing.T) {
+	history.Instance.Reset()
+	defer history.Instance.Reset()
+	for _, v := range []float64{1, 5, 3} {
+		history.Instance.Add(metrics.Metrics{ID: "Alloc", MType: "gauge", Value: float64Ptr(v)})
+	}
+	history.Instance.Add(metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(1)})
+	history.Instance.Add(metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(1)})
+	s := &MockStorage{}
+	s.On("GetMetrics", mock.Anything).Return(&[]metrics.Metrics{
+		{ID: "Alloc", MType: "gauge", Value: float64Ptr(3)},
+		{ID: "PollCount", MType: "counter", Delta: int64Ptr(10)},
+	}, nil)
+	now := time.Now()
+
+	result, err := Aggregate(s, AggregateQuery{Op: AggMax, Select: ListQuery{Type: "gauge"}, Window: time.Minute}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, *result.Value)
+	assert.Equal(t, 3, result.Samples)
+	assert.Equal(t, "1m0s", result.Window)
+
+	// счётчик сдвинут к сохранённому значению: 9, 10
+	result, err = Aggregate(s, AggregateQuery{Op: AggSum, Select: ListQuery{Type: "counter"}, Window: time.Minute}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 19.0, *result.Value)
+
+	result, err = Aggregate(s, AggregateQuery{Op: AggTopK, Window: time.Minute}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []SeriesValue{{Type: "counter", ID: "PollCount", Value: 10}, {Type: "gauge", ID: "Alloc", Value: 5}}, result.Top)
+
+	result, err = Aggregate(s, AggregateQuery{Op: AggCount, Window: time.Minute}, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, *result.Value)
+	assert.Equal(t, 2, result.Series)
+}
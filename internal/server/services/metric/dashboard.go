@@ -0,0 +1,141 @@
+package metric
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+	"github.com/Maxim-Ba/metriccollector/internal/templates"
+)
+
+// SortByUpdated orders the dashboard by the time of the last update,
+// metrics without history go last.
+const SortByUpdated = "updated"
+
+// DashboardOptions selects and orders the metrics shown by GetAll.
+// Fields:
+//   - Sort: name, type, value or updated, "-" prefix for descending order
+//   - Type: gauge or counter, empty for both
+//   - Name: case insensitive substring of the metric name
+//   - Refresh: page reload interval in seconds, 0 disables it
+type DashboardOptions struct {
+	Sort    string
+	Type    string
+	Name    string
+	Refresh int
+}
+
+// row is a metric with its history while the dashboard is built.
+type row struct {
+	metric  metrics.Metrics
+	updated time.Time
+	samples []history.Sample
+}
+
+// Dashboard builds the dashboard data from storage and history.Instance.
+// Counter samples are running totals since the server started, they are
+// shifted so that the last one equals the stored value.
+func Dashboard(s Storage, opts DashboardOptions, now time.Time) (*templates.Dashboard, error) {
+	less, err := dashboardSort(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+	all := []*metrics.MetricDTOParams{}
+	stored, err := s.GetMetrics(&all)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ToLower(opts.Name)
+	rows := make([]row, 0, len(*stored))
+	for _, m := range *stored {
+		if opts.Type != "" && m.MType != opts.Type {
+			continue
+		}
+		if name != "" && !strings.Contains(strings.ToLower(m.ID), name) {
+			continue
+		}
+		r := row{metric: m, samples: history.Instance.Series(m.MType, m.ID)}
+		if len(r.samples) > 0 {
+			r.updated = r.samples[len(r.samples)-1].Time
+		}
+		rows = append(rows, r)
+	}
+	slices.SortFunc(rows, less)
+
+	dashboard := &templates.Dashboard{
+		Rows:    make([]templates.DashboardRow, len(rows)),
+		Total:   len(*stored),
+		Type:    opts.Type,
+		Name:    opts.Name,
+		Sort:    opts.Sort,
+		Refresh: opts.Refresh,
+	}
+	for i, r := range rows {
+		dashboard.Rows[i] = templates.DashboardRow{
+			ID:        r.metric.ID,
+			Type:      r.metric.MType,
+			Value:     formatValue(&r.metric),
+			Updated:   templates.RelativeTime(r.updated, now),
+			Sparkline: templates.Sparkline(sampleValues(&r.metric, r.samples)),
+		}
+		if !r.updated.IsZero() {
+			dashboard.Rows[i].UpdatedAt = r.updated.Format(time.RFC3339)
+		}
+	}
+	return dashboard, nil
+}
+
+func dashboardSort(order string) (func(a, b row) int, error) {
+	field, desc := strings.CutPrefix(order, "-")
+	if field != SortByUpdated {
+		less, err := sortFunc(order)
+		if err != nil {
+			return nil, err
+		}
+		return func(a, b row) int { return less(a.metric, b.metric) }, nil
+	}
+	return func(a, b row) int {
+		// метрики без истории всегда в конце
+		if a.updated.IsZero() != b.updated.IsZero() {
+			if a.updated.IsZero() {
+				return 1
+			}
+			return -1
+		}
+		c := a.updated.Compare(b.updated)
+		if desc {
+			c = -c
+		}
+		return cmp.Or(c, cmp.Compare(a.metric.MType, b.metric.MType), cmp.Compare(a.metric.ID, b.metric.ID))
+	}, nil
+}
+
+func formatValue(m *metrics.Metrics) string {
+	if m.MType == constants.Counter && m.Delta != nil {
+		return strconv.FormatInt(*m.Delta, 10)
+	}
+	if m.Value != nil {
+		return strconv.FormatFloat(*m.Value, 'f', -1, 64)
+	}
+	return ""
+}
+
+func sampleValues(m *metrics.Metrics, samples []history.Sample) []float64 {
+	if len(samples) == 0 {
+		return nil
+	}
+	var offset float64
+	if m.MType == constants.Counter {
+		offset = NumericValue(m) - samples[len(samples)-1].Value
+	}
+	values := make([]float64, len(samples))
+	for i, sample := range samples {
+		values[i] = sample.Value + offset
+	}
+	return values
+}
@@ -0,0 +1,77 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+func TestDashboard(t *testing.T) {
+	history.Instance.Reset()
+	defer history.Instance.Reset()
+	// история счётчика начинается с нуля, а в хранилище уже 200
+	history.Instance.Add(
+		metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(5)},
+		metrics.Metrics{ID: "HeapSys", MType: "gauge", Value: float64Ptr(90)},
+	)
+	history.Instance.Add(
+		metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(5)},
+		metrics.Metrics{ID: "HeapSys", MType: "gauge", Value: float64Ptr(100)},
+	)
+	now := time.Now().Add(5 * time.Second)
+
+	tests := []struct {
+		name    string
+		opts    DashboardOptions
+		want    []string
+		wantErr error
+	}{
+		{name: "Sorted by name", opts: DashboardOptions{}, want: []string{"HeapAlloc", "HeapSys", "PollCount", "RandomValue"}},
+		{name: "Type filter", opts: DashboardOptions{Type: "counter"}, want: []string{"PollCount"}},
+		{name: "Name filter ignores case", opts: DashboardOptions{Name: "heap"}, want: []string{"HeapAlloc", "HeapSys"}},
+		{name: "Updated first, without history last", opts: DashboardOptions{Sort: "-updated"}, want: []string{"PollCount", "HeapSys", "HeapAlloc", "RandomValue"}},
+		{name: "Value descending", opts: DashboardOptions{Sort: "-value"}, want: []string{"HeapAlloc", "PollCount", "HeapSys", "RandomValue"}},
+		{name: "Unknown sort", opts: DashboardOptions{Sort: "size"}, wantErr: ErrInvalidSort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(MockStorage)
+			mockStorage.On("GetMetrics", mock.Anything).Return(listFixture(), nil).Maybe()
+
+			dashboard, err := Dashboard(mockStorage, tt.opts, now)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			got := make([]string, len(dashboard.Rows))
+			for i, row := range dashboard.Rows {
+				got[i] = row.ID
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, 4, dashboard.Total)
+		})
+	}
+
+	mockStorage := new(MockStorage)
+	mockStorage.On("GetMetrics", mock.Anything).Return(listFixture(), nil)
+	dashboard, err := Dashboard(mockStorage, DashboardOptions{Type: "counter"}, now)
+	require.NoError(t, err)
+	row := dashboard.Rows[0]
+	assert.Equal(t, "200", row.Value)
+	assert.Equal(t, "5s ago", row.Updated)
+	assert.NotEmpty(t, row.UpdatedAt)
+	// точки сдвинуты к сохранённому значению: 195 -> 200
+	assert.Equal(t, "0.0,24.0 120.0,0.0", row.Sparkline)
+
+	dashboard, err = Dashboard(mockStorage, DashboardOptions{Name: "Random"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, "—", dashboard.Rows[0].Updated)
+	assert.Empty(t, dashboard.Rows[0].Sparkline)
+}
@@ -0,0 +1,110 @@
+package metric
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+// Suffixes of the gauges derived from a counter.
+const (
+	RateSuffix     = "_rate"
+	IncreaseSuffix = "_increase"
+)
+
+// DefaultRateWindow is the window of the derived gauges when none is configured.
+const DefaultRateWindow = 5 * time.Minute
+
+// CounterIncrease computes how much a counter grew within (to-window, to]
+// and its per-second rate from the history samples.
+// The last sample before the window, if any, is the baseline, so an update
+// right after the window start is counted in full. The growth is taken
+// from the running totals, i.e. the sum of the deltas accepted within the
+// window. A sample replacing the counter, see history.Sample.Reset, with a
+// value below the previous one is a counter reset and the counter is taken
+// to have grown from zero to it. Clients sending totals, like agents pulled
+// by the scraper, are turned into deltas with their restarts before saving.
+// Parameters:
+//   - samples: counter samples from the oldest to the newest
+//   - window: length of the window
+//   - to: end of the window
+//
+// Returns:
+//   - increase: growth within the window
+//   - rate: increase per second between the baseline and the last sample
+//   - ok: false when fewer than two samples are available
+func CounterIncrease(samples []history.Sample, window time.Duration, to time.Time) (increase, rate float64, ok bool) {
+	from := to.Add(-window)
+	start := 0
+	for i, sample := range samples {
+		if sample.Time.After(from) {
+			break
+		}
+		start = i
+	}
+	var used []history.Sample
+	for i := start; i < len(samples) && !samples[i].Time.After(to); i++ {
+		used = append(used, samples[i])
+	}
+	if len(used) < 2 {
+		return 0, 0, false
+	}
+	for i := 1; i < len(used); i++ {
+		if used[i].Reset && used[i].Value < used[i-1].Value {
+			increase += used[i].Value
+			continue
+		}
+		increase += used[i].Value - used[i-1].Value
+	}
+	elapsed := used[len(used)-1].Time.Sub(used[0].Time).Seconds()
+	if elapsed > 0 {
+		rate = increase / elapsed
+	}
+	return increase, rate, true
+}
+
+// Derived returns the read-only <name>_rate and <name>_increase gauges of
+// every counter matching sel that has enough history. They are computed on
+// every call and never stored. The gauges carry the labels of the counter.
+// Parameters:
+//   - s: storage to read counters from
+//   - sel: counter selection, its Type, sort and paging are ignored
+//   - window: window of CounterIncrease
+//   - now: end of the window
+//
+// Returns:
+//   - []metrics.Metrics: derived gauges ordered by name
+//   - error: a storage error
+func Derived(s Storage, sel ListQuery, window time.Duration, now time.Time) ([]metrics.Metrics, error) {
+	sel.Type = constants.Counter
+	all := []*metrics.MetricDTOParams{}
+	stored, err := s.GetMetrics(&all)
+	if err != nil {
+		return nil, err
+	}
+	derived := []metrics.Metrics{}
+	for _, m := range *stored {
+		if !sel.matches(&m) {
+			continue
+		}
+		derived = append(derived, deriveCounter(&m, window, now)...)
+	}
+	slices.SortFunc(derived, compareMetrics)
+	return derived, nil
+}
+
+// deriveCounter returns the derived gauges of one counter or nil.
+func deriveCounter(m *metrics.Metrics, window time.Duration, now time.Time) []metrics.Metrics {
+	increase, rate, ok := CounterIncrease(history.Instance.Series(m.MType, m.ID), window, now)
+	if !ok {
+		return nil
+	}
+	return []metrics.Metrics{
+		{ID: m.ID + IncreaseSuffix, MType: constants.Gauge, Value: &increase, Labels: maps.Clone(m.Labels)},
+		{ID: m.ID + RateSuffix, MType: constants.Gauge, Value: &rate, Labels: maps.Clone(m.Labels)},
+	}
+}
@@ -0,0 +1,84 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+func TestCounterIncrease(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// v - накопленное значение счётчика после обновления
+	at := func(sec int, v float64) history.Sample {
+		return history.Sample{Time: start.Add(time.Duration(sec) * time.Second), Value: v}
+	}
+	set := func(sec int, v float64) history.Sample {
+		sample := at(sec, v)
+		sample.Reset = true
+		return sample
+	}
+	tests := []struct {
+		name         string
+		samples      []history.Sample
+		window       time.Duration
+		to           time.Time
+		wantIncrease float64
+		wantRate     float64
+		wantOK       bool
+	}{
+		{name: "No samples", window: time.Minute, to: start},
+		{name: "One sample", samples: []history.Sample{at(0, 5)}, window: time.Minute, to: start.Add(time.Minute)},
+		{name: "Growth", samples: []history.Sample{at(0, 5), at(10, 10), at(20, 25)}, window: time.Minute, to: start.Add(30 * time.Second), wantIncrease: 20, wantRate: 1, wantOK: true},
+		{name: "Deltas 5 then 3", samples: []history.Sample{at(0, 5), at(10, 10), at(20, 13)}, window: time.Minute, to: start.Add(20 * time.Second), wantIncrease: 8, wantRate: 0.4, wantOK: true},
+		{name: "Constant deltas", samples: []history.Sample{at(0, 5), at(10, 10), at(20, 15), at(30, 20)}, window: time.Minute, to: start.Add(30 * time.Second), wantIncrease: 15, wantRate: 0.5, wantOK: true},
+		{name: "Reset by Set", samples: []history.Sample{at(0, 10), at(10, 20), set(20, 3), at(30, 8)}, window: time.Minute, to: start.Add(30 * time.Second), wantIncrease: 18, wantRate: 0.6, wantOK: true},
+		{name: "Set above total", samples: []history.Sample{at(0, 10), set(10, 30)}, window: time.Minute, to: start.Add(10 * time.Second), wantIncrease: 20, wantRate: 2, wantOK: true},
+		{name: "Baseline before window", samples: []history.Sample{at(0, 1), at(50, 4), at(60, 6), at(70, 10)}, window: 15 * time.Second, to: start.Add(70 * time.Second), wantIncrease: 6, wantRate: 0.3, wantOK: true},
+		{name: "Short window", samples: []history.Sample{at(0, 1), at(10, 4), at(20, 6), at(30, 9)}, window: 5 * time.Second, to: start.Add(20 * time.Second), wantIncrease: 2, wantRate: 0.2, wantOK: true},
+		{name: "No samples in window", samples: []history.Sample{at(0, 1), at(10, 4), at(20, 6)}, window: 5 * time.Second, to: start.Add(15 * time.Second)},
+		{name: "Window after samples", samples: []history.Sample{at(0, 1), at(10, 4)}, window: time.Minute, to: start.Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			increase, rate, ok := CounterIncrease(tt.samples, tt.window, tt.to)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.InDelta(t, tt.wantIncrease, increase, 1e-9)
+			assert.InDelta(t, tt.wantRate, rate, 1e-9)
+		})
+	}
+}
+
+func TestDerived(t *testing.T) {
+	history.Instance.Reset()
+	defer history.Instance.Reset()
+	// первое обновление - база окна, прирост - сумма следующих
+	for _, delta := range []int64{1, 2, 3, 1, 2} {
+		history.Instance.Add(metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(delta)})
+	}
+	history.Instance.Add(metrics.Metrics{ID: "Once", MType: "counter", Delta: int64Ptr(3)})
+	s := &MockStorage{}
+	s.On("GetMetrics", mock.Anything).Return(&[]metrics.Metrics{
+		{ID: "PollCount", MType: "counter", Delta: int64Ptr(9), Labels: map[string]string{"host": "a"}},
+		{ID: "Once", MType: "counter", Delta: int64Ptr(3)},
+		{ID: "Alloc", MType: "gauge", Value: float64Ptr(1)},
+	}, nil)
+
+	derived, err := Derived(s, ListQuery{}, time.Minute, time.Now())
+	require.NoError(t, err)
+	require.Len(t, derived, 2)
+	assert.Equal(t, "PollCount_increase", derived[0].ID)
+	assert.Equal(t, "gauge", derived[0].MType)
+	assert.Equal(t, 8.0, *derived[0].Value)
+	assert.Equal(t, map[string]string{"host": "a"}, derived[0].Labels)
+	assert.Equal(t, "PollCount_rate", derived[1].ID)
+
+	derived, err = Derived(s, ListQuery{Prefix: "Alloc"}, time.Minute, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, derived)
+}
@@ -0,0 +1,170 @@
+package metric
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+// Export formats.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Iterator is implemented by storages that can walk their metrics
+// without copying all of them at once.
+type Iterator interface {
+	Storage
+	EachMetric(visit func(m *metrics.Metrics) error) error
+}
+
+// TimeRange limits exported history samples to From <= time <= To.
+// A zero bound is open.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+func (r TimeRange) contains(t time.Time) bool {
+	return (r.From.IsZero() || !t.Before(r.From)) && (r.To.IsZero() || !t.After(r.To))
+}
+
+// sampleRecord is an exported history sample.
+type sampleRecord struct {
+	Time  time.Time `json:"time"`
+	Type  string    `json:"type"`
+	ID    string    `json:"id"`
+	Value float64   `json:"value"`
+}
+
+// recordWriter writes exported records in one format.
+type recordWriter interface {
+	metric(m *metrics.Metrics) error
+	sample(s *sampleRecord) error
+	flush() error
+}
+
+// Export writes the current metrics to w, one record per metric.
+// Parameters:
+//   - w: destination, written as records are produced
+//   - s: storage to export
+//   - format: FormatCSV or FormatNDJSON
+//
+// Returns:
+//   - error: ErrUnknownFormat or the first write error
+func Export(w io.Writer, s Iterator, format string) error {
+	out, err := newRecordWriter(w, format, []string{"type", "id", "value", "labels"})
+	if err != nil {
+		return err
+	}
+	if err = s.EachMetric(out.metric); err != nil {
+		return err
+	}
+	return out.flush()
+}
+
+// ExportHistory writes the history samples within r to w, one record per
+// sample. Counter samples are shifted to the stored totals as on the dashboard.
+func ExportHistory(w io.Writer, s Iterator, format string, r TimeRange) error {
+	out, err := newRecordWriter(w, format, []string{"time", "type", "id", "value"})
+	if err != nil {
+		return err
+	}
+	err = history.Instance.Each(func(mType, id string, samples []history.Sample) error {
+		var offset float64
+		if mType == constants.Counter {
+			params := []*metrics.MetricDTOParams{{MetricType: mType, MetricsName: id}}
+			stored, err := s.GetMetrics(&params)
+			if err != nil || len(*stored) == 0 {
+				// метрика удалена, пока шла выгрузка
+				return nil
+			}
+			offset = NumericValue(&(*stored)[0]) - samples[len(samples)-1].Value
+		}
+		for _, sample := range samples {
+			if !r.contains(sample.Time) {
+				continue
+			}
+			record := sampleRecord{Time: sample.Time, Type: mType, ID: id, Value: sample.Value + offset}
+			if err := out.sample(&record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return out.flush()
+}
+
+func newRecordWriter(w io.Writer, format string, header []string) (recordWriter, error) {
+	switch format {
+	case FormatCSV:
+		out := &csvWriter{w: csv.NewWriter(w)}
+		return out, out.w.Write(header)
+	case FormatNDJSON:
+		buf := bufio.NewWriter(w)
+		return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// csvWriter writes metrics as "type,id,value,labels" rows with labels
+// as sorted key=value pairs joined by ";", and samples as "time,type,id,value".
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) metric(m *metrics.Metrics) error {
+	value := strconv.FormatFloat(NumericValue(m), 'f', -1, 64)
+	if m.MType == constants.Counter && m.Delta != nil {
+		value = strconv.FormatInt(*m.Delta, 10)
+	}
+	labels := make([]string, 0, len(m.Labels))
+	for _, k := range slices.Sorted(maps.Keys(m.Labels)) {
+		labels = append(labels, k+"="+m.Labels[k])
+	}
+	return c.w.Write([]string{m.MType, m.ID, value, strings.Join(labels, ";")})
+}
+
+func (c *csvWriter) sample(s *sampleRecord) error {
+	return c.w.Write([]string{s.Time.Format(time.RFC3339Nano), s.Type, s.ID, strconv.FormatFloat(s.Value, 'f', -1, 64)})
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter writes one JSON object per line.
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) metric(m *metrics.Metrics) error {
+	return n.enc.Encode(m)
+}
+
+func (n *ndjsonWriter) sample(s *sampleRecord) error {
+	return n.enc.Encode(s)
+}
+
+func (n *ndjsonWriter) flush() error {
+	return n.buf.Flush()
+}
@@ -0,0 +1,88 @@
+package metric
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+// MockIterator добавляет к MockStorage обход метрик
+type MockIterator struct {
+	MockStorage
+	metrics []metrics.Metrics
+}
+
+func (m *MockIterator) EachMetric(visit func(m *metrics.Metrics) error) error {
+	for i := range m.metrics {
+		if err := visit(&m.metrics[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	s := &MockIterator{metrics: []metrics.Metrics{
+		{ID: "PollCount", MType: "counter", Delta: int64Ptr(5)},
+		{ID: "Alloc", MType: "gauge", Value: float64Ptr(1.5), Labels: map[string]string{"host": "a", "dc": "eu,west"}},
+	}}
+
+	tests := []struct {
+		format  string
+		want    string
+		wantErr error
+	}{
+		{format: FormatCSV, want: "type,id,value,labels\ncounter,PollCount,5,\ngauge,Alloc,1.5,\"dc=eu,west;host=a\"\n"},
+		{format: FormatNDJSON, want: `{"id":"PollCount","type":"counter","delta":5}` + "\n" + `{"id":"Alloc","type":"gauge","value":1.5,"labels":{"dc":"eu,west","host":"a"}}` + "\n"},
+		{format: "xml", wantErr: ErrUnknownFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out strings.Builder
+			err := Export(&out, s, tt.format)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestExportHistory(t *testing.T) {
+	history.Instance.Reset()
+	defer history.Instance.Reset()
+	history.Instance.Add(metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(1)})
+	history.Instance.Add(metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(1)})
+	samples := history.Instance.Series("counter", "PollCount")
+
+	s := &MockIterator{}
+	s.On("GetMetrics", mock.Anything).Return(&[]metrics.Metrics{{ID: "PollCount", MType: "counter", Delta: int64Ptr(10)}}, nil)
+
+	var out strings.Builder
+	require.NoError(t, ExportHistory(&out, s, FormatCSV, TimeRange{}))
+	// суммы с момента запуска сдвинуты к сохранённому значению
+	assert.Equal(t, "time,type,id,value\n"+
+		samples[0].Time.Format(time.RFC3339Nano)+",counter,PollCount,9\n"+
+		samples[1].Time.Format(time.RFC3339Nano)+",counter,PollCount,10\n", out.String())
+
+	out.Reset()
+	require.NoError(t, ExportHistory(&out, s, FormatNDJSON, TimeRange{From: samples[1].Time.Add(time.Hour)}))
+	assert.Empty(t, out.String())
+}
+
+func TestTimeRange_contains(t *testing.T) {
+	now := time.Now()
+	assert.True(t, TimeRange{}.contains(now))
+	assert.True(t, TimeRange{From: now, To: now}.contains(now))
+	assert.False(t, TimeRange{From: now.Add(time.Second)}.contains(now))
+	assert.False(t, TimeRange{To: now.Add(-time.Second)}.contains(now))
+}
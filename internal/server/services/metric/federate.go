@@ -0,0 +1,64 @@
+package metric
+
+import (
+	"slices"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+// FederatedMetric is a metric with the time of its last update.
+// Fields:
+//   - Metrics: the metric, a counter carries its total
+//   - Updated: time of the last update, nil when unknown, e.g. for a metric restored at start
+type FederatedMetric struct {
+	metrics.Metrics
+	Updated *time.Time `json:"updated,omitempty"`
+}
+
+// Federation is the body of GET /federate.
+// Fields:
+//   - Time: time of the server when the metrics were selected,
+//     a scraper passes it as since of the next request
+//   - Metrics: selected metrics ordered by type and name
+type Federation struct {
+	Time    time.Time         `json:"time"`
+	Metrics []FederatedMetric `json:"metrics"`
+}
+
+// Federate selects metrics for a server pulling from this one.
+// Parameters:
+//   - s: storage
+//   - q: selection, sorting and paging are ignored
+//   - since: only metrics updated at or after it are returned, zero for all;
+//     metrics without a known update time are returned only for zero
+//   - now: time of the selection
+//
+// Returns:
+//   - *Federation: selected metrics
+//   - error: the error of the storage
+func Federate(s Storage, q ListQuery, since, now time.Time) (*Federation, error) {
+	all, err := s.GetMetrics(&[]*metrics.MetricDTOParams{})
+	if err != nil {
+		return nil, err
+	}
+	result := &Federation{Time: now, Metrics: []FederatedMetric{}}
+	for _, m := range *all {
+		if !q.matches(&m) {
+			continue
+		}
+		fm := FederatedMetric{Metrics: m}
+		if updated, ok := history.Instance.LastUpdated(m.MType, m.ID); ok {
+			fm.Updated = &updated
+		}
+		if !since.IsZero() && (fm.Updated == nil || fm.Updated.Before(since)) {
+			continue
+		}
+		result.Metrics = append(result.Metrics, fm)
+	}
+	slices.SortFunc(result.Metrics, func(a, b FederatedMetric) int {
+		return compareMetrics(a.Metrics, b.Metrics)
+	})
+	return result, nil
+}
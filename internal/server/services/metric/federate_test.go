@@ -0,0 +1,57 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+func TestFederate(t *testing.T) {
+	history.Instance.Reset()
+	defer history.Instance.Reset()
+	before := time.Now().Add(-time.Minute)
+	history.Instance.Add(metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(5)})
+	history.Instance.Add(metrics.Metrics{ID: "Alloc", MType: "gauge", Value: float64Ptr(1)})
+	s := &MockStorage{}
+	s.On("GetMetrics", mock.Anything).Return(&[]metrics.Metrics{
+		{ID: "PollCount", MType: "counter", Delta: int64Ptr(5)},
+		{ID: "Restored", MType: "gauge", Value: float64Ptr(2)},
+		{ID: "Alloc", MType: "gauge", Value: float64Ptr(1), Labels: map[string]string{"host": "a"}},
+	}, nil)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		q       ListQuery
+		since   time.Time
+		wantIDs []string
+	}{
+		{name: "All", wantIDs: []string{"PollCount", "Alloc", "Restored"}},
+		{name: "Selector", q: ListQuery{Labels: map[string]string{"host": "a"}}, wantIDs: []string{"Alloc"}},
+		{name: "Since", since: before, wantIDs: []string{"PollCount", "Alloc"}},
+		{name: "Nothing new", since: now.Add(time.Minute), wantIDs: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Federate(s, tt.q, tt.since, now)
+			require.NoError(t, err)
+			assert.Equal(t, now, result.Time)
+			ids := []string{}
+			for _, m := range result.Metrics {
+				ids = append(ids, m.ID)
+				if m.ID == "Restored" {
+					assert.Nil(t, m.Updated)
+				} else {
+					assert.NotNil(t, m.Updated)
+				}
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
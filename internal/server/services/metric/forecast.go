@@ -0,0 +1,303 @@
+package metric
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+// Forecast defaults.
+const (
+	// MinForecastSamples is the number of samples a trend is fitted to at least.
+	MinForecastSamples = 5
+	// DefaultStaleAfter is the time without samples after which a gauge is stale.
+	DefaultStaleAfter = 5 * time.Minute
+)
+
+// Forecast states.
+const (
+	// ForecastApproaching: the trend reaches the threshold, see Forecast.ETA.
+	ForecastApproaching = "approaching"
+	// ForecastNotApproaching: the gauge is flat or moves away from the threshold.
+	ForecastNotApproaching = "not_approaching"
+	// ForecastReached: the gauge is already at or beyond the threshold.
+	ForecastReached = "reached"
+	// ForecastInsufficientData: fewer than MinForecastSamples samples in the window.
+	ForecastInsufficientData = "insufficient_data"
+	// ForecastStale: the gauge has not been updated for ForecastQuery.StaleAfter.
+	ForecastStale = "stale"
+)
+
+// Forecast confidence levels, derived from the fit quality and the sample count.
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+// ForecastQuery selects gauges and the threshold to forecast.
+// Fields:
+//   - Select: gauge selection, its Type, sort and paging are ignored
+//   - Threshold: value the gauges are expected to reach, e.g. 0 for FreeMemory
+//   - Rising: the gauges approach the threshold from below, e.g. a disk usage
+//     percentage, by default they approach it from above
+//   - Window: history used for the trend, all kept samples when zero
+//   - Horizon: ETAs further away are reported with WithinHorizon false, no limit when zero
+//   - StaleAfter: time without samples after which no forecast is made, DefaultStaleAfter when zero
+type ForecastQuery struct {
+	Select     ListQuery
+	Threshold  float64
+	Rising     bool
+	Window     time.Duration
+	Horizon    time.Duration
+	StaleAfter time.Duration
+}
+
+// Forecast is the linear trend of one gauge.
+// Fields:
+//   - ID, Labels: the gauge
+//   - Status: one of the Forecast* states
+//   - Current: the stored value
+//   - Samples: number of samples the trend is fitted to
+//   - LastSample: time of the newest sample
+//   - Slope: change per second of the least-squares line
+//   - SlopeStdErr: standard error of Slope
+//   - R2: coefficient of determination of the line, 1 for a perfect fit
+//   - Confidence: high, medium or low
+//   - ETA: predicted time the threshold is reached, only when approaching
+//   - SecondsLeft: seconds from now to ETA
+//   - Earliest, Latest: ETA for the slope ± two standard errors, Latest is
+//     absent when the trend may as well be flat
+//   - WithinHorizon: whether ETA is within the horizon of the query
+type Forecast struct {
+	ID            string            `json:"id"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Status        string            `json:"status"`
+	Current       float64           `json:"current"`
+	Samples       int               `json:"samples"`
+	LastSample    *time.Time        `json:"last_sample,omitempty"`
+	Slope         *float64          `json:"slope,omitempty"`
+	SlopeStdErr   *float64          `json:"slope_stderr,omitempty"`
+	R2            *float64          `json:"r2,omitempty"`
+	Confidence    string            `json:"confidence,omitempty"`
+	ETA           *time.Time        `json:"eta,omitempty"`
+	SecondsLeft   *float64          `json:"seconds_left,omitempty"`
+	Earliest      *time.Time        `json:"earliest,omitempty"`
+	Latest        *time.Time        `json:"latest,omitempty"`
+	WithinHorizon bool              `json:"within_horizon"`
+}
+
+// ForecastResult is the result of Forecasts.
+type ForecastResult struct {
+	Threshold float64    `json:"threshold"`
+	Rising    bool       `json:"rising"`
+	Window    string     `json:"window,omitempty"`
+	Horizon   string     `json:"horizon,omitempty"`
+	Forecasts []Forecast `json:"forecasts"`
+}
+
+// linearFit is a least-squares line y = intercept + slope*x,
+// x in seconds since the first sample.
+type linearFit struct {
+	slope     float64
+	intercept float64
+	stderr    float64
+	r2        float64
+}
+
+// Forecasts fits a least-squares line to the recent history of every gauge
+// matching q and predicts when it reaches q.Threshold.
+// Parameters:
+//   - s: storage to read gauges from
+//   - q: selection, threshold and windows
+//   - now: time of the forecast
+//
+// Returns:
+//   - *ForecastResult: approaching gauges by ETA first, then the others by name
+//   - error: a storage error
+func Forecasts(s Storage, q ForecastQuery, now time.Time) (*ForecastResult, error) {
+	sel := q.Select
+	sel.Type = constants.Gauge
+	if q.StaleAfter <= 0 {
+		q.StaleAfter = DefaultStaleAfter
+	}
+	all := []*metrics.MetricDTOParams{}
+	stored, err := s.GetMetrics(&all)
+	if err != nil {
+		return nil, err
+	}
+	result := &ForecastResult{Threshold: q.Threshold, Rising: q.Rising, Forecasts: []Forecast{}}
+	if q.Window > 0 {
+		result.Window = q.Window.String()
+	}
+	if q.Horizon > 0 {
+		result.Horizon = q.Horizon.String()
+	}
+	for _, m := range *stored {
+		if !sel.matches(&m) {
+			continue
+		}
+		result.Forecasts = append(result.Forecasts, forecastGauge(&m, history.Instance.Series(m.MType, m.ID), q, now))
+	}
+	slices.SortFunc(result.Forecasts, func(a, b Forecast) int {
+		if (a.ETA != nil) != (b.ETA != nil) {
+			if a.ETA != nil {
+				return -1
+			}
+			return 1
+		}
+		if a.ETA != nil {
+			if c := a.ETA.Compare(*b.ETA); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return result, nil
+}
+
+func forecastGauge(m *metrics.Metrics, samples []history.Sample, q ForecastQuery, now time.Time) Forecast {
+	f := Forecast{ID: m.ID, Labels: m.Labels, Current: NumericValue(m)}
+	if len(samples) > 0 {
+		last := samples[len(samples)-1].Time
+		f.LastSample = &last
+		// агент перестал присылать значения, тренд устарел
+		if now.Sub(last) > q.StaleAfter {
+			f.Status = ForecastStale
+			return f
+		}
+	}
+	if q.Window > 0 {
+		from := now.Add(-q.Window)
+		samples = slices.DeleteFunc(slices.Clone(samples), func(s history.Sample) bool {
+			return s.Time.Before(from)
+		})
+	}
+	f.Samples = len(samples)
+	if (q.Rising && f.Current >= q.Threshold) || (!q.Rising && f.Current <= q.Threshold) {
+		f.Status = ForecastReached
+		return f
+	}
+	fit, ok := fitLine(samples)
+	if !ok {
+		f.Status = ForecastInsufficientData
+		return f
+	}
+	f.Slope, f.SlopeStdErr, f.R2 = &fit.slope, &fit.stderr, &fit.r2
+	f.Confidence = confidence(fit, len(samples))
+
+	start, last := samples[0].Time, samples[len(samples)-1].Time
+	lastX := last.Sub(start).Seconds()
+	// прогноз строится от значения прямой в момент последнего отсчёта
+	fitted := fit.intercept + fit.slope*lastX
+	eta, ok := crossing(last, fitted, fit.slope, q, now)
+	if !ok {
+		f.Status = ForecastNotApproaching
+		return f
+	}
+	f.Status = ForecastApproaching
+	f.ETA = &eta
+	left := eta.Sub(now).Seconds()
+	f.SecondsLeft = &left
+	f.WithinHorizon = q.Horizon <= 0 || eta.Sub(now) <= q.Horizon
+	f.Earliest, f.Latest = bounds(last, fitted, fit, q, now)
+	return f
+}
+
+// crossing returns when a line through (from, value) with slope reaches
+// the threshold of q, no earlier than now. A line already beyond the
+// threshold reaches it now. ok is false when the line moves away from
+// the threshold or would take longer than time.Duration can hold.
+func crossing(from time.Time, value, slope float64, q ForecastQuery, now time.Time) (time.Time, bool) {
+	if slope == 0 || (slope > 0) != q.Rising {
+		return time.Time{}, false
+	}
+	seconds := max(0, (q.Threshold-value)/slope)
+	if seconds > float64(math.MaxInt64)/float64(time.Second) {
+		return time.Time{}, false
+	}
+	eta := from.Add(time.Duration(seconds * float64(time.Second)))
+	if eta.Before(now) {
+		eta = now
+	}
+	return eta, true
+}
+
+// bounds returns the earliest and latest crossings over the slope range
+// slope ± 2 stderr. The latest bound is nil when the range includes
+// slopes that never reach the threshold.
+func bounds(from time.Time, value float64, fit linearFit, q ForecastQuery, now time.Time) (*time.Time, *time.Time) {
+	var crossings []time.Time
+	for _, slope := range []float64{fit.slope - 2*fit.stderr, fit.slope + 2*fit.stderr} {
+		if t, ok := crossing(from, value, slope, q, now); ok {
+			crossings = append(crossings, t)
+		}
+	}
+	switch len(crossings) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &crossings[0], nil
+	}
+	earliest, latest := crossings[0], crossings[1]
+	if latest.Before(earliest) {
+		earliest, latest = latest, earliest
+	}
+	return &earliest, &latest
+}
+
+// fitLine fits a least-squares line to samples.
+// ok is false for fewer than MinForecastSamples samples or samples taken at the same time.
+func fitLine(samples []history.Sample) (linearFit, bool) {
+	n := float64(len(samples))
+	if len(samples) < MinForecastSamples {
+		return linearFit{}, false
+	}
+	start := samples[0].Time
+	var sumX, sumY float64
+	for _, s := range samples {
+		sumX += s.Time.Sub(start).Seconds()
+		sumY += s.Value
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var sxx, sxy, syy float64
+	for _, s := range samples {
+		dx, dy := s.Time.Sub(start).Seconds()-meanX, s.Value-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return linearFit{}, false
+	}
+	fit := linearFit{slope: sxy / sxx}
+	fit.intercept = meanY - fit.slope*meanX
+	var sse float64
+	for _, s := range samples {
+		r := s.Value - (fit.intercept + fit.slope*s.Time.Sub(start).Seconds())
+		sse += r * r
+	}
+	fit.r2 = 1
+	if syy > 0 {
+		fit.r2 = max(0, 1-sse/syy)
+	}
+	fit.stderr = math.Sqrt(sse / (n - 2) / sxx)
+	return fit, true
+}
+
+// confidence grades a fit by how well the line explains the samples
+// and how many samples it is based on.
+func confidence(fit linearFit, samples int) string {
+	switch {
+	case fit.r2 >= 0.8 && samples >= 2*MinForecastSamples:
+		return ConfidenceHigh
+	case fit.r2 >= 0.5:
+		return ConfidenceMedium
+	}
+	return ConfidenceLow
+}
@@ -0,0 +1,168 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+func TestFitLine(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := func(values ...float64) []history.Sample {
+		samples := make([]history.Sample, len(values))
+		for i, v := range values {
+			samples[i] = history.Sample{Time: start.Add(time.Duration(i*10) * time.Second), Value: v}
+		}
+		return samples
+	}
+	tests := []struct {
+		name       string
+		samples    []history.Sample
+		wantOK     bool
+		wantSlope  float64
+		wantR2     float64
+		wantStdErr float64
+	}{
+		{name: "Too few samples", samples: series(1, 2, 3, 4)},
+		{name: "Same time", samples: []history.Sample{{Time: start, Value: 1}, {Time: start, Value: 2}, {Time: start, Value: 3}, {Time: start, Value: 4}, {Time: start, Value: 5}}},
+		{name: "Exact line", samples: series(100, 90, 80, 70, 60), wantOK: true, wantSlope: -1, wantR2: 1},
+		{name: "Flat", samples: series(5, 5, 5, 5, 5), wantOK: true, wantR2: 1},
+		{name: "Noisy", samples: series(10, 12, 11, 13, 12), wantOK: true, wantSlope: 0.05, wantR2: 2.5 / 5.2, wantStdErr: 0.03},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fit, ok := fitLine(tt.samples)
+			require.Equal(t, tt.wantOK, ok)
+			if !ok {
+				return
+			}
+			assert.InDelta(t, tt.wantSlope, fit.slope, 1e-9)
+			assert.InDelta(t, tt.wantR2, fit.r2, 1e-9)
+			assert.InDelta(t, tt.wantStdErr, fit.stderr, 1e-6)
+		})
+	}
+}
+
+func TestCrossing(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		from   time.Time
+		value  float64
+		slope  float64
+		q      ForecastQuery
+		want   time.Time
+		wantOK bool
+	}{
+		{name: "Falling", from: now, value: 100, slope: -2, q: ForecastQuery{Threshold: 10}, want: now.Add(45 * time.Second), wantOK: true},
+		{name: "Rising", from: now, value: 10, slope: 0.5, q: ForecastQuery{Threshold: 100, Rising: true}, want: now.Add(180 * time.Second), wantOK: true},
+		{name: "Moving away", from: now, value: 100, slope: 2, q: ForecastQuery{Threshold: 10}},
+		{name: "Flat", from: now, value: 100, q: ForecastQuery{Threshold: 10}},
+		{name: "Already crossed", from: now.Add(-time.Minute), value: 20, slope: -1, q: ForecastQuery{Threshold: 10}, want: now, wantOK: true},
+		{name: "Beyond duration", from: now, value: 1e300, slope: -1e-300, q: ForecastQuery{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eta, ok := crossing(tt.from, tt.value, tt.slope, tt.q, now)
+			require.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, eta)
+		})
+	}
+}
+
+func TestBounds(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := ForecastQuery{Threshold: 0}
+
+	earliest, latest := bounds(now, 100, linearFit{slope: -2, stderr: 0.5}, q, now)
+	require.NotNil(t, earliest)
+	require.NotNil(t, latest)
+	assert.Equal(t, now.Add(100*time.Second/3), *earliest)
+	assert.Equal(t, now.Add(100*time.Second), *latest)
+
+	earliest, latest = bounds(now, 100, linearFit{slope: -1, stderr: 1}, q, now)
+	require.NotNil(t, earliest)
+	assert.Equal(t, now.Add(100*time.Second/3), *earliest)
+	assert.Nil(t, latest)
+
+	earliest, latest = bounds(now, 100, linearFit{slope: 1, stderr: 0.1}, q, now)
+	assert.Nil(t, earliest)
+	assert.Nil(t, latest)
+}
+
+func TestForecastGauge(t *testing.T) {
+	now := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	series := func(last time.Time, values ...float64) []history.Sample {
+		samples := make([]history.Sample, len(values))
+		for i, v := range values {
+			samples[i] = history.Sample{Time: last.Add(time.Duration(i-len(values)+1) * 10 * time.Second), Value: v}
+		}
+		return samples
+	}
+	tests := []struct {
+		name        string
+		current     float64
+		samples     []history.Sample
+		q           ForecastQuery
+		wantStatus  string
+		wantSamples int
+		wantETA     time.Duration
+		wantConf    string
+	}{
+		{name: "No history", current: 50, q: ForecastQuery{Threshold: 10, StaleAfter: time.Minute}, wantStatus: ForecastInsufficientData},
+		{name: "Stale", current: 50, samples: series(now.Add(-time.Hour), 90, 80, 70, 60, 50), q: ForecastQuery{Threshold: 10, StaleAfter: time.Minute}, wantStatus: ForecastStale},
+		{name: "Reached", current: 5, samples: series(now, 45, 35, 25, 15, 5), q: ForecastQuery{Threshold: 10, StaleAfter: time.Minute}, wantStatus: ForecastReached, wantSamples: 5},
+		{name: "Approaching", current: 50, samples: series(now, 90, 80, 70, 60, 50), q: ForecastQuery{Threshold: 10, StaleAfter: time.Minute}, wantStatus: ForecastApproaching, wantSamples: 5, wantETA: 40 * time.Second, wantConf: ConfidenceMedium},
+		{name: "Rising away", current: 90, samples: series(now, 50, 60, 70, 80, 90), q: ForecastQuery{Threshold: 10, StaleAfter: time.Minute}, wantStatus: ForecastNotApproaching, wantSamples: 5, wantConf: ConfidenceMedium},
+		{name: "Window too short", current: 50, samples: series(now, 90, 80, 70, 60, 50), q: ForecastQuery{Threshold: 10, StaleAfter: time.Minute, Window: 25 * time.Second}, wantStatus: ForecastInsufficientData, wantSamples: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := forecastGauge(&metrics.Metrics{ID: "FreeMemory", MType: "gauge", Value: &tt.current}, tt.samples, tt.q, now)
+			assert.Equal(t, tt.wantStatus, f.Status)
+			assert.Equal(t, tt.wantSamples, f.Samples)
+			assert.Equal(t, tt.wantConf, f.Confidence)
+			if tt.wantETA == 0 {
+				assert.Nil(t, f.ETA)
+				return
+			}
+			require.NotNil(t, f.ETA)
+			assert.Equal(t, now.Add(tt.wantETA), *f.ETA)
+			assert.InDelta(t, tt.wantETA.Seconds(), *f.SecondsLeft, 1e-9)
+			assert.True(t, f.WithinHorizon)
+		})
+	}
+}
+
+func TestForecasts(t *testing.T) {
+	history.Instance.Reset()
+	defer history.Instance.Reset()
+	history.Instance.Add(metrics.Metrics{ID: "Alloc", MType: "gauge", Value: float64Ptr(1)})
+	s := &MockStorage{}
+	s.On("GetMetrics", mock.Anything).Return(&[]metrics.Metrics{
+		{ID: "Alloc", MType: "gauge", Value: float64Ptr(1)},
+		{ID: "Free", MType: "gauge", Value: float64Ptr(0)},
+		{ID: "PollCount", MType: "counter", Delta: int64Ptr(5)},
+	}, nil)
+
+	result, err := Forecasts(s, ForecastQuery{Threshold: 10, Rising: true, Window: time.Hour}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "1h0m0s", result.Window)
+	assert.Empty(t, result.Horizon)
+	require.Len(t, result.Forecasts, 2)
+	assert.Equal(t, "Alloc", result.Forecasts[0].ID)
+	assert.Equal(t, ForecastInsufficientData, result.Forecasts[0].Status)
+	assert.Equal(t, 1, result.Forecasts[0].Samples)
+	assert.NotNil(t, result.Forecasts[0].LastSample)
+	assert.Equal(t, "Free", result.Forecasts[1].ID)
+
+	result, err = Forecasts(s, ForecastQuery{Threshold: 10, Rising: true}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, ForecastStale, result.Forecasts[0].Status)
+}
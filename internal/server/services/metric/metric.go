@@ -2,12 +2,23 @@ package metric
 
 import (
 	"errors"
+	"strings"
+	"time"
 
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
 	"github.com/Maxim-Ba/metriccollector/internal/logger"
 	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/anomaly"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/relay"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/stream"
 	"github.com/Maxim-Ba/metriccollector/internal/templates"
 )
 
+// ErrNotFound is returned by Get when storage has none of the requested metrics.
+var ErrNotFound = errors.New("no metrics found")
+
 // Storage defines the interface for metric persistence operations.
 // Implementations should provide methods for saving and retrieving metrics.
 type Storage interface {
@@ -16,15 +27,18 @@ type Storage interface {
 	GetMetrics(params *[]*metrics.MetricDTOParams) (*[]metrics.Metrics, error)
 }
 
-// GetAll retrieves all metrics from storage and returns them as an HTML page.
-func GetAll(s Storage) (string, error) {
-	empySlice := []*metrics.MetricDTOParams{}
-	metricsSlice, err := s.GetMetrics(&empySlice)
+// GetAll retrieves all metrics from storage and returns them as an HTML
+// dashboard filtered and ordered according to opts.
+func GetAll(s Storage, opts DashboardOptions) (string, error) {
+	dashboard, err := Dashboard(s, opts, time.Now())
 	if err != nil {
 		return "", err
 	}
-	html := templates.GetAllMetricsHTMLPage(metricsSlice)
-	return html, nil
+	var html strings.Builder
+	if err = templates.RenderDashboard(&html, dashboard); err != nil {
+		return "", err
+	}
+	return html.String(), nil
 }
 
 // Get retrieves a specific metric from storage based on provided parameters.
@@ -35,26 +49,60 @@ func Get(s Storage, metricsNames *[]*metrics.MetricDTOParams) (*metrics.Metrics,
 		return nil, err
 	}
 	if len(*metricsSlice) == 0 {
-		return nil, errors.New("no metrics found")
+		return nil, ErrNotFound
 	}
 	metric := (*metricsSlice)[0]
 	return &metric, nil
 }
 
-// Update persists a single metric to storage.
+// Update persists a single metric to storage, appends it to the replication
+// log, records it in the history, feeds it to the anomaly detector and
+// publishes it to stream subscribers.
 func Update(s Storage, m *metrics.Metrics) error {
-	err := s.SaveMetric(m)
+	err := replication.Instance.Record(replication.OpUpdate, []metrics.Metrics{*m}, func() error {
+		return s.SaveMetric(m)
+	})
 	if err != nil {
 		return err
 	}
+	updated(*m)
 	return nil
 }
 
-// UpdateMany persists multiple metrics to storage in a batch operation.
+// UpdateMany persists multiple metrics to storage in a batch operation,
+// appends them to the replication log as one entry, records them in the
+// history, feeds them to the anomaly detector and publishes them to
+// stream subscribers.
 func UpdateMany(s Storage, m *[]metrics.Metrics) error {
-	err := s.SaveMetrics(m)
+	err := replication.Instance.Record(replication.OpUpdate, *m, func() error {
+		return s.SaveMetrics(m)
+	})
 	if err != nil {
 		return err
 	}
+	updated(*m...)
 	return nil
 }
+
+// updated passes saved updates to the history, the anomaly detector,
+// stream subscribers and the relay.
+func updated(m ...metrics.Metrics) {
+	history.Instance.Add(m...)
+	anomaly.Instance.Observe(m...)
+	stream.Instance.Publish(m...)
+	relay.Instance.Add(m...)
+}
+
+// replaced passes values stored by Set to the history, the anomaly detector,
+// stream subscribers and the relay. The relay forwards increments, so only
+// gauges are relayed: a replaced counter can not be sent as a delta.
+func replaced(m ...metrics.Metrics) {
+	history.Instance.Set(m...)
+	anomaly.Instance.Observe(m...)
+	stream.Instance.Publish(m...)
+	for _, g := range m {
+		if g.MType == constants.Gauge {
+			relay.Instance.Add(g)
+		}
+	}
+}
@@ -62,7 +62,7 @@ func TestGetAll(t *testing.T) {
 			mockStorage := new(MockStorage)
 			tt.mockSetup(mockStorage)
 
-			html, err := GetAll(mockStorage)
+			html, err := GetAll(mockStorage, DashboardOptions{})
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
@@ -0,0 +1,125 @@
+package metric
+
+import (
+	"bufio"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+)
+
+// PrometheusContentType is the media type of the text exposition format.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WritePrometheus writes every metric in the Prometheus text exposition
+// format. Each counter is followed by its derived _increase and _rate gauges.
+// Names and label names are sanitized to the Prometheus charset; when two
+// metrics end up with the same name, e.g. a gauge and a counter called the
+// same, only the first one is written.
+// Parameters:
+//   - w: destination
+//   - s: storage to expose
+//   - window: window of the derived gauges
+//   - now: end of the window
+//
+// Returns:
+//   - error: the first write error
+func WritePrometheus(w io.Writer, s Iterator, window time.Duration, now time.Time) error {
+	out := bufio.NewWriter(w)
+	written := map[string]struct{}{}
+	family := func(m *metrics.Metrics) {
+		name := PrometheusName(m.ID)
+		if _, ok := written[name]; ok {
+			return
+		}
+		written[name] = struct{}{}
+		out.WriteString("# TYPE " + name + " " + m.MType + "\n")
+		out.WriteString(name)
+		writePrometheusLabels(out, m.Labels)
+		out.WriteString(" " + prometheusValue(m) + "\n")
+	}
+	err := s.EachMetric(func(m *metrics.Metrics) error {
+		family(m)
+		if m.MType == constants.Counter {
+			for _, d := range deriveCounter(m, window, now) {
+				family(&d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// PrometheusName replaces the characters Prometheus does not allow
+// in metric and label names with underscores.
+func PrometheusName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_' || r == ':' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func writePrometheusLabels(out *bufio.Writer, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	out.WriteByte('{')
+	for i, k := range slices.Sorted(maps.Keys(labels)) {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		// в именах меток двоеточие недопустимо
+		out.WriteString(strings.ReplaceAll(PrometheusName(k), ":", "_"))
+		out.WriteString(`="`)
+		out.WriteString(escapeLabelValue(labels[k]))
+		out.WriteByte('"')
+	}
+	out.WriteByte('}')
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+// prometheusValue formats a sample value, Prometheus spells
+// infinities and NaN differently from strconv.
+func prometheusValue(m *metrics.Metrics) string {
+	if m.MType == constants.Counter && m.Delta != nil {
+		return strconv.FormatInt(*m.Delta, 10)
+	}
+	v := NumericValue(m)
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
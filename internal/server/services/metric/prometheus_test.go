@@ -0,0 +1,56 @@
+package metric
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	history.Instance.Reset()
+	defer history.Instance.Reset()
+	history.Instance.Add(metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(1)})
+	history.Instance.Add(metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(4)})
+	s := &MockIterator{metrics: []metrics.Metrics{
+		{ID: "PollCount", MType: "counter", Delta: int64Ptr(5)},
+		{ID: "Alloc", MType: "gauge", Value: float64Ptr(1.5), Labels: map[string]string{"host": "web-1", "path": `C:\"x"`}},
+		{ID: "cpu.load-1", MType: "gauge", Value: float64Ptr(math.Inf(1))},
+		{ID: "PollCount", MType: "gauge", Value: float64Ptr(2)},
+	}}
+
+	var out strings.Builder
+	require.NoError(t, WritePrometheus(&out, s, time.Minute, time.Now()))
+
+	lines := strings.Split(out.String(), "\n")
+	assert.Equal(t, []string{
+		"# TYPE PollCount counter",
+		"PollCount 5",
+		"# TYPE PollCount_increase gauge",
+		"PollCount_increase 4",
+		"# TYPE PollCount_rate gauge",
+	}, lines[:5])
+	assert.Regexp(t, `^PollCount_rate \S+$`, lines[5])
+	assert.Equal(t, []string{
+		"# TYPE Alloc gauge",
+		`Alloc{host="web-1",path="C:\\\"x\""} 1.5`,
+		"# TYPE cpu_load_1 gauge",
+		"cpu_load_1 +Inf",
+		"",
+	}, lines[6:])
+}
+
+func TestPrometheusName(t *testing.T) {
+	assert.Equal(t, "HeapAlloc", PrometheusName("HeapAlloc"))
+	assert.Equal(t, "_9lives", PrometheusName("9lives"))
+	assert.Equal(t, "disk_used_percent", PrometheusName("disk.used%percent"))
+	assert.Equal(t, "ns:metric", PrometheusName("ns:metric"))
+	assert.Equal(t, "_", PrometheusName(""))
+	assert.Equal(t, "__", PrometheusName("мы"))
+}
@@ -0,0 +1,280 @@
+package metric
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/anomaly"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/relay"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+)
+
+// Sort orders accepted by ListQuery.Sort, a leading "-" reverses the order.
+const (
+	SortByName  = "name"
+	SortByType  = "type"
+	SortByValue = "value"
+)
+
+// Page size limits for List.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrInvalidSort = errors.New("invalid sort order")
+
+// Editor is implemented by storages that can replace and remove metrics.
+type Editor interface {
+	SetMetric(m *metrics.Metrics) error
+	DeleteMetric(mType, name string) error
+}
+
+// ListQuery selects, orders and pages metrics for List.
+// Zero values disable the corresponding filter.
+type ListQuery struct {
+	Type   string
+	Prefix string
+	Regex  *regexp.Regexp
+	Labels map[string]string
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+// Page is one page of List results.
+// NextCursor is empty on the last page.
+type Page struct {
+	Metrics    []metrics.Metrics `json:"metrics"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// cursor points at the last metric of a page.
+// Sort is kept to reject a cursor reused with another order.
+// Value is the gauge value or the counter delta formatted exactly,
+// a delta beyond 2^53 would not survive a float64.
+type cursor struct {
+	Sort  string `json:"s"`
+	Type  string `json:"t"`
+	ID    string `json:"id"`
+	Value string `json:"v,omitempty"`
+}
+
+// List returns metrics matching q as a page.
+// Metrics are ordered by q.Sort with type and name as tie breakers,
+// so a cursor stays valid while metrics are added or removed.
+func List(s Storage, q ListQuery) (*Page, error) {
+	less, err := sortFunc(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	var after *cursor
+	if q.Cursor != "" {
+		after, err = decodeCursor(q.Cursor)
+		if err != nil || after.Sort != q.Sort {
+			return nil, ErrInvalidCursor
+		}
+	}
+	var pos metrics.Metrics
+	if after != nil {
+		if pos, err = after.metric(); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	all := []*metrics.MetricDTOParams{}
+	stored, err := s.GetMetrics(&all)
+	if err != nil {
+		return nil, err
+	}
+	matched := slices.DeleteFunc(*stored, func(m metrics.Metrics) bool {
+		return !q.matches(&m)
+	})
+	slices.SortFunc(matched, less)
+	if after != nil {
+		start, found := slices.BinarySearchFunc(matched, pos, less)
+		if found {
+			start++
+		}
+		matched = matched[start:]
+	}
+
+	if matched == nil {
+		matched = []metrics.Metrics{}
+	}
+	page := &Page{Metrics: matched}
+	if len(matched) > limit {
+		page.Metrics = matched[:limit]
+		page.NextCursor = encodeCursor(q.Sort, matched[limit-1])
+	}
+	return page, nil
+}
+
+func (q ListQuery) matches(m *metrics.Metrics) bool {
+	if q.Type != "" && m.MType != q.Type {
+		return false
+	}
+	if q.Prefix != "" && !strings.HasPrefix(m.ID, q.Prefix) {
+		return false
+	}
+	if q.Regex != nil && !q.Regex.MatchString(m.ID) {
+		return false
+	}
+	for k, v := range q.Labels {
+		if label, ok := m.Labels[k]; !ok || label != v {
+			return false
+		}
+	}
+	return true
+}
+
+func sortFunc(order string) (func(a, b metrics.Metrics) int, error) {
+	field, desc := strings.CutPrefix(order, "-")
+	var primary func(a, b metrics.Metrics) int
+	switch field {
+	case "", SortByName:
+		primary = func(a, b metrics.Metrics) int { return cmp.Compare(a.ID, b.ID) }
+	case SortByType:
+		primary = func(a, b metrics.Metrics) int { return cmp.Compare(a.MType, b.MType) }
+	case SortByValue:
+		primary = compareValues
+	default:
+		return nil, ErrInvalidSort
+	}
+	return func(a, b metrics.Metrics) int {
+		c := primary(a, b)
+		if desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Or(cmp.Compare(a.MType, b.MType), cmp.Compare(a.ID, b.ID))
+	}, nil
+}
+
+// NumericValue returns the gauge value or the counter delta as float64.
+func NumericValue(m *metrics.Metrics) float64 {
+	if m.MType == constants.Counter && m.Delta != nil {
+		return float64(*m.Delta)
+	}
+	if m.Value != nil {
+		return *m.Value
+	}
+	return 0
+}
+
+// compareValues orders metrics by value, counters are compared
+// as integers so that large deltas keep their order.
+func compareValues(a, b metrics.Metrics) int {
+	if a.MType == constants.Counter && b.MType == constants.Counter && a.Delta != nil && b.Delta != nil {
+		return cmp.Compare(*a.Delta, *b.Delta)
+	}
+	return cmp.Compare(NumericValue(&a), NumericValue(&b))
+}
+
+func encodeCursor(order string, m metrics.Metrics) string {
+	c := cursor{Sort: order, Type: m.MType, ID: m.ID}
+	switch {
+	case m.MType == constants.Counter && m.Delta != nil:
+		c.Value = strconv.FormatInt(*m.Delta, 10)
+	case m.Value != nil:
+		c.Value = strconv.FormatFloat(*m.Value, 'g', -1, 64)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// metric rebuilds the metric the cursor points at for comparisons.
+func (c *cursor) metric() (metrics.Metrics, error) {
+	m := metrics.Metrics{ID: c.ID, MType: c.Type}
+	text := cmp.Or(c.Value, "0")
+	if c.Type == constants.Counter {
+		delta, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return m, err
+		}
+		m.Delta = &delta
+		return m, nil
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return m, err
+	}
+	m.Value = &value
+	return m, nil
+}
+
+// Set stores m replacing the current value, a counter is not incremented.
+// The change is appended to the replication log, recorded in the history,
+// fed to the anomaly detector, published to stream subscribers and,
+// for gauges, passed to the relay.
+func Set(s Editor, m *metrics.Metrics) error {
+	return set(s, m, true)
+}
+
+func set(s Editor, m *metrics.Metrics, record bool) error {
+	err := journal(record, replication.OpSet, []metrics.Metrics{*m}, func() error {
+		return s.SetMetric(m)
+	})
+	if err != nil {
+		return err
+	}
+	replaced(*m)
+	return nil
+}
+
+// Delete removes the metric of the given type and name, its history, its
+// anomaly detector state and its update pending in the relay.
+// The change is appended to the replication log.
+func Delete(s Editor, mType, name string) error {
+	return remove(s, mType, name, true)
+}
+
+func remove(s Editor, mType, name string, record bool) error {
+	err := journal(record, replication.OpDelete, []metrics.Metrics{{ID: name, MType: mType}}, func() error {
+		return s.DeleteMetric(mType, name)
+	})
+	if err != nil {
+		return err
+	}
+	forget(mType, name)
+	return nil
+}
+
+// forget drops the history, the anomaly detector state and the update
+// pending in the relay of a deleted metric.
+func forget(mType, name string) {
+	history.Instance.Delete(mType, name)
+	relay.Instance.Forget(mType, name)
+	if mType == constants.Gauge {
+		anomaly.Instance.Forget(name)
+	}
+}
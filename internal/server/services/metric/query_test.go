@@ -0,0 +1,108 @@
+package metric
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func listFixture() *[]metrics.Metrics {
+	return &[]metrics.Metrics{
+		{ID: "HeapAlloc", MType: "gauge", Value: float64Ptr(300), Labels: map[string]string{"host": "a"}},
+		{ID: "HeapSys", MType: "gauge", Value: float64Ptr(100), Labels: map[string]string{"host": "b"}},
+		{ID: "PollCount", MType: "counter", Delta: int64Ptr(200), Labels: map[string]string{"host": "a"}},
+		{ID: "RandomValue", MType: "gauge", Value: float64Ptr(0.5)},
+	}
+}
+
+func ids(page *Page) []string {
+	result := make([]string, len(page.Metrics))
+	for i, m := range page.Metrics {
+		result[i] = m.ID
+	}
+	return result
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   ListQuery
+		want    []string
+		wantErr error
+	}{
+		{name: "all sorted by name", query: ListQuery{}, want: []string{"HeapAlloc", "HeapSys", "PollCount", "RandomValue"}},
+		{name: "by type", query: ListQuery{Type: "counter"}, want: []string{"PollCount"}},
+		{name: "by prefix", query: ListQuery{Prefix: "Heap"}, want: []string{"HeapAlloc", "HeapSys"}},
+		{name: "by regex", query: ListQuery{Regex: regexp.MustCompile("Value$|Count$")}, want: []string{"PollCount", "RandomValue"}},
+		{name: "by label", query: ListQuery{Labels: map[string]string{"host": "a"}}, want: []string{"HeapAlloc", "PollCount"}},
+		{name: "by value descending", query: ListQuery{Sort: "-value"}, want: []string{"HeapAlloc", "PollCount", "HeapSys", "RandomValue"}},
+		{name: "by type then name", query: ListQuery{Sort: "type"}, want: []string{"PollCount", "HeapAlloc", "HeapSys", "RandomValue"}},
+		{name: "unknown sort", query: ListQuery{Sort: "size"}, wantErr: ErrInvalidSort},
+		{name: "broken cursor", query: ListQuery{Cursor: "!!"}, wantErr: ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(MockStorage)
+			mockStorage.On("GetMetrics", mock.Anything).Return(listFixture(), nil).Maybe()
+
+			page, err := List(mockStorage, tt.query)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(page))
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+}
+
+func TestList_Pagination(t *testing.T) {
+	mockStorage := new(MockStorage)
+	// List сортирует результат на месте, поэтому каждый вызов получает свою копию
+	mockStorage.On("GetMetrics", mock.Anything).Return(listFixture(), nil).Once()
+	mockStorage.On("GetMetrics", mock.Anything).Return(listFixture(), nil).Once()
+
+	first, err := List(mockStorage, ListQuery{Sort: "-value", Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HeapAlloc", "PollCount", "HeapSys"}, ids(first))
+	require.NotEmpty(t, first.NextCursor)
+
+	second, err := List(mockStorage, ListQuery{Sort: "-value", Limit: 3, Cursor: first.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"RandomValue"}, ids(second))
+	assert.Empty(t, second.NextCursor)
+
+	// курсор привязан к порядку сортировки
+	_, err = List(mockStorage, ListQuery{Sort: "name", Cursor: first.NextCursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestList_CounterCursor(t *testing.T) {
+	// 2^53+1 и 2^53 неразличимы во float64
+	big := int64(1<<53 + 1)
+	fixture := func() *[]metrics.Metrics {
+		return &[]metrics.Metrics{
+			{ID: "a", MType: "counter", Delta: int64Ptr(big - 1)},
+			{ID: "b", MType: "counter", Delta: int64Ptr(big)},
+			{ID: "c", MType: "counter", Delta: int64Ptr(big - 1)},
+		}
+	}
+	mockStorage := new(MockStorage)
+	mockStorage.On("GetMetrics", mock.Anything).Return(fixture(), nil).Once()
+	mockStorage.On("GetMetrics", mock.Anything).Return(fixture(), nil).Once()
+
+	first, err := List(mockStorage, ListQuery{Sort: "-value", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids(first))
+
+	second, err := List(mockStorage, ListQuery{Sort: "-value", Cursor: first.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, ids(second))
+	mockStorage.AssertExpectations(t)
+}
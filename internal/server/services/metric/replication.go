@@ -0,0 +1,68 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+)
+
+var ErrUnknownOperation = errors.New("unknown replication operation")
+
+// journal applies a change through the replication log,
+// or directly when record is false, i.e. the change came from the leader.
+func journal(record bool, op string, changed []metrics.Metrics, apply func() error) error {
+	if !record {
+		return apply()
+	}
+	return replication.Instance.Record(op, changed, apply)
+}
+
+// journalChanges is journal for changes known only once they are applied, see Restore.
+func journalChanges(record bool, apply func() ([]replication.Entry, error)) error {
+	if !record {
+		_, err := apply()
+		return err
+	}
+	return replication.Instance.RecordChanges(apply)
+}
+
+// ApplyEntry applies a change streamed from the leader like Update, Set or
+// Delete would, except that it is not appended to the replication log.
+// Parameters:
+//   - s: storage of the follower
+//   - e: entry of the leader log
+//
+// Returns:
+//   - error: ErrUnknownOperation or a storage error
+func ApplyEntry(s Restorer, e *replication.Entry) error {
+	switch e.Op {
+	case replication.OpUpdate:
+		if err := s.SaveMetrics(&e.Metrics); err != nil {
+			return err
+		}
+		updated(e.Metrics...)
+	case replication.OpSet:
+		for i := range e.Metrics {
+			if err := set(s, &e.Metrics[i], false); err != nil {
+				return err
+			}
+		}
+	case replication.OpDelete:
+		for _, m := range e.Metrics {
+			if err := remove(s, m.MType, m.ID, false); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownOperation, e.Op)
+	}
+	return nil
+}
+
+// ApplySnapshot replaces the storage of a follower with a snapshot
+// of the leader, see Restore. The changes are not appended to the replication log.
+func ApplySnapshot(s Restorer, snap *Snapshot) (*RestoreResult, error) {
+	return restore(s, snap, RestoreReplace, false, false)
+}
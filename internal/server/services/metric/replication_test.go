@@ -0,0 +1,142 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/relay"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+)
+
+func TestChangesAreRecorded(t *testing.T) {
+	original := replication.Instance
+	defer func() {
+		replication.Instance = original
+	}()
+	replication.Instance = replication.New(10)
+	s := memRestorer{}
+
+	require.NoError(t, Update(s, &metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(1)}))
+	require.NoError(t, UpdateMany(s, &[]metrics.Metrics{{ID: "Alloc", MType: "gauge", Value: float64Ptr(2)}, {ID: "Heap", MType: "gauge", Value: float64Ptr(3)}}))
+	require.NoError(t, Set(s, &metrics.Metrics{ID: "Alloc", MType: "gauge", Value: float64Ptr(4)}))
+	require.NoError(t, Delete(s, "gauge", "Heap"))
+
+	entries, _, err := replication.Instance.Since(replication.Instance.Status().Epoch, 0, 0)
+	require.NoError(t, err)
+	ops := []string{}
+	for _, e := range entries {
+		ops = append(ops, e.Op)
+	}
+	assert.Equal(t, []string{replication.OpUpdate, replication.OpUpdate, replication.OpSet, replication.OpDelete}, ops)
+	assert.Len(t, entries[1].Metrics, 2)
+	assert.Equal(t, metrics.Metrics{ID: "Heap", MType: "gauge"}, entries[3].Metrics[0])
+}
+
+func TestApplyEntry(t *testing.T) {
+	original := replication.Instance
+	defer func() {
+		replication.Instance = original
+	}()
+	replication.Instance = replication.New(10)
+	s := memRestorer{}
+
+	tests := []struct {
+		name    string
+		entry   replication.Entry
+		want    map[string]float64
+		wantErr error
+	}{
+		{name: "Update", entry: replication.Entry{Seq: 1, Op: replication.OpUpdate, Metrics: []metrics.Metrics{{ID: "Alloc", MType: "gauge", Value: float64Ptr(1)}, {ID: "Heap", MType: "gauge", Value: float64Ptr(2)}}}, want: map[string]float64{"Alloc": 1, "Heap": 2}},
+		{name: "Set", entry: replication.Entry{Seq: 2, Op: replication.OpSet, Metrics: []metrics.Metrics{{ID: "Alloc", MType: "gauge", Value: float64Ptr(5)}}}, want: map[string]float64{"Alloc": 5, "Heap": 2}},
+		{name: "Delete", entry: replication.Entry{Seq: 3, Op: replication.OpDelete, Metrics: []metrics.Metrics{{ID: "Heap", MType: "gauge"}}}, want: map[string]float64{"Alloc": 5}},
+		{name: "Unknown", entry: replication.Entry{Seq: 4, Op: "truncate"}, want: map[string]float64{"Alloc": 5}, wantErr: ErrUnknownOperation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ApplyEntry(s, &tt.entry)
+			assert.ErrorIs(t, err, tt.wantErr)
+			got := map[string]float64{}
+			for _, m := range s {
+				got[m.ID] = NumericValue(&m)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	// изменения лидера не попадают в журнал фолловера
+	assert.Zero(t, replication.Instance.Status().Seq)
+}
+
+func TestApplySnapshot(t *testing.T) {
+	original := replication.Instance
+	defer func() {
+		replication.Instance = original
+	}()
+	replication.Instance = replication.New(10)
+	source := memRestorer{}
+	require.NoError(t, source.SaveMetric(&metrics.Metrics{ID: "Alloc", MType: "gauge", Value: float64Ptr(1)}))
+	snap, err := TakeSnapshot(source, time.Now())
+	require.NoError(t, err)
+	target := memRestorer{}
+	require.NoError(t, target.SaveMetric(&metrics.Metrics{ID: "Stale", MType: "gauge", Value: float64Ptr(1)}))
+
+	result, err := ApplySnapshot(target, snap)
+	require.NoError(t, err)
+	assert.Equal(t, RestoreReplace, result.Mode)
+	assert.Len(t, result.Added, 1)
+	assert.Len(t, result.Removed, 1)
+	assert.Len(t, target, 1)
+	assert.Zero(t, replication.Instance.Status().Seq)
+}
+
+func TestRestoreIsRecordedAtOnce(t *testing.T) {
+	original := replication.Instance
+	defer func() {
+		replication.Instance = original
+	}()
+	replication.Instance = replication.New(10)
+	source := memRestorer{}
+	require.NoError(t, source.SaveMetric(&metrics.Metrics{ID: "Alloc", MType: "gauge", Value: float64Ptr(1)}))
+	require.NoError(t, source.SaveMetric(&metrics.Metrics{ID: "Heap", MType: "gauge", Value: float64Ptr(2)}))
+	snap, err := TakeSnapshot(source, time.Now())
+	require.NoError(t, err)
+	target := memRestorer{}
+	require.NoError(t, target.SaveMetric(&metrics.Metrics{ID: "Stale", MType: "gauge", Value: float64Ptr(1)}))
+
+	_, err = Restore(target, snap, RestoreReplace, false)
+	require.NoError(t, err)
+
+	// одна запись на удаление и одна на все новые значения
+	entries, _, err := replication.Instance.Since(replication.Instance.Status().Epoch, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, replication.OpDelete, entries[0].Op)
+	assert.Equal(t, []metrics.Metrics{{ID: "Stale", MType: "gauge"}}, entries[0].Metrics)
+	assert.Equal(t, replication.OpSet, entries[1].Op)
+	assert.Len(t, entries[1].Metrics, 2)
+}
+
+func TestUpdatesAreRelayed(t *testing.T) {
+	original := relay.Instance
+	defer func() {
+		relay.Instance = original
+	}()
+	q, err := relay.OpenQueue(t.TempDir(), 0)
+	require.NoError(t, err)
+	relay.Instance = relay.New("central:8080", nil, q, time.Hour)
+	s := memRestorer{}
+
+	require.NoError(t, Update(s, &metrics.Metrics{ID: "PollCount", MType: "counter", Delta: int64Ptr(1)}))
+	require.NoError(t, UpdateMany(s, &[]metrics.Metrics{{ID: "PollCount", MType: "counter", Delta: int64Ptr(2)}, {ID: "Alloc", MType: "gauge", Value: float64Ptr(3)}}))
+	// Set датчика передаётся, а заменённый счётчик не может быть передан как приращение
+	require.NoError(t, Set(s, &metrics.Metrics{ID: "Heap", MType: "gauge", Value: float64Ptr(4)}))
+	require.NoError(t, Set(s, &metrics.Metrics{ID: "Restarts", MType: "counter", Delta: int64Ptr(5)}))
+	assert.Equal(t, 3, relay.Instance.Status().Pending)
+
+	// удалённая метрика не отправляется
+	require.NoError(t, Delete(s, "gauge", "Alloc"))
+	assert.Equal(t, 2, relay.Instance.Status().Pending)
+}
@@ -0,0 +1,289 @@
+package metric
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/replication"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/stream"
+)
+
+// SnapshotVersion is the version of the snapshot format written by TakeSnapshot.
+const SnapshotVersion = 1
+
+// Restore modes.
+const (
+	RestoreReplace = "replace"
+	RestoreMerge   = "merge"
+)
+
+var ErrSnapshotVersion = errors.New("unsupported snapshot version")
+var ErrSnapshotChecksum = errors.New("snapshot checksum mismatch")
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+var ErrUnknownRestoreMode = errors.New("unknown restore mode")
+
+// checksumPrefix names the hash function used for Snapshot.Checksum.
+const checksumPrefix = "sha256:"
+
+// Snapshot is a portable copy of every stored metric. It does not depend
+// on the storage backend, so a snapshot taken from a server keeping metrics
+// in a file can be restored on one using Postgres and vice versa.
+// Fields:
+//   - Version: SnapshotVersion of the writer
+//   - CreatedAt: time the snapshot was taken
+//   - Checksum: "sha256:" and the hex digest of the JSON encoded Metrics
+//   - Metrics: metrics ordered by type and name
+type Snapshot struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Checksum  string            `json:"checksum"`
+	Metrics   []metrics.Metrics `json:"metrics"`
+}
+
+// Restorer is implemented by storages a snapshot can be restored into.
+// SwapMetrics calls stage with every stored metric and applies the metrics
+// it returns to set and to delete as one change, with no write in between.
+type Restorer interface {
+	Storage
+	Editor
+	SwapMetrics(stage func(current []metrics.Metrics) (set, removed []metrics.Metrics, err error)) error
+}
+
+// MetricChange is a metric whose value or labels differ from the snapshot.
+type MetricChange struct {
+	Old metrics.Metrics `json:"old"`
+	New metrics.Metrics `json:"new"`
+}
+
+// RestoreResult describes what Restore changed or, in dry-run mode, would change.
+// Fields:
+//   - Mode: RestoreReplace or RestoreMerge
+//   - DryRun: true if nothing was applied
+//   - Added: metrics missing from storage
+//   - Changed: metrics stored with another value or labels
+//   - Removed: metrics absent from the snapshot, only in RestoreReplace mode
+//   - Unchanged: number of metrics already equal to the snapshot
+type RestoreResult struct {
+	Mode      string            `json:"mode"`
+	DryRun    bool              `json:"dry_run"`
+	Added     []metrics.Metrics `json:"added"`
+	Changed   []MetricChange    `json:"changed"`
+	Removed   []metrics.Metrics `json:"removed"`
+	Unchanged int               `json:"unchanged"`
+}
+
+// TakeSnapshot copies all metrics from storage into a checksummed snapshot.
+// Parameters:
+//   - s: storage to copy
+//   - now: creation time recorded in the snapshot
+//
+// Returns:
+//   - *Snapshot: snapshot of the current state
+//   - error: if storage cannot be read
+func TakeSnapshot(s Storage, now time.Time) (*Snapshot, error) {
+	params := []*metrics.MetricDTOParams{}
+	all, err := s.GetMetrics(&params)
+	if err != nil {
+		return nil, err
+	}
+	list := []metrics.Metrics{}
+	if all != nil {
+		list = append(list, *all...)
+	}
+	slices.SortFunc(list, compareMetrics)
+	checksum, err := snapshotChecksum(list)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Version: SnapshotVersion, CreatedAt: now.UTC(), Checksum: checksum, Metrics: list}, nil
+}
+
+// Verify checks the version, the checksum and every metric of the snapshot.
+func (snap *Snapshot) Verify() error {
+	if snap.Version != SnapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, snap.Version)
+	}
+	checksum, err := snapshotChecksum(snap.Metrics)
+	if err != nil {
+		return err
+	}
+	if checksum != snap.Checksum {
+		return ErrSnapshotChecksum
+	}
+	seen := make(map[string]struct{}, len(snap.Metrics))
+	for i, m := range snap.Metrics {
+		switch {
+		case m.ID == "":
+			return fmt.Errorf("%w: metric %d has no id", ErrInvalidSnapshot, i)
+		case m.MType == constants.Gauge && m.Value == nil:
+			return fmt.Errorf("%w: gauge %q has no value", ErrInvalidSnapshot, m.ID)
+		case m.MType == constants.Counter && m.Delta == nil:
+			return fmt.Errorf("%w: counter %q has no delta", ErrInvalidSnapshot, m.ID)
+		case m.MType != constants.Gauge && m.MType != constants.Counter:
+			return fmt.Errorf("%w: metric %q has type %q", ErrInvalidSnapshot, m.ID, m.MType)
+		}
+		if _, ok := seen[m.MType+"/"+m.ID]; ok {
+			return fmt.Errorf("%w: %s %q is duplicated", ErrInvalidSnapshot, m.MType, m.ID)
+		}
+		seen[m.MType+"/"+m.ID] = struct{}{}
+	}
+	return nil
+}
+
+// Restore brings storage to the state of the snapshot.
+// In RestoreReplace mode metrics missing from the snapshot are deleted,
+// in RestoreMerge mode they are kept. Metrics of the snapshot always
+// replace stored ones, counters are not incremented. The difference is
+// computed and applied in one step, see Restorer.
+// Parameters:
+//   - s: storage to restore into
+//   - snap: snapshot, verified before anything is changed
+//   - mode: RestoreReplace or RestoreMerge
+//   - dryRun: only compute the difference
+//
+// Returns:
+//   - *RestoreResult: difference between storage and the snapshot
+//   - error: ErrUnknownRestoreMode, a verification error or a storage error
+func Restore(s Restorer, snap *Snapshot, mode string, dryRun bool) (*RestoreResult, error) {
+	return restore(s, snap, mode, dryRun, true)
+}
+
+// restore implements Restore, the changes are appended to the replication log if record is set.
+// The comparison and the changes run in one SwapMetrics call and are recorded
+// as one delete and one set entry, so a concurrent write either precedes the
+// whole restore or follows it.
+func restore(s Restorer, snap *Snapshot, mode string, dryRun, record bool) (*RestoreResult, error) {
+	if mode != RestoreReplace && mode != RestoreMerge {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownRestoreMode, mode)
+	}
+	if err := snap.Verify(); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		params := []*metrics.MetricDTOParams{}
+		all, err := s.GetMetrics(&params)
+		if err != nil {
+			return nil, err
+		}
+		var current []metrics.Metrics
+		if all != nil {
+			current = *all
+		}
+		return compareSnapshot(current, snap, mode, true), nil
+	}
+
+	var result *RestoreResult
+	var set []metrics.Metrics
+	err := journalChanges(record, func() ([]replication.Entry, error) {
+		err := s.SwapMetrics(func(current []metrics.Metrics) ([]metrics.Metrics, []metrics.Metrics, error) {
+			result = compareSnapshot(current, snap, mode, false)
+			set = make([]metrics.Metrics, 0, len(result.Added)+len(result.Changed))
+			for _, m := range result.Added {
+				set = append(set, restoredMetric(m))
+			}
+			for _, c := range result.Changed {
+				set = append(set, restoredMetric(c.New))
+			}
+			return set, result.Removed, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		var entries []replication.Entry
+		if len(result.Removed) > 0 {
+			removed := make([]metrics.Metrics, len(result.Removed))
+			for i, m := range result.Removed {
+				removed[i] = metrics.Metrics{ID: m.ID, MType: m.MType}
+			}
+			entries = append(entries, replication.Entry{Op: replication.OpDelete, Metrics: removed})
+		}
+		if len(set) > 0 {
+			entries = append(entries, replication.Entry{Op: replication.OpSet, Metrics: set})
+		}
+		return entries, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range result.Removed {
+		forget(m.MType, m.ID)
+	}
+	for _, m := range set {
+		history.Instance.Set(m)
+		stream.Instance.Publish(m)
+	}
+	return result, nil
+}
+
+// compareSnapshot computes the difference between the current metrics and the snapshot.
+func compareSnapshot(all []metrics.Metrics, snap *Snapshot, mode string, dryRun bool) *RestoreResult {
+	current := make(map[string]metrics.Metrics, len(all))
+	for _, m := range all {
+		current[m.MType+"/"+m.ID] = m
+	}
+
+	result := &RestoreResult{Mode: mode, DryRun: dryRun, Added: []metrics.Metrics{}, Changed: []MetricChange{}, Removed: []metrics.Metrics{}}
+	for _, m := range snap.Metrics {
+		old, ok := current[m.MType+"/"+m.ID]
+		delete(current, m.MType+"/"+m.ID)
+		switch {
+		case !ok:
+			result.Added = append(result.Added, m)
+		case !sameMetric(&old, &m):
+			result.Changed = append(result.Changed, MetricChange{Old: old, New: m})
+		default:
+			result.Unchanged++
+		}
+	}
+	if mode == RestoreReplace {
+		for _, m := range current {
+			result.Removed = append(result.Removed, m)
+		}
+		slices.SortFunc(result.Removed, compareMetrics)
+	}
+	slices.SortFunc(result.Added, compareMetrics)
+	slices.SortFunc(result.Changed, func(a, b MetricChange) int { return compareMetrics(a.New, b.New) })
+	return result
+}
+
+// restoredMetric returns m with exactly its labels: nil labels would keep the stored ones.
+func restoredMetric(m metrics.Metrics) metrics.Metrics {
+	if m.Labels == nil {
+		m.Labels = map[string]string{}
+	}
+	return m
+}
+
+func snapshotChecksum(list []metrics.Metrics) (string, error) {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return checksumPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+func sameMetric(a, b *metrics.Metrics) bool {
+	if a.MType == constants.Counter {
+		if a.Delta == nil || b.Delta == nil || *a.Delta != *b.Delta {
+			return false
+		}
+	} else if NumericValue(a) != NumericValue(b) {
+		return false
+	}
+	return maps.Equal(a.Labels, b.Labels)
+}
+
+func compareMetrics(a, b metrics.Metrics) int {
+	return cmp.Or(cmp.Compare(a.MType, b.MType), cmp.Compare(a.ID, b.ID))
+}
@@ -0,0 +1,175 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+// memRestorer keeps metrics in a map keyed by type and name.
+type memRestorer map[string]metrics.Metrics
+
+func (s memRestorer) SaveMetric(m *metrics.Metrics) error {
+	s[m.MType+"/"+m.ID] = *m
+	return nil
+}
+
+func (s memRestorer) SaveMetrics(list *[]metrics.Metrics) error {
+	for i := range *list {
+		s[(*list)[i].MType+"/"+(*list)[i].ID] = (*list)[i]
+	}
+	return nil
+}
+
+func (s memRestorer) GetMetrics(*[]*metrics.MetricDTOParams) (*[]metrics.Metrics, error) {
+	list := []metrics.Metrics{}
+	for _, m := range s {
+		list = append(list, m)
+	}
+	return &list, nil
+}
+
+func (s memRestorer) SetMetric(m *metrics.Metrics) error {
+	if len(m.Labels) == 0 {
+		m.Labels = nil
+	}
+	return s.SaveMetric(m)
+}
+
+func (s memRestorer) DeleteMetric(mType, name string) error {
+	delete(s, mType+"/"+name)
+	return nil
+}
+
+func (s memRestorer) SwapMetrics(stage func([]metrics.Metrics) ([]metrics.Metrics, []metrics.Metrics, error)) error {
+	all, _ := s.GetMetrics(nil)
+	set, removed, err := stage(*all)
+	if err != nil {
+		return err
+	}
+	for _, m := range removed {
+		delete(s, m.MType+"/"+m.ID)
+	}
+	for i := range set {
+		if err = s.SetMetric(&set[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	s := memRestorer{}
+	require.NoError(t, s.SaveMetric(&metrics.Metrics{ID: "b", MType: "gauge", Value: float64Ptr(2)}))
+	require.NoError(t, s.SaveMetric(&metrics.Metrics{ID: "a", MType: "gauge", Value: float64Ptr(1)}))
+	require.NoError(t, s.SaveMetric(&metrics.Metrics{ID: "c", MType: "counter", Delta: int64Ptr(3)}))
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("MSK", 3*60*60))
+
+	snap, err := TakeSnapshot(s, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, SnapshotVersion, snap.Version)
+	assert.Equal(t, now.UTC(), snap.CreatedAt)
+	assert.Equal(t, []string{"c", "a", "b"}, []string{snap.Metrics[0].ID, snap.Metrics[1].ID, snap.Metrics[2].ID})
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, snap.Checksum)
+	assert.NoError(t, snap.Verify())
+}
+
+func TestSnapshot_Verify(t *testing.T) {
+	valid := func(list ...metrics.Metrics) *Snapshot {
+		snap := &Snapshot{Version: SnapshotVersion, Metrics: list}
+		snap.Checksum, _ = snapshotChecksum(list)
+		return snap
+	}
+	tampered := valid(metrics.Metrics{ID: "a", MType: "gauge", Value: float64Ptr(1)})
+	tampered.Metrics[0].Value = float64Ptr(2)
+	future := valid()
+	future.Version = 2
+
+	tests := []struct {
+		name    string
+		snap    *Snapshot
+		wantErr error
+	}{
+		{name: "Empty", snap: valid()},
+		{name: "Future version", snap: future, wantErr: ErrSnapshotVersion},
+		{name: "Tampered", snap: tampered, wantErr: ErrSnapshotChecksum},
+		{name: "Gauge without value", snap: valid(metrics.Metrics{ID: "a", MType: "gauge"}), wantErr: ErrInvalidSnapshot},
+		{name: "Counter without delta", snap: valid(metrics.Metrics{ID: "a", MType: "counter"}), wantErr: ErrInvalidSnapshot},
+		{name: "Unknown type", snap: valid(metrics.Metrics{ID: "a", MType: "histogram", Value: float64Ptr(1)}), wantErr: ErrInvalidSnapshot},
+		{name: "No id", snap: valid(metrics.Metrics{MType: "gauge", Value: float64Ptr(1)}), wantErr: ErrInvalidSnapshot},
+		{name: "Duplicate", snap: valid(metrics.Metrics{ID: "a", MType: "gauge", Value: float64Ptr(1)}, metrics.Metrics{ID: "a", MType: "gauge", Value: float64Ptr(2)}), wantErr: ErrInvalidSnapshot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.snap.Verify()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	defer history.Instance.Reset()
+	source := memRestorer{}
+	require.NoError(t, source.SaveMetric(&metrics.Metrics{ID: "same", MType: "gauge", Value: float64Ptr(1)}))
+	require.NoError(t, source.SaveMetric(&metrics.Metrics{ID: "changed", MType: "counter", Delta: int64Ptr(10)}))
+	require.NoError(t, source.SaveMetric(&metrics.Metrics{ID: "new", MType: "gauge", Value: float64Ptr(3)}))
+	snap, err := TakeSnapshot(source, time.Now())
+	require.NoError(t, err)
+
+	target := func() memRestorer {
+		s := memRestorer{}
+		_ = s.SaveMetric(&metrics.Metrics{ID: "same", MType: "gauge", Value: float64Ptr(1)})
+		_ = s.SaveMetric(&metrics.Metrics{ID: "changed", MType: "counter", Delta: int64Ptr(4), Labels: map[string]string{"host": "a"}})
+		_ = s.SaveMetric(&metrics.Metrics{ID: "old", MType: "gauge", Value: float64Ptr(5)})
+		return s
+	}
+
+	t.Run("Dry run", func(t *testing.T) {
+		s := target()
+		result, err := Restore(s, snap, RestoreReplace, true)
+		require.NoError(t, err)
+		assert.True(t, result.DryRun)
+		assert.Equal(t, "new", result.Added[0].ID)
+		assert.Equal(t, int64(4), *result.Changed[0].Old.Delta)
+		assert.Equal(t, int64(10), *result.Changed[0].New.Delta)
+		assert.Equal(t, "old", result.Removed[0].ID)
+		assert.Equal(t, 1, result.Unchanged)
+		assert.Equal(t, target(), s)
+	})
+	t.Run("Replace", func(t *testing.T) {
+		s := target()
+		_, err := Restore(s, snap, RestoreReplace, false)
+		require.NoError(t, err)
+		assert.Equal(t, source, s)
+	})
+	t.Run("Merge", func(t *testing.T) {
+		s := target()
+		result, err := Restore(s, snap, RestoreMerge, false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Removed)
+		assert.Len(t, s, 4)
+		assert.Equal(t, int64(10), *s["counter/changed"].Delta)
+		assert.Nil(t, s["counter/changed"].Labels)
+		assert.Contains(t, s, "gauge/old")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		s := target()
+		_, err := Restore(s, snap, "append", false)
+		assert.ErrorIs(t, err, ErrUnknownRestoreMode)
+		broken := *snap
+		broken.Checksum = "sha256:00"
+		_, err = Restore(s, &broken, RestoreMerge, false)
+		assert.ErrorIs(t, err, ErrSnapshotChecksum)
+		assert.Equal(t, target(), s)
+	})
+}
@@ -0,0 +1,220 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValueType is the type of an expression result.
+type ValueType string
+
+// Value types. A range vector only appears as a function argument.
+const (
+	TypeScalar ValueType = "scalar"
+	TypeVector ValueType = "vector"
+	TypeRange  ValueType = "range"
+)
+
+// Pseudo labels matching the metric name and type in selectors.
+const (
+	NameLabel = "__name__"
+	TypeLabel = "__type__"
+)
+
+// Expr is a node of a parsed query.
+type Expr interface {
+	// Type is the type of the value the node evaluates to.
+	Type() ValueType
+	String() string
+}
+
+// NumberLiteral is a constant such as 100 or 1e3.
+type NumberLiteral struct {
+	Val float64
+}
+
+func (n *NumberLiteral) Type() ValueType { return TypeScalar }
+
+func (n *NumberLiteral) String() string {
+	return strconv.FormatFloat(n.Val, 'g', -1, 64)
+}
+
+// MatchOp is a label matcher operator.
+type MatchOp string
+
+// Label matcher operators.
+const (
+	MatchEqual     MatchOp = "="
+	MatchNotEqual  MatchOp = "!="
+	MatchRegexp    MatchOp = "=~"
+	MatchNotRegexp MatchOp = "!~"
+)
+
+// Matcher matches one label. A missing label has the empty value.
+type Matcher struct {
+	Label string
+	Op    MatchOp
+	Value string
+	re    *regexp.Regexp
+}
+
+// NewMatcher builds a matcher outside of a query, e.g. for silences.
+// Parameters:
+//   - label: label name, NameLabel or TypeLabel
+//   - op: matcher operator
+//   - value: value or regular expression, anchored to the whole value
+//
+// Returns:
+//   - *Matcher: the matcher
+//   - error: ErrInvalidMatcher for an unknown operator or a broken regular expression
+func NewMatcher(label string, op MatchOp, value string) (*Matcher, error) {
+	if label == "" {
+		return nil, fmt.Errorf("%w: label name is empty", ErrInvalidMatcher)
+	}
+	if !slices.Contains([]MatchOp{MatchEqual, MatchNotEqual, MatchRegexp, MatchNotRegexp}, op) {
+		return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidMatcher, op)
+	}
+	m := &Matcher{Label: label, Op: op, Value: value}
+	if err := m.compile(); err != nil {
+		return nil, fmt.Errorf("%w: invalid regular expression: %w", ErrInvalidMatcher, err)
+	}
+	return m, nil
+}
+
+// compile prepares the regular expression of =~ and !~ matchers.
+func (m *Matcher) compile() error {
+	if m.Op != MatchRegexp && m.Op != MatchNotRegexp {
+		return nil
+	}
+	// выражение разбирается отдельно, иначе "a)|(b" вырвалось бы из группы
+	parsed, err := syntax.Parse(m.Value, syntax.Perl)
+	if err != nil {
+		return err
+	}
+	// как в Prometheus, выражение должно совпасть со всем значением
+	re, err := regexp.Compile("^(?:" + parsed.String() + ")$")
+	if err != nil {
+		return err
+	}
+	m.re = re
+	return nil
+}
+
+// Matches reports whether value satisfies the matcher.
+func (m *Matcher) Matches(value string) bool {
+	switch m.Op {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	}
+	return !m.re.MatchString(value)
+}
+
+func (m *Matcher) String() string {
+	return m.Label + string(m.Op) + strconv.Quote(m.Value)
+}
+
+// VectorSelector selects the current values of the matching metrics,
+// e.g. HeapAlloc{host="a"} or {__name__=~"Heap.*"}.
+type VectorSelector struct {
+	Matchers []*Matcher
+}
+
+func (v *VectorSelector) Type() ValueType { return TypeVector }
+
+func (v *VectorSelector) String() string {
+	parts := make([]string, len(v.Matchers))
+	for i, m := range v.Matchers {
+		parts[i] = m.String()
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// RangeSelector selects the history samples of the matching metrics
+// within Range before the evaluation time, e.g. PollCount[5m].
+type RangeSelector struct {
+	Selector *VectorSelector
+	Range    time.Duration
+}
+
+func (r *RangeSelector) Type() ValueType { return TypeRange }
+
+func (r *RangeSelector) String() string {
+	return r.Selector.String() + "[" + r.Range.String() + "]"
+}
+
+// Call is a function call such as rate(PollCount[5m]).
+type Call struct {
+	Func *Function
+	Args []Expr
+}
+
+func (c *Call) Type() ValueType { return c.Func.Returns }
+
+func (c *Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = a.String()
+	}
+	return c.Func.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// AggregateExpr aggregates a vector, optionally grouped by labels,
+// e.g. sum by (host) (HeapAlloc) or topk(3, HeapAlloc).
+type AggregateExpr struct {
+	Op       string
+	Param    Expr
+	Expr     Expr
+	Grouping []string
+}
+
+func (a *AggregateExpr) Type() ValueType { return TypeVector }
+
+func (a *AggregateExpr) String() string {
+	s := a.Op
+	if len(a.Grouping) > 0 {
+		s += " by (" + strings.Join(a.Grouping, ", ") + ")"
+	}
+	if a.Param != nil {
+		return s + " (" + a.Param.String() + ", " + a.Expr.String() + ")"
+	}
+	return s + " (" + a.Expr.String() + ")"
+}
+
+// BinaryExpr applies an arithmetic operator.
+// Vectors on both sides are matched by their labels.
+type BinaryExpr struct {
+	Op  byte
+	LHS Expr
+	RHS Expr
+}
+
+func (b *BinaryExpr) Type() ValueType {
+	if b.LHS.Type() == TypeScalar && b.RHS.Type() == TypeScalar {
+		return TypeScalar
+	}
+	return TypeVector
+}
+
+func (b *BinaryExpr) String() string {
+	return "(" + b.LHS.String() + " " + string(b.Op) + " " + b.RHS.String() + ")"
+}
+
+// NegExpr is the unary minus.
+type NegExpr struct {
+	Expr Expr
+}
+
+func (n *NegExpr) Type() ValueType { return n.Expr.Type() }
+
+func (n *NegExpr) String() string {
+	return "-" + n.Expr.String()
+}
@@ -0,0 +1,30 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrLimitExceeded = errors.New("query limit exceeded")
+
+var ErrTooManySeries = fmt.Errorf("%w: too many series", ErrLimitExceeded)
+
+var ErrTooManySamples = fmt.Errorf("%w: too many samples", ErrLimitExceeded)
+
+var ErrTimeout = errors.New("query timed out")
+
+var ErrExecution = errors.New("query execution failed")
+
+var ErrInvalidMatcher = errors.New("invalid matcher")
+
+// ParseError reports where a query could not be parsed.
+// Pos is the byte offset in Query, printed starting from 1.
+type ParseError struct {
+	Query string
+	Pos   int
+	Msg   string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos+1)
+}
@@ -0,0 +1,426 @@
+// Package query implements a small query language over the stored
+// metrics: selectors with label matchers, arithmetic, aggregations and
+// functions such as rate() over history windows. See Parse for the grammar.
+package query
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+)
+
+// Limits bound the resources a single query may use.
+// Zero fields are unlimited.
+type Limits struct {
+	MaxSeries  int
+	MaxSamples int
+	Timeout    time.Duration
+}
+
+// DefaultLimits are the limits of /api/v1/query.
+var DefaultLimits = Limits{MaxSeries: 10000, MaxSamples: 500000, Timeout: 5 * time.Second}
+
+// Sample is an element of a vector. Type and ID are empty when the
+// value combines several metrics, e.g. in HeapInuse / HeapSys.
+type Sample struct {
+	Type   string            `json:"type,omitempty"`
+	ID     string            `json:"id,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// Result is the value of a query: a scalar or a vector.
+type Result struct {
+	Type   ValueType
+	Scalar float64
+	Vector []Sample
+}
+
+// MarshalJSON encodes the result as {"result_type": ..., "result": ...}.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	var value any = r.Scalar
+	if r.Type == TypeVector {
+		vector := r.Vector
+		if vector == nil {
+			vector = []Sample{}
+		}
+		value = vector
+	}
+	return json.Marshal(struct {
+		Type   ValueType `json:"result_type"`
+		Result any       `json:"result"`
+	}{r.Type, value})
+}
+
+// Exec parses and evaluates q.
+// Parameters:
+//   - ctx: cancels the evaluation
+//   - s: storage to read metrics from
+//   - q: the query
+//   - now: evaluation time, the end of range windows
+//   - limits: resource limits
+//
+// Returns:
+//   - *Result: value of the query
+//   - error: *ParseError, ErrLimitExceeded, ErrTimeout, ErrExecution or a storage error
+func Exec(ctx context.Context, s metricsService.Storage, q string, now time.Time, limits Limits) (*Result, error) {
+	expr, err := Parse(q)
+	if err != nil {
+		return nil, err
+	}
+	return Eval(ctx, s, expr, now, limits)
+}
+
+// Eval evaluates a parsed query. Metrics are read from storage once.
+// Vector values that are not finite, e.g. after a division by zero,
+// are dropped, a scalar that is not finite is an ErrExecution.
+func Eval(ctx context.Context, s metricsService.Storage, expr Expr, now time.Time, limits Limits) (*Result, error) {
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+	ev := &evaluator{ctx: ctx, storage: s, now: now, limits: limits}
+	v, err := ev.eval(expr)
+	if err != nil {
+		return nil, err
+	}
+	if expr.Type() == TypeScalar {
+		if math.IsNaN(v.scalar) || math.IsInf(v.scalar, 0) {
+			return nil, fmt.Errorf("%w: result is not a finite number", ErrExecution)
+		}
+		return &Result{Type: TypeScalar, Scalar: v.scalar}, nil
+	}
+	return &Result{Type: TypeVector, Vector: v.vector}, nil
+}
+
+// value is a scalar or a vector depending on the type of its expression.
+type value struct {
+	scalar float64
+	vector []Sample
+}
+
+type evaluator struct {
+	ctx     context.Context
+	storage metricsService.Storage
+	now     time.Time
+	limits  Limits
+	stored  []metrics.Metrics
+	loaded  bool
+	samples int
+}
+
+// check stops the evaluation once the context is done.
+func (ev *evaluator) check() error {
+	err := ev.ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return err
+}
+
+// read accounts n more samples against the limit.
+func (ev *evaluator) read(n int) error {
+	ev.samples += n
+	if ev.limits.MaxSamples > 0 && ev.samples > ev.limits.MaxSamples {
+		return fmt.Errorf("%w, the limit is %d", ErrTooManySamples, ev.limits.MaxSamples)
+	}
+	return nil
+}
+
+func (ev *evaluator) eval(expr Expr) (value, error) {
+	if err := ev.check(); err != nil {
+		return value{}, err
+	}
+	switch e := expr.(type) {
+	case *NumberLiteral:
+		return value{scalar: e.Val}, nil
+	case *VectorSelector:
+		return ev.instant(e)
+	case *NegExpr:
+		v, err := ev.eval(e.Expr)
+		if err != nil {
+			return value{}, err
+		}
+		v.scalar = -v.scalar
+		for i := range v.vector {
+			v.vector[i].Value = -v.vector[i].Value
+		}
+		return v, nil
+	case *BinaryExpr:
+		return ev.binary(e)
+	case *AggregateExpr:
+		return ev.aggregate(e)
+	case *Call:
+		vector, err := e.Func.call(ev, e.Args)
+		if err != nil {
+			return value{}, err
+		}
+		return value{vector: finite(vector)}, nil
+	}
+	return value{}, fmt.Errorf("%w: unsupported expression %s", ErrExecution, expr)
+}
+
+// selectMetrics returns the stored metrics matching sel.
+func (ev *evaluator) selectMetrics(sel *VectorSelector) ([]metrics.Metrics, error) {
+	if !ev.loaded {
+		all := []*metrics.MetricDTOParams{}
+		stored, err := ev.storage.GetMetrics(&all)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			ev.stored = *stored
+		}
+		ev.loaded = true
+	}
+	var selected []metrics.Metrics
+	for _, m := range ev.stored {
+		if !sel.matches(&m) {
+			continue
+		}
+		selected = append(selected, m)
+		if ev.limits.MaxSeries > 0 && len(selected) > ev.limits.MaxSeries {
+			return nil, fmt.Errorf("%w in %s, the limit is %d", ErrTooManySeries, sel, ev.limits.MaxSeries)
+		}
+	}
+	return selected, nil
+}
+
+func (sel *VectorSelector) matches(m *metrics.Metrics) bool {
+	for _, matcher := range sel.Matchers {
+		var v string
+		switch matcher.Label {
+		case NameLabel:
+			v = m.ID
+		case TypeLabel:
+			v = m.MType
+		default:
+			v = m.Labels[matcher.Label]
+		}
+		if !matcher.Matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ev *evaluator) instant(sel *VectorSelector) (value, error) {
+	selected, err := ev.selectMetrics(sel)
+	if err != nil {
+		return value{}, err
+	}
+	if err = ev.read(len(selected)); err != nil {
+		return value{}, err
+	}
+	vector := make([]Sample, 0, len(selected))
+	for _, m := range selected {
+		vector = append(vector, Sample{Type: m.MType, ID: m.ID, Labels: m.Labels, Value: metricsService.NumericValue(&m)})
+	}
+	sortVector(vector)
+	return value{vector: vector}, nil
+}
+
+func (ev *evaluator) binary(e *BinaryExpr) (value, error) {
+	lhs, err := ev.eval(e.LHS)
+	if err != nil {
+		return value{}, err
+	}
+	rhs, err := ev.eval(e.RHS)
+	if err != nil {
+		return value{}, err
+	}
+	lt, rt := e.LHS.Type(), e.RHS.Type()
+	switch {
+	case lt == TypeScalar && rt == TypeScalar:
+		return value{scalar: apply(e.Op, lhs.scalar, rhs.scalar)}, nil
+	case lt == TypeVector && rt == TypeScalar:
+		for i := range lhs.vector {
+			lhs.vector[i].Value = apply(e.Op, lhs.vector[i].Value, rhs.scalar)
+		}
+		return value{vector: finite(lhs.vector)}, nil
+	case lt == TypeScalar && rt == TypeVector:
+		for i := range rhs.vector {
+			rhs.vector[i].Value = apply(e.Op, lhs.scalar, rhs.vector[i].Value)
+		}
+		return value{vector: finite(rhs.vector)}, nil
+	}
+
+	// векторы сопоставляются по меткам, имя метрики не учитывается
+	right := make(map[string]Sample, len(rhs.vector))
+	for _, s := range rhs.vector {
+		key := labelsKey(s.Labels)
+		if _, ok := right[key]; ok {
+			return value{}, fmt.Errorf("%w: many-to-many matching, several series on the right side have labels {%s}", ErrExecution, key)
+		}
+		right[key] = s
+	}
+	seen := make(map[string]struct{}, len(lhs.vector))
+	var vector []Sample
+	for _, l := range lhs.vector {
+		key := labelsKey(l.Labels)
+		if _, ok := seen[key]; ok {
+			return value{}, fmt.Errorf("%w: many-to-many matching, several series on the left side have labels {%s}", ErrExecution, key)
+		}
+		seen[key] = struct{}{}
+		r, ok := right[key]
+		if !ok {
+			continue
+		}
+		vector = append(vector, Sample{Labels: l.Labels, Value: apply(e.Op, l.Value, r.Value)})
+	}
+	vector = finite(vector)
+	sortVector(vector)
+	return value{vector: vector}, nil
+}
+
+func apply(op byte, x, y float64) float64 {
+	switch op {
+	case '+':
+		return x + y
+	case '-':
+		return x - y
+	case '*':
+		return x * y
+	}
+	return x / y
+}
+
+func (ev *evaluator) aggregate(e *AggregateExpr) (value, error) {
+	var k int
+	if e.Param != nil {
+		param, err := ev.eval(e.Param)
+		if err != nil {
+			return value{}, err
+		}
+		if param.scalar < 1 || param.scalar > float64(math.MaxInt32) {
+			return value{}, fmt.Errorf("%w: %s parameter must be a positive number, got %v", ErrExecution, e.Op, param.scalar)
+		}
+		k = int(param.scalar)
+	}
+	v, err := ev.eval(e.Expr)
+	if err != nil {
+		return value{}, err
+	}
+
+	type group struct {
+		labels  map[string]string
+		samples []Sample
+	}
+	groups := map[string]*group{}
+	var order []string
+	for _, s := range v.vector {
+		labels := map[string]string{}
+		for _, l := range e.Grouping {
+			if v := groupValue(&s, l); v != "" {
+				labels[l] = v
+			}
+		}
+		key := labelsKey(labels)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: labels}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.samples = append(g.samples, s)
+	}
+
+	var vector []Sample
+	for _, key := range order {
+		g := groups[key]
+		if e.Op == "topk" || e.Op == "bottomk" {
+			ranked := slices.Clone(g.samples)
+			slices.SortStableFunc(ranked, func(a, b Sample) int {
+				if e.Op == "topk" {
+					return cmp.Compare(b.Value, a.Value)
+				}
+				return cmp.Compare(a.Value, b.Value)
+			})
+			vector = append(vector, ranked[:min(k, len(ranked))]...)
+			continue
+		}
+		out := Sample{Labels: g.labels}
+		if name, ok := g.labels[NameLabel]; ok {
+			out.ID = name
+		}
+		if mType, ok := g.labels[TypeLabel]; ok {
+			out.Type = mType
+		}
+		delete(out.Labels, NameLabel)
+		delete(out.Labels, TypeLabel)
+		if len(out.Labels) == 0 {
+			out.Labels = nil
+		}
+		values := make([]float64, len(g.samples))
+		for i, s := range g.samples {
+			values[i] = s.Value
+		}
+		out.Value = reduce(e.Op, values)
+		vector = append(vector, out)
+	}
+	if e.Op != "topk" && e.Op != "bottomk" {
+		sortVector(vector)
+	}
+	return value{vector: vector}, nil
+}
+
+// groupValue returns the value of label l of s, including the pseudo labels.
+func groupValue(s *Sample, l string) string {
+	switch l {
+	case NameLabel:
+		return s.ID
+	case TypeLabel:
+		return s.Type
+	}
+	return s.Labels[l]
+}
+
+func reduce(op string, values []float64) float64 {
+	switch op {
+	case "count":
+		return float64(len(values))
+	case "min":
+		return slices.Min(values)
+	case "max":
+		return slices.Max(values)
+	case "avg":
+		return avgOf(values)
+	}
+	return sumOf(values)
+}
+
+// finite drops samples whose value is NaN or infinite.
+func finite(vector []Sample) []Sample {
+	return slices.DeleteFunc(vector, func(s Sample) bool {
+		return math.IsNaN(s.Value) || math.IsInf(s.Value, 0)
+	})
+}
+
+func sortVector(vector []Sample) {
+	slices.SortFunc(vector, func(a, b Sample) int {
+		return cmp.Or(cmp.Compare(a.ID, b.ID), cmp.Compare(a.Type, b.Type), cmp.Compare(labelsKey(a.Labels), labelsKey(b.Labels)))
+	})
+}
+
+// labelsKey identifies a label set, labels are sorted by name.
+func labelsKey(labels map[string]string) string {
+	var b strings.Builder
+	for i, k := range slices.Sorted(maps.Keys(labels)) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k + "=" + fmt.Sprintf("%q", labels[k]))
+	}
+	return b.String()
+}
@@ -0,0 +1,162 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+// listStorage serves a fixed list of metrics.
+type listStorage []metrics.Metrics
+
+func (s listStorage) SaveMetric(*metrics.Metrics) error {
+	return nil
+}
+
+func (s listStorage) SaveMetrics(*[]metrics.Metrics) error {
+	return nil
+}
+
+func (s listStorage) GetMetrics(*[]*metrics.MetricDTOParams) (*[]metrics.Metrics, error) {
+	list := append([]metrics.Metrics(nil), s...)
+	return &list, nil
+}
+
+func gauge(id string, v float64, labels map[string]string) metrics.Metrics {
+	return metrics.Metrics{ID: id, MType: "gauge", Value: &v, Labels: labels}
+}
+
+func counter(id string, v int64) metrics.Metrics {
+	return metrics.Metrics{ID: id, MType: "counter", Delta: &v}
+}
+
+func TestExec(t *testing.T) {
+	history.Instance.Reset()
+	defer history.Instance.Reset()
+	history.Instance.Add(counter("PollCount", 2), gauge("Alloc", 4, nil))
+	history.Instance.Add(counter("PollCount", 3), gauge("Alloc", 8, nil))
+	history.Instance.Add(counter("PollCount", 5), gauge("Alloc", 6, nil))
+	s := listStorage{
+		gauge("HeapInuse", 50, map[string]string{"host": "a"}),
+		gauge("HeapInuse", 30, map[string]string{"host": "b"}),
+		gauge("HeapSys", 200, map[string]string{"host": "a"}),
+		gauge("HeapSys", 100, map[string]string{"host": "b"}),
+		gauge("Alloc", 6, nil),
+		gauge("Zero", 0, nil),
+		counter("PollCount", 12),
+	}
+	now := time.Now().Add(time.Second)
+
+	type want struct {
+		result string
+		err    error
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  want
+	}{
+		{name: "Scalar", query: "1 + 2 * 3", want: want{result: `{"result_type":"scalar","result":7}`}},
+		{name: "Selector", query: `HeapInuse{host="a"}`, want: want{result: `{"result_type":"vector","result":[{"type":"gauge","id":"HeapInuse","labels":{"host":"a"},"value":50}]}`}},
+		{name: "Name regex", query: `{__name__=~"Heap.*", host="b"}`, want: want{result: `{"result_type":"vector","result":[{"type":"gauge","id":"HeapInuse","labels":{"host":"b"},"value":30},{"type":"gauge","id":"HeapSys","labels":{"host":"b"},"value":100}]}`}},
+		{name: "Type matcher", query: `{__type__="counter"}`, want: want{result: `{"result_type":"vector","result":[{"type":"counter","id":"PollCount","value":12}]}`}},
+		{name: "No match", query: "Missing", want: want{result: `{"result_type":"vector","result":[]}`}},
+		{name: "Vector and scalar", query: "Alloc * 2 - 1", want: want{result: `{"result_type":"vector","result":[{"type":"gauge","id":"Alloc","value":11}]}`}},
+		{name: "Vector ratio", query: "HeapInuse / HeapSys * 100", want: want{result: `{"result_type":"vector","result":[{"labels":{"host":"a"},"value":25},{"labels":{"host":"b"},"value":30}]}`}},
+		{name: "Division by zero dropped", query: "Alloc / Zero", want: want{result: `{"result_type":"vector","result":[]}`}},
+		{name: "Unary minus", query: "-Alloc", want: want{result: `{"result_type":"vector","result":[{"type":"gauge","id":"Alloc","value":-6}]}`}},
+		{name: "Abs", query: "abs(Alloc - 10)", want: want{result: `{"result_type":"vector","result":[{"type":"gauge","id":"Alloc","value":4}]}`}},
+		{name: "Sum", query: "sum(HeapInuse)", want: want{result: `{"result_type":"vector","result":[{"value":80}]}`}},
+		{name: "Sum by label", query: `sum by (host) ({__name__=~"Heap.*"})`, want: want{result: `{"result_type":"vector","result":[{"labels":{"host":"a"},"value":250},{"labels":{"host":"b"},"value":130}]}`}},
+		{name: "Avg by name", query: `avg by (__name__) ({__name__=~"Heap.*"})`, want: want{result: `{"result_type":"vector","result":[{"id":"HeapInuse","value":40},{"id":"HeapSys","value":150}]}`}},
+		{name: "Count", query: `count({__type__="gauge"})`, want: want{result: `{"result_type":"vector","result":[{"value":6}]}`}},
+		{name: "Min and max", query: "max(HeapSys) - min(HeapSys)", want: want{result: `{"result_type":"vector","result":[{"value":100}]}`}},
+		{name: "Topk", query: `topk(2, {__type__="gauge"})`, want: want{result: `{"result_type":"vector","result":[{"type":"gauge","id":"HeapSys","labels":{"host":"a"},"value":200},{"type":"gauge","id":"HeapSys","labels":{"host":"b"},"value":100}]}`}},
+		{name: "Bottomk by host", query: "bottomk by (host) (1, HeapInuse)", want: want{result: `{"result_type":"vector","result":[{"type":"gauge","id":"HeapInuse","labels":{"host":"a"},"value":50},{"type":"gauge","id":"HeapInuse","labels":{"host":"b"},"value":30}]}`}},
+		{name: "Increase", query: "increase(PollCount[5m])", want: want{result: `{"result_type":"vector","result":[{"type":"counter","id":"PollCount","value":8}]}`}},
+		{name: "Increase skips gauges", query: `increase({__name__=~".*"}[5m])`, want: want{result: `{"result_type":"vector","result":[{"type":"counter","id":"PollCount","value":8}]}`}},
+		{name: "Max over time", query: "max_over_time(Alloc[5m])", want: want{result: `{"result_type":"vector","result":[{"type":"gauge","id":"Alloc","value":8}]}`}},
+		{name: "Avg over time", query: "avg_over_time(Alloc[5m])", want: want{result: `{"result_type":"vector","result":[{"type":"gauge","id":"Alloc","value":6}]}`}},
+		{name: "Count over time", query: "count_over_time(PollCount[5m])", want: want{result: `{"result_type":"vector","result":[{"type":"counter","id":"PollCount","value":3}]}`}},
+		{name: "Counter shifted to total", query: "min_over_time(PollCount[5m])", want: want{result: `{"result_type":"vector","result":[{"type":"counter","id":"PollCount","value":4}]}`}},
+		{name: "Window before samples", query: "sum_over_time(Alloc[1ms])", want: want{result: `{"result_type":"vector","result":[]}`}},
+		{name: "Scalar division by zero", query: "1 / 0", want: want{err: ErrExecution}},
+		{name: "Many to many", query: `{__name__=~"Heap.*"} + Alloc`, want: want{err: ErrExecution}},
+		{name: "Topk zero", query: "topk(0, Alloc)", want: want{err: ErrExecution}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Exec(context.Background(), s, tt.query, now, DefaultLimits)
+			if tt.want.err != nil {
+				assert.ErrorIs(t, err, tt.want.err)
+				return
+			}
+			require.NoError(t, err)
+			body, err := json.Marshal(result)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want.result, string(body))
+		})
+	}
+}
+
+func TestExec_Rate(t *testing.T) {
+	history.Instance.Reset()
+	defer history.Instance.Reset()
+	history.Instance.Add(counter("PollCount", 2))
+	time.Sleep(10 * time.Millisecond)
+	history.Instance.Add(counter("PollCount", 3))
+
+	result, err := Exec(context.Background(), listStorage{counter("PollCount", 5)}, "rate(PollCount[1m])", time.Now(), DefaultLimits)
+	require.NoError(t, err)
+	require.Len(t, result.Vector, 1)
+	assert.Greater(t, result.Vector[0].Value, 0.0)
+	assert.Less(t, result.Vector[0].Value, 300.0)
+}
+
+func TestExec_Limits(t *testing.T) {
+	history.Instance.Reset()
+	defer history.Instance.Reset()
+	history.Instance.Add(gauge("Alloc", 1, nil))
+	history.Instance.Add(gauge("Alloc", 2, nil))
+	s := listStorage{gauge("Alloc", 2, nil), gauge("HeapSys", 1, nil), gauge("HeapInuse", 1, nil)}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		query  string
+		limits Limits
+		err    error
+	}{
+		{name: "Series", ctx: context.Background(), query: `{__type__="gauge"}`, limits: Limits{MaxSeries: 2}, err: ErrTooManySeries},
+		{name: "Samples", ctx: context.Background(), query: "Alloc + HeapSys + HeapInuse", limits: Limits{MaxSamples: 2}, err: ErrTooManySamples},
+		{name: "History samples", ctx: context.Background(), query: "max_over_time(Alloc[1h])", limits: Limits{MaxSamples: 1}, err: ErrLimitExceeded},
+		{name: "Canceled", ctx: canceled, query: "Alloc", limits: DefaultLimits, err: context.Canceled},
+		{name: "Within limits", ctx: context.Background(), query: `{__type__="gauge"}`, limits: Limits{MaxSeries: 3, MaxSamples: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Exec(tt.ctx, s, tt.query, time.Now(), tt.limits)
+			if tt.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestEval_Timeout(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := Exec(ctx, listStorage{}, "1", time.Now(), DefaultLimits)
+	assert.ErrorIs(t, err, ErrTimeout)
+}
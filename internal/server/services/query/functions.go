@@ -0,0 +1,121 @@
+package query
+
+import (
+	"math"
+	"slices"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+)
+
+// Function is a query function such as rate.
+// Fields:
+//   - Name: name used in queries
+//   - Args: types of the arguments
+//   - Returns: type of the result
+type Function struct {
+	Name    string
+	Args    []ValueType
+	Returns ValueType
+	call    func(ev *evaluator, args []Expr) ([]Sample, error)
+}
+
+// functions are the functions available in queries.
+// rate and increase only apply to counters, other metrics are skipped.
+var functions = map[string]*Function{
+	"rate":            {Name: "rate", Args: []ValueType{TypeRange}, Returns: TypeVector, call: counterFunc(true)},
+	"increase":        {Name: "increase", Args: []ValueType{TypeRange}, Returns: TypeVector, call: counterFunc(false)},
+	"avg_over_time":   {Name: "avg_over_time", Args: []ValueType{TypeRange}, Returns: TypeVector, call: overTime(avgOf)},
+	"min_over_time":   {Name: "min_over_time", Args: []ValueType{TypeRange}, Returns: TypeVector, call: overTime(slices.Min[[]float64])},
+	"max_over_time":   {Name: "max_over_time", Args: []ValueType{TypeRange}, Returns: TypeVector, call: overTime(slices.Max[[]float64])},
+	"sum_over_time":   {Name: "sum_over_time", Args: []ValueType{TypeRange}, Returns: TypeVector, call: overTime(sumOf)},
+	"count_over_time": {Name: "count_over_time", Args: []ValueType{TypeRange}, Returns: TypeVector, call: overTime(countOf)},
+	"abs":             {Name: "abs", Args: []ValueType{TypeVector}, Returns: TypeVector, call: absFunc},
+}
+
+// rangeVisit calls visit for every metric selected by the range selector
+// with its history samples in the window, accounting them against the limits.
+func (ev *evaluator) rangeVisit(arg Expr, visit func(m *metrics.Metrics, r *RangeSelector, samples []history.Sample) []float64) ([]Sample, error) {
+	r := arg.(*RangeSelector)
+	selected, err := ev.selectMetrics(r.Selector)
+	if err != nil {
+		return nil, err
+	}
+	var vector []Sample
+	for _, m := range selected {
+		if err = ev.check(); err != nil {
+			return nil, err
+		}
+		samples := history.Instance.Series(m.MType, m.ID)
+		if err = ev.read(len(samples)); err != nil {
+			return nil, err
+		}
+		for _, v := range visit(&m, r, samples) {
+			vector = append(vector, Sample{Type: m.MType, ID: m.ID, Labels: m.Labels, Value: v})
+		}
+	}
+	sortVector(vector)
+	return vector, nil
+}
+
+// counterFunc returns rate when perSecond is set and increase otherwise.
+func counterFunc(perSecond bool) func(ev *evaluator, args []Expr) ([]Sample, error) {
+	return func(ev *evaluator, args []Expr) ([]Sample, error) {
+		return ev.rangeVisit(args[0], func(m *metrics.Metrics, r *RangeSelector, samples []history.Sample) []float64 {
+			if m.MType != constants.Counter {
+				return nil
+			}
+			increase, rate, ok := metricsService.CounterIncrease(samples, r.Range, ev.now)
+			if !ok {
+				return nil
+			}
+			if perSecond {
+				return []float64{rate}
+			}
+			return []float64{increase}
+		})
+	}
+}
+
+// overTime applies fn to the history values in the window, counter
+// values shifted to the stored totals. Metrics without samples are skipped.
+func overTime(fn func([]float64) float64) func(ev *evaluator, args []Expr) ([]Sample, error) {
+	return func(ev *evaluator, args []Expr) ([]Sample, error) {
+		return ev.rangeVisit(args[0], func(m *metrics.Metrics, r *RangeSelector, _ []history.Sample) []float64 {
+			values := metricsService.WindowValues(m, ev.now.Add(-r.Range), ev.now)
+			if len(values) == 0 {
+				return nil
+			}
+			return []float64{fn(values)}
+		})
+	}
+}
+
+func absFunc(ev *evaluator, args []Expr) ([]Sample, error) {
+	v, err := ev.eval(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for i := range v.vector {
+		v.vector[i].Value = math.Abs(v.vector[i].Value)
+	}
+	return v.vector, nil
+}
+
+func sumOf(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+func avgOf(values []float64) float64 {
+	return sumOf(values) / float64(len(values))
+}
+
+func countOf(values []float64) float64 {
+	return float64(len(values))
+}
@@ -0,0 +1,204 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tokenKind classifies lexer tokens.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokError
+	tokIdent
+	tokNumber
+	tokDuration
+	tokString
+	tokLParen
+	tokRParen
+	tokLBrace
+	tokRBrace
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokAdd
+	tokSub
+	tokMul
+	tokDiv
+	tokEq
+	tokNeq
+	tokRegex
+	tokNotRegex
+)
+
+var tokenNames = map[tokenKind]string{
+	tokEOF:      "end of query",
+	tokLParen:   `"("`,
+	tokRParen:   `")"`,
+	tokLBrace:   `"{"`,
+	tokRBrace:   `"}"`,
+	tokLBracket: `"["`,
+	tokRBracket: `"]"`,
+	tokComma:    `","`,
+}
+
+// token is a lexeme with its byte offset in the query.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if name, ok := tokenNames[t.kind]; ok {
+		return name
+	}
+	return strconv.Quote(t.text)
+}
+
+// lexer splits a query into tokens. Errors are returned as tokError
+// tokens with the message as text, so the parser reports them with the position.
+type lexer struct {
+	src string
+	pos int
+}
+
+// lex returns all tokens of src ending with tokEOF or the first tokError.
+func lex(src string) []token {
+	l := &lexer{src: src}
+	var tokens []token
+	for {
+		tok := l.next()
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF || tok.kind == tokError {
+			return tokens
+		}
+	}
+}
+
+func (l *lexer) next() token {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}
+	}
+	emit := func(kind tokenKind, width int) token {
+		l.pos += width
+		return token{kind: kind, text: l.src[start:l.pos], pos: start}
+	}
+	c := l.src[l.pos]
+	switch {
+	case c == '(':
+		return emit(tokLParen, 1)
+	case c == ')':
+		return emit(tokRParen, 1)
+	case c == '{':
+		return emit(tokLBrace, 1)
+	case c == '}':
+		return emit(tokRBrace, 1)
+	case c == '[':
+		return emit(tokLBracket, 1)
+	case c == ']':
+		return emit(tokRBracket, 1)
+	case c == ',':
+		return emit(tokComma, 1)
+	case c == '+':
+		return emit(tokAdd, 1)
+	case c == '-':
+		return emit(tokSub, 1)
+	case c == '*':
+		return emit(tokMul, 1)
+	case c == '/':
+		return emit(tokDiv, 1)
+	case c == '=' && l.peek(1) == '~':
+		return emit(tokRegex, 2)
+	case c == '=':
+		return emit(tokEq, 1)
+	case c == '!' && l.peek(1) == '=':
+		return emit(tokNeq, 2)
+	case c == '!' && l.peek(1) == '~':
+		return emit(tokNotRegex, 2)
+	case c == '"':
+		return l.string()
+	case isDigit(c) || c == '.' && isDigit(l.peek(1)):
+		return l.number()
+	case isIdentStart(c):
+		for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}
+	}
+	return token{kind: tokError, text: fmt.Sprintf("unexpected character %q", l.src[start:start+1]), pos: start}
+}
+
+func (l *lexer) peek(offset int) byte {
+	if l.pos+offset < len(l.src) {
+		return l.src[l.pos+offset]
+	}
+	return 0
+}
+
+// string scans a double quoted string with Go escapes.
+func (l *lexer) string() token {
+	start := l.pos
+	for i := start + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case '\\':
+			i++
+		case '"':
+			l.pos = i + 1
+			value, err := strconv.Unquote(l.src[start:l.pos])
+			if err != nil {
+				return token{kind: tokError, text: "invalid escape in string", pos: start}
+			}
+			return token{kind: tokString, text: value, pos: start}
+		}
+	}
+	l.pos = len(l.src)
+	return token{kind: tokError, text: "unterminated string", pos: start}
+}
+
+// number scans a number or, when it is followed by a unit, a duration like 5m or 1h30m.
+func (l *lexer) number() token {
+	start := l.pos
+	for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+		l.pos++
+	}
+	if c := l.peek(0); c == 'e' || c == 'E' {
+		exp := 1
+		if sign := l.peek(1); sign == '+' || sign == '-' {
+			exp++
+		}
+		if isDigit(l.peek(exp)) {
+			l.pos += exp
+			for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+				l.pos++
+			}
+			return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}
+		}
+	}
+	if isIdentStart(l.peek(0)) {
+		for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || isIdentStart(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokDuration, text: l.src[start:l.pos], pos: start}
+	}
+	return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isIdentChar allows the dots and colons found in metric IDs.
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.' || c == ':'
+}
@@ -0,0 +1,466 @@
+package query
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Parser limits.
+const (
+	MaxQueryLength = 4096
+	MaxDepth       = 32
+)
+
+// aggregators are the operators accepted by AggregateExpr.
+var aggregators = []string{"sum", "min", "max", "avg", "count", "topk", "bottomk"}
+
+// Parse parses a query.
+//
+// Grammar:
+//
+//	expr      = term { ("+" | "-") term }
+//	term      = unary { ("*" | "/") unary }
+//	unary     = "-" unary | postfix
+//	postfix   = primary [ "[" duration "]" ]
+//	primary   = number | "(" expr ")" | aggregate | call | selector
+//	aggregate = aggregator [ by ] "(" [ expr "," ] expr ")" [ by ]
+//	by        = "by" "(" [ label { "," label } ] ")"
+//	call      = function "(" [ expr { "," expr } ] ")"
+//	selector  = name [ "{" matchers "}" ] | "{" matchers "}"
+//	matchers  = matcher { "," matcher } [ "," ]
+//	matcher   = label ( "=" | "!=" | "=~" | "!~" ) string
+//
+// Durations are written as 30s, 5m, 1h30m, 1d or 1w.
+// Parameters:
+//   - q: the query, at most MaxQueryLength bytes
+//
+// Returns:
+//   - Expr: the root of the checked syntax tree
+//   - error: *ParseError pointing at the offending token
+func Parse(q string) (Expr, error) {
+	if len(q) > MaxQueryLength {
+		return nil, &ParseError{Query: q, Pos: MaxQueryLength, Msg: fmt.Sprintf("query is longer than %d bytes", MaxQueryLength)}
+	}
+	p := &parser{query: q, tokens: lex(q)}
+	expr, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.unexpected(tok, "operator or end of query")
+	}
+	if expr.Type() == TypeRange {
+		return nil, p.errorAt(0, "a range selector must be passed to a function such as rate()")
+	}
+	return expr, nil
+}
+
+type parser struct {
+	query  string
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF && tok.kind != tokError {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorAt(pos int, format string, args ...any) error {
+	return &ParseError{Query: p.query, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// unexpected reports tok where something else was expected.
+// Lexer errors are reported as is.
+func (p *parser) unexpected(tok token, expected string) error {
+	if tok.kind == tokError {
+		return p.errorAt(tok.pos, "%s", tok.text)
+	}
+	return p.errorAt(tok.pos, "unexpected %s, expected %s", tok, expected)
+}
+
+func (p *parser) expect(kind tokenKind, expected string) (token, error) {
+	tok := p.advance()
+	if tok.kind != kind {
+		return tok, p.unexpected(tok, expected)
+	}
+	return tok, nil
+}
+
+// enter guards against stack exhaustion by deeply nested queries.
+func (p *parser) enter(pos int) error {
+	p.depth++
+	if p.depth > MaxDepth {
+		return p.errorAt(pos, "query is nested deeper than %d levels", MaxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) expr() (Expr, error) {
+	lhs, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.kind == tokAdd || tok.kind == tokSub; tok = p.peek() {
+		p.advance()
+		rhs, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		if lhs, err = p.binary(tok, lhs, rhs); err != nil {
+			return nil, err
+		}
+	}
+	return lhs, nil
+}
+
+func (p *parser) term() (Expr, error) {
+	lhs, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.kind == tokMul || tok.kind == tokDiv; tok = p.peek() {
+		p.advance()
+		rhs, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if lhs, err = p.binary(tok, lhs, rhs); err != nil {
+			return nil, err
+		}
+	}
+	return lhs, nil
+}
+
+func (p *parser) binary(op token, lhs, rhs Expr) (Expr, error) {
+	if lhs.Type() == TypeRange || rhs.Type() == TypeRange {
+		return nil, p.errorAt(op.pos, "operator %s needs scalar or vector operands, not a range selector", op)
+	}
+	return &BinaryExpr{Op: op.text[0], LHS: lhs, RHS: rhs}, nil
+}
+
+func (p *parser) unary() (Expr, error) {
+	tok := p.peek()
+	if tok.kind != tokSub {
+		return p.postfix()
+	}
+	p.advance()
+	if err := p.enter(tok.pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	expr, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if expr.Type() == TypeRange {
+		return nil, p.errorAt(tok.pos, "unary minus needs a scalar or vector, not a range selector")
+	}
+	if n, ok := expr.(*NumberLiteral); ok {
+		return &NumberLiteral{Val: -n.Val}, nil
+	}
+	return &NegExpr{Expr: expr}, nil
+}
+
+func (p *parser) postfix() (Expr, error) {
+	start := p.peek()
+	expr, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokLBracket {
+		return expr, nil
+	}
+	bracket := p.advance()
+	sel, ok := expr.(*VectorSelector)
+	if !ok || start.kind == tokLParen {
+		return nil, p.errorAt(bracket.pos, "a range can only follow a selector")
+	}
+	tok := p.advance()
+	if tok.kind != tokDuration {
+		return nil, p.unexpected(tok, "duration such as 5m")
+	}
+	d, err := parseDuration(tok.text)
+	if err != nil {
+		return nil, p.errorAt(tok.pos, "%s", err)
+	}
+	if _, err = p.expect(tokRBracket, `"]"`); err != nil {
+		return nil, err
+	}
+	return &RangeSelector{Selector: sel, Range: d}, nil
+}
+
+func (p *parser) primary() (Expr, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokNumber:
+		p.advance()
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorAt(tok.pos, "invalid number %s", tok)
+		}
+		return &NumberLiteral{Val: v}, nil
+	case tokLParen:
+		p.advance()
+		if err := p.enter(tok.pos); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		expr, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(tokRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case tokLBrace:
+		return p.selector("")
+	case tokIdent:
+		next := p.tokens[p.pos+1]
+		if slices.Contains(aggregators, tok.text) && (next.kind == tokLParen || next.kind == tokIdent && next.text == "by") {
+			return p.aggregate()
+		}
+		if next.kind == tokLParen {
+			return p.call()
+		}
+		p.advance()
+		return p.selector(tok.text)
+	}
+	return nil, p.unexpected(tok, "number, selector, function or \"(\"")
+}
+
+// selector parses the optional matchers after a metric name.
+func (p *parser) selector(name string) (Expr, error) {
+	sel := &VectorSelector{}
+	if name != "" {
+		sel.Matchers = append(sel.Matchers, &Matcher{Label: NameLabel, Op: MatchEqual, Value: name})
+	}
+	if p.peek().kind != tokLBrace {
+		return sel, nil
+	}
+	brace := p.advance()
+	for p.peek().kind != tokRBrace {
+		m, err := p.matcher()
+		if err != nil {
+			return nil, err
+		}
+		sel.Matchers = append(sel.Matchers, m)
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.advance()
+	}
+	if _, err := p.expect(tokRBrace, `"," or "}"`); err != nil {
+		return nil, err
+	}
+	if len(sel.Matchers) == 0 {
+		return nil, p.errorAt(brace.pos, "a selector needs at least one matcher")
+	}
+	return sel, nil
+}
+
+func (p *parser) matcher() (*Matcher, error) {
+	label, err := p.expect(tokIdent, "label name")
+	if err != nil {
+		return nil, err
+	}
+	op := p.advance()
+	m := &Matcher{Label: label.text}
+	switch op.kind {
+	case tokEq:
+		m.Op = MatchEqual
+	case tokNeq:
+		m.Op = MatchNotEqual
+	case tokRegex:
+		m.Op = MatchRegexp
+	case tokNotRegex:
+		m.Op = MatchNotRegexp
+	default:
+		return nil, p.unexpected(op, `"=", "!=", "=~" or "!~"`)
+	}
+	value, err := p.expect(tokString, "quoted label value")
+	if err != nil {
+		return nil, err
+	}
+	m.Value = value.text
+	if err = m.compile(); err != nil {
+		return nil, p.errorAt(value.pos, "invalid regular expression: %s", err)
+	}
+	return m, nil
+}
+
+func (p *parser) aggregate() (Expr, error) {
+	op := p.advance()
+	if err := p.enter(op.pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	agg := &AggregateExpr{Op: op.text}
+	var err error
+	if p.peek().kind == tokIdent {
+		if agg.Grouping, err = p.grouping(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = p.expect(tokLParen, `"("`); err != nil {
+		return nil, err
+	}
+	args, err := p.args()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind == tokIdent && p.peek().text == "by" {
+		if agg.Grouping != nil {
+			return nil, p.errorAt(p.peek().pos, "by is given twice")
+		}
+		if agg.Grouping, err = p.grouping(); err != nil {
+			return nil, err
+		}
+	}
+
+	wantArgs := 1
+	if op.text == "topk" || op.text == "bottomk" {
+		wantArgs = 2
+	}
+	if len(args) != wantArgs {
+		return nil, p.errorAt(op.pos, "%s expects %d argument(s), got %d", op.text, wantArgs, len(args))
+	}
+	if wantArgs == 2 {
+		agg.Param = args[0]
+		if agg.Param.Type() != TypeScalar {
+			return nil, p.errorAt(op.pos, "%s expects a scalar as its first argument", op.text)
+		}
+	}
+	agg.Expr = args[len(args)-1]
+	if agg.Expr.Type() != TypeVector {
+		return nil, p.errorAt(op.pos, "%s expects a vector, got %s", op.text, agg.Expr.Type())
+	}
+	return agg, nil
+}
+
+func (p *parser) grouping() ([]string, error) {
+	by := p.advance()
+	if by.text != "by" {
+		return nil, p.unexpected(by, `"by" or "("`)
+	}
+	if _, err := p.expect(tokLParen, `"("`); err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for p.peek().kind != tokRParen {
+		label, err := p.expect(tokIdent, "label name")
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, label.text)
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.advance()
+	}
+	if _, err := p.expect(tokRParen, `"," or ")"`); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+func (p *parser) call() (Expr, error) {
+	name := p.advance()
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, p.errorAt(name.pos, "unknown function %s", name)
+	}
+	if err := p.enter(name.pos); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	p.advance()
+	args, err := p.args()
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != len(fn.Args) {
+		return nil, p.errorAt(name.pos, "%s expects %d argument(s), got %d", fn.Name, len(fn.Args), len(args))
+	}
+	for i, arg := range args {
+		if arg.Type() != fn.Args[i] {
+			return nil, p.errorAt(name.pos, "%s expects a %s as argument %d, got %s", fn.Name, fn.Args[i], i+1, arg.Type())
+		}
+	}
+	return &Call{Func: fn, Args: args}, nil
+}
+
+// args parses a comma separated argument list after "(" up to and including ")".
+func (p *parser) args() ([]Expr, error) {
+	var args []Expr
+	for p.peek().kind != tokRParen {
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.advance()
+	}
+	if _, err := p.expect(tokRParen, `"," or ")"`); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// durationUnits are the units accepted in ranges.
+var durationUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// parseDuration parses durations such as 90s, 5m or 1h30m.
+func parseDuration(s string) (time.Duration, error) {
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && isDigit(rest[i]) {
+			i++
+		}
+		j := i
+		for j < len(rest) && !isDigit(rest[j]) {
+			j++
+		}
+		unit, ok := durationUnits[rest[i:j]]
+		if i == 0 || !ok {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil || n > int64(1<<62)/int64(unit) {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		total += time.Duration(n) * unit
+		rest = rest[j:]
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("duration %q must be positive", s)
+	}
+	return total, nil
+}
@@ -0,0 +1,136 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		kinds []tokenKind
+		texts []string
+	}{
+		{name: "Selector", src: `HeapAlloc{host="a"}`, kinds: []tokenKind{tokIdent, tokLBrace, tokIdent, tokEq, tokString, tokRBrace, tokEOF}, texts: []string{"HeapAlloc", "{", "host", "=", "a", "}", ""}},
+		{name: "Matchers", src: `a!="x" b=~"y" c!~"z"`, kinds: []tokenKind{tokIdent, tokNeq, tokString, tokIdent, tokRegex, tokString, tokIdent, tokNotRegex, tokString, tokEOF}},
+		{name: "Numbers and durations", src: "1.5 2e3 .5 5m 1h30m", kinds: []tokenKind{tokNumber, tokNumber, tokNumber, tokDuration, tokDuration, tokEOF}, texts: []string{"1.5", "2e3", ".5", "5m", "1h30m", ""}},
+		{name: "Operators", src: "(a+b)*c/-d", kinds: []tokenKind{tokLParen, tokIdent, tokAdd, tokIdent, tokRParen, tokMul, tokIdent, tokDiv, tokSub, tokIdent, tokEOF}},
+		{name: "Dotted name", src: "cpu.load:avg", kinds: []tokenKind{tokIdent, tokEOF}, texts: []string{"cpu.load:avg", ""}},
+		{name: "Escapes", src: `"a\"b\n"`, kinds: []tokenKind{tokString, tokEOF}, texts: []string{"a\"b\n", ""}},
+		{name: "Unterminated string", src: `"abc`, kinds: []tokenKind{tokError}, texts: []string{"unterminated string"}},
+		{name: "Unexpected character", src: "a # b", kinds: []tokenKind{tokIdent, tokError}, texts: []string{"a", `unexpected character "#"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := lex(tt.src)
+			kinds := make([]tokenKind, len(tokens))
+			texts := make([]string, len(tokens))
+			for i, tok := range tokens {
+				kinds[i] = tok.kind
+				texts[i] = tok.text
+			}
+			assert.Equal(t, tt.kinds, kinds)
+			if tt.texts != nil {
+				assert.Equal(t, tt.texts, texts)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	type want struct {
+		expr     string
+		valueTyp ValueType
+		err      string
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  want
+	}{
+		{name: "Number", query: "42", want: want{expr: "42", valueTyp: TypeScalar}},
+		{name: "Name", query: "HeapAlloc", want: want{expr: `{__name__="HeapAlloc"}`, valueTyp: TypeVector}},
+		{name: "Name and labels", query: `HeapAlloc{host="a", env!~"dev|test",}`, want: want{expr: `{__name__="HeapAlloc",host="a",env!~"dev|test"}`, valueTyp: TypeVector}},
+		{name: "Name regex", query: `{__name__=~"Heap.*"}`, want: want{expr: `{__name__=~"Heap.*"}`, valueTyp: TypeVector}},
+		{name: "Precedence", query: "1 + 2 * 3 - 4 / 2", want: want{expr: "((1 + (2 * 3)) - (4 / 2))", valueTyp: TypeScalar}},
+		{name: "Parentheses", query: "(1 + 2) * 3", want: want{expr: "((1 + 2) * 3)", valueTyp: TypeScalar}},
+		{name: "Unary minus", query: "-HeapAlloc * 2", want: want{expr: `(-{__name__="HeapAlloc"} * 2)`, valueTyp: TypeVector}},
+		{name: "Vector ratio", query: "HeapInuse / HeapSys * 100", want: want{expr: `(({__name__="HeapInuse"} / {__name__="HeapSys"}) * 100)`, valueTyp: TypeVector}},
+		{name: "Rate", query: "rate(PollCount[5m])", want: want{expr: `rate({__name__="PollCount"}[5m0s])`, valueTyp: TypeVector}},
+		{name: "Day duration", query: "max_over_time(Alloc[1d])", want: want{expr: `max_over_time({__name__="Alloc"}[24h0m0s])`, valueTyp: TypeVector}},
+		{name: "Sum by after", query: "sum(HeapAlloc) by (host)", want: want{expr: `sum by (host) ({__name__="HeapAlloc"})`, valueTyp: TypeVector}},
+		{name: "Sum by before", query: "sum by (host, env) (rate(PollCount[1m]))", want: want{expr: `sum by (host, env) (rate({__name__="PollCount"}[1m0s]))`, valueTyp: TypeVector}},
+		{name: "Topk", query: "topk(3, {__type__=\"gauge\"})", want: want{expr: `topk (3, {__type__="gauge"})`, valueTyp: TypeVector}},
+		{name: "Aggregator as metric name", query: "count + 1", want: want{expr: `({__name__="count"} + 1)`, valueTyp: TypeVector}},
+		{name: "Empty", query: " ", want: want{err: `unexpected end of query, expected number, selector, function or "(" at position 2`}},
+		{name: "Trailing token", query: "HeapAlloc HeapSys", want: want{err: `unexpected "HeapSys", expected operator or end of query at position 11`}},
+		{name: "Unclosed paren", query: "(1 + 2", want: want{err: `unexpected end of query, expected ")" at position 7`}},
+		{name: "Unknown function", query: "median(HeapAlloc)", want: want{err: `unknown function "median" at position 1`}},
+		{name: "Wrong argument type", query: "rate(PollCount)", want: want{err: "rate expects a range as argument 1, got vector at position 1"}},
+		{name: "Wrong argument count", query: "abs(a, b)", want: want{err: "abs expects 1 argument(s), got 2 at position 1"}},
+		{name: "Range at top level", query: "PollCount[5m]", want: want{err: "a range selector must be passed to a function such as rate() at position 1"}},
+		{name: "Range in arithmetic", query: "PollCount[5m] * 2", want: want{err: `operator "*" needs scalar or vector operands, not a range selector at position 15`}},
+		{name: "Range of expression", query: "(PollCount)[5m]", want: want{err: "a range can only follow a selector at position 12"}},
+		{name: "Bad duration", query: "rate(PollCount[5x])", want: want{err: `invalid duration "5x" at position 16`}},
+		{name: "Zero duration", query: "rate(PollCount[0s])", want: want{err: `duration "0s" must be positive at position 16`}},
+		{name: "Bad regex", query: `{__name__=~"("}`, want: want{err: "invalid regular expression: error parsing regexp: missing closing ): `(` at position 12"}},
+		{name: "Empty selector", query: "{}", want: want{err: "a selector needs at least one matcher at position 1"}},
+		{name: "Topk without param", query: "topk(HeapAlloc)", want: want{err: "topk expects 2 argument(s), got 1 at position 1"}},
+		{name: "Sum of scalar", query: "sum(1)", want: want{err: "sum expects a vector, got scalar at position 1"}},
+		{name: "Lexer error", query: `HeapAlloc{host="a}`, want: want{err: "unterminated string at position 16"}},
+		{name: "Too long", query: strings.Repeat("a", MaxQueryLength+1), want: want{err: "query is longer than 4096 bytes"}},
+		{name: "Too deep", query: strings.Repeat("(", MaxDepth+1) + "1" + strings.Repeat(")", MaxDepth+1), want: want{err: "query is nested deeper than 32 levels"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.query)
+			if tt.want.err != "" {
+				require.Error(t, err)
+				var parseErr *ParseError
+				require.ErrorAs(t, err, &parseErr)
+				assert.Contains(t, err.Error(), tt.want.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.expr, expr.String())
+			assert.Equal(t, tt.want.valueTyp, expr.Type())
+		})
+	}
+}
+
+func TestNewMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		label   string
+		op      MatchOp
+		value   string
+		match   string
+		want    bool
+		wantErr bool
+	}{
+		{name: "Equal", label: "host", op: MatchEqual, value: "a", match: "a", want: true},
+		{name: "Not equal", label: "host", op: MatchNotEqual, value: "a", match: "a"},
+		{name: "Regex anchored", label: NameLabel, op: MatchRegexp, value: "Heap", match: "HeapAlloc"},
+		{name: "Not regex", label: NameLabel, op: MatchNotRegexp, value: "Heap.*", match: "Alloc", want: true},
+		{name: "Unknown operator", label: "host", op: "~", value: "a", wantErr: true},
+		{name: "Broken regex", label: "host", op: MatchRegexp, value: "(", wantErr: true},
+		{name: "Regex leaving the group", label: "host", op: MatchRegexp, value: "a)|(b", wantErr: true},
+		{name: "Regex alternatives anchored", label: "host", op: MatchRegexp, value: "a|b", match: "ab"},
+		{name: "Empty label", op: MatchEqual, value: "a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMatcher(tt.label, tt.op, tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidMatcher)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, m.Matches(tt.match))
+		})
+	}
+}
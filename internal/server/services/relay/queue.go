@@ -0,0 +1,201 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+)
+
+// DefaultQueueLimit is the number of batches kept while the upstream is unavailable.
+const DefaultQueueLimit = 10000
+
+const batchExt = ".json"
+
+// RejectedDir is the subdirectory of the queue keeping the batches
+// the upstream refused, see Queue.Reject.
+const RejectedDir = "rejected"
+
+// ErrQueueEmpty is returned by Peek when no batch is queued.
+var ErrQueueEmpty = errors.New("relay queue is empty")
+
+// Queue keeps batches of metrics in a directory, one file per batch named
+// by its sequence number, so that they survive restarts and upstream
+// outages. Batches are read back in the order they were pushed.
+type Queue struct {
+	mu    sync.Mutex
+	dir   string
+	limit int
+	seqs  []uint64
+	next  uint64
+}
+
+// OpenQueue opens the queue in dir, creating the directory if needed.
+// Batches left by a previous run are kept, unfinished writes are removed.
+// Parameters:
+//   - dir: directory of the queue
+//   - limit: batches kept, the oldest are dropped beyond it, DefaultQueueLimit when not positive
+//
+// Returns:
+//   - *Queue: the queue
+//   - error: the error reading the directory
+func OpenQueue(dir string, limit int) (*Queue, error) {
+	if limit <= 0 {
+		limit = DefaultQueueLimit
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue{dir: dir, limit: limit, next: 1}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			// запись прервалась до переименования, батч не был подтверждён
+			if err = os.Remove(filepath.Join(dir, name)); err != nil {
+				logger.LogError(err)
+			}
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, batchExt), 10, 64)
+		if err != nil || !strings.HasSuffix(name, batchExt) {
+			continue
+		}
+		q.seqs = append(q.seqs, seq)
+		q.next = max(q.next, seq+1)
+	}
+	slices.Sort(q.seqs)
+	return q, nil
+}
+
+// Len returns the number of queued batches.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.seqs)
+}
+
+// Push appends a batch. The file is written under a temporary name and
+// renamed, so a crash never leaves a partial batch. When the queue is full
+// the oldest batch is dropped.
+// Returns:
+//   - error: the error writing the file
+func (q *Queue) Push(batch []metrics.Metrics) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	seq := q.next
+	path := q.path(seq)
+	if err = os.WriteFile(path+".tmp", body, 0o644); err != nil {
+		return err
+	}
+	if err = os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	q.next++
+	q.seqs = append(q.seqs, seq)
+	for len(q.seqs) > q.limit {
+		logger.LogError("relay queue is full, dropping batch ", q.seqs[0])
+		if err = q.remove(q.seqs[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Peek returns the oldest batch without removing it.
+// Returns:
+//   - seq: sequence number of the batch for Remove
+//   - batch: metrics of the batch
+//   - err: ErrQueueEmpty or the error reading the file
+func (q *Queue) Peek() (seq uint64, batch []metrics.Metrics, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.seqs) > 0 {
+		seq = q.seqs[0]
+		var body []byte
+		if body, err = os.ReadFile(q.path(seq)); err != nil {
+			return 0, nil, err
+		}
+		batch = nil
+		if err = json.Unmarshal(body, &batch); err != nil {
+			// повреждённый файл не должен навсегда остановить очередь
+			logger.LogError(fmt.Sprintf("relay queue: dropping corrupt batch %d: ", seq), err)
+			if err = q.remove(seq); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+		return seq, batch, nil
+	}
+	return 0, nil, ErrQueueEmpty
+}
+
+// Remove deletes a batch returned by Peek once it was delivered.
+func (q *Queue) Remove(seq uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !slices.Contains(q.seqs, seq) {
+		return nil
+	}
+	return q.remove(seq)
+}
+
+// Reject moves a batch returned by Peek to RejectedDir, so that a batch the
+// upstream will never accept does not stop the queue, and it can still be
+// inspected or pushed again by hand. At most the queue limit of rejected
+// batches is kept, the oldest are deleted.
+// Returns:
+//   - error: the error moving the file
+func (q *Queue) Reject(seq uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !slices.Contains(q.seqs, seq) {
+		return nil
+	}
+	dir := filepath.Join(q.dir, RejectedDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(q.path(seq), filepath.Join(dir, filepath.Base(q.path(seq)))); err != nil {
+		return err
+	}
+	q.seqs = slices.DeleteFunc(q.seqs, func(s uint64) bool { return s == seq })
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	// имена файлов дополнены нулями, поэтому старые батчи идут первыми
+	for _, entry := range entries[:max(0, len(entries)-q.limit)] {
+		if err = os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *Queue) remove(seq uint64) error {
+	if err := os.Remove(q.path(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	q.seqs = slices.DeleteFunc(q.seqs, func(s uint64) bool { return s == seq })
+	return nil
+}
+
+func (q *Queue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, batchExt))
+}
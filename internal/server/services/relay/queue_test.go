@@ -0,0 +1,101 @@
+package relay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func gauge(id string, value float64) metrics.Metrics {
+	return metrics.Metrics{ID: id, MType: constants.Gauge, Value: utils.FloatToPointerFloat(value)}
+}
+
+func counter(id string, delta int64) metrics.Metrics {
+	return metrics.Metrics{ID: id, MType: constants.Counter, Delta: utils.FloatToPointerInt(delta)}
+}
+
+func TestQueue(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+	q, err := OpenQueue(dir, 0)
+	require.NoError(t, err)
+	_, _, err = q.Peek()
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+
+	require.NoError(t, q.Push([]metrics.Metrics{gauge("Alloc", 1)}))
+	require.NoError(t, q.Push([]metrics.Metrics{counter("PollCount", 2)}))
+	assert.Equal(t, 2, q.Len())
+
+	seq, batch, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.Metrics{gauge("Alloc", 1)}, batch)
+	require.NoError(t, q.Remove(seq))
+	require.NoError(t, q.Remove(seq))
+
+	// очередь переживает перезапуск, незавершённая запись отбрасывается
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000009.json.tmp"), []byte("[{"), 0o644))
+	q, err = OpenQueue(dir, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, q.Len())
+	require.NoError(t, q.Push([]metrics.Metrics{gauge("Heap", 3)}))
+	_, batch, err = q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.Metrics{counter("PollCount", 2)}, batch)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestQueue_Limit(t *testing.T) {
+	q, err := OpenQueue(t.TempDir(), 2)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, q.Push([]metrics.Metrics{counter("PollCount", int64(i))}))
+	}
+	assert.Equal(t, 2, q.Len())
+	_, batch, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.Metrics{counter("PollCount", 1)}, batch)
+}
+
+func TestQueue_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001.json"), []byte("[{"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000002.json"), []byte(`[{"id":"Alloc","type":"gauge","value":1}]`), 0o644))
+	q, err := OpenQueue(dir, 0)
+	require.NoError(t, err)
+
+	seq, batch, err := q.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), seq)
+	assert.Equal(t, []metrics.Metrics{gauge("Alloc", 1)}, batch)
+	assert.Equal(t, 1, q.Len())
+}
+
+func TestQueue_Reject(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenQueue(dir, 2)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, q.Push([]metrics.Metrics{counter("PollCount", int64(i))}))
+		seq, _, err := q.Peek()
+		require.NoError(t, err)
+		require.NoError(t, q.Reject(seq))
+	}
+	assert.Zero(t, q.Len())
+
+	// хранятся только последние отвергнутые батчи, и очередь их не читает после перезапуска
+	entries, err := os.ReadDir(filepath.Join(dir, RejectedDir))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "00000000000000000002.json", entries[0].Name())
+	q, err = OpenQueue(dir, 2)
+	require.NoError(t, err)
+	assert.Zero(t, q.Len())
+}
@@ -0,0 +1,285 @@
+// Package relay forwards the updates received by a relay server to a
+// central server. Updates are aggregated in memory, queued on disk as
+// batches every interval and sent upstream with the agent client, so a
+// remote site keeps collecting while the upstream is unavailable.
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/agent/client"
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+// DefaultInterval is the period of queuing and sending batches.
+const DefaultInterval = 10 * time.Second
+
+// DefaultBatchSize is the largest number of metrics queued as one batch.
+const DefaultBatchSize = 1000
+
+// ErrNotRelay is returned by the relay endpoints of a server that is not a relay.
+var ErrNotRelay = errors.New("server is not a relay")
+
+// retryErrors are the errors of client.HTTPClient retried before
+// a batch is left in the queue until the next interval.
+var retryErrors = []error{client.ErrServerInternalError, client.ErrRequestTimeout, client.ErrTooManyRequests}
+
+// Sender sends a batch upstream, implemented by client.HTTPClient.
+type Sender interface {
+	SendMetricsWithBatch(ms []*metrics.Metrics) error
+}
+
+// Status describes the state of a relay.
+// Fields:
+//   - Upstream: address of the central server
+//   - Pending: metrics aggregated since the last interval
+//   - Queued: batches waiting in the queue
+//   - Sent: batches delivered since start
+//   - Rejected: batches refused by the upstream since start, see Queue.Reject
+//   - LastSent: time the last batch was delivered
+//   - LastError: last error sending a batch, empty after a delivery
+type Status struct {
+	Upstream  string     `json:"upstream"`
+	Pending   int        `json:"pending"`
+	Queued    int        `json:"queued"`
+	Sent      uint64     `json:"sent"`
+	Rejected  uint64     `json:"rejected"`
+	LastSent  *time.Time `json:"last_sent,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Relay aggregates updates and forwards them upstream.
+type Relay struct {
+	upstream string
+	sender   Sender
+	queue    *Queue
+	interval time.Duration
+
+	mu       sync.Mutex
+	gauges   map[string]metrics.Metrics
+	counters map[string]metrics.Metrics
+	status   Status
+}
+
+// Instance is the relay of the server, nil when the server is not a relay.
+var Instance *Relay
+
+// New creates a relay. It does not send anything until Run is called.
+// Parameters:
+//   - upstream: address of the central server, reported by Status
+//   - sender: client of the central server
+//   - queue: queue of the batches waiting to be sent
+//   - interval: period of queuing and sending, DefaultInterval when not positive
+//
+// Returns:
+//   - *Relay: the relay
+func New(upstream string, sender Sender, queue *Queue, interval time.Duration) *Relay {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Relay{
+		upstream: upstream,
+		sender:   sender,
+		queue:    queue,
+		interval: interval,
+		gauges:   map[string]metrics.Metrics{},
+		counters: map[string]metrics.Metrics{},
+	}
+}
+
+// Add aggregates updates until the next interval: the last value of
+// a gauge and the sum of the counter deltas are sent. Does nothing
+// on a nil relay, so the metric service calls it unconditionally.
+func (r *Relay) Add(ms ...metrics.Metrics) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range ms {
+		switch m.MType {
+		case constants.Gauge:
+			if m.Value == nil {
+				continue
+			}
+			value := *m.Value
+			m.Value = &value
+			r.gauges[m.ID] = m
+		case constants.Counter:
+			if m.Delta == nil {
+				continue
+			}
+			delta := *m.Delta
+			if old, ok := r.counters[m.ID]; ok {
+				delta += *old.Delta
+			}
+			m.Delta = &delta
+			r.counters[m.ID] = m
+		}
+	}
+}
+
+// Forget drops the update of a metric aggregated since the last interval,
+// e.g. when the metric is deleted. Queued batches are sent as they are.
+// Does nothing on a nil relay.
+func (r *Relay) Forget(mType, id string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if mType == constants.Gauge {
+		delete(r.gauges, id)
+	} else {
+		delete(r.counters, id)
+	}
+}
+
+// Flush moves the aggregated updates to the queue in batches of at most
+// DefaultBatchSize metrics. The updates are taken under the lock and written
+// without it, so Add is not blocked by the disk. Updates that could not be
+// queued are aggregated again with the ones added meanwhile.
+// Returns:
+//   - error: the error of the queue
+func (r *Relay) Flush() error {
+	r.mu.Lock()
+	pending := make([]metrics.Metrics, 0, len(r.gauges)+len(r.counters))
+	for _, m := range r.gauges {
+		pending = append(pending, m)
+	}
+	for _, m := range r.counters {
+		pending = append(pending, m)
+	}
+	r.gauges = map[string]metrics.Metrics{}
+	r.counters = map[string]metrics.Metrics{}
+	r.mu.Unlock()
+
+	for len(pending) > 0 {
+		batch := pending[:min(DefaultBatchSize, len(pending))]
+		if err := r.queue.Push(batch); err != nil {
+			r.requeue(pending)
+			return err
+		}
+		pending = pending[len(batch):]
+	}
+	return nil
+}
+
+// requeue returns updates that could not be queued. A gauge set
+// meanwhile is newer and kept, counter deltas are summed.
+func (r *Relay) requeue(ms []metrics.Metrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range ms {
+		if m.MType == constants.Gauge {
+			if _, ok := r.gauges[m.ID]; !ok {
+				r.gauges[m.ID] = m
+			}
+			continue
+		}
+		if newer, ok := r.counters[m.ID]; ok {
+			delta := *m.Delta + *newer.Delta
+			m.Delta = &delta
+		}
+		r.counters[m.ID] = m
+	}
+}
+
+// Send delivers the queued batches oldest first. Errors listed in
+// retryErrors are retried with utils.RetryWrapper, a batch that still
+// fails stays in the queue for the next call. A batch answered with
+// client.ErrUnexpectedStatus, e.g. 400 or 413, would be refused again,
+// so it is moved aside with Queue.Reject and the next batch is sent.
+// Returns:
+//   - error: the error of the first batch not delivered
+func (r *Relay) Send() error {
+	var rejected error
+	for {
+		seq, batch, err := r.queue.Peek()
+		if errors.Is(err, ErrQueueEmpty) {
+			return rejected
+		}
+		if err != nil {
+			return err
+		}
+		ptrs := make([]*metrics.Metrics, len(batch))
+		for i := range batch {
+			ptrs[i] = &batch[i]
+		}
+		err = utils.RetryWrapper(func() error {
+			return r.sender.SendMetricsWithBatch(ptrs)
+		}, retryErrors)
+		if errors.Is(err, client.ErrUnexpectedStatus) {
+			logger.LogError(fmt.Sprintf("relay: rejecting batch %d: ", seq), err)
+			if rerr := r.queue.Reject(seq); rerr != nil {
+				return rerr
+			}
+			r.update(func(s *Status) {
+				s.Rejected++
+				s.LastError = err.Error()
+			})
+			if rejected == nil {
+				rejected = err
+			}
+			continue
+		}
+		if err != nil {
+			r.update(func(s *Status) { s.LastError = err.Error() })
+			return err
+		}
+		if err = r.queue.Remove(seq); err != nil {
+			return err
+		}
+		now := time.Now()
+		r.update(func(s *Status) {
+			s.Sent++
+			s.LastSent = &now
+			s.LastError = ""
+		})
+	}
+}
+
+// Run queues and sends batches every interval until ctx is done.
+// The updates aggregated after the last interval are not queued on
+// return, call Flush once no more updates are accepted.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Flush(); err != nil {
+				logger.LogError("relay: ", err)
+			}
+			if err := r.Send(); err != nil {
+				logger.LogError("relay: ", err)
+			}
+		}
+	}
+}
+
+// Status returns the state of the relay.
+func (r *Relay) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := r.status
+	status.Upstream = r.upstream
+	status.Pending = len(r.gauges) + len(r.counters)
+	status.Queued = r.queue.Len()
+	return status
+}
+
+func (r *Relay) update(change func(s *Status)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change(&r.status)
+}
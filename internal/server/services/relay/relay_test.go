@@ -0,0 +1,223 @@
+package relay
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/agent/client"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/signature"
+)
+
+// upstream records the batches it accepts, it fails while err is set.
+type upstream struct {
+	mu      sync.Mutex
+	err     error
+	batches [][]metrics.Metrics
+}
+
+func (u *upstream) SendMetricsWithBatch(ms []*metrics.Metrics) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.err != nil {
+		return u.err
+	}
+	batch := make([]metrics.Metrics, len(ms))
+	for i, m := range ms {
+		batch[i] = *m
+	}
+	u.batches = append(u.batches, batch)
+	return nil
+}
+
+func (u *upstream) fail(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.err = err
+}
+
+func (u *upstream) received() [][]metrics.Metrics {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.batches
+}
+
+func newRelay(t *testing.T, u *upstream) *Relay {
+	q, err := OpenQueue(t.TempDir(), 0)
+	require.NoError(t, err)
+	return New("central:8080", u, q, time.Hour)
+}
+
+func TestAdd(t *testing.T) {
+	var nilRelay *Relay
+	nilRelay.Add(gauge("Alloc", 1))
+
+	u := &upstream{}
+	r := newRelay(t, u)
+	r.Add(gauge("Alloc", 1), counter("PollCount", 2))
+	r.Add(gauge("Alloc", 3), counter("PollCount", 4), metrics.Metrics{ID: "Broken", MType: "gauge"})
+	assert.Equal(t, 2, r.Status().Pending)
+
+	require.NoError(t, r.Flush())
+	require.NoError(t, r.Send())
+	require.Len(t, u.received(), 1)
+	assert.ElementsMatch(t, []metrics.Metrics{gauge("Alloc", 3), counter("PollCount", 6)}, u.received()[0])
+
+	status := r.Status()
+	assert.Equal(t, "central:8080", status.Upstream)
+	assert.Zero(t, status.Pending)
+	assert.Zero(t, status.Queued)
+	assert.Equal(t, uint64(1), status.Sent)
+	assert.NotNil(t, status.LastSent)
+}
+
+func TestFlush_Batches(t *testing.T) {
+	u := &upstream{}
+	r := newRelay(t, u)
+	for i := 0; i < DefaultBatchSize+1; i++ {
+		r.Add(counter(fmt.Sprintf("c%d", i), 1))
+	}
+	require.NoError(t, r.Flush())
+	assert.Equal(t, 2, r.Status().Queued)
+
+	require.NoError(t, r.Send())
+	require.Len(t, u.received(), 2)
+	assert.Len(t, u.received()[0], DefaultBatchSize)
+	assert.Len(t, u.received()[1], 1)
+}
+
+func TestSend_Outage(t *testing.T) {
+	u := &upstream{}
+	r := newRelay(t, u)
+	// ошибки соединения не повторяются сразу, батч ждёт следующего интервала
+	outage := errors.New("connection refused")
+	u.fail(outage)
+
+	r.Add(counter("PollCount", 1))
+	require.NoError(t, r.Flush())
+	r.Add(counter("PollCount", 2))
+	require.NoError(t, r.Flush())
+	assert.ErrorIs(t, r.Send(), outage)
+	status := r.Status()
+	assert.Equal(t, 2, status.Queued)
+	assert.Equal(t, outage.Error(), status.LastError)
+
+	// очередь на диске переживает перезапуск ретранслятора
+	restarted := New("central:8080", u, r.queue, time.Hour)
+	u.fail(nil)
+	require.NoError(t, restarted.Send())
+	assert.Equal(t, [][]metrics.Metrics{{counter("PollCount", 1)}, {counter("PollCount", 2)}}, u.received())
+	assert.Empty(t, restarted.Status().LastError)
+	assert.Zero(t, restarted.Status().Queued)
+}
+
+func TestSend_UnexpectedStatus(t *testing.T) {
+	u := &upstream{}
+	r := newRelay(t, u)
+	rejected := fmt.Errorf("%w: 400 Bad Request", client.ErrUnexpectedStatus)
+	u.fail(rejected)
+	r.Add(gauge("Alloc", 1))
+	require.NoError(t, r.Flush())
+
+	// отвергнутый батч не задерживает следующие
+	assert.ErrorIs(t, r.Send(), client.ErrUnexpectedStatus)
+	status := r.Status()
+	assert.Zero(t, status.Queued)
+	assert.Equal(t, uint64(1), status.Rejected)
+	assert.Equal(t, rejected.Error(), status.LastError)
+	entries, err := os.ReadDir(filepath.Join(r.queue.dir, RejectedDir))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	u.fail(nil)
+	r.Add(gauge("Alloc", 2))
+	require.NoError(t, r.Flush())
+	require.NoError(t, r.Send())
+	assert.Equal(t, [][]metrics.Metrics{{gauge("Alloc", 2)}}, u.received())
+}
+
+func TestFlush_QueueError(t *testing.T) {
+	u := &upstream{}
+	r := newRelay(t, u)
+	r.Add(gauge("Alloc", 1), counter("PollCount", 2))
+	require.NoError(t, os.RemoveAll(r.queue.dir))
+
+	assert.Error(t, r.Flush())
+	// обновления, пришедшие после неудачной записи, объединяются с возвращёнными
+	r.Add(gauge("Alloc", 3), counter("PollCount", 4))
+	assert.Equal(t, 2, r.Status().Pending)
+
+	require.NoError(t, os.MkdirAll(r.queue.dir, 0o755))
+	require.NoError(t, r.Flush())
+	require.NoError(t, r.Send())
+	require.Len(t, u.received(), 1)
+	assert.ElementsMatch(t, []metrics.Metrics{gauge("Alloc", 3), counter("PollCount", 6)}, u.received()[0])
+}
+
+func TestRun(t *testing.T) {
+	u := &upstream{}
+	q, err := OpenQueue(t.TempDir(), 0)
+	require.NoError(t, err)
+	r := New("central:8080", u, q, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+
+	r.Add(gauge("Alloc", 1))
+	assert.Eventually(t, func() bool {
+		return len(u.received()) == 1
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
+
+func TestSend_HTTPClient(t *testing.T) {
+	original := signature.Instance
+	defer func() {
+		signature.Instance = original
+	}()
+	signature.New("secret", "")
+	var received []metrics.Metrics
+	central := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/updates/", r.URL.Path)
+		assert.Equal(t, "Bearer relay-token", r.Header.Get("Authorization"))
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		hash, err := base64.StdEncoding.DecodeString(r.Header.Get("HashSHA256"))
+		require.NoError(t, err)
+		assert.NoError(t, signature.Instance.Check(hash, body))
+		require.NoError(t, json.Unmarshal(body, &received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer central.Close()
+
+	c := client.NewClient(central.Listener.Addr().String())
+	c.SetToken("relay-token")
+	q, err := OpenQueue(t.TempDir(), 0)
+	require.NoError(t, err)
+	r := New(central.Listener.Addr().String(), c, q, time.Hour)
+	r.Add(gauge("Alloc", 1))
+	require.NoError(t, r.Flush())
+	require.NoError(t, r.Send())
+	assert.Equal(t, []metrics.Metrics{gauge("Alloc", 1)}, received)
+}
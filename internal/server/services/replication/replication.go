@@ -0,0 +1,244 @@
+// Package replication keeps the log of metric changes applied on a leader,
+// so that followers can stream them after restoring a snapshot.
+package replication
+
+import (
+	"errors"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+)
+
+// DefaultLogSize is the number of entries kept for followers catching up.
+const DefaultLogSize = 10000
+
+// Operations recorded in the log, one per metric service call.
+const (
+	OpUpdate = "update" // metricsService.Update, counters are incremented
+	OpSet    = "set"    // metricsService.Set, values are replaced
+	OpDelete = "delete" // metricsService.Delete, only ID and MType are set
+)
+
+// Server roles reported by Status.
+const (
+	RoleLeader   = "leader"
+	RoleFollower = "follower"
+)
+
+// ErrGap is returned by Since when the requested entries are no longer
+// kept or belong to another epoch, e.g. the leader restarted.
+// The follower has to restore a snapshot first.
+var ErrGap = errors.New("replication log does not contain the requested entries")
+
+// ErrLogDisabled is returned by a log of size 0, used on followers.
+var ErrLogDisabled = errors.New("replication log is disabled")
+
+// ErrLogClosed is returned by Since after Close.
+var ErrLogClosed = errors.New("replication log is closed")
+
+// Entry is a change applied on the leader.
+// Fields:
+//   - Seq: sequence number, increasing by one without gaps within an epoch
+//   - Op: OpUpdate, OpSet or OpDelete
+//   - Metrics: changed metrics
+type Entry struct {
+	Seq     uint64            `json:"seq"`
+	Op      string            `json:"op"`
+	Metrics []metrics.Metrics `json:"metrics"`
+}
+
+// Status describes the replication state of a server.
+// Fields:
+//   - Role: RoleLeader or RoleFollower
+//   - Leader: address of the leader, followers only
+//   - Epoch: epoch of the log the sequence number belongs to
+//   - Seq: last recorded entry on a leader, last applied one on a follower
+//   - Connected: whether a follower is streaming from its leader
+//   - Snapshots: number of snapshots a follower restored
+//   - LastApplied: time a follower applied the last entry or snapshot
+//   - LastError: last error of a follower
+type Status struct {
+	Role        string     `json:"role"`
+	Leader      string     `json:"leader,omitempty"`
+	Epoch       string     `json:"epoch"`
+	Seq         uint64     `json:"seq"`
+	Connected   bool       `json:"connected"`
+	Snapshots   int        `json:"snapshots"`
+	LastApplied *time.Time `json:"last_applied,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Log numbers the changes applied to storage and keeps the latest of them.
+// A new epoch starts with every log, so sequence numbers of a restarted
+// leader are never taken for the old ones.
+type Log struct {
+	mu      sync.Mutex
+	epoch   string
+	size    int
+	seq     uint64
+	entries []Entry
+	changed chan struct{}
+	closed  bool
+}
+
+// Instance is the log fed by the metric service.
+var Instance = New(DefaultLogSize)
+
+// New creates a log.
+// Parameters:
+//   - size: entries kept, 0 disables the log: changes are applied but not recorded
+//
+// Returns:
+//   - *Log: empty log of a new epoch
+func New(size int) *Log {
+	return &Log{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:    max(size, 0),
+		changed: make(chan struct{}),
+	}
+}
+
+// Enabled reports whether changes are recorded.
+func (l *Log) Enabled() bool {
+	return l.size > 0
+}
+
+// Record applies a change and appends it to the log if apply succeeds.
+// The log is locked meanwhile, so a snapshot taken with Consistent
+// contains exactly the changes up to its sequence number.
+// Parameters:
+//   - op: OpUpdate, OpSet or OpDelete
+//   - changed: metrics the change applies to, copied into the entry
+//   - apply: writes the change to storage
+//
+// Returns:
+//   - error: the error of apply
+func (l *Log) Record(op string, changed []metrics.Metrics, apply func() error) error {
+	return l.RecordChanges(func() ([]Entry, error) {
+		if err := apply(); err != nil {
+			return nil, err
+		}
+		return []Entry{{Op: op, Metrics: changed}}, nil
+	})
+}
+
+// RecordChanges is Record for changes known only once they are applied,
+// e.g. a restore that compares the storage with a snapshot under the storage lock.
+// The entries are appended in order with consecutive sequence numbers,
+// their Seq is assigned by the log.
+// Parameters:
+//   - apply: writes the changes to storage and returns them as entries
+//
+// Returns:
+//   - error: the error of apply
+func (l *Log) RecordChanges(apply func() ([]Entry, error)) error {
+	if !l.Enabled() {
+		_, err := apply()
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changes, err := apply()
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		l.seq++
+		entry := Entry{Seq: l.seq, Op: c.Op, Metrics: make([]metrics.Metrics, len(c.Metrics))}
+		for i := range c.Metrics {
+			entry.Metrics[i] = clone(&c.Metrics[i])
+		}
+		l.entries = append(l.entries, entry)
+	}
+	// старые записи отбрасываются пачкой, чтобы не копировать журнал на каждой записи
+	if len(l.entries) >= 2*l.size {
+		l.entries = append([]Entry(nil), l.entries[len(l.entries)-l.size:]...)
+	}
+	if !l.closed {
+		close(l.changed)
+		l.changed = make(chan struct{})
+	}
+	return nil
+}
+
+// Consistent calls read while no change can be recorded,
+// e.g. to take a snapshot matching the returned sequence number.
+// Returns:
+//   - epoch: epoch of the log
+//   - seq: sequence number of the last change visible to read
+//   - err: ErrLogDisabled or the error of read
+func (l *Log) Consistent(read func() error) (epoch string, seq uint64, err error) {
+	if !l.Enabled() {
+		return "", 0, ErrLogDisabled
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err = read(); err != nil {
+		return "", 0, err
+	}
+	return l.epoch, l.seq, nil
+}
+
+// Since returns up to limit entries following seq and a channel
+// closed when the next change is recorded.
+// Returns:
+//   - []Entry: entries after seq, empty when the caller is up to date
+//   - <-chan struct{}: closed on the next change or Close
+//   - error: ErrGap, ErrLogDisabled or ErrLogClosed
+func (l *Log) Since(epoch string, seq uint64, limit int) ([]Entry, <-chan struct{}, error) {
+	if !l.Enabled() {
+		return nil, nil, ErrLogDisabled
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, nil, ErrLogClosed
+	}
+	first := l.seq + 1
+	if len(l.entries) > 0 {
+		first = l.entries[0].Seq
+	}
+	if epoch != l.epoch || seq > l.seq || seq+1 < first {
+		return nil, nil, ErrGap
+	}
+	entries := l.entries[seq+1-first:]
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, l.changed, nil
+}
+
+// Status returns the leader status of the log.
+func (l *Log) Status() Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Status{Role: RoleLeader, Epoch: l.epoch, Seq: l.seq}
+}
+
+// Close ends the streams waiting for changes, so that they finish on shutdown.
+func (l *Log) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		l.closed = true
+		close(l.changed)
+	}
+}
+
+// clone copies m so that the log does not share pointers with the caller.
+func clone(m *metrics.Metrics) metrics.Metrics {
+	c := *m
+	if m.Value != nil {
+		value := *m.Value
+		c.Value = &value
+	}
+	if m.Delta != nil {
+		delta := *m.Delta
+		c.Delta = &delta
+	}
+	c.Labels = maps.Clone(m.Labels)
+	return c
+}
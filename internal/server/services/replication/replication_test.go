@@ -0,0 +1,158 @@
+package replication
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func gauge(id string, value float64) metrics.Metrics {
+	return metrics.Metrics{ID: id, MType: constants.Gauge, Value: utils.FloatToPointerFloat(value)}
+}
+
+func record(t *testing.T, l *Log, ms ...metrics.Metrics) {
+	t.Helper()
+	require.NoError(t, l.Record(OpUpdate, ms, func() error { return nil }))
+}
+
+func TestLog_Record(t *testing.T) {
+	l := New(10)
+	m := gauge("Alloc", 1)
+	m.Labels = map[string]string{"host": "a"}
+	record(t, l, m)
+	*m.Value = 2
+	m.Labels["host"] = "b"
+
+	failed := errors.New("storage is down")
+	err := l.Record(OpSet, []metrics.Metrics{m}, func() error { return failed })
+	assert.ErrorIs(t, err, failed)
+
+	entries, _, err := l.Since(l.Status().Epoch, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, uint64(1), entries[0].Seq)
+	assert.Equal(t, OpUpdate, entries[0].Op)
+	// запись журнала не меняется вместе с метрикой вызывающего
+	assert.Equal(t, 1.0, *entries[0].Metrics[0].Value)
+	assert.Equal(t, "a", entries[0].Metrics[0].Labels["host"])
+}
+
+func TestLog_RecordChanges(t *testing.T) {
+	l := New(10)
+	record(t, l, gauge("Alloc", 1))
+
+	err := l.RecordChanges(func() ([]Entry, error) {
+		return []Entry{
+			{Op: OpDelete, Metrics: []metrics.Metrics{{ID: "Alloc", MType: constants.Gauge}}},
+			{Op: OpSet, Metrics: []metrics.Metrics{gauge("HeapAlloc", 2)}},
+		}, nil
+	})
+	require.NoError(t, err)
+
+	failed := errors.New("storage is down")
+	err = l.RecordChanges(func() ([]Entry, error) { return nil, failed })
+	assert.ErrorIs(t, err, failed)
+
+	entries, _, err := l.Since(l.Status().Epoch, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, []uint64{2, 3}, []uint64{entries[0].Seq, entries[1].Seq})
+	assert.Equal(t, []string{OpDelete, OpSet}, []string{entries[0].Op, entries[1].Op})
+}
+
+func TestLog_Since(t *testing.T) {
+	l := New(3)
+	for i := range 7 {
+		record(t, l, gauge("Alloc", float64(i)))
+	}
+	epoch := l.Status().Epoch
+	// хранится от size до 2*size последних записей
+	tests := []struct {
+		name     string
+		epoch    string
+		since    uint64
+		limit    int
+		wantSeqs []uint64
+		wantErr  error
+	}{
+		{name: "Kept", epoch: epoch, since: 4, wantSeqs: []uint64{5, 6, 7}},
+		{name: "Limited", epoch: epoch, since: 4, limit: 2, wantSeqs: []uint64{5, 6}},
+		{name: "Up to date", epoch: epoch, since: 7, wantSeqs: []uint64{}},
+		{name: "Dropped", epoch: epoch, since: 2, wantErr: ErrGap},
+		{name: "Ahead", epoch: epoch, since: 8, wantErr: ErrGap},
+		{name: "Other epoch", epoch: "restarted", since: 5, wantErr: ErrGap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, changed, err := l.Since(tt.epoch, tt.since, tt.limit)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, changed)
+			seqs := []uint64{}
+			for _, e := range entries {
+				seqs = append(seqs, e.Seq)
+			}
+			assert.Equal(t, tt.wantSeqs, seqs)
+		})
+	}
+}
+
+func TestLog_Changed(t *testing.T) {
+	l := New(10)
+	_, changed, err := l.Since(l.Status().Epoch, 0, 0)
+	require.NoError(t, err)
+	select {
+	case <-changed:
+		t.Fatal("changed before a record")
+	default:
+	}
+	record(t, l, gauge("Alloc", 1))
+	<-changed
+
+	_, changed, err = l.Since(l.Status().Epoch, 1, 0)
+	require.NoError(t, err)
+	l.Close()
+	<-changed
+	_, _, err = l.Since(l.Status().Epoch, 1, 0)
+	assert.ErrorIs(t, err, ErrLogClosed)
+	record(t, l, gauge("Alloc", 2))
+}
+
+func TestLog_Consistent(t *testing.T) {
+	l := New(10)
+	record(t, l, gauge("Alloc", 1), gauge("Heap", 2))
+	called := false
+	epoch, seq, err := l.Consistent(func() error {
+		called = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, called)
+	assert.Equal(t, l.Status().Epoch, epoch)
+	assert.Equal(t, uint64(1), seq)
+	assert.Equal(t, Status{Role: RoleLeader, Epoch: epoch, Seq: 1}, l.Status())
+}
+
+func TestLog_Disabled(t *testing.T) {
+	l := New(0)
+	applied := false
+	require.NoError(t, l.Record(OpUpdate, nil, func() error {
+		applied = true
+		return nil
+	}))
+	assert.True(t, applied)
+	assert.False(t, l.Enabled())
+	_, _, err := l.Consistent(func() error { return nil })
+	assert.ErrorIs(t, err, ErrLogDisabled)
+	_, _, err = l.Since("", 0, 0)
+	assert.ErrorIs(t, err, ErrLogDisabled)
+}
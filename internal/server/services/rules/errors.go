@@ -0,0 +1,11 @@
+package rules
+
+import "errors"
+
+var ErrNoResult = errors.New("expression has no result")
+
+var ErrManyResults = errors.New("expression has more than one result")
+
+var ErrReadOnly = errors.New("rule metrics are read-only")
+
+var ErrInvalidRule = errors.New("invalid rule")
@@ -0,0 +1,217 @@
+// Package rules evaluates recording rules: queries in the language of
+// the query package whose results are stored back as gauges on a schedule.
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/query"
+)
+
+// Rule records the result of Expr as the gauge Record. Expr is a query,
+// see query.Parse, that results in a scalar or in a single sample.
+// Example: {"record": "HeapUtilization", "expr": "HeapInuse / HeapSys"}.
+type Rule struct {
+	Record string            `json:"record"`
+	Expr   string            `json:"expr"`
+	Labels map[string]string `json:"labels,omitempty"`
+	expr   query.Expr
+}
+
+// Engine evaluates a list of rules in order, so a rule may use
+// the result of a rule above it.
+type Engine struct {
+	rules []Rule
+}
+
+// Instance is the global engine started by the server. It has no rules by default.
+var Instance = &Engine{}
+
+// New parses and validates rules.
+// Parameters:
+//   - rules: rules in evaluation order
+//
+// Returns:
+//   - *Engine: engine ready to evaluate the rules
+//   - error: ErrInvalidRule with the number and name of the first broken rule
+func New(rules []Rule) (*Engine, error) {
+	e := &Engine{rules: make([]Rule, len(rules))}
+	seen := map[string]struct{}{}
+	for i, r := range rules {
+		if r.Record == "" {
+			return nil, fmt.Errorf("%w %d: record is required", ErrInvalidRule, i+1)
+		}
+		if _, ok := seen[r.Record]; ok {
+			return nil, fmt.Errorf("%w %d %q: record is duplicated", ErrInvalidRule, i+1, r.Record)
+		}
+		seen[r.Record] = struct{}{}
+		expr, err := query.Parse(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w %d %q: %w", ErrInvalidRule, i+1, r.Record, err)
+		}
+		r.expr = expr
+		e.rules[i] = r
+	}
+	return e, nil
+}
+
+// Load reads rules from a JSON file holding an array of Rule.
+// Parameters:
+//   - path: rules file
+//
+// Returns:
+//   - *Engine: engine with the rules of the file
+//   - error: if the file cannot be read or a rule is invalid
+func Load(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err = json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	e, err := New(rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return e, nil
+}
+
+// Rules returns the number of rules.
+func (e *Engine) Rules() int {
+	return len(e.rules)
+}
+
+// Evaluate computes every rule once against the current metrics with
+// query.Eval and stores the results through metricsService.Update.
+// A counter is hidden by a gauge with the same ID, so PollCount means
+// the gauge when both exist. A vector result must hold one sample, values
+// that are not finite, e.g. after a division by zero, leave it empty.
+// A failing rule does not stop the following ones.
+// Parameters:
+//   - s: storage to read from and write to
+//
+// Returns:
+//   - error: the failures of all rules joined, nil if every rule succeeded
+func (e *Engine) Evaluate(s metricsService.Storage) error {
+	if len(e.rules) == 0 {
+		return nil
+	}
+	all := []*metrics.MetricDTOParams{}
+	stored, err := s.GetMetrics(&all)
+	if err != nil {
+		return err
+	}
+	v := newView(*stored)
+
+	var errs []error
+	for _, r := range e.rules {
+		value, err := evalRule(v, r.expr)
+		if err == nil {
+			err = metricsService.Update(s, &metrics.Metrics{ID: r.Record, MType: constants.Gauge, Value: &value, Labels: r.Labels})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %q: %w", r.Record, err))
+			continue
+		}
+		v.set(metrics.Metrics{ID: r.Record, MType: constants.Gauge, Value: &value, Labels: r.Labels})
+	}
+	return errors.Join(errs...)
+}
+
+// evalRule evaluates expr to a single value.
+func evalRule(v *view, expr query.Expr) (float64, error) {
+	result, err := query.Eval(context.Background(), v, expr, time.Now(), query.DefaultLimits)
+	if err != nil {
+		return 0, err
+	}
+	if result.Type == query.TypeScalar {
+		return result.Scalar, nil
+	}
+	switch len(result.Vector) {
+	case 0:
+		return 0, ErrNoResult
+	case 1:
+		return result.Vector[0].Value, nil
+	}
+	return 0, fmt.Errorf("%w: %d samples", ErrManyResults, len(result.Vector))
+}
+
+// view is the read-only storage the rules are evaluated against:
+// the stored metrics with the results of the rules evaluated so far,
+// so a rule may use the result of a rule above it.
+type view struct {
+	metrics []metrics.Metrics
+	index   map[string]int
+}
+
+func newView(stored []metrics.Metrics) *view {
+	v := &view{index: map[string]int{}}
+	for _, m := range stored {
+		if m.MType == constants.Gauge {
+			v.set(m)
+		}
+	}
+	for _, m := range stored {
+		if _, shadowed := v.index[m.ID]; m.MType != constants.Gauge && !shadowed {
+			v.set(m)
+		}
+	}
+	return v
+}
+
+// set adds or replaces the metric with the ID of m.
+func (v *view) set(m metrics.Metrics) {
+	if i, ok := v.index[m.ID]; ok {
+		v.metrics[i] = m
+		return
+	}
+	v.index[m.ID] = len(v.metrics)
+	v.metrics = append(v.metrics, m)
+}
+
+// SaveMetric implements metricsService.Storage, always returns ErrReadOnly.
+func (v *view) SaveMetric(*metrics.Metrics) error {
+	return ErrReadOnly
+}
+
+// SaveMetrics implements metricsService.Storage, always returns ErrReadOnly.
+func (v *view) SaveMetrics(*[]metrics.Metrics) error {
+	return ErrReadOnly
+}
+
+// GetMetrics returns all metrics of the view, query.Eval reads them once.
+func (v *view) GetMetrics(*[]*metrics.MetricDTOParams) (*[]metrics.Metrics, error) {
+	all := append([]metrics.Metrics(nil), v.metrics...)
+	return &all, nil
+}
+
+// Run evaluates the rules every interval until ctx is done.
+// It returns at once when there are no rules or interval is not positive.
+func (e *Engine) Run(ctx context.Context, s metricsService.Storage, interval time.Duration) {
+	if len(e.rules) == 0 || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Evaluate(s); err != nil {
+				logger.LogError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package rules
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/history"
+)
+
+// memStorage keeps metrics in a map keyed by type and name.
+type memStorage map[string]metrics.Metrics
+
+func (s memStorage) SaveMetric(m *metrics.Metrics) error {
+	s[m.MType+"/"+m.ID] = *m
+	return nil
+}
+
+func (s memStorage) SaveMetrics(list *[]metrics.Metrics) error {
+	for i := range *list {
+		_ = s.SaveMetric(&(*list)[i])
+	}
+	return nil
+}
+
+func (s memStorage) GetMetrics(*[]*metrics.MetricDTOParams) (*[]metrics.Metrics, error) {
+	list := []metrics.Metrics{}
+	for _, m := range s {
+		list = append(list, m)
+	}
+	return &list, nil
+}
+
+func gauge(v float64) *float64 {
+	return &v
+}
+
+func counter(v int64) *int64 {
+	return &v
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		wantErr string
+	}{
+		{name: "Valid", rules: []Rule{{Record: "UsedMemory", Expr: "TotalMemory - FreeMemory"}}},
+		{name: "No record", rules: []Rule{{Expr: "1"}}, wantErr: "invalid rule 1: record is required"},
+		{name: "Duplicate", rules: []Rule{{Record: "A", Expr: "1"}, {Record: "A", Expr: "2"}}, wantErr: `invalid rule 2 "A": record is duplicated`},
+		{name: "Syntax", rules: []Rule{{Record: "A", Expr: "1"}, {Record: "UsedMemory", Expr: "TotalMemory -"}}, wantErr: `invalid rule 2 "UsedMemory": unexpected end of query, expected number, selector, function or "(" at position 14`},
+		{name: "Range", rules: []Rule{{Record: "Polls", Expr: "PollCount[5m]"}}, wantErr: `invalid rule 1 "Polls": a range selector must be passed to a function such as rate() at position 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.rules)
+			if tt.wantErr != "" {
+				assert.ErrorIs(t, err, ErrInvalidRule)
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.rules), e.Rules())
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"record": "HeapUtilization", "expr": "HeapInuse / HeapSys", "labels": {"unit": "ratio"}}]`), 0600))
+	e, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, e.Rules())
+	assert.Equal(t, map[string]string{"unit": "ratio"}, e.rules[0].Labels)
+
+	_, err = Load(filepath.Join(dir, "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"record": "A", "expr": "("}]`), 0600))
+	_, err = Load(path)
+	assert.ErrorIs(t, err, ErrInvalidRule)
+	assert.Contains(t, err.Error(), path)
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	defer history.Instance.Reset()
+	s := memStorage{}
+	_ = s.SaveMetric(&metrics.Metrics{ID: "HeapInuse", MType: "gauge", Value: gauge(30)})
+	_ = s.SaveMetric(&metrics.Metrics{ID: "HeapSys", MType: "gauge", Value: gauge(120)})
+	_ = s.SaveMetric(&metrics.Metrics{ID: "PollCount", MType: "counter", Delta: counter(5)})
+	_ = s.SaveMetric(&metrics.Metrics{ID: "PollCount", MType: "gauge", Value: gauge(1)})
+	_ = s.SaveMetric(&metrics.Metrics{ID: "Zero", MType: "gauge", Value: gauge(0)})
+	_ = s.SaveMetric(&metrics.Metrics{ID: "cpu-load", MType: "gauge", Value: gauge(0.5)})
+	e, err := New([]Rule{
+		{Record: "HeapUtilization", Expr: "HeapInuse / HeapSys", Labels: map[string]string{"unit": "ratio"}},
+		{Record: "HeapPercent", Expr: "HeapUtilization * 100"},
+		{Record: "Broken", Expr: "HeapSys / Zero"},
+		{Record: "Polls", Expr: "PollCount"},
+		{Record: "CPUPercent", Expr: `{__name__="cpu-load"} * 1e2`},
+		{Record: "HeapTotal", Expr: `sum({__name__=~"Heap(Inuse|Sys)"})`},
+		{Record: "Heaps", Expr: `{__name__=~"Heap(Inuse|Sys)"}`},
+		{Record: "Constant", Expr: "1 + 2 * 3"},
+	})
+	require.NoError(t, err)
+
+	err = e.Evaluate(s)
+	assert.ErrorIs(t, err, ErrNoResult)
+	assert.Contains(t, err.Error(), `rule "Broken"`)
+	assert.ErrorIs(t, err, ErrManyResults)
+	assert.Contains(t, err.Error(), `rule "Heaps"`)
+
+	assert.Equal(t, 0.25, *s["gauge/HeapUtilization"].Value)
+	assert.Equal(t, map[string]string{"unit": "ratio"}, s["gauge/HeapUtilization"].Labels)
+	assert.Equal(t, 25.0, *s["gauge/HeapPercent"].Value)
+	assert.NotContains(t, s, "gauge/Broken")
+	// одноимённый gauge важнее счётчика
+	assert.Equal(t, 1.0, *s["gauge/Polls"].Value)
+	assert.Equal(t, 50.0, *s["gauge/CPUPercent"].Value)
+	assert.Equal(t, 150.0, *s["gauge/HeapTotal"].Value)
+	assert.NotContains(t, s, "gauge/Heaps")
+	assert.Equal(t, 7.0, *s["gauge/Constant"].Value)
+	assert.Len(t, history.Instance.Series("gauge", "HeapPercent"), 1)
+}
+
+func TestEngine_Run(t *testing.T) {
+	defer history.Instance.Reset()
+	s := memStorage{}
+	_ = s.SaveMetric(&metrics.Metrics{ID: "A", MType: "gauge", Value: gauge(2)})
+	e, err := New([]Rule{{Record: "B", Expr: "A * 2"}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	e.Run(ctx, s, 5*time.Millisecond)
+	assert.Equal(t, 4.0, *s["gauge/B"].Value)
+
+	// без правил Run сразу возвращается
+	Instance.Run(context.Background(), s, time.Millisecond)
+}
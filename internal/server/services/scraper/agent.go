@@ -0,0 +1,156 @@
+package scraper
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/Maxim-Ba/metriccollector/internal/agent/exporter"
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/internal/signature"
+)
+
+// KindAgent is the kind of the targets pulled by Agents.
+const KindAgent = "agent"
+
+// Agents pulls the metrics served by agents started with -expose and
+// stores them with metricsService.UpdateMany. An agent serves the total
+// of a counter, the growth since the previous scrape of the target is
+// stored as the delta. A new start time of the agent, or a total below the
+// previous one, means the agent restarted and the whole total is stored.
+// The first scrape of a target only takes the totals as the baseline:
+// after a server restart they are not known to be stored already or not,
+// so the growth until the next scrape is counted instead of the totals.
+type Agents struct {
+	storage metricsService.Storage
+	client  *http.Client
+
+	mu      sync.Mutex
+	targets map[string]*baseline
+}
+
+// baseline is the state of an agent at its previous scrape.
+type baseline struct {
+	started string
+	totals  map[string]int64
+}
+
+// NewAgents creates the puller of agent targets.
+// Parameters:
+//   - s: storage of the pulled metrics
+//
+// Returns:
+//   - *Agents: the puller
+func NewAgents(s metricsService.Storage) *Agents {
+	return &Agents{
+		storage: s,
+		client:  &http.Client{Timeout: DefaultTimeout},
+		targets: map[string]*baseline{},
+	}
+}
+
+// Pull requests the metrics of the agent t and stores them. When the
+// server has a signing key the body must be signed in the HashSHA256
+// header. Statuses 5xx and 429 are returned as ErrTargetUnavailable,
+// other failures as ErrTargetFailed.
+func (a *Agents) Pull(ctx context.Context, t Target) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL+exporter.Path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return 0, fmt.Errorf("%w: %s", ErrTargetUnavailable, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%w: %s", ErrTargetFailed, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if err = checkSignature(resp.Header.Get("HashSHA256"), body); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrTargetFailed, err)
+	}
+	var pulled []metrics.Metrics
+	if err = json.Unmarshal(body, &pulled); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrTargetFailed, err)
+	}
+
+	a.mu.Lock()
+	updates, next := a.updates(a.targets[t.Name], resp.Header.Get(exporter.StartedHeader), pulled)
+	a.mu.Unlock()
+	if len(updates) > 0 {
+		if err = metricsService.UpdateMany(a.storage, &updates); err != nil {
+			return 0, err
+		}
+	}
+	// итоги запоминаются после сохранения, иначе прирост потеряется при ошибке
+	a.mu.Lock()
+	a.targets[t.Name] = next
+	a.mu.Unlock()
+	return len(updates), nil
+}
+
+// updates turns the pulled metrics into updates, counter totals into
+// deltas against previous, and returns the baseline for the next scrape.
+// Counters are skipped when previous is nil. Metrics without a value are
+// skipped.
+// Parameters:
+//   - previous: baseline of the target, nil before its first scrape
+//   - started: start time of the agent, empty for an agent not sending it
+//   - pulled: the served metrics
+func (a *Agents) updates(previous *baseline, started string, pulled []metrics.Metrics) ([]metrics.Metrics, *baseline) {
+	updates := make([]metrics.Metrics, 0, len(pulled))
+	next := &baseline{started: started, totals: map[string]int64{}}
+	restarted := previous != nil && started != previous.started
+	for _, m := range pulled {
+		switch {
+		case m.ID == "":
+			continue
+		case m.MType == constants.Gauge && m.Value != nil:
+		case m.MType == constants.Counter && m.Delta != nil:
+			total := *m.Delta
+			next.totals[m.ID] = total
+			if previous == nil {
+				continue
+			}
+			delta := total
+			if last, ok := previous.totals[m.ID]; ok && !restarted && total >= last {
+				delta = total - last
+			}
+			m.Delta = &delta
+		default:
+			continue
+		}
+		updates = append(updates, m)
+	}
+	return updates, next
+}
+
+// checkSignature checks the HashSHA256 header of a response when the
+// server has a signing key. The header is required then: the agent signs
+// its responses with the shared key, so an unsigned one may be spoofed.
+func checkSignature(header string, body []byte) error {
+	if signature.Instance == nil || signature.Instance.GetKey() == "" {
+		return nil
+	}
+	if header == "" {
+		return ErrUnsigned
+	}
+	hash, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return err
+	}
+	return signature.Instance.Check(hash, body)
+}
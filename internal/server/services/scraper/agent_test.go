@@ -0,0 +1,146 @@
+package scraper
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/agent/exporter"
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage"
+	"github.com/Maxim-Ba/metriccollector/internal/signature"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func stored(t *testing.T, mType, id string) metrics.Metrics {
+	all, err := storage.StorageInstance.GetMetrics(&[]*metrics.MetricDTOParams{{MetricsName: id, MetricType: mType}})
+	require.NoError(t, err)
+	require.Len(t, *all, 1)
+	return (*all)[0]
+}
+
+func TestAgents_Pull(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	original := signature.Instance
+	defer func() {
+		signature.Instance = original
+	}()
+	signature.New("secret", "")
+
+	e := exporter.New()
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.Handler().ServeHTTP(w, r)
+	}))
+	defer agent.Close()
+	a := NewAgents(storage.StorageInstance)
+	target := Target{Name: "web1", URL: agent.URL}
+
+	tests := []struct {
+		name        string
+		restart     bool
+		pollCount   int64
+		alloc       float64
+		wantSamples int
+		wantCount   int64
+	}{
+		// итог первого опроса только запоминается
+		{name: "First scrape", pollCount: 3, alloc: 1, wantSamples: 1},
+		{name: "Growth", pollCount: 5, alloc: 2, wantSamples: 2, wantCount: 2},
+		{name: "Agent restarted", restart: true, pollCount: 1, alloc: 3, wantSamples: 2, wantCount: 3},
+		{name: "Restarted agent passed the old total", restart: true, pollCount: 7, alloc: 4, wantSamples: 2, wantCount: 10},
+		{name: "Total dropped", pollCount: 2, alloc: 5, wantSamples: 2, wantCount: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.restart {
+				e = exporter.New()
+			}
+			e.Set([]*metrics.Metrics{
+				{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(tt.alloc)},
+				{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(tt.pollCount)},
+				{ID: "Broken", MType: constants.Gauge},
+			})
+			samples, err := a.Pull(context.Background(), target)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSamples, samples)
+			assert.Equal(t, tt.alloc, *stored(t, constants.Gauge, "Alloc").Value)
+			if tt.wantCount == 0 {
+				_, err = storage.StorageInstance.GetMetrics(&[]*metrics.MetricDTOParams{{MetricsName: "PollCount", MetricType: constants.Counter}})
+				assert.ErrorIs(t, err, storage.ErrUnknownMetricName)
+				return
+			}
+			assert.Equal(t, tt.wantCount, *stored(t, constants.Counter, "PollCount").Delta)
+		})
+	}
+}
+
+func TestAgents_PullAfterServerRestart(t *testing.T) {
+	storage.StorageInstance.ClearAll()
+	defer storage.StorageInstance.ClearAll()
+	e := exporter.New()
+	agent := httptest.NewServer(e.Handler())
+	defer agent.Close()
+	target := Target{Name: "web1", URL: agent.URL}
+	pull := func(a *Agents, pollCount int64) {
+		t.Helper()
+		e.Set([]*metrics.Metrics{{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(pollCount)}})
+		_, err := a.Pull(context.Background(), target)
+		require.NoError(t, err)
+	}
+
+	a := NewAgents(storage.StorageInstance)
+	pull(a, 3)
+	pull(a, 5)
+	require.Equal(t, int64(2), *stored(t, constants.Counter, "PollCount").Delta)
+
+	// перезапущенный сервер не знает, какой итог агента уже сохранён
+	a = NewAgents(storage.StorageInstance)
+	pull(a, 8)
+	assert.Equal(t, int64(2), *stored(t, constants.Counter, "PollCount").Delta)
+	pull(a, 9)
+	assert.Equal(t, int64(3), *stored(t, constants.Counter, "PollCount").Delta)
+}
+
+func TestAgents_PullErrors(t *testing.T) {
+	original := signature.Instance
+	defer func() {
+		signature.Instance = original
+	}()
+	signature.New("secret", "")
+
+	tests := []struct {
+		name    string
+		status  int
+		hash    string
+		body    string
+		wantErr error
+	}{
+		{name: "Unavailable", status: http.StatusBadGateway, wantErr: ErrTargetUnavailable},
+		{name: "Not found", status: http.StatusNotFound, wantErr: ErrTargetFailed},
+		{name: "Broken body", status: http.StatusOK, body: "[{", wantErr: ErrTargetFailed},
+		{name: "Wrong signature", status: http.StatusOK, hash: base64.StdEncoding.EncodeToString([]byte("forged")), body: "[]", wantErr: ErrTargetFailed},
+		{name: "Not signed", status: http.StatusOK, body: "[]", wantErr: ErrUnsigned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, exporter.Path, r.URL.Path)
+				if tt.hash != "" {
+					w.Header().Set("HashSHA256", tt.hash)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer agent.Close()
+			_, err := NewAgents(storage.StorageInstance).Pull(context.Background(), Target{Name: "web1", URL: agent.URL})
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
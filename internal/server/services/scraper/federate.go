@@ -0,0 +1,112 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+)
+
+// KindFederate is the kind of the targets pulled by Federation.
+const KindFederate = "federate"
+
+// SourceLabel is the label holding the name of the target a metric was pulled from.
+const SourceLabel = "source"
+
+// Federation pulls metrics from the /federate endpoint of other servers.
+// A metric is stored as "<target>.<id>" with the label source=<target>,
+// so metrics of the same name from different servers do not overwrite
+// each other. Values are replaced, a counter keeps the total of the target.
+// After the first scrape only the metrics updated since the previous
+// scrape are requested; metrics removed on a target are kept.
+type Federation struct {
+	token   string
+	storage metricsService.Editor
+	client  *http.Client
+
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+// NewFederation creates the puller of federation targets.
+// Parameters:
+//   - token: API token sent to the targets, empty when they do not require one
+//   - s: storage of the pulled metrics
+//
+// Returns:
+//   - *Federation: the puller
+func NewFederation(token string, s metricsService.Editor) *Federation {
+	return &Federation{
+		token:   token,
+		storage: s,
+		client:  &http.Client{Timeout: DefaultTimeout},
+		since:   map[string]time.Time{},
+	}
+}
+
+// Pull requests the metrics of t updated since the previous scrape and
+// stores them. Statuses 5xx and 429 are returned as ErrTargetUnavailable,
+// other failures as ErrTargetFailed.
+func (f *Federation) Pull(ctx context.Context, t Target) (int, error) {
+	f.mu.Lock()
+	since := f.since[t.Name]
+	f.mu.Unlock()
+
+	address := t.URL + "/federate"
+	if !since.IsZero() {
+		address += "?since=" + url.QueryEscape(since.Format(time.RFC3339Nano))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return 0, err
+	}
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return 0, fmt.Errorf("%w: %s", ErrTargetUnavailable, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%w: %s", ErrTargetFailed, resp.Status)
+	}
+	var federation metricsService.Federation
+	if err = json.NewDecoder(resp.Body).Decode(&federation); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrTargetFailed, err)
+	}
+
+	for _, fm := range federation.Metrics {
+		m := sourced(fm.Metrics, t.Name)
+		if err = metricsService.Set(f.storage, &m); err != nil {
+			return 0, err
+		}
+	}
+	// время берётся с цели, расхождение часов не теряет обновления
+	f.mu.Lock()
+	f.since[t.Name] = federation.Time
+	f.mu.Unlock()
+	return len(federation.Metrics), nil
+}
+
+// sourced returns m renamed and labelled with the source.
+func sourced(m metrics.Metrics, source string) metrics.Metrics {
+	labels := maps.Clone(m.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[SourceLabel] = source
+	m.ID = source + "." + m.ID
+	m.Labels = labels
+	return m
+}
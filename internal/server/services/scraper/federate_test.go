@@ -0,0 +1,99 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	metricsService "github.com/Maxim-Ba/metriccollector/internal/server/services/metric"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+// editor keeps the metrics stored by Federation.
+type editor struct {
+	mu      sync.Mutex
+	metrics map[string]metrics.Metrics
+}
+
+func (e *editor) SetMetric(m *metrics.Metrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.metrics[m.ID] = *m
+	return nil
+}
+
+func (e *editor) DeleteMetric(_, name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.metrics, name)
+	return nil
+}
+
+func TestFederation_Pull(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var sinces []string
+	region := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/federate", r.URL.Path)
+		assert.Equal(t, "Bearer central", r.Header.Get("Authorization"))
+		sinces = append(sinces, r.URL.Query().Get("since"))
+		_ = json.NewEncoder(w).Encode(metricsService.Federation{
+			Time: at,
+			Metrics: []metricsService.FederatedMetric{
+				{Metrics: metrics.Metrics{ID: "PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(5)}, Updated: &at},
+				{Metrics: metrics.Metrics{ID: "Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1), Labels: map[string]string{"host": "a"}}},
+			},
+		})
+	}))
+	defer region.Close()
+
+	e := &editor{metrics: map[string]metrics.Metrics{}}
+	f := NewFederation("central", e)
+	target := Target{Name: "eu", URL: region.URL}
+	for i := 0; i < 2; i++ {
+		samples, err := f.Pull(context.Background(), target)
+		require.NoError(t, err)
+		assert.Equal(t, 2, samples)
+	}
+
+	assert.Equal(t, []string{"", at.Format(time.RFC3339Nano)}, sinces)
+	assert.Equal(t, map[string]metrics.Metrics{
+		"eu.PollCount": {ID: "eu.PollCount", MType: constants.Counter, Delta: utils.FloatToPointerInt(5), Labels: map[string]string{SourceLabel: "eu"}},
+		"eu.Alloc":     {ID: "eu.Alloc", MType: constants.Gauge, Value: utils.FloatToPointerFloat(1), Labels: map[string]string{"host": "a", SourceLabel: "eu"}},
+	}, e.metrics)
+}
+
+func TestFederation_PullErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+	}{
+		{name: "Unavailable", status: http.StatusServiceUnavailable, wantErr: ErrTargetUnavailable},
+		{name: "Too many requests", status: http.StatusTooManyRequests, wantErr: ErrTargetUnavailable},
+		{name: "Unauthorized", status: http.StatusUnauthorized, wantErr: ErrTargetFailed},
+		{name: "Broken body", status: http.StatusOK, body: "{", wantErr: ErrTargetFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			region := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer region.Close()
+			e := &editor{metrics: map[string]metrics.Metrics{}}
+			_, err := NewFederation("", e).Pull(context.Background(), Target{Name: "eu", URL: region.URL})
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Empty(t, e.metrics)
+		})
+	}
+}
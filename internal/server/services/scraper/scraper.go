@@ -0,0 +1,270 @@
+// Package scraper pulls metrics into the server from configured targets
+// every interval and keeps the health of every target. What a target is
+// and how its metrics are stored is decided by a Puller: Federation for
+// other servers and Agents for agents serving their metrics.
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+// DefaultInterval is the period of scraping a target.
+const DefaultInterval = 30 * time.Second
+
+// DefaultTimeout limits a single request to a target.
+const DefaultTimeout = 10 * time.Second
+
+// ErrInvalidTarget is returned by ParseTargets for a target that is not
+// a host:port or an http(s) URL, or for a repeated name.
+var ErrInvalidTarget = errors.New("invalid scrape target")
+
+// ErrTargetUnavailable is returned by a Puller when the target answered
+// with a status worth retrying, e.g. 503, the scrape is retried with
+// utils.RetryWrapper.
+var ErrTargetUnavailable = errors.New("scrape target unavailable")
+
+// ErrTargetFailed is returned by a Puller when the target rejected the
+// request or answered with an unreadable body.
+var ErrTargetFailed = errors.New("scrape target failed")
+
+// ErrUnsigned is wrapped in ErrTargetFailed when the server has a signing
+// key and an agent response carries no HashSHA256 header.
+var ErrUnsigned = errors.New("response is not signed")
+
+// retryErrors are the errors of a Puller retried within one scrape.
+var retryErrors = []error{ErrTargetUnavailable}
+
+// Target is a process the server pulls metrics from.
+// Fields:
+//   - Name: name of the target, unique within its kind
+//   - URL: base URL of the target
+type Target struct {
+	Name string
+	URL  string
+}
+
+// Puller pulls the metrics of a target and stores them.
+type Puller interface {
+	// Pull stores the current metrics of t and returns how many were stored.
+	Pull(ctx context.Context, t Target) (int, error)
+}
+
+// Health describes the result of the last scrapes of a target.
+// Fields:
+//   - Target: name of the target
+//   - URL: base URL of the target
+//   - Kind: kind of the target, KindFederate or KindAgent
+//   - Up: the last scrape succeeded
+//   - LastScrape: time the last scrape finished
+//   - LastSuccess: time the last successful scrape finished
+//   - Samples: metrics stored by the last successful scrape
+//   - Failures: scrapes failed in a row
+//   - LastError: error of the last scrape, empty after a success
+type Health struct {
+	Target      string     `json:"target"`
+	URL         string     `json:"url"`
+	Kind        string     `json:"kind"`
+	Up          bool       `json:"up"`
+	LastScrape  *time.Time `json:"last_scrape,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	Samples     int        `json:"samples"`
+	Failures    int        `json:"failures"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// job scrapes a group of targets of one kind with the same interval.
+type job struct {
+	kind     string
+	puller   Puller
+	targets  []Target
+	interval time.Duration
+}
+
+// Scraper runs the scrape jobs of the server.
+type Scraper struct {
+	mu     sync.Mutex
+	jobs   []*job
+	health map[string]*Health
+}
+
+// Instance is the scraper of the server, nil when no targets are configured.
+var Instance *Scraper
+
+// New creates a scraper without jobs.
+func New() *Scraper {
+	return &Scraper{health: map[string]*Health{}}
+}
+
+// Add registers targets scraped by p. Call it before Run.
+// Parameters:
+//   - kind: kind of the targets reported by Health
+//   - p: puller of the targets
+//   - targets: targets, see ParseTargets
+//   - interval: period of scraping, DefaultInterval when not positive
+func (s *Scraper) Add(kind string, p Puller, targets []Target, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &job{kind: kind, puller: p, targets: targets, interval: interval})
+	for _, t := range targets {
+		s.health[healthKey(kind, t.Name)] = &Health{Target: t.Name, URL: t.URL, Kind: kind}
+	}
+}
+
+// Run scrapes every target at start and then every interval of its job
+// until ctx is done.
+func (s *Scraper) Run(ctx context.Context) {
+	s.mu.Lock()
+	jobs := s.jobs
+	s.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+			for {
+				s.scrapeJob(ctx, j)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// ScrapeAll scrapes every target once and waits for the results.
+func (s *Scraper) ScrapeAll(ctx context.Context) {
+	s.mu.Lock()
+	jobs := s.jobs
+	s.mu.Unlock()
+	for _, j := range jobs {
+		s.scrapeJob(ctx, j)
+	}
+}
+
+// scrapeJob scrapes the targets of j concurrently, so a slow target
+// does not delay the others.
+func (s *Scraper) scrapeJob(ctx context.Context, j *job) {
+	var wg sync.WaitGroup
+	for _, t := range j.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.scrape(ctx, j, t)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Scraper) scrape(ctx context.Context, j *job, t Target) {
+	var samples int
+	var lastErr error
+	err := utils.RetryWrapper(func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		samples, err = j.puller.Pull(ctx, t)
+		lastErr = err
+		return err
+	}, retryErrors)
+	if err != nil && lastErr != nil {
+		// RetryWrapper не сохраняет причину последней неудачи
+		err = lastErr
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.health[healthKey(j.kind, t.Name)]
+	h.LastScrape = &now
+	if err != nil {
+		logger.LogError(fmt.Sprintf("scrape %s %s: ", j.kind, t.Name), err)
+		h.Up = false
+		h.Failures++
+		h.LastError = err.Error()
+		return
+	}
+	h.Up = true
+	h.LastSuccess = &now
+	h.Samples = samples
+	h.Failures = 0
+	h.LastError = ""
+}
+
+// Health returns the health of all targets in the order they were added.
+// Returns an empty list on a nil scraper.
+func (s *Scraper) Health() []Health {
+	result := []Health{}
+	if s == nil {
+		return result
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		for _, t := range j.targets {
+			result = append(result, *s.health[healthKey(j.kind, t.Name)])
+		}
+	}
+	return result
+}
+
+func healthKey(kind, name string) string {
+	return kind + "/" + name
+}
+
+// ParseTargets parses a comma separated list of targets. A target is
+// "name=address" or a bare address named after its host:port, the address
+// is a host:port or an http(s) URL.
+// Parameters:
+//   - list: the list, e.g. "eu=10.0.0.1:8080,us=https://us.example.com"
+//
+// Returns:
+//   - []Target: the targets in list order
+//   - error: ErrInvalidTarget
+func ParseTargets(list string) ([]Target, error) {
+	var targets []Target
+	seen := map[string]bool{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, address, named := strings.Cut(item, "=")
+		if !named {
+			address = name
+		}
+		if !strings.Contains(address, "://") {
+			address = "http://" + address
+		}
+		u, err := url.Parse(address)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTarget, item)
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		if !named {
+			name = u.Host
+		}
+		if name == "" || seen[name] {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTarget, item)
+		}
+		seen[name] = true
+		targets = append(targets, Target{Name: name, URL: u.String()})
+	}
+	return targets, nil
+}
@@ -0,0 +1,120 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []Target
+		wantErr bool
+	}{
+		{name: "Empty", list: ""},
+		{name: "Named", list: "eu=10.0.0.1:8080, us=https://us.example.com/", want: []Target{{Name: "eu", URL: "http://10.0.0.1:8080"}, {Name: "us", URL: "https://us.example.com"}}},
+		{name: "Bare address", list: "10.0.0.1:8080", want: []Target{{Name: "10.0.0.1:8080", URL: "http://10.0.0.1:8080"}}},
+		{name: "Repeated name", list: "eu=10.0.0.1:8080,eu=10.0.0.2:8080", wantErr: true},
+		{name: "Empty name", list: "=10.0.0.1:8080", wantErr: true},
+		{name: "Bad scheme", list: "eu=ftp://10.0.0.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := ParseTargets(tt.list)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTarget)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, targets)
+		})
+	}
+}
+
+// fakePuller returns the queued results of a target in turn.
+type fakePuller struct {
+	mu      sync.Mutex
+	results map[string][]error
+	calls   map[string]int
+}
+
+func (p *fakePuller) Pull(_ context.Context, t Target) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls[t.Name]++
+	results := p.results[t.Name]
+	if len(results) == 0 {
+		return 3, nil
+	}
+	p.results[t.Name] = results[1:]
+	return 3, results[0]
+}
+
+func TestScrapeAll_Health(t *testing.T) {
+	down := errors.New("connection refused")
+	p := &fakePuller{
+		results: map[string][]error{
+			"flaky": {fmt.Errorf("%w: 503 Service Unavailable", ErrTargetUnavailable)},
+			"down":  {down, down},
+		},
+		calls: map[string]int{},
+	}
+	s := New()
+	s.Add(KindFederate, p, []Target{{Name: "ok", URL: "http://ok"}, {Name: "flaky", URL: "http://flaky"}, {Name: "down", URL: "http://down"}}, time.Hour)
+
+	s.ScrapeAll(context.Background())
+	health := s.Health()
+	require.Len(t, health, 3)
+	assert.True(t, health[0].Up)
+	assert.Equal(t, 3, health[0].Samples)
+	// недоступная цель повторяется в пределах одного опроса
+	assert.True(t, health[1].Up)
+	assert.Equal(t, 2, p.calls["flaky"])
+	assert.False(t, health[2].Up)
+	assert.Equal(t, 1, p.calls["down"])
+	assert.Equal(t, down.Error(), health[2].LastError)
+	assert.Equal(t, 1, health[2].Failures)
+	assert.NotNil(t, health[2].LastScrape)
+	assert.Nil(t, health[2].LastSuccess)
+
+	s.ScrapeAll(context.Background())
+	assert.Equal(t, 2, s.Health()[2].Failures)
+	s.ScrapeAll(context.Background())
+	health = s.Health()
+	assert.True(t, health[2].Up)
+	assert.Zero(t, health[2].Failures)
+	assert.Empty(t, health[2].LastError)
+	assert.Equal(t, KindFederate, health[2].Kind)
+}
+
+func TestHealth_Nil(t *testing.T) {
+	var s *Scraper
+	assert.Equal(t, []Health{}, s.Health())
+}
+
+func TestRun(t *testing.T) {
+	p := &fakePuller{results: map[string][]error{}, calls: map[string]int{}}
+	s := New()
+	s.Add(KindFederate, p, []Target{{Name: "ok", URL: "http://ok"}}, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	assert.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.calls["ok"] >= 2
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,7 @@
+package silence
+
+import "errors"
+
+var ErrInvalidSilence = errors.New("invalid silence")
+
+var ErrSilenceNotFound = errors.New("silence not found")
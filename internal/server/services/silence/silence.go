@@ -0,0 +1,242 @@
+// Package silence keeps the alert silences: time bounded matchers on the
+// metric name and labels that suppress notifications, e.g. during a deploy.
+// Silences only mute notifications, alert state is evaluated and tracked
+// as usual, so an alert that is still firing when its silence ends is
+// notified right away.
+package silence
+
+import (
+	"cmp"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/silences"
+	"github.com/Maxim-Ba/metriccollector/internal/server/services/query"
+)
+
+// entry is a silence with its compiled matchers.
+type entry struct {
+	silence  *silences.Silence
+	matchers []*query.Matcher
+}
+
+// Silencer keeps the silences and persists changes to the configured store.
+type Silencer struct {
+	mu       sync.RWMutex
+	silences map[string]*entry
+	store    silenceStore
+	now      func() time.Time
+}
+
+// Instance is the global silencer consulted before alert notifications
+// are sent and managed by the /api/silences handlers.
+var Instance = &Silencer{
+	silences: map[string]*entry{},
+	store:    fileStore{},
+	now:      time.Now,
+}
+
+// New loads the stored silences and installs the silencer as Instance.
+// Silences are kept in the database when db is not nil, otherwise in the JSON file at path.
+// Parameters:
+//   - path: silences file used without database
+//   - db: database connection or nil
+//
+// Returns:
+//   - *Silencer: Initialized silencer
+//   - error: if stored silences can not be loaded
+func New(path string, db *sql.DB) (*Silencer, error) {
+	s := &Silencer{
+		silences: map[string]*entry{},
+		now:      time.Now,
+	}
+	if db != nil {
+		s.store = dbStore{db: db}
+	} else {
+		s.store = fileStore{path: path}
+	}
+	stored, err := s.store.Load()
+	if err != nil {
+		logger.LogError(err)
+		return nil, err
+	}
+	for _, silence := range stored {
+		e, err := compile(silence)
+		if err != nil {
+			logger.LogError(fmt.Errorf("skip stored silence %s: %w", silence.ID, err))
+			continue
+		}
+		s.silences[silence.ID] = e
+	}
+	Instance = s
+	return s, nil
+}
+
+// Create validates and stores a new silence.
+// A zero StartsAt starts the silence now. The ID and CreatedAt are assigned.
+// Returns:
+//   - *silences.Silence: Created silence
+//   - error: ErrInvalidSilence for broken matchers or times, or a store error
+func (s *Silencer) Create(in silences.Silence) (*silences.Silence, error) {
+	now := s.now().UTC()
+	silence := in
+	silence.Matchers = slices.Clone(in.Matchers)
+	for i := range silence.Matchers {
+		if silence.Matchers[i].Op == "" {
+			silence.Matchers[i].Op = string(query.MatchEqual)
+		}
+	}
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = now
+	}
+	switch {
+	case silence.EndsAt.IsZero():
+		return nil, fmt.Errorf("%w: ends_at is required", ErrInvalidSilence)
+	case !silence.EndsAt.After(silence.StartsAt):
+		return nil, fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidSilence)
+	case !silence.EndsAt.After(now):
+		return nil, fmt.Errorf("%w: ends_at is in the past", ErrInvalidSilence)
+	}
+	e, err := compile(&silence)
+	if err != nil {
+		return nil, err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	silence.ID = id
+	silence.CreatedAt = now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silences[id] = e
+	if err = s.store.Save(&silence, s.all()); err != nil {
+		delete(s.silences, id)
+		return nil, err
+	}
+	created := silence
+	return &created, nil
+}
+
+// List returns all silences, including expired ones, ordered by start time.
+func (s *Silencer) List() []silences.Silence {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]silences.Silence, 0, len(s.silences))
+	for _, e := range s.silences {
+		list = append(list, *e.silence)
+	}
+	slices.SortFunc(list, func(x, y silences.Silence) int {
+		return cmp.Or(x.StartsAt.Compare(y.StartsAt), cmp.Compare(x.ID, y.ID))
+	})
+	return list
+}
+
+// Delete removes the silence with the given identifier.
+// Returns ErrSilenceNotFound when there is no such silence.
+func (s *Silencer) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.silences[id]
+	if !ok {
+		return ErrSilenceNotFound
+	}
+	delete(s.silences, id)
+	if err := s.store.Delete(id, s.all()); err != nil {
+		s.silences[id] = e
+		return err
+	}
+	return nil
+}
+
+// Silenced reports whether notifications about an alert on the metric
+// are suppressed at t.
+// Parameters:
+//   - name: metric name, matched by the "__name__" matchers
+//   - labels: labels of the metric
+//   - t: time of the notification
+//
+// Returns:
+//   - *silences.Silence: the first active silence matching the alert
+//   - bool: whether such a silence exists
+func (s *Silencer) Silenced(name string, labels map[string]string, t time.Time) (*silences.Silence, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found *silences.Silence
+	for _, e := range s.silences {
+		if !e.silence.Active(t) || !e.matches(name, labels) {
+			continue
+		}
+		if found == nil || e.silence.ID < found.ID {
+			found = e.silence
+		}
+	}
+	if found == nil {
+		return nil, false
+	}
+	silence := *found
+	return &silence, true
+}
+
+func (e *entry) matches(name string, labels map[string]string) bool {
+	for _, m := range e.matchers {
+		value := labels[m.Label]
+		if m.Label == query.NameLabel {
+			value = name
+		}
+		if !m.Matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// compile checks the matchers of a silence.
+// A silence must have a matcher that rejects the empty value,
+// otherwise it would silence every alert.
+func compile(silence *silences.Silence) (*entry, error) {
+	if len(silence.Matchers) == 0 {
+		return nil, fmt.Errorf("%w: at least one matcher is required", ErrInvalidSilence)
+	}
+	e := &entry{silence: silence}
+	matchesEmpty := true
+	for _, sm := range silence.Matchers {
+		m, err := query.NewMatcher(sm.Name, query.MatchOp(sm.Op), sm.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSilence, err)
+		}
+		matchesEmpty = matchesEmpty && m.Matches("")
+		e.matchers = append(e.matchers, m)
+	}
+	if matchesEmpty {
+		return nil, fmt.Errorf("%w: matchers select every alert", ErrInvalidSilence)
+	}
+	return e, nil
+}
+
+// all returns the silences that must be written to the store.
+func (s *Silencer) all() []*silences.Silence {
+	list := make([]*silences.Silence, 0, len(s.silences))
+	for _, e := range s.silences {
+		list = append(list, e.silence)
+	}
+	slices.SortFunc(list, func(x, y *silences.Silence) int {
+		return cmp.Compare(x.ID, y.ID)
+	})
+	return list
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,150 @@
+package silence
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/silences"
+)
+
+var now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newSilencer(t *testing.T) *Silencer {
+	t.Helper()
+	originalInstance := Instance
+	t.Cleanup(func() {
+		Instance = originalInstance
+	})
+	s, err := New(filepath.Join(t.TempDir(), "silences.json"), nil)
+	require.NoError(t, err)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestSilencer_Create(t *testing.T) {
+	host := []silences.Matcher{{Name: "host", Op: "=", Value: "a"}}
+	tests := []struct {
+		name    string
+		in      silences.Silence
+		wantErr bool
+	}{
+		{name: "Valid", in: silences.Silence{Matchers: host, EndsAt: now.Add(time.Hour)}},
+		{name: "Default operator", in: silences.Silence{Matchers: []silences.Matcher{{Name: "__name__", Value: "HeapAlloc"}}, EndsAt: now.Add(time.Hour)}},
+		{name: "Future start", in: silences.Silence{Matchers: host, StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)}},
+		{name: "No matchers", in: silences.Silence{EndsAt: now.Add(time.Hour)}, wantErr: true},
+		{name: "No end", in: silences.Silence{Matchers: host}, wantErr: true},
+		{name: "End before start", in: silences.Silence{Matchers: host, StartsAt: now.Add(time.Hour), EndsAt: now.Add(time.Minute)}, wantErr: true},
+		{name: "End in the past", in: silences.Silence{Matchers: host, StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)}, wantErr: true},
+		{name: "Unknown operator", in: silences.Silence{Matchers: []silences.Matcher{{Name: "host", Op: "~", Value: "a"}}, EndsAt: now.Add(time.Hour)}, wantErr: true},
+		{name: "Broken regex", in: silences.Silence{Matchers: []silences.Matcher{{Name: "host", Op: "=~", Value: "("}}, EndsAt: now.Add(time.Hour)}, wantErr: true},
+		{name: "Empty name", in: silences.Silence{Matchers: []silences.Matcher{{Op: "=", Value: "a"}}, EndsAt: now.Add(time.Hour)}, wantErr: true},
+		{name: "Matches everything", in: silences.Silence{Matchers: []silences.Matcher{{Name: "host", Op: "=~", Value: ".*"}}, EndsAt: now.Add(time.Hour)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSilencer(t)
+			created, err := s.Create(tt.in)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSilence)
+				assert.Empty(t, s.List())
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, created.ID)
+			assert.Equal(t, now, created.CreatedAt)
+			assert.False(t, created.StartsAt.IsZero())
+			assert.NotEmpty(t, created.Matchers[0].Op)
+			assert.Equal(t, []silences.Silence{*created}, s.List())
+		})
+	}
+}
+
+func TestSilencer_Silenced(t *testing.T) {
+	s := newSilencer(t)
+	deploy, err := s.Create(silences.Silence{
+		Matchers: []silences.Matcher{{Name: "__name__", Op: "=~", Value: "Heap.*"}, {Name: "host", Op: "=", Value: "a"}},
+		EndsAt:   now.Add(time.Hour),
+		Comment:  "deploy",
+	})
+	require.NoError(t, err)
+	_, err = s.Create(silences.Silence{
+		Matchers: []silences.Matcher{{Name: "env", Op: "=", Value: "staging"}},
+		StartsAt: now.Add(time.Hour),
+		EndsAt:   now.Add(2 * time.Hour),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		metric string
+		labels map[string]string
+		at     time.Time
+		wantID string
+	}{
+		{name: "Matching alert", metric: "HeapAlloc", labels: map[string]string{"host": "a"}, at: now, wantID: deploy.ID},
+		{name: "Other host", metric: "HeapAlloc", labels: map[string]string{"host": "b"}, at: now},
+		{name: "Other metric", metric: "Alloc", labels: map[string]string{"host": "a"}, at: now},
+		{name: "No labels", metric: "HeapAlloc", at: now},
+		{name: "After the end", metric: "HeapAlloc", labels: map[string]string{"host": "a"}, at: now.Add(time.Hour)},
+		{name: "Pending silence", metric: "Alloc", labels: map[string]string{"env": "staging"}, at: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			silence, ok := s.Silenced(tt.metric, tt.labels, tt.at)
+			if tt.wantID == "" {
+				assert.False(t, ok)
+				assert.Nil(t, silence)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tt.wantID, silence.ID)
+		})
+	}
+}
+
+func TestSilencer_DeleteAndRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "silences.json")
+	originalInstance := Instance
+	defer func() {
+		Instance = originalInstance
+	}()
+	s, err := New(path, nil)
+	require.NoError(t, err)
+	assert.Same(t, s, Instance)
+
+	kept, err := s.Create(silences.Silence{Matchers: []silences.Matcher{{Name: "host", Value: "a"}}, EndsAt: time.Now().Add(time.Hour), CreatedBy: "deploy-bot"})
+	require.NoError(t, err)
+	removed, err := s.Create(silences.Silence{Matchers: []silences.Matcher{{Name: "host", Value: "b"}}, EndsAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Delete(removed.ID))
+	assert.ErrorIs(t, s.Delete(removed.ID), ErrSilenceNotFound)
+
+	// тишины переживают перезапуск
+	restored, err := New(path, nil)
+	require.NoError(t, err)
+	list := restored.List()
+	require.Len(t, list, 1)
+	assert.Equal(t, kept.ID, list[0].ID)
+	assert.Equal(t, "deploy-bot", list[0].CreatedBy)
+	_, ok := restored.Silenced("Alloc", map[string]string{"host": "a"}, time.Now())
+	assert.True(t, ok)
+}
+
+func TestNew_BrokenFile(t *testing.T) {
+	originalInstance := Instance
+	defer func() {
+		Instance = originalInstance
+	}()
+	path := filepath.Join(t.TempDir(), "silences.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+
+	_, err := New(path, nil)
+	assert.Error(t, err)
+	assert.Same(t, originalInstance, Instance)
+}
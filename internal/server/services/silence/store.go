@@ -0,0 +1,83 @@
+package silence
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/silences"
+	"github.com/Maxim-Ba/metriccollector/internal/server/storage/database/postgres"
+)
+
+// silenceStore persists silences between server restarts.
+// Save and Delete also receive the full list so file based stores can rewrite it.
+type silenceStore interface {
+	Load() ([]*silences.Silence, error)
+	Save(s *silences.Silence, all []*silences.Silence) error
+	Delete(id string, all []*silences.Silence) error
+}
+
+type fileStore struct {
+	path string
+}
+
+func (s fileStore) Load() ([]*silences.Silence, error) {
+	var list []*silences.Silence
+	if s.path == "" {
+		return list, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return list, nil
+	}
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s fileStore) Save(_ *silences.Silence, all []*silences.Silence) error {
+	return s.write(all)
+}
+
+func (s fileStore) Delete(_ string, all []*silences.Silence) error {
+	return s.write(all)
+}
+
+func (s fileStore) write(all []*silences.Silence) error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(s.path, data, 0644); err != nil {
+		logger.LogError(err)
+		return err
+	}
+	return nil
+}
+
+type dbStore struct {
+	db *sql.DB
+}
+
+func (s dbStore) Load() ([]*silences.Silence, error) {
+	return postgres.LoadSilencesFromDB(s.db)
+}
+
+func (s dbStore) Save(silence *silences.Silence, _ []*silences.Silence) error {
+	return postgres.SaveSilenceToDB(silence, s.db)
+}
+
+func (s dbStore) Delete(id string, _ []*silences.Silence) error {
+	return postgres.DeleteSilenceFromDB(id, s.db)
+}
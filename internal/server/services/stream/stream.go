@@ -0,0 +1,155 @@
+// Package stream fans accepted metric updates out to live subscribers,
+// e.g. the Server-Sent Events endpoint.
+package stream
+
+import (
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+)
+
+// DefaultBufferSize is the number of events buffered per subscriber.
+const DefaultBufferSize = 256
+
+// Filter selects the updates delivered to a subscriber.
+// Zero values match every metric.
+type Filter struct {
+	Type   string
+	Prefix string
+}
+
+// Matches reports whether m passes the filter.
+func (f Filter) Matches(m *metrics.Metrics) bool {
+	if f.Type != "" && m.MType != f.Type {
+		return false
+	}
+	return strings.HasPrefix(m.ID, f.Prefix)
+}
+
+// Subscription receives updates published to a Broker.
+// Updates that do not fit into the buffer are dropped and counted,
+// so a slow consumer never blocks the publisher.
+type Subscription struct {
+	filter  Filter
+	events  chan metrics.Metrics
+	dropped atomic.Uint64
+}
+
+// Events returns the channel of updates.
+// It is closed when the subscription is cancelled or the broker is closed.
+func (s *Subscription) Events() <-chan metrics.Metrics {
+	return s.events
+}
+
+// Dropped returns the number of updates dropped because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Broker delivers published updates to every matching subscription.
+type Broker struct {
+	mu         sync.RWMutex
+	bufferSize int
+	subs       map[*Subscription]struct{}
+	closed     bool
+}
+
+// Instance is the global broker fed by the metric service.
+var Instance = New(DefaultBufferSize)
+
+// New creates a broker.
+// Parameters:
+//   - bufferSize: updates buffered per subscriber, DefaultBufferSize when not positive
+//
+// Returns:
+//   - *Broker: broker without subscribers
+func New(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Broker{bufferSize: bufferSize, subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe registers a subscription for updates matching f.
+// The caller must cancel it with Unsubscribe.
+func (b *Broker) Subscribe(f Filter) *Subscription {
+	sub := &Subscription{filter: f, events: make(chan metrics.Metrics, b.bufferSize)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe cancels the subscription and closes its channel.
+// Calling it more than once is safe.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Publish delivers updates to the matching subscriptions without blocking.
+func (b *Broker) Publish(updates ...metrics.Metrics) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.subs) == 0 {
+		return
+	}
+	for i := range updates {
+		m := clone(&updates[i])
+		for sub := range b.subs {
+			if !sub.filter.Matches(&m) {
+				continue
+			}
+			select {
+			case sub.events <- m:
+			default:
+				sub.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Broker) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Close cancels every subscription, so that open streams end
+// on shutdown. Later subscriptions are closed immediately.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+// clone copies m so that subscribers do not share pointers with the caller.
+func clone(m *metrics.Metrics) metrics.Metrics {
+	c := *m
+	if m.Value != nil {
+		value := *m.Value
+		c.Value = &value
+	}
+	if m.Delta != nil {
+		delta := *m.Delta
+		c.Delta = &delta
+	}
+	c.Labels = maps.Clone(m.Labels)
+	return c
+}
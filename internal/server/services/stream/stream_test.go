@@ -0,0 +1,97 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Maxim-Ba/metriccollector/internal/constants"
+	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+func gauge(id string, value float64) metrics.Metrics {
+	return metrics.Metrics{ID: id, MType: constants.Gauge, Value: utils.FloatToPointerFloat(value)}
+}
+
+func counter(id string, delta int64) metrics.Metrics {
+	return metrics.Metrics{ID: id, MType: constants.Counter, Delta: utils.FloatToPointerInt(delta)}
+}
+
+func TestFilter_Matches(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		metric metrics.Metrics
+		want   bool
+	}{
+		{name: "Empty filter", filter: Filter{}, metric: gauge("Alloc", 1), want: true},
+		{name: "Type matches", filter: Filter{Type: constants.Gauge}, metric: gauge("Alloc", 1), want: true},
+		{name: "Type differs", filter: Filter{Type: constants.Counter}, metric: gauge("Alloc", 1), want: false},
+		{name: "Prefix matches", filter: Filter{Prefix: "Heap"}, metric: gauge("HeapAlloc", 1), want: true},
+		{name: "Prefix differs", filter: Filter{Prefix: "Heap"}, metric: gauge("Alloc", 1), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(&tt.metric))
+		})
+	}
+}
+
+func TestBroker_Publish(t *testing.T) {
+	b := New(2)
+	all := b.Subscribe(Filter{})
+	counters := b.Subscribe(Filter{Type: constants.Counter})
+	require.Equal(t, 2, b.Subscribers())
+
+	b.Publish(gauge("Alloc", 1), counter("PollCount", 1), gauge("Alloc", 2))
+
+	// буфер на двоих: третье обновление отброшено
+	assert.Equal(t, "Alloc", (<-all.Events()).ID)
+	assert.Equal(t, "PollCount", (<-all.Events()).ID)
+	assert.Empty(t, all.Events())
+	assert.Equal(t, uint64(1), all.Dropped())
+
+	assert.Equal(t, "PollCount", (<-counters.Events()).ID)
+	assert.Empty(t, counters.Events())
+	assert.Zero(t, counters.Dropped())
+
+	b.Unsubscribe(all)
+	b.Unsubscribe(all)
+	_, ok := <-all.Events()
+	assert.False(t, ok)
+	assert.Equal(t, 1, b.Subscribers())
+}
+
+func TestBroker_PublishCopies(t *testing.T) {
+	b := New(1)
+	sub := b.Subscribe(Filter{})
+	m := gauge("Alloc", 1)
+	m.Labels = map[string]string{"host": "a"}
+
+	b.Publish(m)
+	*m.Value = 2
+	m.Labels["host"] = "b"
+
+	got := <-sub.Events()
+	assert.Equal(t, 1.0, *got.Value)
+	assert.Equal(t, "a", got.Labels["host"])
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := New(0)
+	sub := b.Subscribe(Filter{})
+
+	b.Close()
+
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.Zero(t, b.Subscribers())
+	b.Unsubscribe(sub)
+
+	late := b.Subscribe(Filter{})
+	_, ok = <-late.Events()
+	assert.False(t, ok)
+	b.Publish(gauge("Alloc", 1))
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"sync"
 
@@ -59,7 +60,7 @@ func LoadMetricsFromDB(dbInstance *sql.DB) ([]*metrics.Metrics, error) {
 
 	var metricsList []*metrics.Metrics
 	err := utils.RetryWrapper(func() error {
-		rows, err := dbInstance.Query(`SELECT * FROM metrics`)
+		rows, err := dbInstance.Query(`SELECT id, type, value, delta, labels FROM metrics`)
 		if err != nil {
 			return err
 		}
@@ -72,9 +73,15 @@ func LoadMetricsFromDB(dbInstance *sql.DB) ([]*metrics.Metrics, error) {
 
 		for rows.Next() {
 			var m metrics.Metrics
-			if err := rows.Scan(&m.ID, &m.MType, &m.Value, &m.Delta); err != nil {
+			var labels []byte
+			if err := rows.Scan(&m.ID, &m.MType, &m.Value, &m.Delta, &labels); err != nil {
 				return err
 			}
+			if len(labels) > 0 {
+				if err := json.Unmarshal(labels, &m.Labels); err != nil {
+					return err
+				}
+			}
 			metricsList = append(metricsList, &m)
 		}
 
@@ -103,12 +110,19 @@ func SaveMetricsToDB(metricsList *[]metrics.Metrics, dbInstance *sql.DB) error {
 			return err
 		}
 		for _, m := range *metricsList {
+			var labels []byte
+			if len(m.Labels) > 0 {
+				labels, err = json.Marshal(m.Labels)
+				if err != nil {
+					return err
+				}
+			}
 			// все изменения записываются в транзакцию
-			_, err = dbInstance.Exec(`INSERT INTO metrics (id, type, value, delta) 
-				VALUES ($1, $2, $3, $4)
+			_, err = dbInstance.Exec(`INSERT INTO metrics (id, type, value, delta, labels) 
+				VALUES ($1, $2, $3, $4, $5)
 				ON CONFLICT (id) DO UPDATE 
-				SET type = $2, value = $3, delta = $4`,
-				m.ID, m.MType, m.Value, m.Delta)
+				SET type = $2, value = $3, delta = $4, labels = $5`,
+				m.ID, m.MType, m.Value, m.Delta, labels)
 			if err != nil {
 				logger.LogError(err)
 				err = tx.Rollback()
@@ -133,3 +147,78 @@ func SaveMetricsToDB(metricsList *[]metrics.Metrics, dbInstance *sql.DB) error {
 
 	return nil
 }
+
+// RestoreMetricsInDB deletes and upserts metrics in one transaction,
+// so a restore is either written completely or not at all. Unlike
+// SaveMetricsToDB it is not retried: the caller holds the storage lock
+// while it runs, see storage.MemStorage.SwapMetrics, and the restore can
+// be repeated instead.
+// Parameters:
+//   - set: metrics to insert or replace
+//   - removed: metrics to delete, only ID and MType are used
+//   - dbInstance: database connection
+//
+// Returns:
+//   - error: if the transaction fails, nothing is changed then
+func RestoreMetricsInDB(set, removed []metrics.Metrics, dbInstance *sql.DB) error {
+	saveMetricsMutex.Lock()
+	defer saveMetricsMutex.Unlock()
+
+	tx, err := dbInstance.Begin()
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+	if err = restoreInTx(tx, set, removed); err != nil {
+		logger.LogError(err)
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logger.LogError(rbErr)
+		}
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		logger.LogError(err)
+		return err
+	}
+	return nil
+}
+
+func restoreInTx(tx *sql.Tx, set, removed []metrics.Metrics) error {
+	for _, m := range removed {
+		if _, err := tx.Exec(`DELETE FROM metrics WHERE id = $1 AND type = $2`, m.ID, m.MType); err != nil {
+			return err
+		}
+	}
+	for _, m := range set {
+		var labels []byte
+		if len(m.Labels) > 0 {
+			var err error
+			if labels, err = json.Marshal(m.Labels); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`INSERT INTO metrics (id, type, value, delta, labels)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (id) DO UPDATE
+			SET type = $2, value = $3, delta = $4, labels = $5`,
+			m.ID, m.MType, m.Value, m.Delta, labels)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMetricFromDB removes a metric by its name and type.
+func DeleteMetricFromDB(id, mType string, dbInstance *sql.DB) error {
+	err := utils.RetryWrapper(func() error {
+		_, err := dbInstance.Exec(`DELETE FROM metrics WHERE id = $1 AND type = $2`, id, mType)
+		return err
+	}, []error{sql.ErrConnDone})
+
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -80,7 +81,7 @@ func TestSaveMetricsToDB(t *testing.T) {
 
 		for _, m := range testMetrics {
 			mock.ExpectExec(`INSERT INTO metrics`).
-				WithArgs(m.ID, m.MType, m.Value, m.Delta).
+				WithArgs(m.ID, m.MType, m.Value, m.Delta, sqlmock.AnyArg()).
 				WillReturnResult(sqlmock.NewResult(1, 1))
 		}
 
@@ -104,7 +105,7 @@ func TestSaveMetricsToDB(t *testing.T) {
 		mock.ExpectBegin()
 		for _, m := range testMetrics {
 			mock.ExpectExec(`INSERT INTO metrics`).
-				WithArgs(m.ID, m.MType, m.Value, m.Delta).
+				WithArgs(m.ID, m.MType, m.Value, m.Delta, sqlmock.AnyArg()).
 				WillReturnResult(sqlmock.NewResult(1, 1))
 		}
 		mock.ExpectCommit().WillReturnError(sql.ErrConnDone)
@@ -115,6 +116,35 @@ func TestSaveMetricsToDB(t *testing.T) {
 	})
 }
 
+func TestRestoreMetricsInDB(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	set := []metrics.Metrics{{ID: "Alloc", MType: "gauge", Value: utils.FloatToPointerFloat(1.5)}}
+	removed := []metrics.Metrics{{ID: "PollCount", MType: "counter"}}
+
+	t.Run("successful restore", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM metrics`).WithArgs("PollCount", "counter").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO metrics`).WithArgs("Alloc", "gauge", set[0].Value, set[0].Delta, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, RestoreMetricsInDB(set, removed, db))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("failed statement rolls back", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM metrics`).WithArgs("PollCount", "counter").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO metrics`).WillReturnError(errors.New("constraint violation"))
+		mock.ExpectRollback()
+
+		assert.Error(t, RestoreMetricsInDB(set, removed, db))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestLoadMetricsFromDB(t *testing.T) {
 	expectedMetrics := []*metrics.Metrics{
 		{ID: "test1", MType: "gauge", Value: utils.FloatToPointerFloat(1.23)},
@@ -128,11 +158,11 @@ func TestLoadMetricsFromDB(t *testing.T) {
 		}
 		defer db.Close()
 
-		rows := sqlmock.NewRows([]string{"id", "type", "value", "delta"}).
-			AddRow("test1", "gauge", 1.23, nil).
-			AddRow("test2", "counter", nil, 42)
+		rows := sqlmock.NewRows([]string{"id", "type", "value", "delta", "labels"}).
+			AddRow("test1", "gauge", 1.23, nil, nil).
+			AddRow("test2", "counter", nil, 42, nil)
 
-		mock.ExpectQuery(`SELECT \* FROM metrics`).WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT id, type, value, delta, labels FROM metrics`).WillReturnRows(rows)
 
 		metrics, err := LoadMetricsFromDB(db)
 		assert.NoError(t, err)
@@ -154,10 +184,10 @@ func TestLoadMetricsFromDB(t *testing.T) {
 		}
 		defer db.Close()
 
-		rows := sqlmock.NewRows([]string{"id", "type", "value", "delta"}).
-			AddRow("test1", "gauge", 1.23, "not_an_int")
+		rows := sqlmock.NewRows([]string{"id", "type", "value", "delta", "labels"}).
+			AddRow("test1", "gauge", 1.23, "not_an_int", nil)
 
-		mock.ExpectQuery(`SELECT \* FROM metrics`).WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT id, type, value, delta, labels FROM metrics`).WillReturnRows(rows)
 
 		metrics, err := LoadMetricsFromDB(db)
 		assert.Error(t, err)
@@ -172,11 +202,11 @@ func TestLoadMetricsFromDB(t *testing.T) {
 		}
 		defer db.Close()
 
-		rows := sqlmock.NewRows([]string{"id", "type", "value", "delta"}).
-			AddRow("test1", "gauge", 1.23, nil).
+		rows := sqlmock.NewRows([]string{"id", "type", "value", "delta", "labels"}).
+			AddRow("test1", "gauge", 1.23, nil, nil).
 			RowError(0, sql.ErrNoRows)
 
-		mock.ExpectQuery(`SELECT \* FROM metrics`).WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT id, type, value, delta, labels FROM metrics`).WillReturnRows(rows)
 
 		metrics, err := LoadMetricsFromDB(db)
 		assert.Error(t, err)
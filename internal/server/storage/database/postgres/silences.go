@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/silences"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+// LoadSilencesFromDB reads all silences from the silences table.
+func LoadSilencesFromDB(dbInstance *sql.DB) ([]*silences.Silence, error) {
+	logger.LogInfo("LoadSilencesFromDB")
+
+	if dbInstance == nil {
+		err := errors.New("database not initialized")
+		logger.LogError(err)
+		return nil, err
+	}
+
+	var list []*silences.Silence
+	err := utils.RetryWrapper(func() error {
+		list = nil
+		rows, err := dbInstance.Query(`SELECT id, matchers, starts_at, ends_at, created_by, comment, created_at FROM silences`)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logger.LogError(err)
+			}
+		}()
+
+		for rows.Next() {
+			var s silences.Silence
+			var matchers []byte
+			if err := rows.Scan(&s.ID, &matchers, &s.StartsAt, &s.EndsAt, &s.CreatedBy, &s.Comment, &s.CreatedAt); err != nil {
+				return err
+			}
+			if err := json.Unmarshal(matchers, &s.Matchers); err != nil {
+				return err
+			}
+			list = append(list, &s)
+		}
+
+		return rows.Err()
+	}, []error{sql.ErrConnDone})
+
+	if err != nil {
+		logger.LogError(err)
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// SaveSilenceToDB inserts a silence or replaces the stored one with the same ID.
+func SaveSilenceToDB(s *silences.Silence, dbInstance *sql.DB) error {
+	matchers, err := json.Marshal(s.Matchers)
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+	err = utils.RetryWrapper(func() error {
+		_, err := dbInstance.Exec(`INSERT INTO silences (id, matchers, starts_at, ends_at, created_by, comment, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (id) DO UPDATE
+			SET matchers = $2, starts_at = $3, ends_at = $4, created_by = $5, comment = $6`,
+			s.ID, matchers, s.StartsAt, s.EndsAt, s.CreatedBy, s.Comment, s.CreatedAt)
+		return err
+	}, []error{sql.ErrConnDone})
+
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteSilenceFromDB removes a silence by its identifier.
+func DeleteSilenceFromDB(id string, dbInstance *sql.DB) error {
+	err := utils.RetryWrapper(func() error {
+		_, err := dbInstance.Exec(`DELETE FROM silences WHERE id = $1`, id)
+		return err
+	}, []error{sql.ErrConnDone})
+
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+	return nil
+}
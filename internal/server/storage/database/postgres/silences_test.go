@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Maxim-Ba/metriccollector/internal/models/silences"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSilencesDB(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	silence := &silences.Silence{
+		ID:        "id1",
+		Matchers:  []silences.Matcher{{Name: "host", Op: "=", Value: "a"}},
+		StartsAt:  start,
+		EndsAt:    start.Add(time.Hour),
+		CreatedBy: "deploy-bot",
+		Comment:   "deploy",
+		CreatedAt: start,
+	}
+	matchers := []byte(`[{"name":"host","op":"=","value":"a"}]`)
+
+	t.Run("save silence", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO silences`).
+			WithArgs(silence.ID, matchers, silence.StartsAt, silence.EndsAt, silence.CreatedBy, silence.Comment, silence.CreatedAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		assert.NoError(t, SaveSilenceToDB(silence, db))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("load silences", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "matchers", "starts_at", "ends_at", "created_by", "comment", "created_at"}).
+			AddRow(silence.ID, matchers, silence.StartsAt, silence.EndsAt, silence.CreatedBy, silence.Comment, silence.CreatedAt)
+		mock.ExpectQuery(`SELECT id, matchers, starts_at, ends_at, created_by, comment, created_at FROM silences`).WillReturnRows(rows)
+
+		loaded, err := LoadSilencesFromDB(db)
+		assert.NoError(t, err)
+		assert.Equal(t, []*silences.Silence{silence}, loaded)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delete silence", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM silences`).
+			WithArgs(silence.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, DeleteSilenceFromDB(silence.ID, db))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database not initialized", func(t *testing.T) {
+		loaded, err := LoadSilencesFromDB(nil)
+		assert.Error(t, err)
+		assert.Nil(t, loaded)
+	})
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/Maxim-Ba/metriccollector/internal/logger"
+	"github.com/Maxim-Ba/metriccollector/internal/models/tokens"
+	"github.com/Maxim-Ba/metriccollector/pkg/utils"
+)
+
+// LoadTokensFromDB reads all API tokens from the api_tokens table.
+func LoadTokensFromDB(dbInstance *sql.DB) ([]*tokens.Token, error) {
+	logger.LogInfo("LoadTokensFromDB")
+
+	if dbInstance == nil {
+		err := errors.New("database not initialized")
+		logger.LogError(err)
+		return nil, err
+	}
+
+	var tokenList []*tokens.Token
+	err := utils.RetryWrapper(func() error {
+		tokenList = nil
+		rows, err := dbInstance.Query(`SELECT id, name, hash, scopes, created_at FROM api_tokens`)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err := rows.Close()
+			if err != nil {
+				logger.LogError(err)
+			}
+		}()
+
+		for rows.Next() {
+			var t tokens.Token
+			var scopes string
+			if err := rows.Scan(&t.ID, &t.Name, &t.Hash, &scopes, &t.CreatedAt); err != nil {
+				return err
+			}
+			t.Scopes = strings.Split(scopes, ",")
+			tokenList = append(tokenList, &t)
+		}
+
+		return rows.Err()
+	}, []error{sql.ErrConnDone})
+
+	if err != nil {
+		logger.LogError(err)
+		return nil, err
+	}
+
+	return tokenList, nil
+}
+
+// SaveTokenToDB inserts a new API token.
+func SaveTokenToDB(t *tokens.Token, dbInstance *sql.DB) error {
+	err := utils.RetryWrapper(func() error {
+		_, err := dbInstance.Exec(`INSERT INTO api_tokens (id, name, hash, scopes, created_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			t.ID, t.Name, t.Hash, strings.Join(t.Scopes, ","), t.CreatedAt)
+		return err
+	}, []error{sql.ErrConnDone})
+
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteTokenFromDB removes an API token by its identifier.
+func DeleteTokenFromDB(id string, dbInstance *sql.DB) error {
+	err := utils.RetryWrapper(func() error {
+		_, err := dbInstance.Exec(`DELETE FROM api_tokens WHERE id = $1`, id)
+		return err
+	}, []error{sql.ErrConnDone})
+
+	if err != nil {
+		logger.LogError(err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Maxim-Ba/metriccollector/internal/models/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokensDB(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := &tokens.Token{ID: "id1", Name: "grafana", Hash: "hash", Scopes: []string{"read", "write"}, CreatedAt: createdAt}
+
+	t.Run("save token", func(t *testing.T) {
+		mock.ExpectExec(`INSERT INTO api_tokens`).
+			WithArgs(token.ID, token.Name, token.Hash, "read,write", token.CreatedAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		assert.NoError(t, SaveTokenToDB(token, db))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("load tokens", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "name", "hash", "scopes", "created_at"}).
+			AddRow(token.ID, token.Name, token.Hash, "read,write", createdAt)
+		mock.ExpectQuery(`SELECT id, name, hash, scopes, created_at FROM api_tokens`).WillReturnRows(rows)
+
+		loaded, err := LoadTokensFromDB(db)
+		assert.NoError(t, err)
+		assert.Equal(t, []*tokens.Token{token}, loaded)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delete token", func(t *testing.T) {
+		mock.ExpectExec(`DELETE FROM api_tokens`).
+			WithArgs(token.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, DeleteTokenFromDB(token.ID, db))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database not initialized", func(t *testing.T) {
+		loaded, err := LoadTokensFromDB(nil)
+		assert.Error(t, err)
+		assert.Nil(t, loaded)
+	})
+}
@@ -79,20 +79,33 @@ func saveLoop() {
 	for {
 
 		time.Sleep(time.Duration(saveInterval) * time.Second)
-		paramsForGetAllMetrics := []*metrics.MetricDTOParams{}
-		metricList, err := StorageInstance.GetMetrics(&paramsForGetAllMetrics)
-		if err != nil {
+		if err := Persist(); err != nil {
 			logger.LogError(err)
 		}
-		if databaseDSN != "" {
-			err = postgres.SaveMetricsToDB(metricList, db)
-		} else {
-			err = saveMetricsToFile(localStoragePath, metricList)
-		}
-		if err != nil {
-			logger.LogError(err)
+	}
+}
+
+// Persist writes the current metrics to the configured backend right away,
+// to the database when a DSN is set and to the storage file otherwise.
+// It is a no-op when neither is configured.
+// Returns:
+//   - error: if the metrics cannot be written
+func Persist() error {
+	if databaseDSN == "" && localStoragePath == "" {
+		return nil
+	}
+	paramsForGetAllMetrics := []*metrics.MetricDTOParams{}
+	metricList, err := StorageInstance.GetMetrics(&paramsForGetAllMetrics)
+	if err != nil {
+		return err
+	}
+	if databaseDSN != "" {
+		if db == nil {
+			return ErrDatabaseConnection
 		}
+		return postgres.SaveMetricsToDB(metricList, db)
 	}
+	return saveMetricsToFile(localStoragePath, metricList)
 }
 
 func WithSyncLocalStorage(next http.HandlerFunc) http.HandlerFunc {
@@ -169,3 +169,30 @@ func TestWithSyncLocalStorage(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestPersist(t *testing.T) {
+	origPath, origDSN := localStoragePath, databaseDSN
+	defer func() {
+		localStoragePath, databaseDSN = origPath, origDSN
+	}()
+	StorageInstance.ClearAll()
+	defer StorageInstance.ClearAll()
+	require.NoError(t, StorageInstance.SaveMetric(&metrics.Metrics{ID: "Alloc", MType: "gauge", Value: utils.FloatToPointerFloat(1.5)}))
+
+	t.Run("not configured", func(t *testing.T) {
+		localStoragePath, databaseDSN = "", ""
+		assert.NoError(t, Persist())
+	})
+	t.Run("file", func(t *testing.T) {
+		localStoragePath, databaseDSN = t.TempDir()+"/metrics.json", ""
+		require.NoError(t, Persist())
+		saved, err := loadMetricsFromFile(localStoragePath)
+		require.NoError(t, err)
+		require.Len(t, saved, 1)
+		assert.Equal(t, "Alloc", saved[0].ID)
+	})
+	t.Run("database is not connected", func(t *testing.T) {
+		localStoragePath, databaseDSN = "", "postgres://localhost/metrics"
+		assert.ErrorIs(t, Persist(), ErrDatabaseConnection)
+	})
+}
@@ -3,6 +3,9 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"maps"
+	"slices"
+	"sync"
 
 	"github.com/Maxim-Ba/metriccollector/internal/constants"
 	"github.com/Maxim-Ba/metriccollector/internal/logger"
@@ -13,23 +16,29 @@ import (
 )
 
 // MemStorage represents an in-memory storage implementation for metrics.
-// It maintains two separate collections for gauge and counter metrics.
+// It maintains two separate collections for gauge and counter metrics
+// and the labels of both, keyed by labelsKey.
 type MemStorage struct {
 	collectionGauge   map[string]float64
 	collectionCounter map[string]int64
+	labels            map[string]map[string]string
 }
 
 // StorageInstance is the global instance of MemStorage initialized with empty collections.
 var StorageInstance = MemStorage{
 	collectionGauge:   map[string]float64{},
 	collectionCounter: map[string]int64{},
+	labels:            map[string]map[string]string{},
 }
 
+// mu guards the collections of StorageInstance.
+var mu sync.RWMutex
+
 var db *sql.DB
 
 // New initializes the storage system based on configuration parameters.
 // It handles:
-// - Database connection setup if DSN is provided
+// - Database connection setup if DSN is provided, regardless of Restore
 // - Metric restoration from file or database
 // - Background saving routine
 // Parameters:
@@ -45,13 +54,17 @@ func New(cfg config.Parameters) (*MemStorage, error) {
 	localStoragePath = cfg.StoragePath
 	databaseDSN = cfg.DatabaseDSN
 	var err error
+	// база открывается по одному DSN: в ней же хранятся токены и тишины,
+	// а Restore решает только, загружать ли сохранённые метрики
+	if cfg.DatabaseDSN != "" {
+		db, err = postgres.New(cfg.DatabaseDSN, cfg.MigrationsPath)
+		if err != nil {
+			logger.LogError(err)
+			return nil, err
+		}
+	}
 	if cfg.Restore {
-		if cfg.DatabaseDSN != "" {
-			db, err = postgres.New(cfg.DatabaseDSN, cfg.MigrationsPath)
-			if err != nil {
-				logger.LogError(err)
-				return nil, err
-			}
+		if db != nil {
 			initStoreValues, err = postgres.LoadMetricsFromDB(db)
 		} else {
 			initStoreValues, err = loadMetricsFromFile(localStoragePath)
@@ -86,6 +99,12 @@ func Close() {
 	}
 }
 
+// DB returns the database connection opened by New or nil
+// when metrics are kept in a file.
+func DB() *sql.DB {
+	return db
+}
+
 // SaveMetric persists a single metric to memory storage.
 // For gauge metrics, it overwrites the existing value.
 // For counter metrics, it increments the existing value.
@@ -95,6 +114,15 @@ func Close() {
 // Returns:
 //   - error: if metric type is invalid
 func (s MemStorage) SaveMetric(m *metrics.Metrics) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return saveMetric(m)
+}
+
+func saveMetric(m *metrics.Metrics) error {
+	if m.Labels != nil && (m.MType == constants.Gauge || m.MType == constants.Counter) {
+		setLabels(m.MType, m.ID, m.Labels)
+	}
 	if m.MType == constants.Gauge {
 		StorageInstance.collectionGauge[m.ID] = *m.Value
 		return nil
@@ -119,8 +147,10 @@ func (s MemStorage) SaveMetric(m *metrics.Metrics) error {
 // Returns:
 //   - error: if any metric fails to save
 func (s MemStorage) SaveMetrics(metricsSlice *[]metrics.Metrics) error {
+	mu.Lock()
+	defer mu.Unlock()
 	for _, m := range *metricsSlice {
-		err := StorageInstance.SaveMetric(&m)
+		err := saveMetric(&m)
 		if err != nil {
 			logger.LogError(err)
 			return err
@@ -141,6 +171,8 @@ func (s MemStorage) SaveMetrics(metricsSlice *[]metrics.Metrics) error {
 //   - *[]metrics.Metrics: Retrieved metrics
 //   - error: if no metrics found (with specific params)
 func (s MemStorage) GetMetrics(metricsParams *[]*metrics.MetricDTOParams) (*[]metrics.Metrics, error) {
+	mu.RLock()
+	defer mu.RUnlock()
 	metricsNames := make([]string, len(*metricsParams))
 	metricsTypes := make([]string, len(*metricsParams))
 	for i, m := range *metricsParams {
@@ -150,12 +182,7 @@ func (s MemStorage) GetMetrics(metricsParams *[]*metrics.MetricDTOParams) (*[]me
 	var metricsSlice []metrics.Metrics
 	// Get all metrics
 	if len(metricsNames) == 0 {
-		for metric, value := range StorageInstance.collectionGauge {
-			metricsSlice = append(metricsSlice, metrics.Metrics{MType: constants.Gauge, ID: metric, Value: utils.FloatToPointerFloat(value)})
-		}
-		for metric, value := range StorageInstance.collectionCounter {
-			metricsSlice = append(metricsSlice, metrics.Metrics{MType: constants.Counter, ID: metric, Delta: utils.FloatToPointerInt(value)})
-		}
+		metricsSlice = allMetrics()
 		return &metricsSlice, nil
 	}
 
@@ -163,11 +190,11 @@ func (s MemStorage) GetMetrics(metricsParams *[]*metrics.MetricDTOParams) (*[]me
 	for _, metric := range *metricsParams {
 		if metric.MetricType == constants.Gauge {
 			if value, ok := StorageInstance.collectionGauge[metric.MetricsName]; ok {
-				metricsSlice = append(metricsSlice, metrics.Metrics{MType: constants.Gauge, ID: metric.MetricsName, Value: utils.FloatToPointerFloat(value)})
+				metricsSlice = append(metricsSlice, metrics.Metrics{MType: constants.Gauge, ID: metric.MetricsName, Value: utils.FloatToPointerFloat(value), Labels: getLabels(constants.Gauge, metric.MetricsName)})
 			}
 		} else if metric.MetricType == constants.Counter {
 			if value, ok := StorageInstance.collectionCounter[metric.MetricsName]; ok {
-				metricsSlice = append(metricsSlice, metrics.Metrics{MType: constants.Counter, ID: metric.MetricsName, Delta: utils.FloatToPointerInt(value)})
+				metricsSlice = append(metricsSlice, metrics.Metrics{MType: constants.Counter, ID: metric.MetricsName, Delta: utils.FloatToPointerInt(value), Labels: getLabels(constants.Counter, metric.MetricsName)})
 			}
 		}
 	}
@@ -177,6 +204,172 @@ func (s MemStorage) GetMetrics(metricsParams *[]*metrics.MetricDTOParams) (*[]me
 	return &metricsSlice, nil
 }
 
+// allMetrics copies every stored metric, mu must be held.
+func allMetrics() []metrics.Metrics {
+	var metricsSlice []metrics.Metrics
+	for metric, value := range StorageInstance.collectionGauge {
+		metricsSlice = append(metricsSlice, metrics.Metrics{MType: constants.Gauge, ID: metric, Value: utils.FloatToPointerFloat(value), Labels: getLabels(constants.Gauge, metric)})
+	}
+	for metric, value := range StorageInstance.collectionCounter {
+		metricsSlice = append(metricsSlice, metrics.Metrics{MType: constants.Counter, ID: metric, Delta: utils.FloatToPointerInt(value), Labels: getLabels(constants.Counter, metric)})
+	}
+	return metricsSlice
+}
+
+// SwapMetrics applies a restore in one step. stage is called with every
+// stored metric while the storage is locked and returns the metrics to set,
+// as SetMetric would, and to delete. They are applied before the lock is
+// released, so no write lands between the comparison and the change.
+// With a database the change is written in one transaction first,
+// and the memory is left untouched if it fails. The lock is held during
+// the transaction, so metric writes and reads wait for it; it runs once,
+// without the retries of the regular database writes.
+// Parameters:
+//   - stage: computes the change from the current metrics
+//
+// Returns:
+//   - error: the error of stage, ErrUnknownMetricType or a database error
+func (s MemStorage) SwapMetrics(stage func(current []metrics.Metrics) (set, removed []metrics.Metrics, err error)) error {
+	mu.Lock()
+	defer mu.Unlock()
+	set, removed, err := stage(allMetrics())
+	if err != nil {
+		return err
+	}
+	for _, m := range set {
+		if m.MType != constants.Gauge && m.MType != constants.Counter {
+			return ErrUnknownMetricType
+		}
+	}
+	if db != nil {
+		if err = postgres.RestoreMetricsInDB(set, removed, db); err != nil {
+			return err
+		}
+	}
+	for _, m := range removed {
+		if m.MType == constants.Gauge {
+			delete(StorageInstance.collectionGauge, m.ID)
+		} else {
+			delete(StorageInstance.collectionCounter, m.ID)
+		}
+		delete(StorageInstance.labels, labelsKey(m.MType, m.ID))
+	}
+	for i := range set {
+		if set[i].MType == constants.Counter {
+			delete(StorageInstance.collectionCounter, set[i].ID)
+		}
+		if err = saveMetric(&set[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMetric stores the metric as is, unlike SaveMetric a counter
+// is replaced rather than incremented.
+// Parameters:
+//   - m: Metric to store
+//
+// Returns:
+//   - error: if metric type is invalid
+func (s MemStorage) SetMetric(m *metrics.Metrics) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if m.MType == constants.Counter {
+		delete(StorageInstance.collectionCounter, m.ID)
+	}
+	return saveMetric(m)
+}
+
+// DeleteMetric removes a metric and its labels from storage
+// and from the database when one is configured.
+// Parameters:
+//   - mType: Metric type
+//   - name: Metric name
+//
+// Returns:
+//   - error: ErrUnknownMetricName if there is no such metric
+func (s MemStorage) DeleteMetric(mType, name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	switch mType {
+	case constants.Gauge:
+		if _, ok := StorageInstance.collectionGauge[name]; !ok {
+			return ErrUnknownMetricName
+		}
+		delete(StorageInstance.collectionGauge, name)
+	case constants.Counter:
+		if _, ok := StorageInstance.collectionCounter[name]; !ok {
+			return ErrUnknownMetricName
+		}
+		delete(StorageInstance.collectionCounter, name)
+	default:
+		return ErrUnknownMetricType
+	}
+	delete(StorageInstance.labels, labelsKey(mType, name))
+	if db != nil {
+		return postgres.DeleteMetricFromDB(name, mType, db)
+	}
+	return nil
+}
+
+// EachMetric calls visit for every stored metric ordered by type and name,
+// stopping at the first error returned by visit.
+// Only the names are copied up front and values are read one at a time,
+// so a slow visitor, e.g. a download, does not hold the storage lock.
+// Metrics deleted during the walk are skipped.
+func (s MemStorage) EachMetric(visit func(m *metrics.Metrics) error) error {
+	mu.RLock()
+	counters := slices.Sorted(maps.Keys(StorageInstance.collectionCounter))
+	gauges := slices.Sorted(maps.Keys(StorageInstance.collectionGauge))
+	mu.RUnlock()
+
+	for _, name := range counters {
+		mu.RLock()
+		value, ok := StorageInstance.collectionCounter[name]
+		m := metrics.Metrics{MType: constants.Counter, ID: name, Delta: &value, Labels: getLabels(constants.Counter, name)}
+		mu.RUnlock()
+		if !ok {
+			continue
+		}
+		if err := visit(&m); err != nil {
+			return err
+		}
+	}
+	for _, name := range gauges {
+		mu.RLock()
+		value, ok := StorageInstance.collectionGauge[name]
+		m := metrics.Metrics{MType: constants.Gauge, ID: name, Value: &value, Labels: getLabels(constants.Gauge, name)}
+		mu.RUnlock()
+		if !ok {
+			continue
+		}
+		if err := visit(&m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func labelsKey(mType, name string) string {
+	return mType + "/" + name
+}
+
+func setLabels(mType, name string, labels map[string]string) {
+	if StorageInstance.labels == nil {
+		StorageInstance.labels = map[string]map[string]string{}
+	}
+	if len(labels) == 0 {
+		delete(StorageInstance.labels, labelsKey(mType, name))
+		return
+	}
+	StorageInstance.labels[labelsKey(mType, name)] = maps.Clone(labels)
+}
+
+func getLabels(mType, name string) map[string]string {
+	return maps.Clone(StorageInstance.labels[labelsKey(mType, name)])
+}
+
 // Ping verifies the database connection is alive.
 // Parameters:
 //   - ctx: Context for operation cancellation
@@ -194,19 +387,28 @@ func (s MemStorage) Ping(ctx context.Context) error {
 // Parameters:
 //   - name: Name of the gauge metric to remove
 func (s *MemStorage) ClearGaugeMetric(name string) {
+	mu.Lock()
+	defer mu.Unlock()
 	delete(s.collectionGauge, name)
+	delete(s.labels, labelsKey(constants.Gauge, name))
 }
 
 // ClearCounterMetric removes a specific counter metric from storage.
 // Parameters:
 //   - name: Name of the counter metric to remove
 func (s *MemStorage) ClearCounterMetric(name string) {
+	mu.Lock()
+	defer mu.Unlock()
 	delete(s.collectionCounter, name)
+	delete(s.labels, labelsKey(constants.Counter, name))
 }
 
 // ClearAll resets the storage by removing all metrics.
 // Reinitializes both gauge and counter collections.
 func (s *MemStorage) ClearAll() {
+	mu.Lock()
+	defer mu.Unlock()
 	s.collectionGauge = make(map[string]float64)
 	s.collectionCounter = make(map[string]int64)
+	s.labels = make(map[string]map[string]string)
 }
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/Maxim-Ba/metriccollector/internal/constants"
@@ -582,3 +583,123 @@ func TestClearAll(t *testing.T) {
 		})
 	}
 }
+
+func TestSetAndDeleteMetric(t *testing.T) {
+	s, err := New(config.Parameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ClearAll()
+
+	counter := metrics.Metrics{ID: "c", MType: constants.Counter, Delta: utils.FloatToPointerInt(5), Labels: map[string]string{"host": "a"}}
+	if err = s.SaveMetric(&counter); err != nil {
+		t.Fatal(err)
+	}
+	counter.Delta = utils.FloatToPointerInt(2)
+	counter.Labels = nil
+	if err = s.SetMetric(&counter); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.collectionCounter["c"]; got != 2 {
+		t.Errorf("SetMetric() counter = %d, want 2", got)
+	}
+	// без меток в запросе сохранённые метки не сбрасываются
+	stored, err := s.GetMetrics(&[]*metrics.MetricDTOParams{{MetricsName: "c", MetricType: constants.Counter}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*stored) != 1 || (*stored)[0].Labels["host"] != "a" {
+		t.Errorf("GetMetrics() labels = %v, want host=a", *stored)
+	}
+
+	if err = s.DeleteMetric(constants.Counter, "c"); err != nil {
+		t.Errorf("DeleteMetric() error = %v", err)
+	}
+	if _, ok := s.collectionCounter["c"]; ok {
+		t.Error("DeleteMetric() metric is still stored")
+	}
+	if _, ok := s.labels[labelsKey(constants.Counter, "c")]; ok {
+		t.Error("DeleteMetric() labels are still stored")
+	}
+	if err = s.DeleteMetric(constants.Counter, "c"); err != ErrUnknownMetricName {
+		t.Errorf("DeleteMetric() error = %v, want %v", err, ErrUnknownMetricName)
+	}
+	if err = s.DeleteMetric("histogram", "c"); err != ErrUnknownMetricType {
+		t.Errorf("DeleteMetric() error = %v, want %v", err, ErrUnknownMetricType)
+	}
+}
+
+func TestSwapMetrics(t *testing.T) {
+	s, err := New(config.Parameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ClearAll()
+	s.collectionGauge["a"] = 1
+	s.collectionCounter["c"] = 5
+	s.labels[labelsKey(constants.Gauge, "a")] = map[string]string{"host": "x"}
+
+	failed := errors.New("stage failed")
+	err = s.SwapMetrics(func([]metrics.Metrics) ([]metrics.Metrics, []metrics.Metrics, error) {
+		return nil, []metrics.Metrics{{ID: "a", MType: constants.Gauge}}, failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("SwapMetrics() error = %v, want %v", err, failed)
+	}
+	if _, ok := s.collectionGauge["a"]; !ok {
+		t.Error("SwapMetrics() applied a failed stage")
+	}
+
+	err = s.SwapMetrics(func(current []metrics.Metrics) ([]metrics.Metrics, []metrics.Metrics, error) {
+		if len(current) != 2 {
+			t.Errorf("SwapMetrics() staged %v, want 2 metrics", current)
+		}
+		set := []metrics.Metrics{{ID: "c", MType: constants.Counter, Delta: utils.FloatToPointerInt(2), Labels: map[string]string{}}}
+		return set, []metrics.Metrics{{ID: "a", MType: constants.Gauge}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.collectionGauge["a"]; ok {
+		t.Error("SwapMetrics() gauge is still stored")
+	}
+	if _, ok := s.labels[labelsKey(constants.Gauge, "a")]; ok {
+		t.Error("SwapMetrics() labels are still stored")
+	}
+	// счётчик заменяется, а не увеличивается
+	if got := s.collectionCounter["c"]; got != 2 {
+		t.Errorf("SwapMetrics() counter = %d, want 2", got)
+	}
+	s.ClearAll()
+}
+
+func TestEachMetric(t *testing.T) {
+	s, err := New(config.Parameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ClearAll()
+	s.collectionGauge["b"] = 2
+	s.collectionGauge["a"] = 1
+	s.collectionCounter["c"] = 3
+	s.labels[labelsKey(constants.Gauge, "a")] = map[string]string{"host": "x"}
+
+	var got []string
+	err = s.EachMetric(func(m *metrics.Metrics) error {
+		got = append(got, m.MType+"/"+m.ID)
+		if m.ID == "a" && m.Labels["host"] != "x" {
+			t.Errorf("EachMetric() labels = %v", m.Labels)
+		}
+		// удаление во время обхода не ломает его
+		delete(s.collectionGauge, "b")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"counter/c", "gauge/a"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("EachMetric() visited %v, want %v", got, want)
+	}
+	s.ClearAll()
+}
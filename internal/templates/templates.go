@@ -1,34 +1,140 @@
 package templates
 
 import (
+	"embed"
 	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
 
-	"github.com/Maxim-Ba/metriccollector/internal/constants"
-	"github.com/Maxim-Ba/metriccollector/internal/models/metrics"
+// Size of the sparkline SVG in pixels.
+const (
+	SparklineWidth  = 120
+	SparklineHeight = 24
 )
 
-func GetAllMetricsHTMLPage(m *[]metrics.Metrics) string {
-	const titlepageStart = `
-	<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <title>Document</title>
-</head>
-<body>
-<h1>Метрики</h1>`
-
-	const titlepageEnd = `</body>
-</html>
-	`
-	var body string
-	for _, metric := range *m {
-		if metric.MType == constants.Gauge {
-
-			body += fmt.Sprintf("Тип: %s, Метрика: %s Значение %f <br/>", metric.MType, metric.ID, *metric.Value)
-		} else {
-			body += fmt.Sprintf("Тип: %s, Метрика: %s Значение %d <br/>", metric.MType, metric.ID, int64(*metric.Delta))
+//go:embed dashboard.html
+var files embed.FS
+
+var dashboardTemplate = template.Must(template.ParseFS(files, "dashboard.html"))
+
+// DashboardRow is one metric on the dashboard.
+// Fields:
+//   - ID, Type: metric name and type
+//   - Value: formatted current value
+//   - Updated: time since the last update, e.g. "5s ago", or "—" when unknown
+//   - UpdatedAt: time of the last update in RFC 3339, empty when unknown
+//   - Sparkline: SVG polyline points of the recent values, empty without history
+type DashboardRow struct {
+	ID        string
+	Type      string
+	Value     string
+	Updated   string
+	UpdatedAt string
+	Sparkline string
+}
+
+// Dashboard is the data of the metrics page.
+// Type, Name and Sort are the applied filters and order, Total is the
+// number of metrics before filtering. The page reloads every Refresh
+// seconds, 0 disables reloading.
+type Dashboard struct {
+	Rows    []DashboardRow
+	Total   int
+	Type    string
+	Name    string
+	Sort    string
+	Refresh int
+}
+
+// SortURL returns the query of the page ordered by field,
+// the order is reversed when the page is already ordered by it.
+func (d *Dashboard) SortURL(field string) string {
+	order := field
+	if d.Sort == field || (d.Sort == "" && field == "name") {
+		order = "-" + field
+	}
+	return "?" + d.query(order).Encode()
+}
+
+// SortMark returns an arrow for the column the page is ordered by.
+func (d *Dashboard) SortMark(field string) string {
+	switch d.Sort {
+	case field:
+		return "▲"
+	case "-" + field:
+		return "▼"
+	case "":
+		if field == "name" {
+			return "▲"
+		}
+	}
+	return ""
+}
+
+func (d *Dashboard) query(order string) url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{"type": d.Type, "name": d.Name, "sort": order, "refresh": strconv.Itoa(d.Refresh)} {
+		if value != "" {
+			values.Set(key, value)
 		}
 	}
-	return titlepageStart + body + titlepageEnd
+	return values
+}
+
+// RenderDashboard writes the metrics page.
+// Metric names are escaped by html/template.
+func RenderDashboard(w io.Writer, d *Dashboard) error {
+	return dashboardTemplate.Execute(w, d)
+}
+
+// Sparkline scales values into SVG polyline points of
+// SparklineWidth x SparklineHeight. A single value is drawn as a flat line.
+func Sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	if len(values) == 1 {
+		values = []float64{values[0], values[0]}
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = min(lo, v)
+		hi = max(hi, v)
+	}
+	step := float64(SparklineWidth) / float64(len(values)-1)
+	points := make([]string, len(values))
+	for i, v := range values {
+		// без разброса линия рисуется посередине
+		y := float64(SparklineHeight) / 2
+		if hi > lo {
+			y = float64(SparklineHeight) - (v-lo)/(hi-lo)*float64(SparklineHeight)
+		}
+		points[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*step, y)
+	}
+	return strings.Join(points, " ")
+}
+
+// RelativeTime describes how long ago t was, e.g. "just now", "5s ago" or "3h ago".
+// The zero time is "—".
+func RelativeTime(t, now time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Second:
+		return "just now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
 }
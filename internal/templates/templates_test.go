@@ -0,0 +1,76 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderDashboard(t *testing.T) {
+	var html strings.Builder
+	err := RenderDashboard(&html, &Dashboard{
+		Rows: []DashboardRow{
+			{ID: "<script>alert(1)</script>", Type: "gauge", Value: "1", Updated: "just now", UpdatedAt: "2024-01-01T00:00:00Z", Sparkline: "0.0,12.0 120.0,12.0"},
+			{ID: "PollCount", Type: "counter", Value: "5", Updated: "—"},
+		},
+		Total:   3,
+		Name:    `"><b>`,
+		Sort:    "-value",
+		Refresh: 5,
+	})
+	require.NoError(t, err)
+	page := html.String()
+
+	assert.NotContains(t, page, "<script>alert(1)</script>")
+	assert.Contains(t, page, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	assert.NotContains(t, page, `"><b>`)
+	assert.Contains(t, page, `<meta http-equiv="refresh" content="5">`)
+	assert.Contains(t, page, `points="0.0,12.0 120.0,12.0"`)
+	assert.Contains(t, page, "2 of 3 metrics")
+	assert.Contains(t, page, "Value ▼")
+	assert.NotContains(t, page, "<script")
+	assert.NotContains(t, page, "http://")
+	assert.NotContains(t, page, "https://")
+}
+
+func TestDashboard_SortURL(t *testing.T) {
+	d := &Dashboard{Type: "gauge", Refresh: 10}
+	assert.Equal(t, "?refresh=10&sort=-name&type=gauge", d.SortURL("name"))
+	assert.Equal(t, "?refresh=10&sort=value&type=gauge", d.SortURL("value"))
+	assert.Equal(t, "▲", d.SortMark("name"))
+
+	d.Sort = "value"
+	assert.Equal(t, "?refresh=10&sort=-value&type=gauge", d.SortURL("value"))
+	assert.Equal(t, "?refresh=10&sort=name&type=gauge", d.SortURL("name"))
+	assert.Equal(t, "▲", d.SortMark("value"))
+	assert.Empty(t, d.SortMark("name"))
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Empty(t, Sparkline(nil))
+	assert.Equal(t, "0.0,12.0 120.0,12.0", Sparkline([]float64{5}))
+	assert.Equal(t, "0.0,24.0 60.0,0.0 120.0,12.0", Sparkline([]float64{0, 10, 5}))
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Time{}, "—"},
+		{now, "just now"},
+		{now.Add(-5 * time.Second), "5s ago"},
+		{now.Add(-3 * time.Minute), "3m ago"},
+		{now.Add(-2 * time.Hour), "2h ago"},
+		{now.Add(-50 * time.Hour), "2d ago"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, RelativeTime(tt.t, now))
+		})
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS labels JSONB;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS silences (
+    id VARCHAR(255) PRIMARY KEY,
+    matchers JSONB NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    created_by VARCHAR(255) NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
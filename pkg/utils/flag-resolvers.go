@@ -31,6 +31,19 @@ func ResolveInt(envValue int, flag FlagValue[int], fileValue int) int {
 	return flag.Value
 }
 
+func ResolveFloat(envValue float64, flag FlagValue[float64], fileValue float64) float64 {
+	if envValue != 0 {
+		return envValue
+	}
+	if flag.Passed {
+		return flag.Value
+	}
+	if fileValue != 0 {
+		return fileValue
+	}
+	return flag.Value
+}
+
 func ResolveBool(isEnvSet bool, envValue bool, flag FlagValue[bool], fileValue bool) bool {
 	if isEnvSet {
 		return envValue
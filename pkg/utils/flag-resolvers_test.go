@@ -100,6 +100,54 @@ func TestResolveInt(t *testing.T) {
 	}
 }
 
+func TestResolveFloat(t *testing.T) {
+	tests := []struct {
+		name      string
+		envValue  float64
+		flag      FlagValue[float64]
+		fileValue float64
+		expected  float64
+	}{
+		{
+			name:      "env takes precedence",
+			envValue:  2.5,
+			flag:      FlagValue[float64]{Passed: true, Value: 10},
+			fileValue: 20,
+			expected:  2.5,
+		},
+		{
+			name:      "flag takes precedence when env is zero",
+			envValue:  0,
+			flag:      FlagValue[float64]{Passed: true, Value: 10},
+			fileValue: 20,
+			expected:  10,
+		},
+		{
+			name:      "file value used when env zero and flag not passed",
+			envValue:  0,
+			flag:      FlagValue[float64]{Passed: false, Value: 1},
+			fileValue: 20,
+			expected:  20,
+		},
+		{
+			name:      "default flag value used when nothing else set",
+			envValue:  0,
+			flag:      FlagValue[float64]{Passed: false, Value: 1},
+			fileValue: 0,
+			expected:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ResolveFloat(tt.envValue, tt.flag, tt.fileValue)
+			if result != tt.expected {
+				t.Errorf("ResolveFloat() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestResolveBool(t *testing.T) {
 	tests := []struct {
 		name      string
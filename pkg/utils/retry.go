@@ -9,13 +9,26 @@ import (
 
 var retries = 3
 
+// maxRetryAfter caps the wait requested by a RetryAfterError,
+// so a broken or hostile Retry-After header can not stall the caller.
+var maxRetryAfter = 30 * time.Second
+
+// RetryAfterError is implemented by errors that know how long
+// to wait before the next attempt, e.g. from a Retry-After header.
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
 // RetryWrapper вызывает функцию, повторяя попытку в случае ошибки из переданного списка.
+// Если ошибка реализует RetryAfterError, ждём указанное ею время вместо стандартной задержки,
+// но не дольше maxRetryAfter.
 func RetryWrapper(action func() error, errorList []error) error {
 	for i := 0; i < retries; i++ {
 		err := action()
 		if err != nil && contains(errorList, err) {
 			logger.LogError("retry %d/%d: %v\n", i+1, retries, err)
-			time.Sleep(time.Duration(2*i+1) * time.Second) // ждем, чтобы дать время для подготовки
+			time.Sleep(retryDelay(i, err)) // ждем, чтобы дать время для подготовки
 			continue
 		}
 		return err
@@ -23,10 +36,18 @@ func RetryWrapper(action func() error, errorList []error) error {
 	return errors.New("over max retry")
 }
 
+func retryDelay(attempt int, err error) time.Duration {
+	var retryAfter RetryAfterError
+	if errors.As(err, &retryAfter) && retryAfter.RetryAfter() > 0 {
+		return min(retryAfter.RetryAfter(), maxRetryAfter)
+	}
+	return time.Duration(2*attempt+1) * time.Second
+}
+
 // contains проверяет наличие ошибки в списке
 func contains(errorList []error, err error) bool {
 	for _, e := range errorList {
-		if errors.Is(e, err) {
+		if errors.Is(err, e) {
 			return true
 		}
 	}
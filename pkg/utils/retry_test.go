@@ -129,3 +129,32 @@ func TestRetryWrapperMaxRetries(t *testing.T) {
 		t.Errorf("Expected 3 attempts, got %d", counter)
 	}
 }
+
+type retryAfterError struct {
+	delay time.Duration
+}
+
+func (e retryAfterError) Error() string             { return "retry after" }
+func (e retryAfterError) RetryAfter() time.Duration { return e.delay }
+
+func TestRetryDelay(t *testing.T) {
+	testCases := []struct {
+		name     string
+		attempt  int
+		err      error
+		expected time.Duration
+	}{
+		{name: "Default first attempt", attempt: 0, err: errors.New("err"), expected: time.Second},
+		{name: "Default third attempt", attempt: 2, err: errors.New("err"), expected: 5 * time.Second},
+		{name: "Server delay", attempt: 0, err: retryAfterError{delay: 7 * time.Second}, expected: 7 * time.Second},
+		{name: "Server delay capped", attempt: 0, err: retryAfterError{delay: time.Hour}, expected: maxRetryAfter},
+		{name: "Zero server delay", attempt: 1, err: retryAfterError{}, expected: 3 * time.Second},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := retryDelay(tc.attempt, tc.err); got != tc.expected {
+				t.Errorf("retryDelay() = %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}